@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"prothomuse-server/internal/handler"
+	"prothomuse-server/internal/model"
 	"prothomuse-server/internal/repository"
 	"prothomuse-server/internal/services"
+	"prothomuse-server/internal/utils"
 
 	"github.com/gorilla/websocket"
 	_ "github.com/lib/pq"
@@ -58,6 +64,12 @@ var upgrader = websocket.Upgrader{
 // Store metrics in memory
 var metrics []Metric
 
+// heartbeatService records when each project last sent a metric (dead-man's switch)
+var heartbeatService *services.HeartbeatService
+
+// orgService checks the project API keys middleware presents on /stream
+var orgService *services.OrganizationService
+
 func main() {
 	// Initialize repository, service, and handler
 	userRepo := repository.NewUserRepository(db)
@@ -72,16 +84,429 @@ func main() {
 	// Debug: print users table columns to help diagnose schema issues
 	printUsersTableColumns(db)
 
-	authService := services.NewAuthService(userRepo)
-	authHandler := handler.NewAuthHandler(authService)
+	tokenRepo := repository.NewTokenRepository(db)
+	if err := tokenRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create token tables: %v", err)
+	} else {
+		log.Println("✅ Token tables ready")
+	}
+
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	if err := userTokenRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create user token table: %v", err)
+	} else {
+		log.Println("✅ User token table ready")
+	}
+
+	emailRepo := repository.NewEmailRepository(db)
+	if err := emailRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create email outbox table: %v", err)
+	} else {
+		log.Println("✅ Email outbox table ready")
+	}
+
+	mfaRepo := repository.NewMFARepository(db)
+	if err := mfaRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create MFA tables: %v", err)
+	} else {
+		log.Println("✅ MFA tables ready")
+	}
+
+	orgRepo := repository.NewOrganizationRepository(db)
+	if err := orgRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create organization tables: %v", err)
+	} else {
+		log.Println("✅ Organization tables ready")
+	}
+
+	projectKeyRepo := repository.NewProjectKeyRepository(db)
+	if err := projectKeyRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create project key table: %v", err)
+	} else {
+		log.Println("✅ Project key table ready")
+	}
+
+	inviteRepo := repository.NewInvitationRepository(db)
+	if err := inviteRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create invitation table: %v", err)
+	} else {
+		log.Println("✅ Invitation table ready")
+	}
+
+	oidcRepo := repository.NewOIDCRepository(db)
+	if err := oidcRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create SSO tables: %v", err)
+	} else {
+		log.Println("✅ SSO tables ready")
+	}
+
+	auditRepo := repository.NewAuditRepository(db)
+	if err := auditRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create audit log table: %v", err)
+	} else {
+		log.Println("✅ Audit log table ready")
+	}
+
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	if err := loginAttemptRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create login failure table: %v", err)
+	} else {
+		log.Println("✅ Login failure table ready")
+	}
+
+	sessionRepo := repository.NewSessionRepository(db)
+	if err := sessionRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create sessions table: %v", err)
+	} else {
+		log.Println("✅ Sessions table ready")
+	}
+
+	alertRepo := repository.NewAlertRepository(db)
+	if err := alertRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create alerts table: %v", err)
+	} else {
+		log.Println("✅ Alerts table ready")
+	}
+
+	silenceRepo := repository.NewSilenceRepository(db)
+	if err := silenceRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create silence tables: %v", err)
+	} else {
+		log.Println("✅ Silence tables ready")
+	}
+
+	anomalyRepo := repository.NewAnomalyRepository(db)
+	if err := anomalyRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create anomaly tables: %v", err)
+	} else {
+		log.Println("✅ Anomaly tables ready")
+	}
+	metricRepo := repository.NewMetricRepository(db)
+
+	sloRepo := repository.NewSLORepository(db)
+	if err := sloRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create SLO table: %v", err)
+	} else {
+		log.Println("✅ SLO table ready")
+	}
+
+	uptimeRepo := repository.NewUptimeRepository(db)
+	if err := uptimeRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create uptime check tables: %v", err)
+	} else {
+		log.Println("✅ Uptime check tables ready")
+	}
+
+	transactionRepo := repository.NewTransactionRepository(db)
+	if err := transactionRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create transaction check tables: %v", err)
+	} else {
+		log.Println("✅ Transaction check tables ready")
+	}
+
+	cronRepo := repository.NewCronMonitorRepository(db)
+	if err := cronRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create cron monitor tables: %v", err)
+	} else {
+		log.Println("✅ Cron monitor tables ready")
+	}
+
+	statusPageRepo := repository.NewStatusPageRepository(db)
+	if err := statusPageRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create status page tables: %v", err)
+	} else {
+		log.Println("✅ Status page tables ready")
+	}
+
+	heartbeatRepo := repository.NewHeartbeatRepository(db)
+	if err := heartbeatRepo.CreateTable(); err != nil {
+		log.Printf("⚠️  Warning: Could not create heartbeat tables: %v", err)
+	} else {
+		log.Println("✅ Heartbeat tables ready")
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		utils.SetJWTSecret(secret)
+	}
+	if keyFile := os.Getenv("JWT_SIGNING_KEY_FILE"); keyFile != "" {
+		var verificationKeyFiles []string
+		for _, file := range strings.Split(os.Getenv("JWT_VERIFICATION_KEY_FILES"), ",") {
+			if file = strings.TrimSpace(file); file != "" {
+				verificationKeyFiles = append(verificationKeyFiles, file)
+			}
+		}
+		if err := utils.LoadSigningKeys(keyFile, verificationKeyFiles); err != nil {
+			log.Fatalf("failed to load JWT signing keys: %v", err)
+		}
+		log.Println("✅ JWT signing keys loaded")
+	} else if os.Getenv("JWT_SECRET") != "" {
+		log.Println("⚠️  Warning: JWT_SIGNING_KEY_FILE not set, signing tokens with HS256 and JWT_SECRET")
+	} else {
+		log.Println("⚠️  Warning: neither JWT_SIGNING_KEY_FILE nor JWT_SECRET set, signing tokens with a random secret; every token stops working at restart")
+	}
+	// unset or zero argon2id parameters keep the defaults (64 MiB, 3 iterations, 2 lanes)
+	hashMemory, _ := strconv.ParseUint(os.Getenv("PASSWORD_HASH_MEMORY_KIB"), 10, 32)
+	hashIterations, _ := strconv.ParseUint(os.Getenv("PASSWORD_HASH_ITERATIONS"), 10, 32)
+	hashParallelism, _ := strconv.ParseUint(os.Getenv("PASSWORD_HASH_PARALLELISM"), 10, 8)
+	utils.SetArgon2Params(utils.Argon2Params{
+		Memory:      uint32(hashMemory),
+		Iterations:  uint32(hashIterations),
+		Parallelism: uint8(hashParallelism),
+	})
+	// at most this many hashes at once, each holding PASSWORD_HASH_MEMORY_KIB; unset keeps one per CPU
+	hashConcurrency, _ := strconv.Atoi(os.Getenv("PASSWORD_HASH_CONCURRENCY"))
+	utils.SetArgon2Concurrency(hashConcurrency)
+	var mailSender services.MailSender
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		mailSender = services.NewSMTPSender(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_FROM"))
+		log.Printf("✅ Sending email through %s:%s", host, port)
+	} else {
+		log.Println("⚠️  Warning: SMTP_HOST not set, emails stay in the email_outbox table")
+	}
+	mailService := services.NewMailService(emailRepo, mailSender)
+	go mailService.Start(context.Background(), 10*time.Second)
+
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:8080"
+	}
+	auditService := services.NewAuditService(auditRepo, orgRepo)
+	orgService = services.NewOrganizationService(orgRepo, projectKeyRepo, userRepo, auditService)
+	inviteService := services.NewInvitationService(inviteRepo, orgRepo, userRepo, mailService, auditService, appURL)
+	// zero values fall back to 5 account failures, 20 IP failures and 15 minutes
+	maxAccountFailures, _ := strconv.Atoi(os.Getenv("LOGIN_MAX_ACCOUNT_FAILURES"))
+	maxIPFailures, _ := strconv.Atoi(os.Getenv("LOGIN_MAX_IP_FAILURES"))
+	lockoutMinutes, _ := strconv.Atoi(os.Getenv("LOGIN_LOCKOUT_MINUTES"))
+	loginGuard := services.NewLoginGuard(loginAttemptRepo, services.LoginGuardConfig{
+		MaxAccountFailures: maxAccountFailures,
+		MaxIPFailures:      maxIPFailures,
+		LockoutDuration:    time.Duration(lockoutMinutes) * time.Minute,
+	})
+	mfaService := services.NewMFAService(userRepo, mfaRepo, loginGuard)
+	handler.SetTrustProxyHeaders(os.Getenv("TRUST_PROXY_HEADERS") == "true")
+	// zero keeps one proxy hop
+	proxyHops, _ := strconv.Atoi(os.Getenv("TRUSTED_PROXY_HOPS"))
+	handler.SetTrustedProxyHops(proxyHops)
+	sessionService := services.NewSessionService(sessionRepo, tokenRepo, auditService)
+	// zero lengths fall back to 8 and 128 characters
+	minPasswordLength, _ := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+	maxPasswordLength, _ := strconv.Atoi(os.Getenv("PASSWORD_MAX_LENGTH"))
+	passwordPolicy, err := services.NewPasswordPolicy(services.PasswordPolicyConfig{
+		MinLength:         minPasswordLength,
+		MaxLength:         maxPasswordLength,
+		RequireUppercase:  os.Getenv("PASSWORD_REQUIRE_UPPERCASE") == "true",
+		RequireLowercase:  os.Getenv("PASSWORD_REQUIRE_LOWERCASE") == "true",
+		RequireDigit:      os.Getenv("PASSWORD_REQUIRE_DIGIT") == "true",
+		RequireSymbol:     os.Getenv("PASSWORD_REQUIRE_SYMBOL") == "true",
+		BreachedPasswords: os.Getenv("BREACHED_PASSWORDS_FILE"),
+	})
+	if err != nil {
+		log.Fatalf("failed to load password policy: %v", err)
+	}
+	if count := passwordPolicy.BreachedCount(); count > 0 {
+		log.Printf("✅ Breached password list loaded (%d passwords)", count)
+	} else if dir := os.Getenv("BREACHED_PASSWORDS_FILE"); dir != "" {
+		log.Printf("✅ Breached password ranges read from %s", dir)
+	}
+	authService := services.NewAuthService(userRepo, tokenRepo, userTokenRepo, mailService, mfaService, inviteService, loginGuard, passwordPolicy, sessionService, auditService, services.AuthConfig{
+		AppURL:                appURL,
+		RequireVerifiedEmail:  os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		PasswordLoginDisabled: os.Getenv("PASSWORD_LOGIN_DISABLED") == "true",
+	})
+	utils.SetRevocationCheck(authService.CheckRevoked)
+	authHandler := handler.NewAuthHandler(authService, orgService)
+	mfaHandler := handler.NewMFAHandler(mfaService, authService)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+
+	oidcRedirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if oidcRedirectURL == "" {
+		oidcRedirectURL = strings.TrimRight(appURL, "/") + "/api/auth/oidc/callback"
+	}
+	oidcService := services.NewOIDCService(services.OIDCConfig{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  oidcRedirectURL,
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+	}, oidcRepo, userRepo, authService)
+	if oidcService.Enabled() {
+		log.Printf("✅ SSO enabled with issuer %s", os.Getenv("OIDC_ISSUER"))
+	}
+	oidcHandler := handler.NewOIDCHandler(oidcService)
+
+	orgHandler := handler.NewOrganizationHandler(orgService)
+	inviteHandler := handler.NewInvitationHandler(inviteService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	adminService := services.NewAdminService(userRepo, projectKeyRepo, authService, auditService)
+	adminHandler := handler.NewAdminHandler(adminService)
+	// ADMIN_EMAILS is a comma-separated list of existing users to make system
+	// administrators; only verified emails qualify, so nobody can register one
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if strings.TrimSpace(email) == "" {
+			continue
+		}
+		if granted, err := adminService.GrantAdmin(email); err != nil {
+			log.Printf("⚠️  Warning: Could not make %s an administrator: %v", email, err)
+		} else if !granted {
+			log.Printf("⚠️  Warning: No user with verified email %s to make an administrator", email)
+		}
+	}
+	authz := handler.NewAuthorizer(orgService, adminService)
+
+	// accounts are deleted ACCOUNT_DELETION_GRACE_DAYS days after the user confirms
+	graceDays, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"))
+	if err != nil || graceDays < 0 {
+		graceDays = 14
+	}
+	accountRepo := repository.NewAccountRepository(db)
+	accountService := services.NewAccountService(userRepo, orgRepo, accountRepo, sessionRepo, oidcRepo, mfaService, authService, auditService, time.Duration(graceDays)*24*time.Hour)
+	accountHandler := handler.NewAccountHandler(accountService)
+	go accountService.Start(context.Background(), time.Hour)
+
+	silenceService := services.NewSilenceService(silenceRepo)
+	silenceHandler := handler.NewSilenceHandler(silenceService)
+
+	alertService := services.NewAlertService(alertRepo, silenceService, auditService)
+	alertHandler := handler.NewAlertHandler(alertService)
+
+	anomalyService := services.NewAnomalyService(anomalyRepo, metricRepo, alertService)
+	anomalyHandler := handler.NewAnomalyHandler(anomalyService)
+	go anomalyService.Start(context.Background(), 5*time.Minute)
+
+	heartbeatService = services.NewHeartbeatService(heartbeatRepo, alertService)
+	heartbeatHandler := handler.NewHeartbeatHandler(heartbeatService)
+	go heartbeatService.Start(context.Background(), 15*time.Second)
+
+	sloService := services.NewSLOService(sloRepo, metricRepo, alertService)
+	sloHandler := handler.NewSLOHandler(sloService)
+	go sloService.Start(context.Background(), time.Minute)
+
+	uptimeService := services.NewUptimeService(uptimeRepo, alertService, nil)
+	uptimeHandler := handler.NewUptimeHandler(uptimeService)
+	transactionService := services.NewTransactionService(transactionRepo, metricRepo, alertService, nil)
+	transactionHandler := handler.NewTransactionHandler(transactionService)
+	checkScheduler := services.NewCheckScheduler(8, uptimeService, transactionService)
+	go checkScheduler.Start(context.Background())
+	cronService := services.NewCronMonitorService(cronRepo, alertService)
+	cronHandler := handler.NewCronMonitorHandler(cronService)
+	go cronService.Start(context.Background(), 30*time.Second)
+	statusPageService := services.NewStatusPageService(statusPageRepo, metricRepo, uptimeRepo, alertService)
+	statusPageHandler := handler.NewStatusPageHandler(statusPageService)
 
 	// Authentication endpoints
 	http.HandleFunc("/api/auth/register", authHandler.RegisterUser)
 	http.HandleFunc("/api/auth/login", authHandler.Login)
 	http.HandleFunc("/api/auth/update", authHandler.UpdateUser)
+	http.HandleFunc("/api/auth/verify-email", authHandler.VerifyEmail)
+	http.HandleFunc("/api/auth/resend-verification", authHandler.ResendVerification)
+	http.HandleFunc("/api/auth/unlock", authHandler.UnlockAccount)
+	http.HandleFunc("/api/auth/forgot-password", authHandler.ForgotPassword)
+	http.HandleFunc("/api/auth/reset-password", authHandler.ResetPassword)
+	http.HandleFunc("/api/auth/refresh", authHandler.Refresh)
+	http.HandleFunc("/api/auth/logout", authHandler.Logout)
+	http.HandleFunc("/api/auth/sessions", sessionHandler.Sessions)
+	http.HandleFunc("/api/auth/sessions/{id}", sessionHandler.Session)
+	http.HandleFunc("/api/auth/account/export", accountHandler.Export)
+	http.HandleFunc("/api/auth/account/deletion", accountHandler.Deletion)
+	http.HandleFunc("/api/auth/account/deletion/confirm", accountHandler.ConfirmDeletion)
+	http.HandleFunc("/.well-known/jwks.json", authHandler.JWKS)
+	http.HandleFunc("/api/auth/mfa/enroll", mfaHandler.Enroll)
+	http.HandleFunc("/api/auth/mfa/confirm", mfaHandler.Confirm)
+	http.HandleFunc("/api/auth/mfa/disable", mfaHandler.Disable)
+	http.HandleFunc("/api/auth/mfa/recovery-codes", mfaHandler.RecoveryCodes)
+	http.HandleFunc("/api/auth/mfa/verify", mfaHandler.Verify)
+	http.HandleFunc("/api/auth/oidc/login", oidcHandler.Login)
+	http.HandleFunc("/api/auth/oidc/callback", oidcHandler.Callback)
+	http.HandleFunc("/api/auth/oidc/link", oidcHandler.Link)
+	http.HandleFunc("/api/auth/oidc/identities", oidcHandler.Identities)
+	http.HandleFunc("/api/auth/oidc/identities/{id}", oidcHandler.Unlink)
 	http.HandleFunc("/api/auth/validate-apikey", authHandler.ValidateAPIKey)
 	http.HandleFunc("/api/auth/validate-jwt", authHandler.ValidateJWT)
 
+	// Organization endpoints - roles are checked by the authorizer
+	http.HandleFunc("/api/admin/users", authz.SystemAdmin(adminHandler.Users))
+	http.HandleFunc("/api/admin/users/{id}", authz.SystemAdmin(adminHandler.User))
+	http.HandleFunc("/api/admin/users/{id}/deactivate", authz.SystemAdmin(adminHandler.Deactivate))
+	http.HandleFunc("/api/admin/users/{id}/reactivate", authz.SystemAdmin(adminHandler.Reactivate))
+	http.HandleFunc("/api/admin/users/{id}/reset-password", authz.SystemAdmin(adminHandler.ResetPassword))
+	http.HandleFunc("/api/admin/users/{id}/revoke-keys", authz.SystemAdmin(adminHandler.RevokeKeys))
+	http.HandleFunc("/api/admin/users/{id}/unlock", authz.SystemAdmin(adminHandler.Unlock))
+	http.HandleFunc("/api/admin/audit", authz.SystemAdmin(adminHandler.Audit))
+
+	http.HandleFunc("/api/organizations", authz.Authenticated(orgHandler.Organizations))
+	http.HandleFunc("/api/organizations/{orgId}", authz.Organization(model.RoleViewer, model.RoleOwner, orgHandler.Organization))
+	http.HandleFunc("/api/organizations/{orgId}/members", authz.Organization(model.RoleViewer, model.RoleAdmin, orgHandler.Members))
+	// Any member may leave; the service checks the rest
+	http.HandleFunc("/api/organizations/{orgId}/members/{userId}", authz.Organization(model.RoleViewer, model.RoleViewer, orgHandler.Member))
+	http.HandleFunc("/api/organizations/{orgId}/invitations", authz.Organization(model.RoleAdmin, model.RoleAdmin, inviteHandler.Invitations))
+	http.HandleFunc("/api/organizations/{orgId}/invitations/{id}", authz.Organization(model.RoleAdmin, model.RoleAdmin, inviteHandler.Invitation))
+	http.HandleFunc("/api/organizations/{orgId}/invitations/{id}/resend", authz.Organization(model.RoleAdmin, model.RoleAdmin, inviteHandler.Resend))
+	http.HandleFunc("/api/invitations/accept", inviteHandler.Accept)
+	http.HandleFunc("/api/organizations/{orgId}/audit", authz.Organization(model.RoleAdmin, model.RoleAdmin, auditHandler.Events))
+	http.HandleFunc("/api/organizations/{orgId}/projects", authz.Organization(model.RoleViewer, model.RoleAdmin, orgHandler.Projects))
+	http.HandleFunc("/api/projects/{projectId}", authz.Project(handler.FromPath("projectId"), model.RoleViewer, model.RoleAdmin, orgHandler.Project))
+	http.HandleFunc("/api/projects/{projectId}/keys", authz.Project(handler.FromPath("projectId"), model.RoleAdmin, model.RoleAdmin, orgHandler.Keys))
+	http.HandleFunc("/api/projects/{projectId}/keys/{keyId}", authz.Project(handler.FromPath("projectId"), model.RoleAdmin, model.RoleAdmin, orgHandler.RevokeKey))
+	http.HandleFunc("/api/projects/{projectId}/keys/{keyId}/rotate", authz.Project(handler.FromPath("projectId"), model.RoleAdmin, model.RoleAdmin, orgHandler.RotateKey))
+
+	// Project endpoints - viewers read, members write
+	byProject := handler.FromPath("projectId")
+	byAlert := handler.ByID(alertService.GetAlert, func(a *model.Alert) string { return a.ProjectID })
+	byDetector := handler.ByID(anomalyService.GetDetector, func(d *model.AnomalyDetector) string { return d.ProjectID })
+	bySilence := handler.ByID(silenceService.GetSilence, func(s *model.Silence) string { return s.ProjectID })
+	byWindow := handler.ByID(silenceService.GetMaintenanceWindow, func(m *model.MaintenanceWindow) string { return m.ProjectID })
+	bySLO := handler.ByID(sloService.GetSLO, func(s *model.SLO) string { return s.ProjectID })
+	byUptimeCheck := handler.ByID(uptimeService.GetCheck, func(c *model.UptimeCheck) string { return c.ProjectID })
+	byTransactionCheck := handler.ByID(transactionService.GetCheck, func(c *model.TransactionCheck) string { return c.ProjectID })
+	byCronMonitor := handler.ByID(cronService.GetMonitor, func(m *model.CronMonitor) string { return m.ProjectID })
+	byStatusPage := handler.ByID(statusPageService.GetPage, func(p *model.StatusPage) string { return p.ProjectID })
+	project := func(resolve handler.ProjectResolver, next http.HandlerFunc) http.HandlerFunc {
+		return authz.Project(resolve, model.RoleViewer, model.RoleMember, next)
+	}
+
+	http.HandleFunc("/api/alerts", project(handler.FromRequest, alertHandler.ListAlerts))
+	http.HandleFunc("/api/alerts/{id}", project(byAlert, alertHandler.GetAlert))
+	http.HandleFunc("/api/alerts/{id}/ack", project(byAlert, alertHandler.Acknowledge))
+	http.HandleFunc("/api/alerts/{id}/unack", project(byAlert, alertHandler.Unacknowledge))
+	http.HandleFunc("/api/projects/{projectId}/timeline", project(byProject, alertHandler.Timeline))
+	http.HandleFunc("/api/projects/{projectId}/notes", project(byProject, alertHandler.AddNote))
+	http.HandleFunc("/api/anomaly/detectors", project(handler.FromRequest, anomalyHandler.Detectors))
+	http.HandleFunc("/api/anomaly/detectors/{id}", project(byDetector, anomalyHandler.DeleteDetector))
+	http.HandleFunc("/api/anomaly/events", project(handler.FromRequest, anomalyHandler.ListEvents))
+	http.HandleFunc("/api/silences", project(handler.FromRequest, silenceHandler.Silences))
+	http.HandleFunc("/api/silences/{id}", project(bySilence, silenceHandler.ExpireSilence))
+	http.HandleFunc("/api/maintenance-windows", project(handler.FromRequest, silenceHandler.MaintenanceWindows))
+	http.HandleFunc("/api/maintenance-windows/{id}", project(byWindow, silenceHandler.DeleteMaintenanceWindow))
+	http.HandleFunc("/api/heartbeats/{projectId}", project(byProject, heartbeatHandler.Heartbeat))
+	http.HandleFunc("/api/slos", project(handler.FromRequest, sloHandler.SLOs))
+	http.HandleFunc("/api/slos/{id}", project(bySLO, sloHandler.SLO))
+	http.HandleFunc("/api/uptime-checks", project(handler.FromRequest, uptimeHandler.Checks))
+	http.HandleFunc("/api/uptime-checks/{id}", project(byUptimeCheck, uptimeHandler.Check))
+	http.HandleFunc("/api/uptime-checks/{id}/results", project(byUptimeCheck, uptimeHandler.Results))
+	http.HandleFunc("/api/certificates", project(handler.FromRequest, uptimeHandler.Certificates))
+	http.HandleFunc("/api/transaction-checks", project(handler.FromRequest, transactionHandler.Checks))
+	http.HandleFunc("/api/transaction-checks/{id}", project(byTransactionCheck, transactionHandler.Check))
+	http.HandleFunc("/api/transaction-checks/{id}/runs", project(byTransactionCheck, transactionHandler.Runs))
+	http.HandleFunc("/api/cron-monitors", project(handler.FromRequest, cronHandler.Monitors))
+	http.HandleFunc("/api/cron-monitors/{id}", project(byCronMonitor, cronHandler.Monitor))
+	http.HandleFunc("/api/cron-monitors/{id}/runs", project(byCronMonitor, cronHandler.Runs))
+	http.HandleFunc("/api/checkin/{token}", cronHandler.CheckIn)
+	http.HandleFunc("/api/checkin/{token}/{kind}", cronHandler.CheckIn)
+	http.HandleFunc("/api/status-pages", project(handler.FromRequest, statusPageHandler.Pages))
+	http.HandleFunc("/api/status-pages/{id}", project(byStatusPage, statusPageHandler.Page))
+	http.HandleFunc("/api/status-pages/{id}/components", project(byStatusPage, statusPageHandler.Components))
+	http.HandleFunc("/api/status-pages/{id}/components/{componentId}", project(byStatusPage, statusPageHandler.Component))
+	http.HandleFunc("/api/status-pages/{id}/messages", project(byStatusPage, statusPageHandler.Messages))
+	http.HandleFunc("/api/status-pages/{id}/messages/{messageId}/resolve", project(byStatusPage, statusPageHandler.ResolveMessage))
+
+	// Public status pages - no auth
+	http.HandleFunc("/status/{slug}", statusPageHandler.Public)
+	http.HandleFunc("/api/status/{slug}", statusPageHandler.PublicJSON)
+
 	// Health check endpoint
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -91,18 +516,35 @@ func main() {
 	// WebSocket endpoint - middleware connects here
 	http.HandleFunc("/stream", handleWebSocket)
 
-	// API to view metrics (for testing/dashboard)
-	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+	// API to view metrics (for testing/dashboard) of the projects the caller can see
+	http.HandleFunc("/metrics", authz.Authenticated(func(w http.ResponseWriter, r *http.Request) {
+		projectIDs, err := orgService.ProjectIDs(handler.RequestClaims(r).UserID)
+		if err != nil {
+			http.Error(w, "failed to list projects", http.StatusInternalServerError)
+			return
+		}
+		visible := make(map[string]bool, len(projectIDs))
+		for _, id := range projectIDs {
+			visible[id] = true
+		}
+
+		visibleMetrics := []Metric{}
+		for _, m := range metrics {
+			if visible[m.ProjectID] {
+				visibleMetrics = append(visibleMetrics, m)
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"total":   len(metrics),
-			"metrics": metrics,
+			"total":   len(visibleMetrics),
+			"metrics": visibleMetrics,
 		})
-	})
+	}))
 
 	// Get metrics by project ID
-	http.HandleFunc("/metrics/", func(w http.ResponseWriter, r *http.Request) {
-		projectID := r.URL.Path[len("/metrics/"):]
+	http.HandleFunc("/metrics/{projectId}", project(byProject, func(w http.ResponseWriter, r *http.Request) {
+		projectID := r.PathValue("projectId")
 
 		var projectMetrics []Metric
 		for _, m := range metrics {
@@ -117,7 +559,7 @@ func main() {
 			"total":     len(projectMetrics),
 			"metrics":   projectMetrics,
 		})
-	})
+	}))
 
 	log.Println("╔══════════════════════════════════════════════════╗")
 	log.Println("║   Prothomuse Health Monitoring Server           ║")
@@ -126,17 +568,134 @@ func main() {
 	log.Println("🚀 Server running on http://localhost:8080")
 	log.Println("")
 	log.Println("� Authentication API Endpoints:")
-	log.Println("   POST   /api/auth/register           - Register a new user")
-	log.Println("   POST   /api/auth/login              - Login and get JWT token")
+	log.Println("   POST   /api/auth/register           - Register a new user (optional inviteToken)")
+	log.Println("   POST   /api/auth/login              - Login and get access + refresh token")
+	log.Println("   GET    /api/auth/verify-email?token= - Confirm an email address")
+	log.Println("   POST   /api/auth/resend-verification - Send a new verification email")
+	log.Println("   GET    /api/auth/unlock?token=      - Unlock an account locked by failed logins")
+	log.Println("   POST   /api/auth/forgot-password    - Email a password reset link")
+	log.Println("   POST   /api/auth/reset-password     - Set a new password with a reset token")
+	log.Println("   POST   /api/auth/refresh            - Rotate refresh token, get new access token")
+	log.Println("   POST   /api/auth/logout             - Revoke tokens (requires Bearer token)")
+	log.Println("   GET    /api/auth/sessions           - List your signed-in devices (requires Bearer token)")
+	log.Println("   DELETE /api/auth/sessions           - Sign out every other device (requires Bearer token)")
+	log.Println("   DELETE /api/auth/sessions/{id}      - Sign out one device (requires Bearer token)")
+	log.Println("   GET    /api/auth/account/export     - Download your data as a zip archive (requires Bearer token)")
+	log.Println("   GET    /api/auth/account/deletion   - What deleting your account would do (requires Bearer token)")
+	log.Println("   POST   /api/auth/account/deletion   - Email a link confirming the deletion (requires Bearer token)")
+	log.Println("   DELETE /api/auth/account/deletion   - Cancel a scheduled deletion (requires Bearer token)")
+	log.Println("   POST   /api/auth/account/deletion/confirm - Schedule the deletion with the emailed token")
+	log.Println("   GET    /.well-known/jwks.json       - Public keys verifying access tokens")
+	log.Println("   POST   /api/auth/mfa/enroll         - Start TOTP enrolment (secret + otpauth URI)")
+	log.Println("   POST   /api/auth/mfa/confirm        - Enable TOTP with a code, get recovery codes")
+	log.Println("   POST   /api/auth/mfa/disable        - Disable TOTP (password + code)")
+	log.Println("   POST   /api/auth/mfa/recovery-codes - Regenerate recovery codes")
+	log.Println("   POST   /api/auth/mfa/verify         - Complete an mfa_required login")
+	log.Println("   GET    /api/auth/oidc/login         - Start SSO login (redirects to the identity provider)")
+	log.Println("   GET    /api/auth/oidc/callback      - SSO redirect target, returns tokens")
+	log.Println("   POST   /api/auth/oidc/link          - Link your account to SSO (returns authorizationUrl)")
+	log.Println("   GET    /api/auth/oidc/identities    - List linked SSO accounts")
+	log.Println("   DELETE /api/auth/oidc/identities/{id} - Unlink an SSO account")
 	log.Println("   PUT    /api/auth/update             - Update user profile (requires Bearer token)")
 	log.Println("   GET    /api/auth/validate-apikey    - Validate API key (Authorization: ApiKey <key>)")
 	log.Println("   GET    /api/auth/validate-jwt       - Validate JWT token (Authorization: Bearer <token>)")
 	log.Println("")
+	log.Println("🛡️  Admin API Endpoints (require a system administrator, see ADMIN_EMAILS):")
+	log.Println("   GET    /api/admin/users?q=&active=&page=&pageSize= - Search users")
+	log.Println("   GET    /api/admin/users/{id}        - Get a user with their usage")
+	log.Println("   POST   /api/admin/users/{id}/deactivate - Deactivate and sign out a user")
+	log.Println("   POST   /api/admin/users/{id}/reactivate - Reactivate a user")
+	log.Println("   POST   /api/admin/users/{id}/reset-password - Invalidate the password and email a reset link")
+	log.Println("   POST   /api/admin/users/{id}/revoke-keys - Replace the API key and revoke project keys")
+	log.Println("   POST   /api/admin/users/{id}/unlock - Clear a login lockout")
+	log.Println("   GET    /api/admin/audit?orgId=&action=&actorId=... - Whole audit log, orgId=none for events outside organizations")
+	log.Println("")
+	log.Println("🏢 Organization API Endpoints (require Bearer token):")
+	log.Println("   GET    /api/organizations           - List your organizations with your role")
+	log.Println("   POST   /api/organizations           - Create an organization (you become owner)")
+	log.Println("   GET    /api/organizations/{orgId}   - Get an organization (viewer)")
+	log.Println("   DELETE /api/organizations/{orgId}   - Delete an organization (owner)")
+	log.Println("   GET    /api/organizations/{orgId}/members - List members (viewer)")
+	log.Println("   POST   /api/organizations/{orgId}/members - Add a user by email with a role (admin)")
+	log.Println("   PUT    /api/organizations/{orgId}/members/{userId} - Change a member's role (admin)")
+	log.Println("   DELETE /api/organizations/{orgId}/members/{userId} - Remove a member (admin, or leave)")
+	log.Println("   GET    /api/organizations/{orgId}/invitations - List pending invitations (admin)")
+	log.Println("   POST   /api/organizations/{orgId}/invitations - Invite an email with a role (admin)")
+	log.Println("   POST   /api/organizations/{orgId}/invitations/{id}/resend - Resend with a new link (admin)")
+	log.Println("   DELETE /api/organizations/{orgId}/invitations/{id} - Revoke an invitation (admin)")
+	log.Println("   POST   /api/invitations/accept      - Accept an invitation sent to your email")
+	log.Println("   GET    /api/organizations/{orgId}/audit - Security audit log, filterable and paginated (admin)")
+	log.Println("   GET    /api/organizations/{orgId}/projects - List projects (viewer)")
+	log.Println("   POST   /api/organizations/{orgId}/projects - Claim a project ID (admin)")
+	log.Println("   GET    /api/projects/{projectId}    - Get a project (viewer)")
+	log.Println("   DELETE /api/projects/{projectId}    - Release a project ID (admin)")
+	log.Println("   GET    /api/projects/{projectId}/keys - List project API keys (admin)")
+	log.Println("   POST   /api/projects/{projectId}/keys - Create a project API key (admin)")
+	log.Println("   POST   /api/projects/{projectId}/keys/{keyId}/rotate - Rotate a key (admin)")
+	log.Println("   DELETE /api/projects/{projectId}/keys/{keyId} - Revoke a key (admin)")
+	log.Println("")
+	log.Println("🚨 Alerting API Endpoints (require Bearer token; viewers read, members write):")
+	log.Println("   GET    /api/alerts?projectId=       - List alerts of a project")
+	log.Println("   GET    /api/alerts/{id}             - Get an alert with its history")
+	log.Println("   POST   /api/alerts/{id}/ack         - Acknowledge an alert")
+	log.Println("   POST   /api/alerts/{id}/unack       - Remove an acknowledgement")
+	log.Println("   GET    /api/projects/{id}/timeline  - Incident timeline of a project")
+	log.Println("   POST   /api/projects/{id}/notes     - Add a note to the incident timeline")
+	log.Println("   GET    /api/anomaly/detectors       - List anomaly detectors of a project")
+	log.Println("   POST   /api/anomaly/detectors       - Create an anomaly detector")
+	log.Println("   DELETE /api/anomaly/detectors/{id}  - Delete an anomaly detector")
+	log.Println("   GET    /api/anomaly/events          - List anomaly events of a project")
+	log.Println("   GET    /api/silences                - List silences of a project")
+	log.Println("   POST   /api/silences                - Create a silence")
+	log.Println("   DELETE /api/silences/{id}           - Expire a silence")
+	log.Println("   GET    /api/maintenance-windows     - List maintenance windows of a project")
+	log.Println("   POST   /api/maintenance-windows     - Create a recurring maintenance window")
+	log.Println("   DELETE /api/maintenance-windows/{id} - Delete a maintenance window")
+	log.Println("   GET    /api/heartbeats/{projectId}  - Dead-man's switch status and connections")
+	log.Println("   PUT    /api/heartbeats/{projectId}  - Configure the expected heartbeat interval")
+	log.Println("   DELETE /api/heartbeats/{projectId}  - Remove the dead-man's switch")
+	log.Println("   GET    /api/slos?projectId=         - List SLOs of a project")
+	log.Println("   POST   /api/slos                    - Create an SLO")
+	log.Println("   GET    /api/slos/{id}               - Error budget, burn rates and forecast")
+	log.Println("   DELETE /api/slos/{id}               - Delete an SLO")
+	log.Println("   GET    /api/uptime-checks?projectId= - List uptime checks of a project")
+	log.Println("   POST   /api/uptime-checks           - Register a URL to probe")
+	log.Println("   GET    /api/uptime-checks/{id}      - Get an uptime check")
+	log.Println("   DELETE /api/uptime-checks/{id}      - Delete an uptime check")
+	log.Println("   GET    /api/uptime-checks/{id}/results - Latest probe results")
+	log.Println("   GET    /api/certificates?projectId= - TLS certificates of HTTPS checks by expiry")
+	log.Println("   GET    /api/transaction-checks?projectId= - List multi-step transaction checks")
+	log.Println("   POST   /api/transaction-checks      - Create a multi-step transaction check")
+	log.Println("   GET    /api/transaction-checks/{id} - Get a transaction check")
+	log.Println("   DELETE /api/transaction-checks/{id} - Delete a transaction check")
+	log.Println("   GET    /api/transaction-checks/{id}/runs - Latest runs with per-step timings")
+	log.Println("   GET    /api/cron-monitors?projectId= - List cron job monitors")
+	log.Println("   POST   /api/cron-monitors           - Create a cron monitor (returns check-in token)")
+	log.Println("   GET    /api/cron-monitors/{id}      - Get a cron monitor")
+	log.Println("   DELETE /api/cron-monitors/{id}      - Delete a cron monitor")
+	log.Println("   GET    /api/cron-monitors/{id}/runs - Runs with duration, late and missed flags")
+	log.Println("   POST   /api/checkin/{token}[/start|/fail] - Job check-in ping (no auth)")
+	log.Println("   GET    /api/status-pages?projectId= - List status pages of a project")
+	log.Println("   POST   /api/status-pages            - Create a status page (opt-in with enabled)")
+	log.Println("   GET    /api/status-pages/{id}       - Get a status page")
+	log.Println("   PUT    /api/status-pages/{id}       - Update slug, texts or visibility")
+	log.Println("   DELETE /api/status-pages/{id}       - Delete a status page")
+	log.Println("   GET    /api/status-pages/{id}/components - List components")
+	log.Println("   POST   /api/status-pages/{id}/components - Add a component mapped to a route or uptime check")
+	log.Println("   DELETE /api/status-pages/{id}/components/{componentId} - Remove a component")
+	log.Println("   GET    /api/status-pages/{id}/messages - List manual status messages")
+	log.Println("   POST   /api/status-pages/{id}/messages - Post a manual status message")
+	log.Println("   POST   /api/status-pages/{id}/messages/{messageId}/resolve - Resolve a status message")
+	log.Println("")
+	log.Println("🌐 Public Status Pages (no auth):")
+	log.Println("   GET    /status/{slug}               - Rendered status page (?format=json for JSON)")
+	log.Println("   GET    /api/status/{slug}           - Status page as JSON")
+	log.Println("")
 	log.Println("� Health & Metrics Endpoints:")
 	log.Println("   GET    /health                      - Health check")
-	log.Println("   WS     /stream                      - WebSocket for metrics")
-	log.Println("   GET    /metrics                     - View all metrics")
-	log.Println("   GET    /metrics/{projectId}        - View metrics by project")
+	log.Println("   WS     /stream?connectionId=        - WebSocket for metrics")
+	log.Println("   GET    /metrics                     - View metrics of your projects (Bearer token)")
+	log.Println("   GET    /metrics/{projectId}        - View metrics by project (Bearer token, viewer)")
 	log.Println("")
 	log.Println("Waiting for connections...")
 
@@ -174,6 +733,19 @@ func printUsersTableColumns(db *sql.DB) {
 }
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Middleware may authenticate with a project API key ("Authorization: ApiKey pk_...");
+	// projects that have keys only accept metrics sent with one of them
+	var projectKey *model.ProjectKey
+	if apiKey := handler.ExtractAPIKey(r); apiKey != "" {
+		key, err := orgService.AuthenticateProjectKey(apiKey)
+		if err != nil {
+			log.Println("❌ WebSocket refused:", err)
+			http.Error(w, services.ErrInvalidProjectKey.Error(), http.StatusUnauthorized)
+			return
+		}
+		projectKey = key
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -182,8 +754,17 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
+	// Middleware may name its connection; otherwise the remote address identifies it
+	connectionID := r.URL.Query().Get("connectionId")
+	if connectionID == "" {
+		connectionID = r.RemoteAddr
+	}
+
 	log.Println("✅ New middleware connected!")
 
+	// The guard re-reads the key and the projects' key requirement every so often, not per metric
+	ingestGuard := orgService.NewIngestGuard(projectKey, time.Now())
+
 	// Read messages from middleware
 	for {
 		_, message, err := conn.ReadMessage()
@@ -199,6 +780,23 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
+		if err := ingestGuard.Authorize(metric.ProjectID, time.Now()); err != nil {
+			log.Printf("⚠️  Metric for project %s refused: %v", metric.ProjectID, err)
+			nack, _ := json.Marshal(map[string]string{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			conn.WriteMessage(websocket.TextMessage, nack)
+			if errors.Is(err, services.ErrInvalidProjectKey) {
+				// the key was revoked, rotated or deleted: end the connection
+				break
+			}
+			continue
+		}
+
+		// Record that the project is alive
+		heartbeatService.Touch(metric.ProjectID, connectionID, time.Now())
+
 		// Store metric in memory
 		metrics = append(metrics, metric)
 		// Store metric in PostgreSQL
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type AccountHandler struct {
+	accountService *services.AccountService
+}
+
+// NewAccountHandler creates a new instance of AccountHandler
+func NewAccountHandler(accountService *services.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+// Export downloads a zip archive of the caller's profile, organizations, projects and settings
+func (h *AccountHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	archive, err := h.accountService.Export(requestActor(r, claims))
+	if err != nil {
+		log.Printf("error exporting account: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "failed to export account data")
+		return
+	}
+	filename := fmt.Sprintf("prothomuse-account-%d-%s.zip", claims.UserID, time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// Deletion shows what deleting the account does (GET), asks for a deletion
+// confirmation email (POST) or cancels a scheduled deletion (DELETE)
+func (h *AccountHandler) Deletion(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		plan, err := h.accountService.DeletionStatus(claims.UserID)
+		if err != nil {
+			log.Printf("error fetching account deletion status: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "failed to fetch account deletion status")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "account deletion status fetched successfully", plan)
+	case http.MethodPost:
+		var req model.DeleteAccountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding delete account request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		plan, err := h.accountService.RequestDeletion(requestActor(r, claims), req)
+		if err != nil {
+			log.Printf("error requesting account deletion: %v", err)
+			if errors.Is(err, services.ErrSoleOwner) {
+				sendErrorResponse(w, http.StatusConflict, err.Error())
+				return
+			}
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusAccepted, "check your email to confirm the deletion of your account", plan)
+	case http.MethodDelete:
+		if err := h.accountService.CancelDeletion(requestActor(r, claims)); err != nil {
+			log.Printf("error cancelling account deletion: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "account deletion cancelled successfully", nil)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET, POST or DELETE method is allowed")
+	}
+}
+
+// ConfirmDeletion schedules the deletion of the account with the token from the
+// confirmation email. It does not need a Bearer token.
+func (h *AccountHandler) ConfirmDeletion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+
+	var req model.ConfirmAccountDeletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding confirm account deletion request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	plan, err := h.accountService.ConfirmDeletion(req.Token, clientInfo(r))
+	if err != nil {
+		log.Printf("error confirming account deletion: %v", err)
+		if errors.Is(err, services.ErrSoleOwner) {
+			sendErrorResponse(w, http.StatusConflict, err.Error())
+			return
+		}
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "account scheduled for deletion", plan)
+}
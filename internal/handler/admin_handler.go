@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type AdminHandler struct {
+	adminService *services.AdminService
+}
+
+// NewAdminHandler creates a new instance of AdminHandler.
+// Its routes are wrapped by Authorizer.SystemAdmin.
+func NewAdminHandler(adminService *services.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+	}
+}
+
+// adminErrorStatus maps the errors of the AdminService to HTTP statuses
+func adminErrorStatus(err error) int {
+	if errors.Is(err, services.ErrUserNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// Users searches users. Query parameters: q (part of the username or email),
+// active (true or false), page (from 1) and pageSize (default 50, at most 200)
+func (h *AdminHandler) Users(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := model.UserFilter{Query: query.Get("q")}
+	if v := query.Get("active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "active must be true or false")
+			return
+		}
+		filter.Active = &active
+	}
+	var err error
+	for name, dst := range map[string]*int{"page": &filter.Page, "pageSize": &filter.PageSize} {
+		if v := query.Get(name); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				sendErrorResponse(w, http.StatusBadRequest, name+" must be a number")
+				return
+			}
+		}
+	}
+
+	page, err := h.adminService.ListUsers(requestActor(r, claims), filter)
+	if err != nil {
+		log.Printf("error searching users: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "failed to search users")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "users fetched successfully", page)
+}
+
+// Audit returns a page of the whole audit log, newest first, including the
+// events outside any organization. It takes the query parameters of the
+// organization audit log, and orgId: an organization ID, or "none" for the
+// events outside any organization.
+func (h *AdminHandler) Audit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	filter, ok := parseAuditFilter(w, r)
+	if !ok {
+		return
+	}
+	if v := r.URL.Query().Get("orgId"); v == "none" {
+		filter.WithoutOrganization = true
+	} else if v != "" {
+		orgID, err := strconv.Atoi(v)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "orgId must be a number or none")
+			return
+		}
+		filter.OrganizationID = orgID
+	}
+
+	page, err := h.adminService.AuditEvents(requestActor(r, claims), filter)
+	if err != nil {
+		log.Printf("error listing audit events: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "failed to list audit events")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "audit events fetched successfully", page)
+}
+
+// User returns a user with their usage over the last 30 days
+func (h *AdminHandler) User(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	userID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.GetUser(requestActor(r, claims), userID)
+	if err != nil {
+		log.Printf("error fetching user: %v", err)
+		sendErrorResponse(w, adminErrorStatus(err), err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "user fetched successfully", user)
+}
+
+// Deactivate disables a user's account and signs them out everywhere
+func (h *AdminHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	h.setActive(w, r, false)
+}
+
+// Reactivate enables a deactivated account again
+func (h *AdminHandler) Reactivate(w http.ResponseWriter, r *http.Request) {
+	h.setActive(w, r, true)
+}
+
+func (h *AdminHandler) setActive(w http.ResponseWriter, r *http.Request, active bool) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	userID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.SetActive(requestActor(r, claims), userID, active)
+	if err != nil {
+		log.Printf("error changing user activation: %v", err)
+		sendErrorResponse(w, adminErrorStatus(err), err.Error())
+		return
+	}
+	message := "user reactivated successfully"
+	if !active {
+		message = "user deactivated successfully"
+	}
+	sendSuccessResponse(w, http.StatusOK, message, user)
+}
+
+// ResetPassword invalidates a user's password and mails them a reset link
+func (h *AdminHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	userID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.ForcePasswordReset(requestActor(r, claims), userID); err != nil {
+		log.Printf("error forcing password reset: %v", err)
+		sendErrorResponse(w, adminErrorStatus(err), err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "password reset, the user was signed out and emailed a reset link", nil)
+}
+
+// RevokeKeys replaces a user's API key and revokes the project keys they created
+func (h *AdminHandler) RevokeKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	userID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	revoked, err := h.adminService.RevokeKeys(requestActor(r, claims), userID)
+	if err != nil {
+		log.Printf("error revoking user keys: %v", err)
+		sendErrorResponse(w, adminErrorStatus(err), err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "keys revoked successfully", map[string]int{"projectKeysRevoked": revoked})
+}
+
+// Unlock clears the login lockout of a user's account
+func (h *AdminHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	userID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.Unlock(requestActor(r, claims), userID); err != nil {
+		log.Printf("error unlocking user: %v", err)
+		sendErrorResponse(w, adminErrorStatus(err), err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "account unlocked successfully", nil)
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type AlertHandler struct {
+	alertService *services.AlertService
+}
+
+// NewAlertHandler creates a new instance of AlertHandler
+func NewAlertHandler(alertService *services.AlertService) *AlertHandler {
+	return &AlertHandler{
+		alertService: alertService,
+	}
+}
+
+// ListAlerts returns the alerts of a project.
+// Query parameters: projectId (required), state (firing|resolved, optional)
+func (h *AlertHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+
+	alerts, err := h.alertService.ListAlerts(r.URL.Query().Get("projectId"), r.URL.Query().Get("state"))
+	if err != nil {
+		log.Printf("error listing alerts: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "alerts fetched successfully", alerts)
+}
+
+// GetAlert returns an alert together with its full history
+func (h *AlertHandler) GetAlert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	alert, err := h.alertService.GetAlert(id)
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "alert not found")
+		return
+	}
+	history, err := h.alertService.GetHistory(id)
+	if err != nil {
+		log.Printf("error fetching alert history: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "could not fetch alert history")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "alert fetched successfully", map[string]interface{}{
+		"alert":   alert,
+		"history": history,
+	})
+}
+
+// Acknowledge marks an alert as acknowledged by the authenticated user
+func (h *AlertHandler) Acknowledge(w http.ResponseWriter, r *http.Request) {
+	h.setAcknowledgement(w, r, true)
+}
+
+// Unacknowledge clears the acknowledgement of an alert
+func (h *AlertHandler) Unacknowledge(w http.ResponseWriter, r *http.Request) {
+	h.setAcknowledgement(w, r, false)
+}
+
+func (h *AlertHandler) setAcknowledgement(w http.ResponseWriter, r *http.Request, ack bool) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var alert *model.Alert
+	var err error
+	message := "alert acknowledged successfully"
+	if ack {
+		alert, err = h.alertService.Acknowledge(id, requestActor(r, claims))
+	} else {
+		alert, err = h.alertService.Unacknowledge(id, requestActor(r, claims))
+		message = "alert unacknowledged successfully"
+	}
+	if err != nil {
+		log.Printf("error updating alert acknowledgement: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, message, alert)
+}
+
+// Timeline returns the incident timeline of a project.
+// Query parameters: from, to (RFC 3339, optional; defaults to the last 7 days)
+func (h *AlertHandler) Timeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+
+	var from, to time.Time
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp")
+			return
+		}
+	}
+
+	timeline, err := h.alertService.Timeline(r.PathValue("projectId"), from, to)
+	if err != nil {
+		log.Printf("error fetching incident timeline: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "timeline fetched successfully", timeline)
+}
+
+// AddNote adds a note to the incident timeline of a project
+func (h *AlertHandler) AddNote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	var req model.CreateIncidentNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding incident note request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	note, err := h.alertService.AddNote(r.PathValue("projectId"), claims.UserID, req)
+	if err != nil {
+		log.Printf("error adding incident note: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusCreated, "note added successfully", note)
+}
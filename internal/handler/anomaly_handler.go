@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type AnomalyHandler struct {
+	anomalyService *services.AnomalyService
+}
+
+// NewAnomalyHandler creates a new instance of AnomalyHandler
+func NewAnomalyHandler(anomalyService *services.AnomalyService) *AnomalyHandler {
+	return &AnomalyHandler{
+		anomalyService: anomalyService,
+	}
+}
+
+// Detectors lists (GET ?projectId=) or creates (POST) anomaly detectors
+func (h *AnomalyHandler) Detectors(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		detectors, err := h.anomalyService.ListDetectors(r.URL.Query().Get("projectId"))
+		if err != nil {
+			log.Printf("error listing anomaly detectors: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "anomaly detectors fetched successfully", detectors)
+	case http.MethodPost:
+		var req model.CreateAnomalyDetectorRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding anomaly detector request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		detector, err := h.anomalyService.CreateDetector(claims.UserID, req)
+		if err != nil {
+			log.Printf("error creating anomaly detector: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "anomaly detector created successfully", detector)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// DeleteDetector removes an anomaly detector and its events
+func (h *AnomalyHandler) DeleteDetector(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only DELETE method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.anomalyService.DeleteDetector(id); err != nil {
+		log.Printf("error deleting anomaly detector: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "could not delete anomaly detector")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "anomaly detector deleted successfully", nil)
+}
+
+// ListEvents returns the anomaly events of a project, newest first.
+// Query parameters: projectId (required), limit (optional, default 100)
+func (h *AnomalyHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	events, err := h.anomalyService.ListEvents(r.URL.Query().Get("projectId"), limit)
+	if err != nil {
+		log.Printf("error listing anomaly events: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "anomaly events fetched successfully", events)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+// NewAuditHandler creates a new instance of AuditHandler
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// Events returns a page of the audit log of an organization, newest first.
+// Query parameters: action, actorId, targetType, targetId, from, to (RFC 3339),
+// page (from 1) and pageSize (default 50, at most 200)
+func (h *AuditHandler) Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	orgID, ok := pathID(w, r, "orgId")
+	if !ok {
+		return
+	}
+	filter, ok := parseAuditFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := h.auditService.List(orgID, filter)
+	if err != nil {
+		log.Printf("error listing audit events: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "failed to list audit events")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "audit events fetched successfully", page)
+}
+
+// parseAuditFilter reads the filter and page of an audit log request, answering
+// a malformed one with 400
+func parseAuditFilter(w http.ResponseWriter, r *http.Request) (model.AuditFilter, bool) {
+	query := r.URL.Query()
+	filter := model.AuditFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("targetType"),
+		TargetID:   query.Get("targetId"),
+	}
+	var err error
+	for name, dst := range map[string]*int{"actorId": &filter.ActorID, "page": &filter.Page, "pageSize": &filter.PageSize} {
+		if v := query.Get(name); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				sendErrorResponse(w, http.StatusBadRequest, name+" must be a number")
+				return filter, false
+			}
+		}
+	}
+	for name, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := query.Get(name); v != "" {
+			if *dst, err = time.Parse(time.RFC3339, v); err != nil {
+				sendErrorResponse(w, http.StatusBadRequest, name+" must be an RFC 3339 timestamp")
+				return filter, false
+			}
+		}
+	}
+	return filter, true
+}
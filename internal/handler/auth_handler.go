@@ -2,8 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"prothomuse-server/internal/model"
@@ -13,12 +17,14 @@ import (
 
 type AuthHandler struct {
 	authService *services.AuthService
+	orgService  *services.OrganizationService
 }
 
 // NewAuthHandler creates a new instance of AuthHandler
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, orgService *services.OrganizationService) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
+		orgService:  orgService,
 	}
 }
 
@@ -39,26 +45,35 @@ func (h *AuthHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	// Call the auth service to register the user
-	user, err := h.authService.RegisterUser(req)
+	user, err := h.authService.RegisterUser(req, clientInfo(r))
 	if err != nil {
 		log.Printf("error registering user: %v", err)
+		if sendPasswordPolicyError(w, err) {
+			return
+		}
 		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	message := "user registered successfully, check your email to verify your address"
+	if user.EmailVerified {
+		message = "user registered successfully"
+	}
+
 	// Return success response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
 		"data": map[string]interface{}{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-			"apiKey":   user.APIKey,
-			"isActive": user.IsActive,
+			"id":            user.ID,
+			"username":      user.Username,
+			"email":         user.Email,
+			"apiKey":        user.APIKey,
+			"isActive":      user.IsActive,
+			"emailVerified": user.EmailVerified,
 		},
-		"message": "user registered successfully",
+		"message": message,
 	})
 }
 
@@ -79,20 +94,28 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	// Call the auth service to login the user
-	response, err := h.authService.Login(req)
+	response, err := h.authService.Login(req, clientInfo(r))
 	if err != nil {
 		log.Printf("error logging in user: %v", err)
+		if sendLockedOutError(w, err) {
+			return
+		}
 		sendErrorResponse(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
+	message := "user logged in successfully"
+	if response.MFARequired {
+		message = "mfa_required"
+	}
+
 	// Return success response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":  "success",
 		"data":    response,
-		"message": "user logged in successfully",
+		"message": message,
 	})
 }
 
@@ -127,9 +150,12 @@ func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	// ensure the update ID is the same as token subject
 	req.ID = claims.UserID
 
-	updated, err := h.authService.UpdateUser(req)
+	updated, err := h.authService.UpdateUser(req, requestActor(r, claims), claims.SessionID)
 	if err != nil {
 		log.Printf("error updating user: %v", err)
+		if sendPasswordPolicyError(w, err) {
+			return
+		}
 		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -148,15 +174,227 @@ func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ValidateAPIKey validates the API key from the request header
+// VerifyEmail confirms an email address with the token from the verification
+// email, given as ?token= (the emailed link) or in a POST body.
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req model.VerifyEmailRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Token = r.URL.Query().Get("token")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding verify email request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+		return
+	}
+
+	user, err := h.authService.VerifyEmail(req.Token)
+	if err != nil {
+		log.Printf("error verifying email: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "email verified successfully", map[string]interface{}{
+		"id":            user.ID,
+		"email":         user.Email,
+		"emailVerified": user.EmailVerified,
+	})
+}
+
+// UnlockAccount unlocks an account locked by failed logins with the token from
+// the lockout email, given as ?token= (the emailed link) or in a POST body.
+func (h *AuthHandler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	var req model.UnlockAccountRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Token = r.URL.Query().Get("token")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding unlock request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+		return
+	}
+
+	if err := h.authService.UnlockAccount(req.Token, clientInfo(r)); err != nil {
+		log.Printf("error unlocking account: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "account unlocked successfully", nil)
+}
+
+// ResendVerification mails a new verification link. The response is the same
+// whether or not the address has an account.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+
+	var req model.ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding resend verification request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := h.authService.ResendVerification(req.Email); err != nil {
+		log.Printf("error resending verification email: %v", err)
+	}
+	sendSuccessResponse(w, http.StatusOK, "if the address belongs to an unverified account, a verification email has been sent", nil)
+}
+
+// ForgotPassword mails a password reset link. The response is the same whether
+// or not the address has an account.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+
+	var req model.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding forgot password request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := h.authService.ForgotPassword(req.Email); err != nil {
+		log.Printf("error sending password reset email: %v", err)
+	}
+	sendSuccessResponse(w, http.StatusOK, "if the address belongs to an account, a password reset email has been sent", nil)
+}
+
+// ResetPassword sets a new password using the token from the reset email
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+
+	var req model.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding reset password request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := h.authService.ResetPassword(req); err != nil {
+		log.Printf("error resetting password: %v", err)
+		if sendPasswordPolicyError(w, err) {
+			return
+		}
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "password reset successfully, please log in again", nil)
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+
+	var req model.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding refresh request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	response, err := h.authService.Refresh(req.RefreshToken, clientInfo(r))
+	if err != nil {
+		log.Printf("error refreshing token: %v", err)
+		sendErrorResponse(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "token refreshed successfully", response)
+}
+
+// Logout revokes the caller's access token and optionally its refresh token chain
+// or every token of the user. Requires Authorization: Bearer <token>
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	var req model.LogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding logout request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	if err := h.authService.Logout(claims, req); err != nil {
+		log.Printf("error logging out user: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "could not log out")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "user logged out successfully", nil)
+}
+
+// JWKS publishes the public keys that verify access tokens, so other services
+// can check Prothomuse tokens themselves. Served at /.well-known/jwks.json.
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(utils.JWKS())
+}
+
+// ValidateAPIKey validates the API key from the request header: a user's API
+// key, or a project API key ("pk_...") that is not revoked
 func (h *AuthHandler) ValidateAPIKey(w http.ResponseWriter, r *http.Request) {
 	// Get API key from Authorization header
-	apiKey := extractAPIKeyFromHeader(r)
+	apiKey := ExtractAPIKey(r)
 	if apiKey == "" {
 		sendErrorResponse(w, http.StatusUnauthorized, "API key is required")
 		return
 	}
 
+	if strings.HasPrefix(apiKey, "pk_") {
+		key, err := h.orgService.AuthenticateProjectKey(apiKey)
+		if err != nil {
+			log.Printf("error validating project API key: %v", err)
+			sendErrorResponse(w, http.StatusUnauthorized, services.ErrInvalidProjectKey.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "API key is valid", map[string]interface{}{
+			"projectId": key.ProjectID,
+			"keyId":     key.ID,
+			"name":      key.Name,
+			"prefix":    key.Prefix,
+		})
+		return
+	}
+
 	// Get user by API key
 	user, err := h.authService.GetUserByAPIKey(apiKey)
 	if err != nil {
@@ -217,9 +455,87 @@ func sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
 	})
 }
 
-// extractAPIKeyFromHeader extracts the API key from the Authorization header
+// sendLockedOutError answers a LockedOutError with 429 and Retry-After, and
+// reports whether err was one
+func sendLockedOutError(w http.ResponseWriter, err error) bool {
+	var lockedOut *services.LockedOutError
+	if !errors.As(err, &lockedOut) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedOut.RetryAfter.Seconds()))))
+	sendErrorResponse(w, http.StatusTooManyRequests, err.Error())
+	return true
+}
+
+// sendPasswordPolicyError answers a password refused by the policy with the
+// code of the rule it breaks, and reports whether err was such a refusal
+func sendPasswordPolicyError(w http.ResponseWriter, err error) bool {
+	var refused *services.PasswordPolicyError
+	if !errors.As(err, &refused) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "error",
+		"code":    refused.Code,
+		"message": refused.Message,
+	})
+	return true
+}
+
+// trustProxyHeaders makes clientInfo take the client IP from X-Forwarded-For,
+// which is only safe behind a proxy that sets it
+var trustProxyHeaders bool
+
+// trustedProxyHops is the number of proxies in front of the server; each one
+// appends the address it received the request from to X-Forwarded-For
+var trustedProxyHops = 1
+
+// SetTrustProxyHeaders makes client IPs come from X-Forwarded-For
+func SetTrustProxyHeaders(trust bool) {
+	trustProxyHeaders = trust
+}
+
+// SetTrustedProxyHops sets how many proxies append to X-Forwarded-For
+func SetTrustedProxyHops(hops int) {
+	if hops > 0 {
+		trustedProxyHops = hops
+	}
+}
+
+// clientInfo returns the IP address and user agent of the request
+func clientInfo(r *http.Request) model.ClientInfo {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if trustProxyHeaders {
+		// the client controls everything left of what our proxies appended, so
+		// count the entries from the right
+		var hops []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(header, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		if len(hops) > 0 {
+			ip = hops[max(0, len(hops)-trustedProxyHops)]
+		}
+	}
+	return model.ClientInfo{IP: ip, UserAgent: r.UserAgent()}
+}
+
+// requestActor returns the signed-in user of the request and where it came from
+func requestActor(r *http.Request, claims *utils.Claims) model.Actor {
+	return model.Actor{UserID: claims.UserID, ClientInfo: clientInfo(r)}
+}
+
+// ExtractAPIKey extracts the API key from the Authorization header
 // Expected format: "ApiKey <api_key>"
-func extractAPIKeyFromHeader(r *http.Request) string {
+func ExtractAPIKey(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
 		return ""
@@ -248,3 +564,53 @@ func extractJWTFromHeader(r *http.Request) string {
 
 	return ""
 }
+
+// sendSuccessResponse sends a JSON success response
+func sendSuccessResponse(w http.ResponseWriter, statusCode int, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"data":    data,
+		"message": message,
+	})
+}
+
+// requireJWT validates the Bearer token of the request and returns its claims.
+// It writes a 401 response and returns nil when the token is missing or invalid.
+func requireJWT(w http.ResponseWriter, r *http.Request) *utils.Claims {
+	if claims := RequestClaims(r); claims != nil {
+		return claims
+	}
+	token := extractJWTFromHeader(r)
+	if token == "" {
+		sendErrorResponse(w, http.StatusUnauthorized, "JWT token is required")
+		return nil
+	}
+	claims, err := utils.ValidateJWT(token)
+	if err != nil {
+		log.Printf("error validating JWT token: %v", err)
+		sendErrorResponse(w, http.StatusUnauthorized, "invalid or expired token")
+		return nil
+	}
+	return claims
+}
+
+// pathID parses the numeric {id} path value of the request.
+// It writes a 400 response and returns false when it is not a number.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := parseID(r.PathValue(name))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "invalid "+name)
+		return 0, false
+	}
+	return id, true
+}
+
+func parseID(value string) (int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid id")
+	}
+	return id, nil
+}
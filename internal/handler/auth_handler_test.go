@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientInfo(t *testing.T) {
+	defer SetTrustProxyHeaders(false)
+	defer SetTrustedProxyHops(1)
+
+	tests := []struct {
+		scenario  string
+		trust     bool
+		hops      int
+		forwarded []string
+		want      string
+	}{
+		{"proxy headers not trusted", false, 1, []string{"203.0.113.7"}, "192.0.2.1"},
+		{"one proxy", true, 1, []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entry before the proxy's", true, 1, []string{"10.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+		{"spoofed header before the proxy's", true, 1, []string{"10.0.0.1", "203.0.113.7"}, "203.0.113.7"},
+		{"two proxies", true, 2, []string{"10.0.0.1, 203.0.113.7, 198.51.100.2"}, "203.0.113.7"},
+		{"fewer entries than proxies", true, 3, []string{"203.0.113.7"}, "203.0.113.7"},
+		{"no header", true, 1, nil, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		SetTrustProxyHeaders(tt.trust)
+		SetTrustedProxyHops(tt.hops)
+		r := httptest.NewRequest("GET", "/api/auth/login", nil)
+		r.RemoteAddr = "192.0.2.1:54321"
+		r.Header.Set("User-Agent", "curl/8.0")
+		for _, value := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		info := clientInfo(r)
+		if info.IP != tt.want || info.UserAgent != "curl/8.0" {
+			t.Errorf("%s: clientInfo = %+v, want IP %s", tt.scenario, info, tt.want)
+		}
+	}
+}
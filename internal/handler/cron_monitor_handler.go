@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type CronMonitorHandler struct {
+	cronService *services.CronMonitorService
+}
+
+// NewCronMonitorHandler creates a new instance of CronMonitorHandler
+func NewCronMonitorHandler(cronService *services.CronMonitorService) *CronMonitorHandler {
+	return &CronMonitorHandler{
+		cronService: cronService,
+	}
+}
+
+// Monitors lists (GET ?projectId=) or creates (POST) cron monitors
+func (h *CronMonitorHandler) Monitors(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		monitors, err := h.cronService.ListMonitors(r.URL.Query().Get("projectId"))
+		if err != nil {
+			log.Printf("error listing cron monitors: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "cron monitors fetched successfully", monitors)
+	case http.MethodPost:
+		var req model.CreateCronMonitorRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding cron monitor request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		monitor, err := h.cronService.CreateMonitor(claims.UserID, req)
+		if err != nil {
+			log.Printf("error creating cron monitor: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "cron monitor created successfully", monitor)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// Monitor returns (GET) or deletes (DELETE) a cron monitor
+func (h *CronMonitorHandler) Monitor(w http.ResponseWriter, r *http.Request) {
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		monitor, err := h.cronService.GetMonitor(id)
+		if err != nil {
+			sendErrorResponse(w, http.StatusNotFound, "cron monitor not found")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "cron monitor fetched successfully", monitor)
+	case http.MethodDelete:
+		if err := h.cronService.DeleteMonitor(id); err != nil {
+			log.Printf("error deleting cron monitor: %v", err)
+			sendErrorResponse(w, http.StatusNotFound, "cron monitor not found")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "cron monitor deleted successfully", nil)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or DELETE method is allowed")
+	}
+}
+
+// Runs returns the latest runs of a cron monitor, including missed and late ones.
+// Query parameters: limit (optional, default 50)
+func (h *CronMonitorHandler) Runs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	runs, err := h.cronService.ListRuns(id, limit)
+	if err != nil {
+		log.Printf("error listing cron runs: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "could not fetch cron runs")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "cron runs fetched successfully", runs)
+}
+
+// CheckIn receives a ping from a job. It is authenticated by the monitor token
+// in the URL so it can be called with a plain curl from a crontab.
+// /api/checkin/{token} reports success, /start and /fail the other states.
+func (h *CronMonitorHandler) CheckIn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+		return
+	}
+	kind := r.PathValue("kind")
+	if kind == "" {
+		kind = services.CronPingSuccess
+	}
+
+	run, err := h.cronService.Ping(r.PathValue("token"), kind, time.Now())
+	if err != nil {
+		log.Printf("error recording cron check-in: %v", err)
+		sendErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "check-in recorded", run)
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type HeartbeatHandler struct {
+	heartbeatService *services.HeartbeatService
+}
+
+// NewHeartbeatHandler creates a new instance of HeartbeatHandler
+func NewHeartbeatHandler(heartbeatService *services.HeartbeatService) *HeartbeatHandler {
+	return &HeartbeatHandler{
+		heartbeatService: heartbeatService,
+	}
+}
+
+// Heartbeat shows (GET), configures (PUT) or removes (DELETE) the dead-man's switch of a project
+func (h *HeartbeatHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	projectID := r.PathValue("projectId")
+
+	switch r.Method {
+	case http.MethodGet:
+		status, err := h.heartbeatService.GetStatus(projectID)
+		if err != nil {
+			log.Printf("error fetching heartbeat status: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "heartbeat status fetched successfully", status)
+	case http.MethodPut:
+		var req model.UpdateHeartbeatConfigRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding heartbeat config request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		config, err := h.heartbeatService.UpdateConfig(projectID, claims.UserID, req)
+		if err != nil {
+			log.Printf("error updating heartbeat config: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "heartbeat config saved successfully", config)
+	case http.MethodDelete:
+		if err := h.heartbeatService.DeleteConfig(projectID); err != nil {
+			log.Printf("error deleting heartbeat config: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "could not delete heartbeat config")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "heartbeat config deleted successfully", nil)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET, PUT or DELETE method is allowed")
+	}
+}
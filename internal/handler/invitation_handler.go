@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type InvitationHandler struct {
+	inviteService *services.InvitationService
+}
+
+// NewInvitationHandler creates a new instance of InvitationHandler
+func NewInvitationHandler(inviteService *services.InvitationService) *InvitationHandler {
+	return &InvitationHandler{
+		inviteService: inviteService,
+	}
+}
+
+// Invitations lists pending (GET) or sends (POST) invitations of an organization
+func (h *InvitationHandler) Invitations(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	orgID, ok := pathID(w, r, "orgId")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		invites, err := h.inviteService.ListPending(orgID)
+		if err != nil {
+			log.Printf("error listing invitations: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "failed to list invitations")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "invitations fetched successfully", invites)
+	case http.MethodPost:
+		var req model.CreateInvitationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding invitation request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		invite, err := h.inviteService.Invite(orgID, requestActor(r, claims), requestRole(r), req)
+		if err != nil {
+			log.Printf("error creating invitation: %v", err)
+			sendErrorResponse(w, organizationErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "invitation sent successfully", invite)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// Invitation revokes (DELETE) a pending invitation
+func (h *InvitationHandler) Invitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only DELETE method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	orgID, ok := pathID(w, r, "orgId")
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.inviteService.Revoke(orgID, requestActor(r, claims), requestRole(r), id); err != nil {
+		log.Printf("error revoking invitation: %v", err)
+		sendErrorResponse(w, organizationErrorStatus(err), err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "invitation revoked successfully", nil)
+}
+
+// Resend mails a pending invitation again with a new link
+func (h *InvitationHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	orgID, ok := pathID(w, r, "orgId")
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	invite, err := h.inviteService.Resend(orgID, requestActor(r, claims), requestRole(r), id)
+	if err != nil {
+		log.Printf("error resending invitation: %v", err)
+		sendErrorResponse(w, organizationErrorStatus(err), err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "invitation sent again successfully", invite)
+}
+
+// Accept makes the signed-in user a member of the organization of an invitation
+func (h *InvitationHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	var req model.AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding accept invitation request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	invite, err := h.inviteService.AcceptForUser(requestActor(r, claims), req.Token)
+	if err != nil {
+		log.Printf("error accepting invitation: %v", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrInvitationEmail) {
+			status = http.StatusForbidden
+		}
+		sendErrorResponse(w, status, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "invitation accepted successfully", invite)
+}
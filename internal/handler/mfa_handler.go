@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type MFAHandler struct {
+	mfaService  *services.MFAService
+	authService *services.AuthService
+}
+
+// NewMFAHandler creates a new instance of MFAHandler
+func NewMFAHandler(mfaService *services.MFAService, authService *services.AuthService) *MFAHandler {
+	return &MFAHandler{
+		mfaService:  mfaService,
+		authService: authService,
+	}
+}
+
+// Enroll starts TOTP enrolment and returns the secret and otpauth URI
+func (h *MFAHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	enrollment, err := h.mfaService.Enroll(claims.UserID)
+	if err != nil {
+		log.Printf("error starting MFA enrolment: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "scan the otpauth URI with an authenticator app, then confirm with a code", enrollment)
+}
+
+// Confirm enables MFA with a first code and returns the recovery codes once
+func (h *MFAHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	var req model.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding MFA confirm request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	codes, err := h.mfaService.Confirm(claims.UserID, req.Code)
+	if err != nil {
+		log.Printf("error confirming MFA: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "two-factor authentication enabled, store the recovery codes safely", model.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable turns MFA off; requires the password and a TOTP or recovery code
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	var req model.DisableMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding MFA disable request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := h.mfaService.Disable(claims.UserID, req.Password, req.Code, clientInfo(r).IP); err != nil {
+		log.Printf("error disabling MFA: %v", err)
+		if sendLockedOutError(w, err) {
+			return
+		}
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "two-factor authentication disabled", nil)
+}
+
+// RecoveryCodes replaces the recovery codes after checking a current code
+func (h *MFAHandler) RecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	var req model.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding recovery codes request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(claims.UserID, req.Code, clientInfo(r).IP)
+	if err != nil {
+		log.Printf("error regenerating recovery codes: %v", err)
+		if sendLockedOutError(w, err) {
+			return
+		}
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "recovery codes regenerated", model.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Verify completes a login that returned mfa_required and issues the tokens
+func (h *MFAHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	var req model.MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("error decoding MFA verify request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	response, err := h.authService.VerifyMFA(req, clientInfo(r))
+	if err != nil {
+		log.Printf("error verifying MFA login: %v", err)
+		if sendLockedOutError(w, err) {
+			return
+		}
+		sendErrorResponse(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "user logged in successfully", response)
+}
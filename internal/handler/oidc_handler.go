@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+}
+
+// NewOIDCHandler creates a new instance of OIDCHandler
+func NewOIDCHandler(oidcService *services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+	}
+}
+
+// oidcStateCookie binds an SSO flow to the browser that started it
+const oidcStateCookie = "oidc_state"
+
+// setStateCookie gives the browser the state of the flow it starts. SameSite=Lax
+// lets it come back on the top-level redirect from the identity provider.
+func setStateCookie(w http.ResponseWriter, r *http.Request, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(services.OIDCStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || (trustProxyHeaders && r.Header.Get("X-Forwarded-Proto") == "https"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (h *OIDCHandler) sendError(w http.ResponseWriter, err error, status int) {
+	if errors.Is(err, services.ErrSSODisabled) {
+		status = http.StatusNotFound
+	}
+	sendErrorResponse(w, status, err.Error())
+}
+
+// Login redirects the browser to the identity provider.
+// An optional ?login_hint= is passed on to the provider.
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+
+	authURL, state, err := h.oidcService.AuthorizationURL(0, r.URL.Query().Get("login_hint"))
+	if err != nil {
+		log.Printf("error starting SSO login: %v", err)
+		h.sendError(w, err, http.StatusBadGateway)
+		return
+	}
+	setStateCookie(w, r, state)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback receives the provider redirect and returns tokens, or confirms a link
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		sendErrorResponse(w, http.StatusUnauthorized, "identity provider error: "+providerErr+" "+query.Get("error_description"))
+		return
+	}
+
+	var browserState string
+	if cookie, err := r.Cookie(oidcStateCookie); err == nil {
+		browserState = cookie.Value
+	}
+	// the state is single-use whatever the outcome
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/auth/oidc", MaxAge: -1, HttpOnly: true})
+
+	login, identity, err := h.oidcService.Callback(query.Get("code"), query.Get("state"), browserState, clientInfo(r))
+	if err != nil {
+		log.Printf("error completing SSO login: %v", err)
+		h.sendError(w, err, http.StatusUnauthorized)
+		return
+	}
+	if identity != nil {
+		sendSuccessResponse(w, http.StatusOK, "SSO account linked successfully", identity)
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "user logged in successfully", login)
+}
+
+// Link starts linking the signed-in user to an account at the identity provider.
+// It returns the URL to open in the browser, which then comes back to Callback.
+// It must be called from that browser, which receives the state cookie.
+func (h *OIDCHandler) Link(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	authURL, state, err := h.oidcService.AuthorizationURL(claims.UserID, "")
+	if err != nil {
+		log.Printf("error starting SSO link: %v", err)
+		h.sendError(w, err, http.StatusBadGateway)
+		return
+	}
+	setStateCookie(w, r, state)
+	sendSuccessResponse(w, http.StatusOK, "open authorizationUrl to link your SSO account", model.OIDCAuthorizationResponse{AuthorizationURL: authURL})
+}
+
+// Identities lists the SSO accounts linked to the signed-in user
+func (h *OIDCHandler) Identities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	identities, err := h.oidcService.ListIdentities(claims.UserID)
+	if err != nil {
+		log.Printf("error listing SSO identities: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "failed to list SSO accounts")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "SSO accounts fetched successfully", identities)
+}
+
+// Unlink removes (DELETE) a linked SSO account
+func (h *OIDCHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only DELETE method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.oidcService.Unlink(requestActor(r, claims), id); err != nil {
+		log.Printf("error unlinking SSO identity: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "failed to unlink SSO account")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "SSO account unlinked successfully", nil)
+}
@@ -0,0 +1,344 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type OrganizationHandler struct {
+	orgService *services.OrganizationService
+}
+
+// NewOrganizationHandler creates a new instance of OrganizationHandler.
+// Its routes are wrapped by the Authorizer, which checks the caller's role.
+func NewOrganizationHandler(orgService *services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+	}
+}
+
+// organizationErrorStatus maps the role errors of the OrganizationService to HTTP statuses
+func organizationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrNotMember), errors.Is(err, services.ErrProjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrLastOwner):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// Organizations lists (GET) the caller's organizations or creates (POST) one owned by the caller
+func (h *OrganizationHandler) Organizations(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		orgs, err := h.orgService.ListOrganizations(claims.UserID)
+		if err != nil {
+			log.Printf("error listing organizations: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "failed to list organizations")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "organizations fetched successfully", orgs)
+	case http.MethodPost:
+		var req model.CreateOrganizationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding organization request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		org, err := h.orgService.CreateOrganization(requestActor(r, claims), req)
+		if err != nil {
+			log.Printf("error creating organization: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "organization created successfully", org)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// Organization returns (GET) or deletes (DELETE) an organization
+func (h *OrganizationHandler) Organization(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	orgID, ok := pathID(w, r, "orgId")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		org, err := h.orgService.GetOrganization(orgID)
+		if err != nil {
+			sendErrorResponse(w, http.StatusNotFound, "organization not found")
+			return
+		}
+		org.Role = requestRole(r)
+		sendSuccessResponse(w, http.StatusOK, "organization fetched successfully", org)
+	case http.MethodDelete:
+		if err := h.orgService.DeleteOrganization(orgID, requestActor(r, claims)); err != nil {
+			log.Printf("error deleting organization: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "failed to delete organization")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "organization deleted successfully", nil)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or DELETE method is allowed")
+	}
+}
+
+// Members lists (GET) or adds (POST) members of an organization
+func (h *OrganizationHandler) Members(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	orgID, ok := pathID(w, r, "orgId")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		members, err := h.orgService.ListMembers(orgID)
+		if err != nil {
+			log.Printf("error listing members: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "failed to list members")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "members fetched successfully", members)
+	case http.MethodPost:
+		var req model.AddMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding member request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		member, err := h.orgService.AddMember(orgID, requestActor(r, claims), requestRole(r), req)
+		if err != nil {
+			log.Printf("error adding member: %v", err)
+			sendErrorResponse(w, organizationErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "member added successfully", member)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// Member changes the role of (PUT) or removes (DELETE) a member
+func (h *OrganizationHandler) Member(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	orgID, ok := pathID(w, r, "orgId")
+	if !ok {
+		return
+	}
+	userID, ok := pathID(w, r, "userId")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req model.UpdateMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding member request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		if err := h.orgService.UpdateMemberRole(orgID, requestActor(r, claims), requestRole(r), userID, req.Role); err != nil {
+			log.Printf("error updating member role: %v", err)
+			sendErrorResponse(w, organizationErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "member role updated successfully", nil)
+	case http.MethodDelete:
+		if err := h.orgService.RemoveMember(orgID, requestActor(r, claims), requestRole(r), userID); err != nil {
+			log.Printf("error removing member: %v", err)
+			sendErrorResponse(w, organizationErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "member removed successfully", nil)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only PUT or DELETE method is allowed")
+	}
+}
+
+// Projects lists (GET) or claims (POST) the projects of an organization
+func (h *OrganizationHandler) Projects(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	orgID, ok := pathID(w, r, "orgId")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		projects, err := h.orgService.ListProjects(orgID)
+		if err != nil {
+			log.Printf("error listing projects: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "failed to list projects")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "projects fetched successfully", projects)
+	case http.MethodPost:
+		var req model.CreateProjectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding project request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		project, err := h.orgService.CreateProject(orgID, requestActor(r, claims), req)
+		if err != nil {
+			log.Printf("error creating project: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "project created successfully", project)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// Project returns (GET) or deletes (DELETE) a project
+func (h *OrganizationHandler) Project(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	projectID := r.PathValue("projectId")
+
+	switch r.Method {
+	case http.MethodGet:
+		project, err := h.orgService.GetProject(projectID)
+		if err != nil {
+			sendErrorResponse(w, organizationErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "project fetched successfully", project)
+	case http.MethodDelete:
+		if err := h.orgService.DeleteProject(projectID, requestActor(r, claims)); err != nil {
+			log.Printf("error deleting project: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "failed to delete project")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "project deleted successfully", nil)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or DELETE method is allowed")
+	}
+}
+
+// Keys lists (GET) or creates (POST) API keys of a project
+func (h *OrganizationHandler) Keys(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	projectID := r.PathValue("projectId")
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := h.orgService.ListProjectKeys(projectID)
+		if err != nil {
+			log.Printf("error listing project keys: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "failed to list keys")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "keys fetched successfully", keys)
+	case http.MethodPost:
+		var req model.CreateProjectKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding key request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		key, err := h.orgService.CreateProjectKey(projectID, requestActor(r, claims), req)
+		if err != nil {
+			log.Printf("error creating project key: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "key created successfully, store it now: it is not shown again", key)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// RotateKey replaces the secret of a project API key
+func (h *OrganizationHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	keyID, ok := pathID(w, r, "keyId")
+	if !ok {
+		return
+	}
+
+	key, err := h.orgService.RotateProjectKey(r.PathValue("projectId"), keyID, requestActor(r, claims))
+	if err != nil {
+		log.Printf("error rotating project key: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "key rotated successfully, store it now: it is not shown again", key)
+}
+
+// RevokeKey revokes (DELETE) a project API key
+func (h *OrganizationHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only DELETE method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	keyID, ok := pathID(w, r, "keyId")
+	if !ok {
+		return
+	}
+
+	if err := h.orgService.RevokeProjectKey(r.PathValue("projectId"), keyID, requestActor(r, claims)); err != nil {
+		log.Printf("error revoking project key: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "key revoked successfully", nil)
+}
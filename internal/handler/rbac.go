@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+	"prothomuse-server/internal/utils"
+)
+
+type contextKey string
+
+const (
+	claimsContextKey contextKey = "claims"
+	roleContextKey   contextKey = "role"
+)
+
+var errNoProject = errors.New("projectId is required")
+
+// ProjectResolver finds the project a request acts on
+type ProjectResolver func(r *http.Request) (string, error)
+
+// FromPath resolves the project from a path value such as {projectId}
+func FromPath(name string) ProjectResolver {
+	return func(r *http.Request) (string, error) {
+		return r.PathValue(name), nil
+	}
+}
+
+// FromRequest resolves the project from the projectId query parameter of reads
+// and from the projectId field of the JSON body of writes, which is what the
+// handler will act on. The body is restored for the handler.
+func FromRequest(r *http.Request) (string, error) {
+	if isRead(r) {
+		return r.URL.Query().Get("projectId"), nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	var req struct {
+		ProjectID string `json:"projectId"`
+	}
+	// An invalid body is left for the handler to report
+	json.Unmarshal(body, &req)
+	return req.ProjectID, nil
+}
+
+// ByID resolves the project of the resource named by the {id} path value
+func ByID[T any](get func(id int) (*T, error), projectID func(resource *T) string) ProjectResolver {
+	return func(r *http.Request) (string, error) {
+		id, err := parseID(r.PathValue("id"))
+		if err != nil {
+			return "", err
+		}
+		resource, err := get(id)
+		if err != nil {
+			return "", err
+		}
+		return projectID(resource), nil
+	}
+}
+
+// Authorizer is the RBAC middleware. Roles are looked up on every request, so a
+// role change or removal from an organization applies to existing tokens at once.
+type Authorizer struct {
+	orgService   *services.OrganizationService
+	adminService *services.AdminService
+}
+
+func NewAuthorizer(orgService *services.OrganizationService, adminService *services.AdminService) *Authorizer {
+	return &Authorizer{orgService: orgService, adminService: adminService}
+}
+
+// Authenticated only requires a valid access token
+func (a *Authorizer) Authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := requireJWT(w, r)
+		if claims == nil {
+			return
+		}
+		next(w, withClaims(r, claims, ""))
+	}
+}
+
+// SystemAdmin requires a system administrator, whatever the method
+func (a *Authorizer) SystemAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := requireJWT(w, r)
+		if claims == nil {
+			return
+		}
+		admin, err := a.adminService.IsAdmin(claims.UserID)
+		if err != nil {
+			log.Printf("error checking system admin: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if !admin {
+			sendErrorResponse(w, http.StatusForbidden, services.ErrNotAdmin.Error())
+			return
+		}
+		next(w, withClaims(r, claims, ""))
+	}
+}
+
+// Project requires readRole in the organization owning the project for GET and
+// HEAD requests, and writeRole for every other method
+func (a *Authorizer) Project(resolve ProjectResolver, readRole string, writeRole string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := requireJWT(w, r)
+		if claims == nil {
+			return
+		}
+		projectID, err := resolve(r)
+		if err != nil {
+			sendErrorResponse(w, http.StatusNotFound, "not found")
+			return
+		}
+		if projectID == "" {
+			sendErrorResponse(w, http.StatusBadRequest, errNoProject.Error())
+			return
+		}
+		role, err := a.orgService.ProjectRole(projectID, claims.UserID)
+		if err != nil {
+			log.Printf("error checking project role: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if !authorize(w, r, role, readRole, writeRole, "you do not have access to this project") {
+			return
+		}
+		next(w, withClaims(r, claims, role))
+	}
+}
+
+// Organization is Project for the {orgId} path value
+func (a *Authorizer) Organization(readRole string, writeRole string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := requireJWT(w, r)
+		if claims == nil {
+			return
+		}
+		orgID, ok := pathID(w, r, "orgId")
+		if !ok {
+			return
+		}
+		role, err := a.orgService.OrganizationRole(orgID, claims.UserID)
+		if err != nil {
+			log.Printf("error checking organization role: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if !authorize(w, r, role, readRole, writeRole, services.ErrNotMember.Error()) {
+			return
+		}
+		next(w, withClaims(r, claims, role))
+	}
+}
+
+// authorize writes a 403 response and returns false when role is below the one the method needs
+func authorize(w http.ResponseWriter, r *http.Request, role string, readRole string, writeRole string, noAccess string) bool {
+	if role == "" {
+		sendErrorResponse(w, http.StatusForbidden, noAccess)
+		return false
+	}
+	required := writeRole
+	if isRead(r) {
+		required = readRole
+	}
+	if !model.RoleAtLeast(role, required) {
+		sendErrorResponse(w, http.StatusForbidden, "this action requires the "+required+" role")
+		return false
+	}
+	return true
+}
+
+func isRead(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+func withClaims(r *http.Request, claims *utils.Claims, role string) *http.Request {
+	ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+	ctx = context.WithValue(ctx, roleContextKey, role)
+	return r.WithContext(ctx)
+}
+
+// RequestClaims returns the claims of a request that went through the Authorizer, or nil
+func RequestClaims(r *http.Request) *utils.Claims {
+	claims, _ := r.Context().Value(claimsContextKey).(*utils.Claims)
+	return claims
+}
+
+// requestRole returns the role the Authorizer found for the request
+func requestRole(r *http.Request) string {
+	role, _ := r.Context().Value(roleContextKey).(string)
+	return role
+}
@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/services"
+	"prothomuse-server/internal/utils"
+)
+
+// fakeOrganizationRepository knows which organization owns each project and the
+// role of each member
+type fakeOrganizationRepository struct {
+	repository.OrganizationRepository
+	projects map[string]int
+	members  map[int]map[int]string
+}
+
+func (r *fakeOrganizationRepository) GetMemberRole(orgID int, userID int) (string, error) {
+	return r.members[orgID][userID], nil
+}
+
+func (r *fakeOrganizationRepository) GetProject(id string) (*model.Project, error) {
+	orgID, ok := r.projects[id]
+	if !ok {
+		return nil, nil
+	}
+	return &model.Project{ID: id, OrganizationID: orgID}, nil
+}
+
+func (r *fakeOrganizationRepository) GetProjectRole(projectID string, userID int) (string, error) {
+	orgID, ok := r.projects[projectID]
+	if !ok {
+		return "", nil
+	}
+	return r.members[orgID][userID], nil
+}
+
+type fakeProjectKeyRepository struct {
+	repository.ProjectKeyRepository
+	keys []model.ProjectKey
+}
+
+func (r *fakeProjectKeyRepository) GetKey(id int) (*model.ProjectKey, error) {
+	if id < 1 || id > len(r.keys) {
+		return nil, errors.New("project key not found")
+	}
+	key := r.keys[id-1]
+	return &key, nil
+}
+
+func (r *fakeProjectKeyRepository) RevokeKey(id int, at time.Time) error {
+	r.keys[id-1].RevokedAt = &at
+	return nil
+}
+
+// fakeUserRepository knows which users are system administrators
+type fakeUserRepository struct {
+	repository.UserRepository
+	users map[int]model.User
+}
+
+func (r *fakeUserRepository) GetUserByID(id int) (*model.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	return &user, nil
+}
+
+// fakeAuditRepository drops audit events
+type fakeAuditRepository struct {
+	repository.AuditRepository
+}
+
+func (r *fakeAuditRepository) CreateEvent(event *model.AuditEvent) error {
+	return nil
+}
+
+// Users of the RBAC tests: the shop project belongs to organization 1 and the
+// blog project to organization 2
+const (
+	shopOwner  = 1
+	shopViewer = 2
+	shopMember = 3
+	blogAdmin  = 4
+	sysAdmin   = 5
+)
+
+// newTestRBACServer routes a few project endpoints the way main does. The
+// endpoints answer with the role the Authorizer found for the request.
+func newTestRBACServer() (*http.ServeMux, *fakeProjectKeyRepository) {
+	orgRepo := &fakeOrganizationRepository{
+		projects: map[string]int{"shop": 1, "blog": 2},
+		members: map[int]map[int]string{
+			1: {shopOwner: model.RoleOwner, shopViewer: model.RoleViewer, shopMember: model.RoleMember},
+			2: {blogAdmin: model.RoleAdmin},
+		},
+	}
+	keyRepo := &fakeProjectKeyRepository{keys: []model.ProjectKey{{ID: 1, ProjectID: "shop", Name: "ingest"}}}
+	orgService := services.NewOrganizationService(orgRepo, keyRepo, nil, services.NewAuditService(&fakeAuditRepository{}, orgRepo))
+	userRepo := &fakeUserRepository{users: map[int]model.User{
+		shopOwner: {ID: shopOwner, IsActive: true},
+		sysAdmin:  {ID: sysAdmin, IsAdmin: true, IsActive: true},
+	}}
+	authz := NewAuthorizer(orgService, services.NewAdminService(userRepo, keyRepo, nil, nil))
+
+	alerts := map[int]*model.Alert{1: {ID: 1, ProjectID: "shop"}}
+	getAlert := func(id int) (*model.Alert, error) {
+		if alert, ok := alerts[id]; ok {
+			return alert, nil
+		}
+		return nil, errors.New("alert not found")
+	}
+	role := func(w http.ResponseWriter, r *http.Request) {
+		if !isRead(r) {
+			// the body read by FromRequest is still there for the handler
+			var req struct {
+				ProjectID string `json:"projectId"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+		}
+		sendSuccessResponse(w, http.StatusOK, requestRole(r), nil)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/projects/{projectId}/notes", authz.Project(FromPath("projectId"), model.RoleViewer, model.RoleMember, role))
+	mux.HandleFunc("/api/alerts", authz.Project(FromRequest, model.RoleViewer, model.RoleMember, role))
+	mux.HandleFunc("/api/alerts/{id}", authz.Project(ByID(getAlert, func(a *model.Alert) string { return a.ProjectID }), model.RoleViewer, model.RoleMember, role))
+	mux.HandleFunc("/api/admin/users", authz.SystemAdmin(role))
+	mux.HandleFunc("/api/projects/{projectId}/keys/{keyId}", authz.Project(FromPath("projectId"), model.RoleAdmin, model.RoleAdmin, NewOrganizationHandler(orgService).RevokeKey))
+	return mux, keyRepo
+}
+
+// serve sends a request as userID, or without a token when userID is 0
+func serve(t *testing.T, mux *http.ServeMux, userID int, method string, target string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if userID != 0 {
+		token, err := utils.GenerateJWT(userID, "user@example.com", 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthorizerProject(t *testing.T) {
+	mux, _ := newTestRBACServer()
+	tests := []struct {
+		scenario   string
+		userID     int
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantRole   string
+	}{
+		{"no token", 0, "GET", "/api/projects/shop/notes", "", http.StatusUnauthorized, ""},
+		{"viewer reads", shopViewer, "GET", "/api/projects/shop/notes", "", http.StatusOK, model.RoleViewer},
+		{"viewer writes", shopViewer, "POST", "/api/projects/shop/notes", `{}`, http.StatusForbidden, ""},
+		{"member writes", shopMember, "POST", "/api/projects/shop/notes", `{}`, http.StatusOK, model.RoleMember},
+		{"non-member reads by path", blogAdmin, "GET", "/api/projects/shop/notes", "", http.StatusForbidden, ""},
+		{"non-member reads by query", blogAdmin, "GET", "/api/alerts?projectId=shop", "", http.StatusForbidden, ""},
+		{"non-member writes by body", blogAdmin, "POST", "/api/alerts", `{"projectId":"shop"}`, http.StatusForbidden, ""},
+		{"non-member reads a resource", blogAdmin, "GET", "/api/alerts/1", "", http.StatusForbidden, ""},
+		{"member reads a resource", shopViewer, "GET", "/api/alerts/1", "", http.StatusOK, model.RoleViewer},
+		{"member writes by body", shopMember, "POST", "/api/alerts", `{"projectId":"shop"}`, http.StatusOK, model.RoleMember},
+		{"viewer writes by body", shopViewer, "POST", "/api/alerts", `{"projectId":"shop"}`, http.StatusForbidden, ""},
+		{"unknown resource", shopOwner, "GET", "/api/alerts/2", "", http.StatusNotFound, ""},
+		{"unregistered project", shopOwner, "GET", "/api/projects/unclaimed/notes", "", http.StatusForbidden, ""},
+		{"no project", shopOwner, "GET", "/api/alerts", "", http.StatusBadRequest, ""},
+		{"project in the query of a write", shopMember, "POST", "/api/alerts?projectId=shop", `{"projectId":"blog"}`, http.StatusForbidden, ""},
+		{"system admin", sysAdmin, "GET", "/api/admin/users", "", http.StatusOK, ""},
+		{"organization owner on the admin API", shopOwner, "GET", "/api/admin/users", "", http.StatusForbidden, ""},
+		{"system admin outside their organizations", sysAdmin, "GET", "/api/projects/shop/notes", "", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		w := serve(t, mux, tt.userID, tt.method, tt.target, tt.body)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d: %s", tt.scenario, w.Code, tt.wantStatus, w.Body)
+			continue
+		}
+		var resp struct {
+			Message string `json:"message"`
+		}
+		if tt.wantRole != "" && (json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.Message != tt.wantRole) {
+			t.Errorf("%s: handler saw role %q, want %s", tt.scenario, resp.Message, tt.wantRole)
+		}
+	}
+}
+
+func TestProjectKeyOfAnotherProject(t *testing.T) {
+	mux, keyRepo := newTestRBACServer()
+
+	// an admin of blog cannot reach the key of shop through the blog project
+	if w := serve(t, mux, blogAdmin, "DELETE", "/api/projects/blog/keys/1", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("revoking the key of another project: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := serve(t, mux, blogAdmin, "DELETE", "/api/projects/shop/keys/1", ""); w.Code != http.StatusForbidden {
+		t.Errorf("revoking a key as a non-member: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := serve(t, mux, shopMember, "DELETE", "/api/projects/shop/keys/1", ""); w.Code != http.StatusForbidden {
+		t.Errorf("revoking a key as a member: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if keyRepo.keys[0].RevokedAt != nil {
+		t.Fatal("the key was revoked")
+	}
+	if w := serve(t, mux, shopOwner, "DELETE", "/api/projects/shop/keys/1", ""); w.Code != http.StatusOK {
+		t.Errorf("revoking a key as the owner: status %d: %s", w.Code, w.Body)
+	}
+	if keyRepo.keys[0].RevokedAt == nil {
+		t.Error("the owner could not revoke the key")
+	}
+}
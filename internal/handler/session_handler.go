@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"prothomuse-server/internal/services"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+}
+
+// NewSessionHandler creates a new instance of SessionHandler
+func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+// Sessions lists (GET) the caller's signed-in devices or signs out (DELETE)
+// every one of them except the current session
+func (h *SessionHandler) Sessions(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sessions, err := h.sessionService.List(claims.UserID, claims.SessionID)
+		if err != nil {
+			log.Printf("error listing sessions: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "failed to list sessions")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "sessions fetched successfully", sessions)
+	case http.MethodDelete:
+		revoked, err := h.sessionService.RevokeOthers(requestActor(r, claims), claims.SessionID)
+		if err != nil {
+			log.Printf("error revoking sessions: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "failed to revoke sessions")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "other sessions revoked successfully", map[string]int{"revoked": revoked})
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or DELETE method is allowed")
+	}
+}
+
+// Session signs out (DELETE) one of the caller's sessions, possibly the current one
+func (h *SessionHandler) Session(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only DELETE method is allowed")
+		return
+	}
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.sessionService.Revoke(requestActor(r, claims), id); err != nil {
+		log.Printf("error revoking session: %v", err)
+		if errors.Is(err, services.ErrSessionNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "session revoked successfully", nil)
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type SilenceHandler struct {
+	silenceService *services.SilenceService
+}
+
+// NewSilenceHandler creates a new instance of SilenceHandler
+func NewSilenceHandler(silenceService *services.SilenceService) *SilenceHandler {
+	return &SilenceHandler{
+		silenceService: silenceService,
+	}
+}
+
+// Silences lists (GET ?projectId=&active=true) or creates (POST) silences
+func (h *SilenceHandler) Silences(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		silences, err := h.silenceService.ListSilences(r.URL.Query().Get("projectId"), r.URL.Query().Get("active") == "true")
+		if err != nil {
+			log.Printf("error listing silences: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "silences fetched successfully", silences)
+	case http.MethodPost:
+		var req model.CreateSilenceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding silence request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		silence, err := h.silenceService.CreateSilence(claims.UserID, req)
+		if err != nil {
+			log.Printf("error creating silence: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "silence created successfully", silence)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// ExpireSilence ends a silence immediately
+func (h *SilenceHandler) ExpireSilence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only DELETE method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.silenceService.ExpireSilence(id); err != nil {
+		log.Printf("error expiring silence: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "could not expire silence")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "silence expired successfully", nil)
+}
+
+// MaintenanceWindows lists (GET ?projectId=) or creates (POST) recurring maintenance windows
+func (h *SilenceHandler) MaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		windows, err := h.silenceService.ListMaintenanceWindows(r.URL.Query().Get("projectId"))
+		if err != nil {
+			log.Printf("error listing maintenance windows: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "maintenance windows fetched successfully", windows)
+	case http.MethodPost:
+		var req model.CreateMaintenanceWindowRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding maintenance window request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		window, err := h.silenceService.CreateMaintenanceWindow(claims.UserID, req)
+		if err != nil {
+			log.Printf("error creating maintenance window: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "maintenance window created successfully", window)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// DeleteMaintenanceWindow removes a maintenance window
+func (h *SilenceHandler) DeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only DELETE method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.silenceService.DeleteMaintenanceWindow(id); err != nil {
+		log.Printf("error deleting maintenance window: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "could not delete maintenance window")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "maintenance window deleted successfully", nil)
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type SLOHandler struct {
+	sloService *services.SLOService
+}
+
+// NewSLOHandler creates a new instance of SLOHandler
+func NewSLOHandler(sloService *services.SLOService) *SLOHandler {
+	return &SLOHandler{
+		sloService: sloService,
+	}
+}
+
+// SLOs lists (GET ?projectId=) or creates (POST) SLOs
+func (h *SLOHandler) SLOs(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		slos, err := h.sloService.ListSLOs(r.URL.Query().Get("projectId"))
+		if err != nil {
+			log.Printf("error listing SLOs: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "SLOs fetched successfully", slos)
+	case http.MethodPost:
+		var req model.CreateSLORequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding SLO request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		slo, err := h.sloService.CreateSLO(claims.UserID, req)
+		if err != nil {
+			log.Printf("error creating SLO: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "SLO created successfully", slo)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// SLO returns the current status (GET) of an SLO or deletes it (DELETE)
+func (h *SLOHandler) SLO(w http.ResponseWriter, r *http.Request) {
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		slo, err := h.sloService.GetSLO(id)
+		if err != nil {
+			sendErrorResponse(w, http.StatusNotFound, "SLO not found")
+			return
+		}
+		status, err := h.sloService.Status(slo, time.Now())
+		if err != nil {
+			log.Printf("error computing SLO status: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "could not compute SLO status")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "SLO status fetched successfully", status)
+	case http.MethodDelete:
+		if err := h.sloService.DeleteSLO(id); err != nil {
+			log.Printf("error deleting SLO: %v", err)
+			sendErrorResponse(w, http.StatusNotFound, "SLO not found")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "SLO deleted successfully", nil)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or DELETE method is allowed")
+	}
+}
@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type StatusPageHandler struct {
+	statusService *services.StatusPageService
+}
+
+// NewStatusPageHandler creates a new instance of StatusPageHandler
+func NewStatusPageHandler(statusService *services.StatusPageService) *StatusPageHandler {
+	return &StatusPageHandler{
+		statusService: statusService,
+	}
+}
+
+// Pages lists (GET ?projectId=) or creates (POST) status pages
+func (h *StatusPageHandler) Pages(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		pages, err := h.statusService.ListPages(r.URL.Query().Get("projectId"))
+		if err != nil {
+			log.Printf("error listing status pages: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "status pages fetched successfully", pages)
+	case http.MethodPost:
+		var req model.SaveStatusPageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding status page request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		page, err := h.statusService.CreatePage(claims.UserID, req)
+		if err != nil {
+			log.Printf("error creating status page: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "status page created successfully", page)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// Page returns (GET), updates (PUT) or deletes (DELETE) a status page
+func (h *StatusPageHandler) Page(w http.ResponseWriter, r *http.Request) {
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		page, err := h.statusService.GetPage(id)
+		if err != nil {
+			sendErrorResponse(w, http.StatusNotFound, "status page not found")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "status page fetched successfully", page)
+	case http.MethodPut:
+		var req model.SaveStatusPageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding status page request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		page, err := h.statusService.UpdatePage(id, req)
+		if err != nil {
+			log.Printf("error updating status page: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "status page updated successfully", page)
+	case http.MethodDelete:
+		if err := h.statusService.DeletePage(id); err != nil {
+			log.Printf("error deleting status page: %v", err)
+			sendErrorResponse(w, http.StatusNotFound, "status page not found")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "status page deleted successfully", nil)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET, PUT or DELETE method is allowed")
+	}
+}
+
+// Components lists (GET) or adds (POST) the components of a status page
+func (h *StatusPageHandler) Components(w http.ResponseWriter, r *http.Request) {
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		components, err := h.statusService.ListComponents(id)
+		if err != nil {
+			log.Printf("error listing status components: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "could not fetch status components")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "status components fetched successfully", components)
+	case http.MethodPost:
+		var req model.CreateStatusComponentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding status component request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		component, err := h.statusService.AddComponent(id, req)
+		if err != nil {
+			log.Printf("error adding status component: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "status component added successfully", component)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// Component removes (DELETE) a component from a status page
+func (h *StatusPageHandler) Component(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only DELETE method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	componentID, ok := pathID(w, r, "componentId")
+	if !ok {
+		return
+	}
+
+	if err := h.statusService.RemoveComponent(id, componentID); err != nil {
+		log.Printf("error removing status component: %v", err)
+		sendErrorResponse(w, http.StatusNotFound, "status component not found")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "status component removed successfully", nil)
+}
+
+// Messages lists (GET) or posts (POST) manual status messages of a page
+func (h *StatusPageHandler) Messages(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		messages, err := h.statusService.ListMessages(id)
+		if err != nil {
+			log.Printf("error listing status messages: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "could not fetch status messages")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "status messages fetched successfully", messages)
+	case http.MethodPost:
+		var req model.CreateStatusMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding status message request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		message, err := h.statusService.PostMessage(id, claims.UserID, req)
+		if err != nil {
+			log.Printf("error posting status message: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "status message posted successfully", message)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// ResolveMessage marks a manual status message as resolved (POST)
+func (h *StatusPageHandler) ResolveMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	messageID, ok := pathID(w, r, "messageId")
+	if !ok {
+		return
+	}
+
+	if err := h.statusService.ResolveMessage(id, messageID); err != nil {
+		log.Printf("error resolving status message: %v", err)
+		sendErrorResponse(w, http.StatusNotFound, "status message not found")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "status message resolved successfully", nil)
+}
+
+// PublicJSON serves the public view of a status page as JSON. No auth is required.
+func (h *StatusPageHandler) PublicJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	page, err := h.statusService.PublicPage(r.PathValue("slug"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "status page not found")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	sendSuccessResponse(w, http.StatusOK, "status page fetched successfully", page)
+}
+
+// Public serves the rendered status page, or its JSON when asked for with
+// ?format=json or an Accept: application/json header. No auth is required.
+func (h *StatusPageHandler) Public(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		h.PublicJSON(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	page, err := h.statusService.PublicPage(r.PathValue("slug"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	if err := statusPageTemplate.Execute(w, page); err != nil {
+		log.Printf("error rendering status page: %v", err)
+	}
+}
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"label": func(status string) string {
+		switch status {
+		case model.StatusOperational:
+			return "Operational"
+		case model.StatusMaintenance:
+			return "Under maintenance"
+		case model.StatusDegraded:
+			return "Degraded performance"
+		case model.StatusPartialOutage:
+			return "Partial outage"
+		case model.StatusMajorOutage:
+			return "Major outage"
+		}
+		return "No data"
+	},
+	"percent": func(p *float64) string {
+		if p == nil {
+			return "no data"
+		}
+		return strconv.FormatFloat(*p, 'f', -1, 64) + "%"
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} status</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 820px; margin: 2rem auto; padding: 0 1rem; color: #1f2933; }
+h1 { margin-bottom: .25rem; }
+.banner { padding: 1rem; border-radius: 6px; color: #fff; font-weight: 600; margin: 1.5rem 0; }
+.component { border: 1px solid #e4e7eb; border-radius: 6px; padding: 1rem; margin-bottom: 1rem; }
+.row { display: flex; justify-content: space-between; align-items: baseline; }
+.bars { display: flex; gap: 2px; margin: .75rem 0 .25rem; }
+.bars span { flex: 1; height: 32px; border-radius: 2px; }
+.muted { color: #7b8794; font-size: .85rem; }
+.operational { background: #2f9e44; } .maintenance { background: #1c7ed6; } .degraded { background: #f59f00; }
+.partial_outage { background: #f76707; } .major_outage { background: #e03131; } .no_data { background: #d9dde1; }
+.text-operational { color: #2f9e44; } .text-maintenance { color: #1c7ed6; } .text-degraded { color: #f59f00; }
+.text-partial_outage { color: #f76707; } .text-major_outage { color: #e03131; }
+.message { border-left: 4px solid #d9dde1; padding: .25rem 1rem; margin-bottom: 1rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Description}}<p class="muted">{{.Description}}</p>{{end}}
+<div class="banner {{.Status}}">{{if eq .Status "operational"}}All systems operational{{else}}{{label .Status}}{{end}}</div>
+
+{{if .Incidents}}<h2>Current incidents</h2>
+{{range .Incidents}}<div class="message"><strong class="text-{{.Status}}">{{label .Status}}</strong> on {{.Component}} &middot; {{.Stage}}<br>
+<span class="muted">Since {{.StartedAt.Format "Jan 2, 15:04 MST"}}</span></div>
+{{end}}{{end}}
+
+{{if .Messages}}<h2>Announcements</h2>
+{{range .Messages}}<div class="message"><strong>{{.Title}}</strong> <span class="text-{{.Status}}">{{label .Status}}</span>
+{{if .Body}}<p>{{.Body}}</p>{{end}}
+<span class="muted">Posted {{.CreatedAt.Format "Jan 2, 15:04 MST"}}{{if .ResolvedAt}} &middot; resolved {{.ResolvedAt.Format "Jan 2, 15:04 MST"}}{{end}}</span></div>
+{{end}}{{end}}
+
+<h2>Components</h2>
+{{range .Components}}<div class="component">
+<div class="row"><strong>{{.Name}}</strong><span class="text-{{.Status}}">{{label .Status}}</span></div>
+{{if .Description}}<div class="muted">{{.Description}}</div>{{end}}
+<div class="bars">{{range .Days}}<span class="{{.Status}}" title="{{.Date}}: {{percent .UptimePercent}}"></span>{{end}}</div>
+<div class="row muted"><span>90 days ago</span><span>{{percent .UptimePercent}} uptime</span><span>Today</span></div>
+</div>
+{{else}}<p class="muted">No components yet.</p>{{end}}
+
+<p class="muted">Updated {{.GeneratedAt.Format "Jan 2, 2006 15:04 MST"}}</p>
+</body>
+</html>
+`))
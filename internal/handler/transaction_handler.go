@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type TransactionHandler struct {
+	transactionService *services.TransactionService
+}
+
+// NewTransactionHandler creates a new instance of TransactionHandler
+func NewTransactionHandler(transactionService *services.TransactionService) *TransactionHandler {
+	return &TransactionHandler{
+		transactionService: transactionService,
+	}
+}
+
+// Checks lists (GET ?projectId=) or creates (POST) transaction checks
+func (h *TransactionHandler) Checks(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		checks, err := h.transactionService.ListChecks(r.URL.Query().Get("projectId"))
+		if err != nil {
+			log.Printf("error listing transaction checks: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "transaction checks fetched successfully", checks)
+	case http.MethodPost:
+		var req model.CreateTransactionCheckRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding transaction check request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		check, err := h.transactionService.CreateCheck(claims.UserID, req)
+		if err != nil {
+			log.Printf("error creating transaction check: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "transaction check created successfully", check)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// Check returns (GET) or deletes (DELETE) an transaction check
+func (h *TransactionHandler) Check(w http.ResponseWriter, r *http.Request) {
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		check, err := h.transactionService.GetCheck(id)
+		if err != nil {
+			sendErrorResponse(w, http.StatusNotFound, "transaction check not found")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "transaction check fetched successfully", check)
+	case http.MethodDelete:
+		if err := h.transactionService.DeleteCheck(id); err != nil {
+			log.Printf("error deleting transaction check: %v", err)
+			sendErrorResponse(w, http.StatusNotFound, "transaction check not found")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "transaction check deleted successfully", nil)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or DELETE method is allowed")
+	}
+}
+
+// Runs returns the latest runs of a transaction check with per-step timings.
+// Query parameters: limit (optional, default 50)
+func (h *TransactionHandler) Runs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	runs, err := h.transactionService.ListRuns(id, limit)
+	if err != nil {
+		log.Printf("error listing transaction runs: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "could not fetch transaction runs")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "transaction runs fetched successfully", runs)
+}
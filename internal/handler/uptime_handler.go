@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/services"
+)
+
+type UptimeHandler struct {
+	uptimeService *services.UptimeService
+}
+
+// NewUptimeHandler creates a new instance of UptimeHandler
+func NewUptimeHandler(uptimeService *services.UptimeService) *UptimeHandler {
+	return &UptimeHandler{
+		uptimeService: uptimeService,
+	}
+}
+
+// Checks lists (GET ?projectId=) or creates (POST) uptime checks
+func (h *UptimeHandler) Checks(w http.ResponseWriter, r *http.Request) {
+	claims := requireJWT(w, r)
+	if claims == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		checks, err := h.uptimeService.ListChecks(r.URL.Query().Get("projectId"))
+		if err != nil {
+			log.Printf("error listing uptime checks: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "uptime checks fetched successfully", checks)
+	case http.MethodPost:
+		var req model.CreateUptimeCheckRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("error decoding uptime check request: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		defer r.Body.Close()
+
+		check, err := h.uptimeService.CreateCheck(claims.UserID, req)
+		if err != nil {
+			log.Printf("error creating uptime check: %v", err)
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sendSuccessResponse(w, http.StatusCreated, "uptime check created successfully", check)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or POST method is allowed")
+	}
+}
+
+// Check returns (GET) or deletes (DELETE) an uptime check
+func (h *UptimeHandler) Check(w http.ResponseWriter, r *http.Request) {
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		check, err := h.uptimeService.GetCheck(id)
+		if err != nil {
+			sendErrorResponse(w, http.StatusNotFound, "uptime check not found")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "uptime check fetched successfully", check)
+	case http.MethodDelete:
+		if err := h.uptimeService.DeleteCheck(id); err != nil {
+			log.Printf("error deleting uptime check: %v", err)
+			sendErrorResponse(w, http.StatusNotFound, "uptime check not found")
+			return
+		}
+		sendSuccessResponse(w, http.StatusOK, "uptime check deleted successfully", nil)
+	default:
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET or DELETE method is allowed")
+	}
+}
+
+// Results returns the latest results of an uptime check.
+// Query parameters: limit (optional, default 100)
+func (h *UptimeHandler) Results(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	results, err := h.uptimeService.ListResults(id, limit)
+	if err != nil {
+		log.Printf("error listing check results: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "could not fetch check results")
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "check results fetched successfully", results)
+}
+
+// Certificates lists the TLS certificates seen by a project's HTTPS checks, soonest expiry first.
+// Query parameters: projectId (required), withinDays (optional)
+func (h *UptimeHandler) Certificates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+	if requireJWT(w, r) == nil {
+		return
+	}
+
+	withinDays, _ := strconv.Atoi(r.URL.Query().Get("withinDays"))
+	certs, err := h.uptimeService.ListCertificates(r.URL.Query().Get("projectId"), withinDays)
+	if err != nil {
+		log.Printf("error listing certificates: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendSuccessResponse(w, http.StatusOK, "certificates fetched successfully", certs)
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+)
+
+// DeleteAccountRequest starts an account deletion. Password is required unless
+// the account has none (users provisioned by SSO).
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type ConfirmAccountDeletionRequest struct {
+	Token string `json:"token"`
+}
+
+// AccountDeletionPlan tells what deleting an account does to its organizations.
+// Organizations whose only member is the user are deleted with their projects,
+// project keys and metrics; the user leaves the others, which keep their projects.
+type AccountDeletionPlan struct {
+	ScheduledFor          *time.Time     `json:"scheduledFor,omitempty"`
+	DeletedOrganizations  []Organization `json:"deletedOrganizations"`
+	LeftOrganizations     []Organization `json:"leftOrganizations"`
+	BlockingOrganizations []Organization `json:"blockingOrganizations"` // the user is the only owner and there are other members
+}
+
+// DeletedAccount reports what a completed account deletion removed
+type DeletedAccount struct {
+	OrganizationIDs []int    `json:"organizationIds"`
+	ProjectIDs      []string `json:"projectIds"`
+	Metrics         int64    `json:"metrics"`
+}
+
+// AccountSettings is the settings part of a personal data export
+type AccountSettings struct {
+	EmailVerified       bool           `json:"emailVerified"`
+	MFAEnabled          bool           `json:"mfaEnabled"`
+	Identities          []UserIdentity `json:"identities"`
+	Sessions            []Session      `json:"sessions"`
+	DeletionScheduledAt *time.Time     `json:"deletionScheduledAt,omitempty"`
+}
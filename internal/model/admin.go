@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+)
+
+// UserFilter selects users for the admin API; zero fields match everything
+type UserFilter struct {
+	Query    string `json:"query,omitempty"` // part of the username or email
+	Active   *bool  `json:"active,omitempty"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize"`
+}
+
+type UserPage struct {
+	Users    []User `json:"users"`
+	Total    int    `json:"total"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize"`
+}
+
+// UserUsage summarises what a user has and does on Prothomuse
+type UserUsage struct {
+	Organizations     int        `json:"organizations"`
+	ProjectsCreated   int        `json:"projectsCreated"`
+	ActiveProjectKeys int        `json:"activeProjectKeys"` // created by the user and not revoked
+	ActiveSessions    int        `json:"activeSessions"`
+	Metrics30Days     int64      `json:"metrics30Days"` // ingested into projects the user created
+	LastSeenAt        *time.Time `json:"lastSeenAt,omitempty"`
+}
+
+// AdminUser is a user with their usage, as shown to administrators
+type AdminUser struct {
+	User  *User      `json:"user"`
+	Usage *UserUsage `json:"usage"`
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+const (
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// Alert is a single firing (or resolved) instance of an alert condition.
+// An alert is identified by its labels: project, route and rule.
+type Alert struct {
+	ID         int        `json:"id"`
+	ProjectID  string     `json:"projectId"`
+	Route      string     `json:"route"`
+	Rule       string     `json:"rule"`
+	Severity   string     `json:"severity"`
+	State      string     `json:"state"`
+	Summary    string     `json:"summary"`
+	FiredAt    time.Time  `json:"firedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+
+	AcknowledgedBy *int       `json:"acknowledgedBy,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+}
+
+// AlertLabels identifies an alert condition. Route may be empty for project wide alerts.
+type AlertLabels struct {
+	ProjectID string `json:"projectId"`
+	Route     string `json:"route"`
+	Rule      string `json:"rule"`
+}
+
+func (a *Alert) Labels() AlertLabels {
+	return AlertLabels{ProjectID: a.ProjectID, Route: a.Route, Rule: a.Rule}
+}
+
+const (
+	AlertEventFired              = "fired"
+	AlertEventResolved           = "resolved"
+	AlertEventAcknowledged       = "acknowledged"
+	AlertEventUnacknowledged     = "unacknowledged"
+	AlertEventNotified           = "notified"
+	AlertEventNotificationFailed = "notification_failed"
+	AlertEventSuppressed         = "notification_suppressed"
+)
+
+// AlertHistoryEntry records one thing that happened to an alert.
+// UserID is set for transitions made by a person, such as acknowledgements.
+type AlertHistoryEntry struct {
+	ID        int       `json:"id"`
+	AlertID   int       `json:"alertId"`
+	Event     string    `json:"event"`
+	Detail    string    `json:"detail"`
+	UserID    *int      `json:"userId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IncidentNote is a free text note on a project's incident timeline, optionally about one alert.
+type IncidentNote struct {
+	ID        int       `json:"id"`
+	ProjectID string    `json:"projectId"`
+	AlertID   *int      `json:"alertId,omitempty"`
+	UserID    int       `json:"userId"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateIncidentNoteRequest struct {
+	AlertID *int   `json:"alertId,omitempty"`
+	Body    string `json:"body"`
+}
+
+const (
+	TimelineKindAlert = "alert"
+	TimelineKindNote  = "note"
+)
+
+// TimelineEntry is one line of a project's incident timeline. Alert entries carry
+// the alert history event (fired, acknowledged, ...); note entries carry the note body.
+type TimelineEntry struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Event   string    `json:"event,omitempty"`
+	AlertID *int      `json:"alertId,omitempty"`
+	Rule    string    `json:"rule,omitempty"`
+	Route   string    `json:"route,omitempty"`
+	UserID  *int      `json:"userId,omitempty"`
+	Text    string    `json:"text"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	AnomalyAlgorithmEWMA     = "ewma"
+	AnomalyAlgorithmSeasonal = "seasonal"
+)
+
+const (
+	AnomalySignalLatency        = "p95_latency"
+	AnomalySignalThroughputDrop = "throughput_drop"
+	AnomalySignalErrorSpike     = "error_spike"
+)
+
+// AnomalyDetector is the per project (and optionally per route) detector configuration.
+// An empty Route means the detector looks at all routes of the project together.
+type AnomalyDetector struct {
+	ID          int       `json:"id"`
+	ProjectID   string    `json:"projectId"`
+	Route       string    `json:"route"`
+	Algorithm   string    `json:"algorithm"`
+	Sensitivity float64   `json:"sensitivity"` // number of standard deviations that counts as anomalous
+	MinRequests int       `json:"minRequests"` // hours with fewer requests are not judged on latency or errors
+	Enabled     bool      `json:"enabled"`
+	CreatedBy   int       `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type CreateAnomalyDetectorRequest struct {
+	ProjectID   string  `json:"projectId"`
+	Route       string  `json:"route"`
+	Algorithm   string  `json:"algorithm"`
+	Sensitivity float64 `json:"sensitivity"`
+	MinRequests int     `json:"minRequests"`
+}
+
+// AnomalyEvent is recorded each time a detector flags an hour as anomalous.
+type AnomalyEvent struct {
+	ID          int       `json:"id"`
+	DetectorID  int       `json:"detectorId"`
+	ProjectID   string    `json:"projectId"`
+	Route       string    `json:"route"`
+	Signal      string    `json:"signal"`
+	Observed    float64   `json:"observed"`
+	Expected    float64   `json:"expected"`
+	Deviation   float64   `json:"deviation"` // z-score of the observed value against the baseline
+	WindowStart time.Time `json:"windowStart"`
+	DetectedAt  time.Time `json:"detectedAt"`
+}
+
+// MetricRollup aggregates the metrics of one hour.
+type MetricRollup struct {
+	BucketStart time.Time `json:"bucketStart"`
+	Requests    int64     `json:"requests"`
+	Errors      int64     `json:"errors"`
+	P95Latency  float64   `json:"p95Latency"`
+}
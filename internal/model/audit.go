@@ -0,0 +1,117 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit event actions
+const (
+	AuditLogin               = "auth.login"
+	AuditLoginFailed         = "auth.login_failed"
+	AuditRegister            = "auth.register"
+	AuditAccountLocked       = "auth.lockout"
+	AuditAccountUnlocked     = "auth.unlock"
+	AuditIdentityLinked      = "auth.sso_link"
+	AuditIdentityUnlinked    = "auth.sso_unlink"
+	AuditSessionRevoked      = "auth.session_revoke"
+	AuditUserUpdated         = "user.update"
+	AuditOrganizationCreated = "organization.create"
+	AuditOrganizationDeleted = "organization.delete"
+	AuditMemberAdded         = "member.add"
+	AuditMemberRoleChanged   = "member.update_role"
+	AuditMemberRemoved       = "member.remove"
+	AuditInvitationCreated   = "invitation.create"
+	AuditInvitationResent    = "invitation.resend"
+	AuditInvitationRevoked   = "invitation.revoke"
+	AuditInvitationAccepted  = "invitation.accept"
+	AuditProjectCreated      = "project.create"
+	AuditProjectDeleted      = "project.delete"
+	AuditKeyCreated          = "api_key.create"
+	AuditKeyRotated          = "api_key.rotate"
+	AuditKeyRevoked          = "api_key.revoke"
+	AuditAlertAcknowledged   = "alert.acknowledge"
+	AuditAlertUnacknowledged = "alert.unacknowledge"
+	AuditAdminUserSearched   = "admin.user_search"
+	AuditAdminUserViewed     = "admin.user_view"
+	AuditAdminUserDeactivate = "admin.user_deactivate"
+	AuditAdminUserReactivate = "admin.user_reactivate"
+	AuditAdminPasswordReset  = "admin.password_reset"
+	AuditAdminKeysRevoked    = "admin.keys_revoke"
+	AuditAdminAuditViewed    = "admin.audit_view"
+	AuditAccountExported     = "account.export"
+	AuditDeletionRequested   = "account.delete_request"
+	AuditDeletionScheduled   = "account.delete_schedule"
+	AuditDeletionCancelled   = "account.delete_cancel"
+	AuditAccountDeleted      = "account.delete"
+)
+
+// ClientInfo identifies where a request came from, for the audit log
+type ClientInfo struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+}
+
+// Actor is the signed-in user making a request and where it came from
+type Actor struct {
+	UserID int
+	ClientInfo
+}
+
+// AuditEvent is an entry of the security audit log
+type AuditEvent struct {
+	ID             int64           `json:"id"`
+	OrganizationID *int            `json:"organizationId,omitempty"`
+	ActorID        *int            `json:"actorId,omitempty"` // nil for anonymous requests and the system
+	Action         string          `json:"action"`
+	TargetType     string          `json:"targetType"`
+	TargetID       string          `json:"targetId"`
+	IP             string          `json:"ip"`
+	UserAgent      string          `json:"userAgent"`
+	Before         json.RawMessage `json:"before,omitempty"`
+	After          json.RawMessage `json:"after,omitempty"`
+	Metadata       json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+// AuditFilter selects audit events; zero fields match everything
+type AuditFilter struct {
+	Action     string
+	ActorID    int
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+	Page       int
+	PageSize   int
+	// only for the system-wide log: one organization, or the events outside
+	// any organization (sign-ins, account and admin actions)
+	OrganizationID      int
+	WithoutOrganization bool
+}
+
+type AuditPage struct {
+	Events   []AuditEvent `json:"events"`
+	Total    int          `json:"total"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"pageSize"`
+}
+
+// Login failure scopes
+const (
+	LoginScopeAccount = "account"
+	LoginScopeIP      = "ip"
+)
+
+// LoginFailure counts recent failed logins of an account (by email) or of an IP address
+type LoginFailure struct {
+	Scope         string
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+type UnlockAccountRequest struct {
+	Token string `json:"token"`
+}
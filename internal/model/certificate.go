@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+// Certificate is one certificate of the chain an HTTPS uptime check was served.
+// Position 0 is the leaf.
+type Certificate struct {
+	ID                int       `json:"id"`
+	CheckID           int       `json:"checkId"`
+	ProjectID         string    `json:"projectId"`
+	Host              string    `json:"host"`
+	Position          int       `json:"position"`
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SANs              []string  `json:"sans"`
+	SerialNumber      string    `json:"serialNumber"`
+	FingerprintSHA256 string    `json:"fingerprintSha256"`
+	NotBefore         time.Time `json:"notBefore"`
+	NotAfter          time.Time `json:"notAfter"`
+	DaysRemaining     int       `json:"daysRemaining"`
+	LastSeenAt        time.Time `json:"lastSeenAt"`
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	CronRunRunning = "running"
+	CronRunSuccess = "success"
+	CronRunFailed  = "fail"
+	CronRunMissed  = "missed"
+)
+
+// CronMonitor watches a batch job that reports itself through check-in pings to
+// /api/checkin/{token}. A run is expected at every time Schedule (a cron expression
+// in UTC) fires; it is missed when no ping arrives within GraceSeconds.
+type CronMonitor struct {
+	ID             int        `json:"id"`
+	ProjectID      string     `json:"projectId"`
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule"`
+	GraceSeconds   int        `json:"graceSeconds"`
+	Token          string     `json:"token"`
+	Enabled        bool       `json:"enabled"`
+	CreatedBy      int        `json:"createdBy"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	NextExpectedAt time.Time  `json:"nextExpectedAt"`
+	LastPingAt     *time.Time `json:"lastPingAt,omitempty"`
+	LastStatus     string     `json:"lastStatus,omitempty"`
+}
+
+type CreateCronMonitorRequest struct {
+	ProjectID    string `json:"projectId"`
+	Name         string `json:"name"`
+	Schedule     string `json:"schedule"`
+	GraceSeconds int    `json:"graceSeconds"`
+}
+
+// CronRun is one execution of a monitored job. ScheduledAt is the schedule slot the
+// run was matched to, if any; Late is set when it reported after the grace period.
+type CronRun struct {
+	ID          int        `json:"id"`
+	MonitorID   int        `json:"monitorId"`
+	Status      string     `json:"status"`
+	Late        bool       `json:"late"`
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	DurationMs  *int64     `json:"durationMs,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+)
+
+// Email kinds, recorded on outbox rows so they can be told apart when testing
+const (
+	EmailKindVerifyEmail   = "verify_email"
+	EmailKindResetPassword = "reset_password"
+	EmailKindInvitation    = "invitation"
+	EmailKindUnlockAccount = "unlock_account"
+	EmailKindDeleteAccount = "delete_account"
+)
+
+// OutboxEmail is an email waiting in, or delivered from, the email_outbox table.
+// Every email is written to the outbox first and delivered by the mail worker
+// when SMTP is configured, so mail can be inspected locally without a server.
+type OutboxEmail struct {
+	ID        int        `json:"id"`
+	Kind      string     `json:"kind"`
+	To        string     `json:"to"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"lastError,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	SentAt    *time.Time `json:"sentAt,omitempty"`
+}
+
+// Purposes of single-use user tokens
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+	TokenPurposeMFAChallenge  = "mfa_challenge"
+	TokenPurposeUnlockAccount = "unlock_account"
+	TokenPurposeDeleteAccount = "delete_account"
+)
+
+// UserToken is a single-use, expiring token mailed to a user. Only its hash is stored.
+type UserToken struct {
+	ID        int
+	UserID    int
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+)
+
+// HeartbeatConfig is the dead-man's switch of a project: when no metric arrives
+// for IntervalSeconds an alert fires, and it resolves once metrics flow again.
+type HeartbeatConfig struct {
+	ProjectID       string    `json:"projectId"`
+	IntervalSeconds int       `json:"intervalSeconds"`
+	Enabled         bool      `json:"enabled"`
+	UpdatedBy       int       `json:"updatedBy"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+type UpdateHeartbeatConfigRequest struct {
+	IntervalSeconds int   `json:"intervalSeconds"`
+	Enabled         *bool `json:"enabled,omitempty"`
+}
+
+// IngestionHeartbeat is the last time a metric was received from one middleware connection.
+type IngestionHeartbeat struct {
+	ProjectID    string    `json:"projectId"`
+	ConnectionID string    `json:"connectionId"`
+	LastSeenAt   time.Time `json:"lastSeenAt"`
+}
+
+type HeartbeatStatus struct {
+	Config      *HeartbeatConfig     `json:"config,omitempty"`
+	LastSeenAt  *time.Time           `json:"lastSeenAt,omitempty"`
+	Silent      bool                 `json:"silent"`
+	Connections []IngestionHeartbeat `json:"connections"`
+}
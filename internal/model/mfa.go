@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+)
+
+// UserMFA is the TOTP enrolment of a user. It is pending until confirmed with a first code.
+type UserMFA struct {
+	UserID      int
+	Secret      string
+	Enabled     bool
+	LastStep    int64 // last accepted TOTP time step, codes from it or before are replays
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+}
+
+// MFAEnrollment is returned once when TOTP enrolment starts
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauthUri"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// MFAVerifyRequest completes a login that returned an mfa_required challenge.
+// Code is a TOTP code or one of the recovery codes.
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
+
+type DisableMFARequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// OIDCState remembers a started SSO login until the provider redirects back.
+// It is single-use and keyed by the hash of the state parameter.
+type OIDCState struct {
+	StateHash    string
+	Nonce        string
+	CodeVerifier string
+	LinkUserID   *int // set when a signed-in user links their account instead of logging in
+	ExpiresAt    time.Time
+}
+
+// UserIdentity links a user to an account (issuer and subject) at an OIDC provider
+type UserIdentity struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"userId"`
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+}
+
+type OIDCAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+}
@@ -0,0 +1,123 @@
+package model
+
+import (
+	"time"
+)
+
+// Organization roles, from most to least privileged
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
+)
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleMember: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// ValidRole reports whether role is one of the organization roles
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// RoleAtLeast reports whether role grants everything min grants. Unknown roles grant nothing.
+func RoleAtLeast(role string, min string) bool {
+	return roleRank[role] > 0 && roleRank[role] >= roleRank[min]
+}
+
+// Organization owns projects and shares them with its members
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy int       `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	Role      string    `json:"role,omitempty"` // role of the requesting user, when listed for them
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type Member struct {
+	OrganizationID int       `json:"organizationId"`
+	UserID         int       `json:"userId"`
+	Username       string    `json:"username"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// AddMemberRequest adds an existing user, found by email, to an organization
+type AddMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+// Project is a project ID, as sent by the middleware with every metric, claimed
+// by an organization. Access to everything of a project goes through its organization.
+type Project struct {
+	ID             string    `json:"id"`
+	OrganizationID int       `json:"organizationId"`
+	Name           string    `json:"name"`
+	CreatedBy      int       `json:"createdBy"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type CreateProjectRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ProjectKey is an API key scoped to a project. Only its hash is stored; Key is
+// set once, in the response that creates or rotates it.
+type ProjectKey struct {
+	ID        int        `json:"id"`
+	ProjectID string     `json:"projectId"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"` // first characters, to recognise the key
+	KeyHash   string     `json:"-"`
+	Key       string     `json:"key,omitempty"`
+	CreatedBy int        `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+type CreateProjectKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// Invitation invites an email address to join an organization with a role. Its
+// single-use token is mailed to the address; only its hash is stored.
+type Invitation struct {
+	ID             int        `json:"id"`
+	OrganizationID int        `json:"organizationId"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	TokenHash      string     `json:"-"`
+	InvitedBy      int        `json:"invitedBy"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	SentAt         time.Time  `json:"sentAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	AcceptedBy     *int       `json:"acceptedBy,omitempty"`
+	AcceptedAt     *time.Time `json:"acceptedAt,omitempty"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty"`
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+)
+
+// Session is a signed-in device: one login and the refresh tokens rotated from
+// it, which share FamilyID. Access tokens carry the session ID, so revoking a
+// session invalidates them on their next validation.
+type Session struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"userId"`
+	FamilyID   string     `json:"-"`
+	Device     string     `json:"device"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"userAgent"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	Current    bool       `json:"current"` // the session of the request listing it
+}
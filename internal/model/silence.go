@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+)
+
+// Silence suppresses notifications for alerts whose labels match between StartsAt and EndsAt.
+// Empty Route or Rule matchers match any value; a trailing "*" matches by prefix.
+type Silence struct {
+	ID        int       `json:"id"`
+	ProjectID string    `json:"projectId"`
+	Route     string    `json:"route"`
+	Rule      string    `json:"rule"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	CreatedBy int       `json:"createdBy"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateSilenceRequest struct {
+	ProjectID string    `json:"projectId"`
+	Route     string    `json:"route"`
+	Rule      string    `json:"rule"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	Comment   string    `json:"comment"`
+}
+
+// MaintenanceWindow is a recurring silence. It opens every time Schedule (a cron
+// expression evaluated in UTC) fires and stays open for DurationMinutes.
+type MaintenanceWindow struct {
+	ID              int       `json:"id"`
+	ProjectID       string    `json:"projectId"`
+	Route           string    `json:"route"`
+	Rule            string    `json:"rule"`
+	Schedule        string    `json:"schedule"`
+	DurationMinutes int       `json:"durationMinutes"`
+	CreatedBy       int       `json:"createdBy"`
+	Comment         string    `json:"comment"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+type CreateMaintenanceWindowRequest struct {
+	ProjectID       string `json:"projectId"`
+	Route           string `json:"route"`
+	Rule            string `json:"rule"`
+	Schedule        string `json:"schedule"`
+	DurationMinutes int    `json:"durationMinutes"`
+	Comment         string `json:"comment"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+)
+
+// SLO is a service level objective computed from metrics, e.g. "99.5% of requests
+// succeed and finish under 300ms over 30 days". A request is good when its status
+// is below 500 and, if LatencyThresholdMs is set, it finished within the threshold.
+// An empty Route covers every route of the project.
+type SLO struct {
+	ID                 int       `json:"id"`
+	ProjectID          string    `json:"projectId"`
+	Route              string    `json:"route"`
+	Name               string    `json:"name"`
+	Target             float64   `json:"target"` // fraction of good requests, e.g. 0.995
+	LatencyThresholdMs int64     `json:"latencyThresholdMs"`
+	WindowDays         int       `json:"windowDays"`
+	AlertsEnabled      bool      `json:"alertsEnabled"`
+	CreatedBy          int       `json:"createdBy"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+type CreateSLORequest struct {
+	ProjectID          string  `json:"projectId"`
+	Route              string  `json:"route"`
+	Name               string  `json:"name"`
+	Target             float64 `json:"target"`
+	LatencyThresholdMs int64   `json:"latencyThresholdMs"`
+	WindowDays         int     `json:"windowDays"`
+	AlertsEnabled      *bool   `json:"alertsEnabled,omitempty"`
+}
+
+// SLOStatus is the state of an SLO at a point in time.
+type SLOStatus struct {
+	SLO           *SLO      `json:"slo"`
+	EvaluatedAt   time.Time `json:"evaluatedAt"`
+	TotalRequests int64     `json:"totalRequests"`
+	GoodRequests  int64     `json:"goodRequests"`
+	Compliance    float64   `json:"compliance"`
+	// ErrorBudgetRemaining is the fraction of the window's error budget left; negative once overspent.
+	ErrorBudgetRemaining float64 `json:"errorBudgetRemaining"`
+	// BurnRates maps a window ("1h", "6h", "3d") to how fast the budget is burning;
+	// 1 means exactly on track to spend the budget by the end of the SLO window.
+	BurnRates map[string]float64 `json:"burnRates"`
+	// ExhaustionForecast is when the budget runs out at the current 6h burn rate, if it is burning.
+	ExhaustionForecast *time.Time `json:"exhaustionForecast,omitempty"`
+	FiringConditions   []string   `json:"firingConditions"`
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"time"
+)
+
+// Component and page statuses, from best to worst
+const (
+	StatusOperational   = "operational"
+	StatusMaintenance   = "maintenance"
+	StatusDegraded      = "degraded"
+	StatusPartialOutage = "partial_outage"
+	StatusMajorOutage   = "major_outage"
+)
+
+// StatusPage is the opt-in public page of a project, served without auth at /status/{slug}.
+type StatusPage struct {
+	ID          int       `json:"id"`
+	ProjectID   string    `json:"projectId"`
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Enabled     bool      `json:"enabled"` // the page is only public when enabled
+	CreatedBy   int       `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type SaveStatusPageRequest struct {
+	ProjectID   string `json:"projectId"`
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// StatusComponent is a customer-facing service on a status page. It is backed
+// either by a route of the project's traffic or by an uptime check.
+type StatusComponent struct {
+	ID          int    `json:"id"`
+	PageID      int    `json:"pageId"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Route       string `json:"route,omitempty"`
+	CheckID     *int   `json:"checkId,omitempty"`
+	Position    int    `json:"position"`
+}
+
+type CreateStatusComponentRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Route       string `json:"route"`
+	CheckID     *int   `json:"checkId"`
+	Position    int    `json:"position"`
+}
+
+// StatusMessage is a manual announcement such as planned maintenance or an incident update.
+type StatusMessage struct {
+	ID         int        `json:"id"`
+	PageID     int        `json:"pageId"`
+	Status     string     `json:"status"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	CreatedBy  int        `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+type CreateStatusMessageRequest struct {
+	Status string `json:"status"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// DailyAvailability is the number of total and successful requests or probes on one UTC day.
+type DailyAvailability struct {
+	Day   time.Time
+	Total int64
+	Good  int64
+}
+
+// PublicStatusPage is what anonymous visitors see. It carries percentages and
+// statuses only, never raw metrics or alert details.
+type PublicStatusPage struct {
+	Slug        string            `json:"slug"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Status      string            `json:"status"`
+	Components  []PublicComponent `json:"components"`
+	Incidents   []PublicIncident  `json:"incidents"`
+	Messages    []StatusMessage   `json:"messages"`
+	GeneratedAt time.Time         `json:"generatedAt"`
+}
+
+type PublicComponent struct {
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	Status        string      `json:"status"`
+	UptimePercent *float64    `json:"uptimePercent"` // over the whole period, nil without data
+	Days          []UptimeDay `json:"days"`          // oldest first
+}
+
+type UptimeDay struct {
+	Date          string   `json:"date"` // YYYY-MM-DD, UTC
+	UptimePercent *float64 `json:"uptimePercent"`
+	Status        string   `json:"status"` // "no_data" when nothing was recorded
+}
+
+type PublicIncident struct {
+	Component string    `json:"component"`
+	Status    string    `json:"status"`
+	Stage     string    `json:"stage"` // investigating or identified (acknowledged)
+	StartedAt time.Time `json:"startedAt"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// RefreshToken is a single-use, rotating refresh token. Only its hash is stored.
+// Tokens rotated from the same login share a FamilyID, so the whole chain can be
+// revoked when a used token is presented again.
+type RefreshToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"userId"`
+	TokenHash string     `json:"-"`
+	FamilyID  string     `json:"familyId"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// LogoutRequest revokes the current access token, the refresh token chain if
+// given, and with AllDevices every token issued to the user.
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+	AllDevices   bool   `json:"allDevices"`
+}
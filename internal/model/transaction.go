@@ -0,0 +1,86 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// TransactionStep is one HTTP request of a scripted transaction check. URL, header
+// values and Body may reference variables extracted by earlier steps as {{name}}.
+type TransactionStep struct {
+	Name         string            `json:"name"`
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Body         string            `json:"body,omitempty"`
+	ExpectStatus int               `json:"expectStatus"`
+	MaxLatencyMs int64             `json:"maxLatencyMs,omitempty"`
+	BodyContains string            `json:"bodyContains,omitempty"`
+	// Extract maps a variable name to a dotted path into the JSON response, e.g. "data.items.0.id".
+	Extract map[string]string `json:"extract,omitempty"`
+}
+
+// TransactionCheck is a synthetic check made of several HTTP steps run in order.
+// The steps share a cookie jar, so session cookies carry over like in a browser.
+type TransactionCheck struct {
+	ID              int               `json:"id"`
+	ProjectID       string            `json:"projectId"`
+	Name            string            `json:"name"`
+	Steps           []TransactionStep `json:"steps"`
+	IntervalSeconds int               `json:"intervalSeconds"`
+	TimeoutSeconds  int               `json:"timeoutSeconds"` // per step
+	Enabled         bool              `json:"enabled"`
+	CreatedBy       int               `json:"createdBy"`
+	CreatedAt       time.Time         `json:"createdAt"`
+}
+
+type CreateTransactionCheckRequest struct {
+	ProjectID       string            `json:"projectId"`
+	Name            string            `json:"name"`
+	Steps           []TransactionStep `json:"steps"`
+	IntervalSeconds int               `json:"intervalSeconds"`
+	TimeoutSeconds  int               `json:"timeoutSeconds"`
+}
+
+type StepResult struct {
+	Name       string `json:"name"`
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	DurationMs int64  `json:"durationMs"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+}
+
+// TransactionRun is the outcome of one execution of a transaction check.
+// Steps after a failing step are not run and not listed.
+type TransactionRun struct {
+	ID         int          `json:"id"`
+	CheckID    int          `json:"checkId"`
+	ProjectID  string       `json:"projectId"`
+	Success    bool         `json:"success"`
+	FailedStep *int         `json:"failedStep,omitempty"` // index into Steps
+	Error      string       `json:"error,omitempty"`
+	DurationMs int64        `json:"durationMs"`
+	Steps      []StepResult `json:"steps"`
+	StartedAt  time.Time    `json:"startedAt"`
+}
+
+const syntheticProjectSuffix = ":synthetic"
+
+// SyntheticProjectID is the project that metrics produced by synthetic checks of
+// projectID are recorded under, so they never mix with real middleware traffic.
+func SyntheticProjectID(projectID string) string {
+	return projectID + syntheticProjectSuffix
+}
+
+// IsSyntheticProjectID reports whether projectID is the synthetic project of another
+func IsSyntheticProjectID(projectID string) bool {
+	return strings.HasSuffix(projectID, syntheticProjectSuffix)
+}
+
+// ParentProjectID returns the project a synthetic project belongs to, or
+// projectID itself when it is not synthetic
+func ParentProjectID(projectID string) string {
+	return strings.TrimSuffix(projectID, syntheticProjectSuffix)
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+)
+
+// UptimeCheck is a URL that is probed on a fixed interval.
+type UptimeCheck struct {
+	ID               int        `json:"id"`
+	ProjectID        string     `json:"projectId"`
+	Name             string     `json:"name"`
+	URL              string     `json:"url"`
+	Method           string     `json:"method"`
+	ExpectedStatus   int        `json:"expectedStatus"`
+	BodyContains     string     `json:"bodyContains"`
+	IntervalSeconds  int        `json:"intervalSeconds"`
+	TimeoutSeconds   int        `json:"timeoutSeconds"`
+	FailureThreshold int        `json:"failureThreshold"` // consecutive failures before alerting
+	CertExpiryDays   int        `json:"certExpiryDays"`   // alert this many days before an HTTPS certificate expires
+	Enabled          bool       `json:"enabled"`
+	CreatedBy        int        `json:"createdBy"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	LastCheckedAt    *time.Time `json:"lastCheckedAt,omitempty"`
+	LastSuccess      *bool      `json:"lastSuccess,omitempty"`
+}
+
+type CreateUptimeCheckRequest struct {
+	ProjectID        string `json:"projectId"`
+	Name             string `json:"name"`
+	URL              string `json:"url"`
+	Method           string `json:"method"`
+	ExpectedStatus   int    `json:"expectedStatus"`
+	BodyContains     string `json:"bodyContains"`
+	IntervalSeconds  int    `json:"intervalSeconds"`
+	TimeoutSeconds   int    `json:"timeoutSeconds"`
+	FailureThreshold int    `json:"failureThreshold"`
+	CertExpiryDays   int    `json:"certExpiryDays"`
+}
+
+// CheckResult is the outcome of one synthetic probe. It carries the same fields as a
+// Metric, so results can be charted and aggregated like middleware traffic.
+type CheckResult struct {
+	ID           int       `json:"id"`
+	CheckID      int       `json:"checkId"`
+	ProjectID    string    `json:"projectId"`
+	Route        string    `json:"route"`
+	Method       string    `json:"method"`
+	StatusCode   int       `json:"statusCode"`
+	ResponseTime int64     `json:"responseTime"`
+	Timestamp    int64     `json:"timestamp"` // Unix timestamp in milliseconds
+	Success      bool      `json:"success"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
@@ -5,38 +5,51 @@ import (
 )
 
 type User struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	APIKey    string    `json:"apiKey"`
-	IsActive  bool      `json:"isActive"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID                  int        `json:"id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	Password            string     `json:"-"`
+	APIKey              string     `json:"apiKey"`
+	IsActive            bool       `json:"isActive"`
+	EmailVerified       bool       `json:"emailVerified"`
+	IsAdmin             bool       `json:"isAdmin"`                       // system administrator, see /api/admin
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty"` // a confirmed account deletion waiting out its grace period
+	TokenVersion        int        `json:"-"`                             // embedded in access tokens; bumping it revokes all of them
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
 }
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	InviteToken string `json:"inviteToken,omitempty"` // accepts an organization invitation sent to Email
 }
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
+
+// LoginResponse carries the tokens of a completed login. When the user has MFA
+// enabled, Login only returns MFARequired and an MFAToken to complete at
+// /api/auth/mfa/verify.
 type LoginResponse struct {
-	ID       int    `json:"id"`
-	Token    string `json:"token"`
-	APIKey   string `json:"apiKey"`
-	Username string `json:"username"`
+	ID           int    `json:"id"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	ExpiresIn    int    `json:"expiresIn,omitempty"` // access token lifetime in seconds
+	APIKey       string `json:"apiKey,omitempty"`
+	Username     string `json:"username"`
+	MFARequired  bool   `json:"mfaRequired,omitempty"`
+	MFAToken     string `json:"mfaToken,omitempty"`
 }
 
 // UpdateUserRequest represents fields that can be updated for a user.
 // Pointer fields are used so the service can detect which fields were provided.
+// Users cannot deactivate themselves or choose their API key here; administrators
+// do that through AdminService.
 type UpdateUserRequest struct {
 	ID       int     `json:"id,omitempty"`
 	Username *string `json:"username,omitempty"`
 	Email    *string `json:"email,omitempty"`
 	Password *string `json:"password,omitempty"`
-	APIKey   *string `json:"apiKey,omitempty"`
-	IsActive *bool   `json:"isActive,omitempty"`
 }
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"prothomuse-server/internal/model"
+
+	"github.com/lib/pq"
+)
+
+type accountRepository struct {
+	db *sql.DB
+}
+
+// AccountRepository deletes user accounts with the data they own
+type AccountRepository interface {
+	// DeleteAccount deletes a user in one transaction, with every organization
+	// the user is the only member of and the projects, keys and metrics of those
+	// organizations, with everything those projects configured and recorded.
+	// Rows referencing the user (memberships, sessions, tokens, MFA, identities)
+	// go with it through their foreign keys.
+	DeleteAccount(userID int) (*model.DeletedAccount, error)
+}
+
+// projectScopedTables hold rows keyed by project_id without a foreign key to
+// projects. Their child rows (alert history, check results, certificates, runs,
+// anomaly events, status page components and messages) cascade from them.
+var projectScopedTables = []string{
+	"metrics",
+	"alerts",
+	"incident_notes",
+	"silences",
+	"maintenance_windows",
+	"slos",
+	"anomaly_detectors",
+	"status_pages",
+	"uptime_checks",
+	"transaction_checks",
+	"cron_monitors",
+	"heartbeat_configs",
+	"ingestion_heartbeats",
+}
+
+func NewAccountRepository(db *sql.DB) AccountRepository {
+	return &accountRepository{db: db}
+}
+
+func (r *accountRepository) DeleteAccount(userID int) (*model.DeletedAccount, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	deleted := &model.DeletedAccount{OrganizationIDs: []int{}, ProjectIDs: []string{}}
+	rows, err := tx.Query(`
+		SELECT m.organization_id FROM organization_members m
+		WHERE m.user_id = $1 AND NOT EXISTS (
+			SELECT 1 FROM organization_members o WHERE o.organization_id = m.organization_id AND o.user_id <> $1
+		)`, userID)
+	if err != nil {
+		log.Println("Error listing organizations of deleted account:", err)
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deleted.OrganizationIDs = append(deleted.OrganizationIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`SELECT id FROM projects WHERE organization_id = ANY($1)`, pq.Array(deleted.OrganizationIDs))
+	if err != nil {
+		log.Println("Error listing projects of deleted account:", err)
+		return nil, err
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deleted.ProjectIDs = append(deleted.ProjectIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// synthetic checks write their metrics to a project of their own
+	scoped := append([]string{}, deleted.ProjectIDs...)
+	for _, id := range deleted.ProjectIDs {
+		scoped = append(scoped, model.SyntheticProjectID(id))
+	}
+	for _, table := range projectScopedTables {
+		result, err := tx.Exec(`DELETE FROM `+table+` WHERE project_id = ANY($1)`, pq.Array(scoped))
+		if err != nil {
+			log.Printf("Error deleting %s of deleted account: %v", table, err)
+			return nil, err
+		}
+		if table == "metrics" {
+			if deleted.Metrics, err = result.RowsAffected(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// projects, their keys and the invitations go with the organizations
+	if _, err := tx.Exec(`DELETE FROM organizations WHERE id = ANY($1)`, pq.Array(deleted.OrganizationIDs)); err != nil {
+		log.Println("Error deleting organizations of deleted account:", err)
+		return nil, err
+	}
+	// queued emails carry the address and links of the user
+	if _, err := tx.Exec(`DELETE FROM email_outbox WHERE recipient = (SELECT email FROM users WHERE id = $1)`, userID); err != nil {
+		log.Println("Error deleting emails of deleted account:", err)
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = $1`, userID); err != nil {
+		log.Println("Error deleting user:", err)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	log.Println("Account deleted for user ID:", userID)
+	return deleted, nil
+}
@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type alertRepository struct {
+	db *sql.DB
+}
+
+// AlertRepository defines the methods implemented by the alert repository
+type AlertRepository interface {
+	CreateTable() error
+	CreateAlert(alert *model.Alert) error
+	GetAlertByID(id int) (*model.Alert, error)
+	GetOpenAlert(labels model.AlertLabels) (*model.Alert, error)
+	ResolveAlert(alert *model.Alert) error
+	SetAcknowledgement(alert *model.Alert, userID *int) error
+	ListAlerts(projectID string, state string) ([]model.Alert, error)
+	AddHistory(entry *model.AlertHistoryEntry) error
+	ListHistory(alertID int) ([]model.AlertHistoryEntry, error)
+	CreateNote(note *model.IncidentNote) error
+	// ListTimeline merges alert transitions (not notification attempts) and notes
+	// of a project in [from, to), newest first.
+	ListTimeline(projectID string, from time.Time, to time.Time, limit int) ([]model.TimelineEntry, error)
+}
+
+func NewAlertRepository(db *sql.DB) AlertRepository {
+	return &alertRepository{db: db}
+}
+
+func (r *alertRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS alerts (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL,
+		route VARCHAR(500) NOT NULL DEFAULT '',
+		rule VARCHAR(255) NOT NULL,
+		severity VARCHAR(20) NOT NULL DEFAULT 'warning',
+		state VARCHAR(20) NOT NULL DEFAULT 'firing',
+		summary TEXT NOT NULL DEFAULT '',
+		fired_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		resolved_at TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS alert_history (
+		id SERIAL PRIMARY KEY,
+		alert_id INT NOT NULL REFERENCES alerts(id) ON DELETE CASCADE,
+		event VARCHAR(50) NOT NULL,
+		detail TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS incident_notes (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL,
+		alert_id INT REFERENCES alerts(id) ON DELETE SET NULL,
+		user_id INT NOT NULL,
+		body TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE alerts ADD COLUMN IF NOT EXISTS acknowledged_by INT;
+	ALTER TABLE alerts ADD COLUMN IF NOT EXISTS acknowledged_at TIMESTAMP;
+	ALTER TABLE alert_history ADD COLUMN IF NOT EXISTS user_id INT;
+	create index if not exists idx_alerts_project on alerts(project_id, state);
+	create index if not exists idx_alert_history_alert on alert_history(alert_id);
+	create index if not exists idx_incident_notes_project on incident_notes(project_id, created_at);
+	create unique index if not exists idx_alerts_open_labels on alerts(project_id, route, rule) where state = 'firing';
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *alertRepository) CreateAlert(alert *model.Alert) error {
+	query := `
+		INSERT INTO alerts (project_id, route, rule, severity, state, summary)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, fired_at, updated_at
+	`
+	if err := r.db.QueryRow(query,
+		alert.ProjectID,
+		alert.Route,
+		alert.Rule,
+		alert.Severity,
+		alert.State,
+		alert.Summary,
+	).Scan(&alert.ID, &alert.FiredAt, &alert.UpdatedAt); err != nil {
+		log.Println("Error creating alert:", err)
+		return err
+	}
+	return nil
+}
+
+const alertColumns = `id, project_id, route, rule, severity, state, summary, fired_at, resolved_at, updated_at, acknowledged_by, acknowledged_at`
+
+func scanAlert(row interface{ Scan(...interface{}) error }) (*model.Alert, error) {
+	alert := &model.Alert{}
+	var resolvedAt, acknowledgedAt sql.NullTime
+	var acknowledgedBy sql.NullInt64
+	err := row.Scan(
+		&alert.ID,
+		&alert.ProjectID,
+		&alert.Route,
+		&alert.Rule,
+		&alert.Severity,
+		&alert.State,
+		&alert.Summary,
+		&alert.FiredAt,
+		&resolvedAt,
+		&alert.UpdatedAt,
+		&acknowledgedBy,
+		&acknowledgedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		alert.ResolvedAt = &resolvedAt.Time
+	}
+	if acknowledgedBy.Valid {
+		id := int(acknowledgedBy.Int64)
+		alert.AcknowledgedBy = &id
+	}
+	if acknowledgedAt.Valid {
+		alert.AcknowledgedAt = &acknowledgedAt.Time
+	}
+	return alert, nil
+}
+
+func (r *alertRepository) GetAlertByID(id int) (*model.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE id = $1`
+	alert, err := scanAlert(r.db.QueryRow(query, id))
+	if err != nil {
+		log.Println("Error fetching alert by ID:", err)
+		return nil, err
+	}
+	return alert, nil
+}
+
+// GetOpenAlert returns the firing alert for the given labels, or nil when there is none
+func (r *alertRepository) GetOpenAlert(labels model.AlertLabels) (*model.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE project_id = $1 AND route = $2 AND rule = $3 AND state = 'firing'`
+	alert, err := scanAlert(r.db.QueryRow(query, labels.ProjectID, labels.Route, labels.Rule))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching open alert:", err)
+		return nil, err
+	}
+	return alert, nil
+}
+
+func (r *alertRepository) ResolveAlert(alert *model.Alert) error {
+	query := `
+		UPDATE alerts SET state = 'resolved', resolved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING state, resolved_at, updated_at
+	`
+	var resolvedAt sql.NullTime
+	if err := r.db.QueryRow(query, alert.ID).Scan(&alert.State, &resolvedAt, &alert.UpdatedAt); err != nil {
+		log.Println("Error resolving alert:", err)
+		return err
+	}
+	if resolvedAt.Valid {
+		alert.ResolvedAt = &resolvedAt.Time
+	}
+	return nil
+}
+
+// SetAcknowledgement records who acknowledged the alert. A nil userID clears the acknowledgement.
+func (r *alertRepository) SetAcknowledgement(alert *model.Alert, userID *int) error {
+	query := `
+		UPDATE alerts SET acknowledged_by = $2,
+			acknowledged_at = CASE WHEN $2::INT IS NULL THEN NULL ELSE CURRENT_TIMESTAMP END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING ` + alertColumns
+	updated, err := scanAlert(r.db.QueryRow(query, alert.ID, userID))
+	if err != nil {
+		log.Println("Error updating alert acknowledgement:", err)
+		return err
+	}
+	*alert = *updated
+	return nil
+}
+
+// ListAlerts returns the alerts of a project, newest first. An empty state returns all states.
+func (r *alertRepository) ListAlerts(projectID string, state string) ([]model.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE project_id = $1 AND ($2 = '' OR state = $2) ORDER BY fired_at DESC LIMIT 500`
+	rows, err := r.db.Query(query, projectID, state)
+	if err != nil {
+		log.Println("Error listing alerts:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	alerts := []model.Alert{}
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *alert)
+	}
+	return alerts, rows.Err()
+}
+
+func (r *alertRepository) AddHistory(entry *model.AlertHistoryEntry) error {
+	query := `
+		INSERT INTO alert_history (alert_id, event, detail, user_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query, entry.AlertID, entry.Event, entry.Detail, entry.UserID).Scan(&entry.ID, &entry.CreatedAt); err != nil {
+		log.Println("Error adding alert history:", err)
+		return err
+	}
+	return nil
+}
+
+// ListHistory returns the history of an alert, oldest first
+func (r *alertRepository) ListHistory(alertID int) ([]model.AlertHistoryEntry, error) {
+	query := `SELECT id, alert_id, event, detail, user_id, created_at FROM alert_history WHERE alert_id = $1 ORDER BY created_at, id`
+	rows, err := r.db.Query(query, alertID)
+	if err != nil {
+		log.Println("Error listing alert history:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []model.AlertHistoryEntry{}
+	for rows.Next() {
+		var e model.AlertHistoryEntry
+		var userID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.AlertID, &e.Event, &e.Detail, &userID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			e.UserID = &id
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (r *alertRepository) CreateNote(note *model.IncidentNote) error {
+	query := `
+		INSERT INTO incident_notes (project_id, alert_id, user_id, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query, note.ProjectID, note.AlertID, note.UserID, note.Body).Scan(&note.ID, &note.CreatedAt); err != nil {
+		log.Println("Error creating incident note:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *alertRepository) ListTimeline(projectID string, from time.Time, to time.Time, limit int) ([]model.TimelineEntry, error) {
+	query := `
+		SELECT h.created_at, 'alert', h.event, h.alert_id, a.rule, a.route, h.user_id,
+			CASE WHEN h.detail <> '' THEN h.detail ELSE a.summary END
+		FROM alert_history h
+		JOIN alerts a ON a.id = h.alert_id
+		WHERE a.project_id = $1 AND h.created_at >= $2 AND h.created_at < $3
+			AND h.event IN ('fired', 'resolved', 'acknowledged', 'unacknowledged')
+		UNION ALL
+		SELECT n.created_at, 'note', '', n.alert_id, COALESCE(a.rule, ''), COALESCE(a.route, ''), n.user_id, n.body
+		FROM incident_notes n
+		LEFT JOIN alerts a ON a.id = n.alert_id
+		WHERE n.project_id = $1 AND n.created_at >= $2 AND n.created_at < $3
+		ORDER BY 1 DESC
+		LIMIT $4
+	`
+	rows, err := r.db.Query(query, projectID, from, to, limit)
+	if err != nil {
+		log.Println("Error listing incident timeline:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []model.TimelineEntry{}
+	for rows.Next() {
+		var e model.TimelineEntry
+		var alertID, userID sql.NullInt64
+		if err := rows.Scan(&e.Time, &e.Kind, &e.Event, &alertID, &e.Rule, &e.Route, &userID, &e.Text); err != nil {
+			return nil, err
+		}
+		if alertID.Valid {
+			id := int(alertID.Int64)
+			e.AlertID = &id
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			e.UserID = &id
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
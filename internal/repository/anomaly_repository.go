@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+)
+
+type anomalyRepository struct {
+	db *sql.DB
+}
+
+// AnomalyRepository defines the methods implemented by the anomaly repository
+type AnomalyRepository interface {
+	CreateTable() error
+	CreateDetector(detector *model.AnomalyDetector) error
+	GetDetectorByID(id int) (*model.AnomalyDetector, error)
+	ListDetectors(projectID string) ([]model.AnomalyDetector, error)
+	ListEnabledDetectors() ([]model.AnomalyDetector, error)
+	DeleteDetector(id int) error
+	// CreateEvent stores the event and reports whether it was new. The same
+	// detector, signal and hour is only ever recorded once.
+	CreateEvent(event *model.AnomalyEvent) (bool, error)
+	ListEvents(projectID string, limit int) ([]model.AnomalyEvent, error)
+}
+
+func NewAnomalyRepository(db *sql.DB) AnomalyRepository {
+	return &anomalyRepository{db: db}
+}
+
+func (r *anomalyRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS anomaly_detectors (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL,
+		route VARCHAR(500) NOT NULL DEFAULT '',
+		algorithm VARCHAR(20) NOT NULL,
+		sensitivity DOUBLE PRECISION NOT NULL,
+		min_requests INT NOT NULL,
+		enabled BOOLEAN DEFAULT TRUE,
+		created_by INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS anomaly_events (
+		id SERIAL PRIMARY KEY,
+		detector_id INT NOT NULL REFERENCES anomaly_detectors(id) ON DELETE CASCADE,
+		project_id VARCHAR(255) NOT NULL,
+		route VARCHAR(500) NOT NULL DEFAULT '',
+		signal VARCHAR(50) NOT NULL,
+		observed DOUBLE PRECISION NOT NULL,
+		expected DOUBLE PRECISION NOT NULL,
+		deviation DOUBLE PRECISION NOT NULL,
+		window_start TIMESTAMP NOT NULL,
+		detected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (detector_id, signal, window_start)
+	);
+	create index if not exists idx_anomaly_detectors_project on anomaly_detectors(project_id);
+	create index if not exists idx_anomaly_events_project on anomaly_events(project_id, detected_at);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *anomalyRepository) CreateDetector(detector *model.AnomalyDetector) error {
+	query := `
+		INSERT INTO anomaly_detectors (project_id, route, algorithm, sensitivity, min_requests, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		detector.ProjectID,
+		detector.Route,
+		detector.Algorithm,
+		detector.Sensitivity,
+		detector.MinRequests,
+		detector.Enabled,
+		detector.CreatedBy,
+	).Scan(&detector.ID, &detector.CreatedAt); err != nil {
+		log.Println("Error creating anomaly detector:", err)
+		return err
+	}
+	return nil
+}
+
+const anomalyDetectorColumns = `id, project_id, route, algorithm, sensitivity, min_requests, enabled, created_by, created_at`
+
+func scanAnomalyDetector(row interface{ Scan(...interface{}) error }) (*model.AnomalyDetector, error) {
+	d := &model.AnomalyDetector{}
+	err := row.Scan(
+		&d.ID,
+		&d.ProjectID,
+		&d.Route,
+		&d.Algorithm,
+		&d.Sensitivity,
+		&d.MinRequests,
+		&d.Enabled,
+		&d.CreatedBy,
+		&d.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r *anomalyRepository) GetDetectorByID(id int) (*model.AnomalyDetector, error) {
+	query := `SELECT ` + anomalyDetectorColumns + ` FROM anomaly_detectors WHERE id = $1`
+	d, err := scanAnomalyDetector(r.db.QueryRow(query, id))
+	if err != nil {
+		log.Println("Error fetching anomaly detector by ID:", err)
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r *anomalyRepository) ListDetectors(projectID string) ([]model.AnomalyDetector, error) {
+	query := `SELECT ` + anomalyDetectorColumns + ` FROM anomaly_detectors WHERE project_id = $1 ORDER BY id`
+	return r.queryDetectors(query, projectID)
+}
+
+func (r *anomalyRepository) ListEnabledDetectors() ([]model.AnomalyDetector, error) {
+	query := `SELECT ` + anomalyDetectorColumns + ` FROM anomaly_detectors WHERE enabled = TRUE ORDER BY id`
+	return r.queryDetectors(query)
+}
+
+func (r *anomalyRepository) queryDetectors(query string, args ...interface{}) ([]model.AnomalyDetector, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Println("Error listing anomaly detectors:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	detectors := []model.AnomalyDetector{}
+	for rows.Next() {
+		d, err := scanAnomalyDetector(rows)
+		if err != nil {
+			return nil, err
+		}
+		detectors = append(detectors, *d)
+	}
+	return detectors, rows.Err()
+}
+
+func (r *anomalyRepository) DeleteDetector(id int) error {
+	_, err := r.db.Exec(`DELETE FROM anomaly_detectors WHERE id = $1`, id)
+	if err != nil {
+		log.Println("Error deleting anomaly detector:", err)
+	}
+	return err
+}
+
+func (r *anomalyRepository) CreateEvent(event *model.AnomalyEvent) (bool, error) {
+	query := `
+		INSERT INTO anomaly_events (detector_id, project_id, route, signal, observed, expected, deviation, window_start)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (detector_id, signal, window_start) DO NOTHING
+		RETURNING id, detected_at
+	`
+	err := r.db.QueryRow(query,
+		event.DetectorID,
+		event.ProjectID,
+		event.Route,
+		event.Signal,
+		event.Observed,
+		event.Expected,
+		event.Deviation,
+		event.WindowStart,
+	).Scan(&event.ID, &event.DetectedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		log.Println("Error creating anomaly event:", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *anomalyRepository) ListEvents(projectID string, limit int) ([]model.AnomalyEvent, error) {
+	query := `
+		SELECT id, detector_id, project_id, route, signal, observed, expected, deviation, window_start, detected_at
+		FROM anomaly_events
+		WHERE project_id = $1
+		ORDER BY window_start DESC, id DESC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(query, projectID, limit)
+	if err != nil {
+		log.Println("Error listing anomaly events:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	events := []model.AnomalyEvent{}
+	for rows.Next() {
+		var e model.AnomalyEvent
+		if err := rows.Scan(
+			&e.ID,
+			&e.DetectorID,
+			&e.ProjectID,
+			&e.Route,
+			&e.Signal,
+			&e.Observed,
+			&e.Expected,
+			&e.Deviation,
+			&e.WindowStart,
+			&e.DetectedAt,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"prothomuse-server/internal/model"
+	"strings"
+)
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+// AuditRepository appends to the security audit log
+type AuditRepository interface {
+	CreateTable() error
+	CreateEvent(event *model.AuditEvent) error
+	// ListEvents returns a page of the events of an organization, newest first, and the total matching
+	ListEvents(orgID int, filter model.AuditFilter) ([]model.AuditEvent, int, error)
+	// ListAllEvents is ListEvents across organizations, including events outside any
+	ListAllEvents(filter model.AuditFilter) ([]model.AuditEvent, int, error)
+}
+
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id BIGSERIAL PRIMARY KEY,
+		organization_id INT,
+		actor_id INT,
+		action VARCHAR(100) NOT NULL,
+		target_type VARCHAR(50) NOT NULL DEFAULT '',
+		target_id VARCHAR(255) NOT NULL DEFAULT '',
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		before JSONB,
+		after JSONB,
+		metadata JSONB,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	create index if not exists idx_audit_events_org on audit_events(organization_id, id);
+	create index if not exists idx_audit_events_actor on audit_events(actor_id, id);
+	-- the log is append-only: rows can be neither changed nor removed
+	CREATE OR REPLACE FUNCTION audit_events_immutable() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_events is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS audit_events_no_change ON audit_events;
+	CREATE TRIGGER audit_events_no_change BEFORE UPDATE OR DELETE ON audit_events
+		FOR EACH ROW EXECUTE FUNCTION audit_events_immutable();
+	DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+	CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+		FOR EACH STATEMENT EXECUTE FUNCTION audit_events_immutable();
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+// nullJSON stores empty JSON as NULL
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+func (r *auditRepository) CreateEvent(event *model.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (organization_id, actor_id, action, target_type, target_id, ip, user_agent, before, after, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		event.OrganizationID,
+		event.ActorID,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.IP,
+		event.UserAgent,
+		nullJSON(event.Before),
+		nullJSON(event.After),
+		nullJSON(event.Metadata),
+	).Scan(&event.ID, &event.CreatedAt); err != nil {
+		log.Println("Error creating audit event:", err)
+		return err
+	}
+	return nil
+}
+
+const auditEventColumns = `id, organization_id, actor_id, action, target_type, target_id, ip, user_agent, before, after, metadata, created_at`
+
+func scanAuditEvent(row interface{ Scan(...interface{}) error }) (*model.AuditEvent, error) {
+	e := &model.AuditEvent{}
+	var orgID, actorID sql.NullInt64
+	var before, after, metadata []byte
+	if err := row.Scan(
+		&e.ID,
+		&orgID,
+		&actorID,
+		&e.Action,
+		&e.TargetType,
+		&e.TargetID,
+		&e.IP,
+		&e.UserAgent,
+		&before,
+		&after,
+		&metadata,
+		&e.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if orgID.Valid {
+		id := int(orgID.Int64)
+		e.OrganizationID = &id
+	}
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		e.ActorID = &id
+	}
+	e.Before, e.After, e.Metadata = before, after, metadata
+	return e, nil
+}
+
+func (r *auditRepository) ListEvents(orgID int, filter model.AuditFilter) ([]model.AuditEvent, int, error) {
+	return r.listEvents([]string{"organization_id = $1"}, []interface{}{orgID}, filter)
+}
+
+func (r *auditRepository) ListAllEvents(filter model.AuditFilter) ([]model.AuditEvent, int, error) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	if filter.WithoutOrganization {
+		conditions = append(conditions, "organization_id IS NULL")
+	} else if filter.OrganizationID != 0 {
+		args = append(args, filter.OrganizationID)
+		conditions = append(conditions, "organization_id = $1")
+	}
+	return r.listEvents(conditions, args, filter)
+}
+
+// listEvents adds the conditions of filter to the given ones and returns a page
+func (r *auditRepository) listEvents(conditions []string, args []interface{}, filter model.AuditFilter) ([]model.AuditEvent, int, error) {
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.ActorID != 0 {
+		add("actor_id = $%d", filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		add("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		add("target_id = $%d", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		add("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("created_at < $%d", filter.To)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM audit_events WHERE `+where, args...).Scan(&total); err != nil {
+		log.Println("Error counting audit events:", err)
+		return nil, 0, err
+	}
+	query := fmt.Sprintf(`SELECT %s FROM audit_events WHERE %s ORDER BY id DESC LIMIT $%d OFFSET $%d`,
+		auditEventColumns, where, len(args)+1, len(args)+2)
+	rows, err := r.db.Query(query, append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)...)
+	if err != nil {
+		log.Println("Error listing audit events:", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+	events := []model.AuditEvent{}
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, *e)
+	}
+	return events, total, rows.Err()
+}
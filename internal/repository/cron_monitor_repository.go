@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type cronMonitorRepository struct {
+	db *sql.DB
+}
+
+// CronMonitorRepository defines the methods implemented by the cron monitor repository
+type CronMonitorRepository interface {
+	CreateTable() error
+	CreateMonitor(monitor *model.CronMonitor) error
+	GetMonitorByID(id int) (*model.CronMonitor, error)
+	GetMonitorByToken(token string) (*model.CronMonitor, error)
+	ListMonitors(projectID string) ([]model.CronMonitor, error)
+	ListEnabledMonitors() ([]model.CronMonitor, error)
+	DeleteMonitor(id int) error
+	// UpdateState saves the schedule position and last ping of a monitor
+	UpdateState(monitor *model.CronMonitor) error
+	CreateRun(run *model.CronRun) error
+	// GetOpenRun returns the latest run still running, or nil
+	GetOpenRun(monitorID int) (*model.CronRun, error)
+	// GetLatestRun returns the most recent run of any status, or nil
+	GetLatestRun(monitorID int) (*model.CronRun, error)
+	UpdateRun(run *model.CronRun) error
+	ListRuns(monitorID int, limit int) ([]model.CronRun, error)
+}
+
+func NewCronMonitorRepository(db *sql.DB) CronMonitorRepository {
+	return &cronMonitorRepository{db: db}
+}
+
+func (r *cronMonitorRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS cron_monitors (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		schedule VARCHAR(255) NOT NULL,
+		grace_seconds INT NOT NULL,
+		token VARCHAR(255) UNIQUE NOT NULL,
+		enabled BOOLEAN DEFAULT TRUE,
+		created_by INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		next_expected_at TIMESTAMP NOT NULL,
+		last_ping_at TIMESTAMP,
+		last_status VARCHAR(20) NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS cron_runs (
+		id SERIAL PRIMARY KEY,
+		monitor_id INT NOT NULL REFERENCES cron_monitors(id) ON DELETE CASCADE,
+		status VARCHAR(20) NOT NULL,
+		late BOOLEAN NOT NULL DEFAULT FALSE,
+		scheduled_at TIMESTAMP,
+		started_at TIMESTAMP,
+		finished_at TIMESTAMP,
+		duration_ms BIGINT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	create index if not exists idx_cron_monitors_project on cron_monitors(project_id);
+	create index if not exists idx_cron_runs_monitor on cron_runs(monitor_id, id);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *cronMonitorRepository) CreateMonitor(monitor *model.CronMonitor) error {
+	query := `
+		INSERT INTO cron_monitors (project_id, name, schedule, grace_seconds, token, enabled, created_by, next_expected_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		monitor.ProjectID,
+		monitor.Name,
+		monitor.Schedule,
+		monitor.GraceSeconds,
+		monitor.Token,
+		monitor.Enabled,
+		monitor.CreatedBy,
+		monitor.NextExpectedAt,
+	).Scan(&monitor.ID, &monitor.CreatedAt); err != nil {
+		log.Println("Error creating cron monitor:", err)
+		return err
+	}
+	return nil
+}
+
+const cronMonitorColumns = `id, project_id, name, schedule, grace_seconds, token, enabled, created_by, created_at, next_expected_at, last_ping_at, last_status`
+
+func scanCronMonitor(row interface{ Scan(...interface{}) error }) (*model.CronMonitor, error) {
+	m := &model.CronMonitor{}
+	var lastPingAt sql.NullTime
+	if err := row.Scan(
+		&m.ID,
+		&m.ProjectID,
+		&m.Name,
+		&m.Schedule,
+		&m.GraceSeconds,
+		&m.Token,
+		&m.Enabled,
+		&m.CreatedBy,
+		&m.CreatedAt,
+		&m.NextExpectedAt,
+		&lastPingAt,
+		&m.LastStatus,
+	); err != nil {
+		return nil, err
+	}
+	if lastPingAt.Valid {
+		m.LastPingAt = &lastPingAt.Time
+	}
+	return m, nil
+}
+
+func (r *cronMonitorRepository) GetMonitorByID(id int) (*model.CronMonitor, error) {
+	query := `SELECT ` + cronMonitorColumns + ` FROM cron_monitors WHERE id = $1`
+	m, err := scanCronMonitor(r.db.QueryRow(query, id))
+	if err != nil {
+		log.Println("Error fetching cron monitor by ID:", err)
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *cronMonitorRepository) GetMonitorByToken(token string) (*model.CronMonitor, error) {
+	query := `SELECT ` + cronMonitorColumns + ` FROM cron_monitors WHERE token = $1`
+	m, err := scanCronMonitor(r.db.QueryRow(query, token))
+	if err != nil {
+		log.Println("Error fetching cron monitor by token:", err)
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *cronMonitorRepository) ListMonitors(projectID string) ([]model.CronMonitor, error) {
+	return r.queryMonitors(`SELECT `+cronMonitorColumns+` FROM cron_monitors WHERE project_id = $1 ORDER BY id`, projectID)
+}
+
+func (r *cronMonitorRepository) ListEnabledMonitors() ([]model.CronMonitor, error) {
+	return r.queryMonitors(`SELECT ` + cronMonitorColumns + ` FROM cron_monitors WHERE enabled = TRUE ORDER BY id`)
+}
+
+func (r *cronMonitorRepository) queryMonitors(query string, args ...interface{}) ([]model.CronMonitor, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Println("Error listing cron monitors:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	monitors := []model.CronMonitor{}
+	for rows.Next() {
+		m, err := scanCronMonitor(rows)
+		if err != nil {
+			return nil, err
+		}
+		monitors = append(monitors, *m)
+	}
+	return monitors, rows.Err()
+}
+
+func (r *cronMonitorRepository) DeleteMonitor(id int) error {
+	_, err := r.db.Exec(`DELETE FROM cron_monitors WHERE id = $1`, id)
+	if err != nil {
+		log.Println("Error deleting cron monitor:", err)
+	}
+	return err
+}
+
+func (r *cronMonitorRepository) UpdateState(monitor *model.CronMonitor) error {
+	query := `UPDATE cron_monitors SET next_expected_at = $2, last_ping_at = $3, last_status = $4 WHERE id = $1`
+	_, err := r.db.Exec(query, monitor.ID, monitor.NextExpectedAt, monitor.LastPingAt, monitor.LastStatus)
+	if err != nil {
+		log.Println("Error updating cron monitor state:", err)
+	}
+	return err
+}
+
+func (r *cronMonitorRepository) CreateRun(run *model.CronRun) error {
+	query := `
+		INSERT INTO cron_runs (monitor_id, status, late, scheduled_at, started_at, finished_at, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		run.MonitorID,
+		run.Status,
+		run.Late,
+		run.ScheduledAt,
+		run.StartedAt,
+		run.FinishedAt,
+		run.DurationMs,
+	).Scan(&run.ID, &run.CreatedAt); err != nil {
+		log.Println("Error creating cron run:", err)
+		return err
+	}
+	return nil
+}
+
+const cronRunColumns = `id, monitor_id, status, late, scheduled_at, started_at, finished_at, duration_ms, created_at`
+
+func scanCronRun(row interface{ Scan(...interface{}) error }) (*model.CronRun, error) {
+	run := &model.CronRun{}
+	var scheduledAt, startedAt, finishedAt sql.NullTime
+	var durationMs sql.NullInt64
+	if err := row.Scan(
+		&run.ID,
+		&run.MonitorID,
+		&run.Status,
+		&run.Late,
+		&scheduledAt,
+		&startedAt,
+		&finishedAt,
+		&durationMs,
+		&run.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	run.ScheduledAt = nullTimePtr(scheduledAt)
+	run.StartedAt = nullTimePtr(startedAt)
+	run.FinishedAt = nullTimePtr(finishedAt)
+	if durationMs.Valid {
+		run.DurationMs = &durationMs.Int64
+	}
+	return run, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func (r *cronMonitorRepository) GetOpenRun(monitorID int) (*model.CronRun, error) {
+	query := `SELECT ` + cronRunColumns + ` FROM cron_runs WHERE monitor_id = $1 AND status = 'running' ORDER BY id DESC LIMIT 1`
+	return r.queryRun(query, monitorID)
+}
+
+func (r *cronMonitorRepository) GetLatestRun(monitorID int) (*model.CronRun, error) {
+	query := `SELECT ` + cronRunColumns + ` FROM cron_runs WHERE monitor_id = $1 ORDER BY id DESC LIMIT 1`
+	return r.queryRun(query, monitorID)
+}
+
+func (r *cronMonitorRepository) queryRun(query string, args ...interface{}) (*model.CronRun, error) {
+	run, err := scanCronRun(r.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching cron run:", err)
+		return nil, err
+	}
+	return run, nil
+}
+
+func (r *cronMonitorRepository) UpdateRun(run *model.CronRun) error {
+	query := `UPDATE cron_runs SET status = $2, late = $3, started_at = $4, finished_at = $5, duration_ms = $6 WHERE id = $1`
+	_, err := r.db.Exec(query, run.ID, run.Status, run.Late, run.StartedAt, run.FinishedAt, run.DurationMs)
+	if err != nil {
+		log.Println("Error updating cron run:", err)
+	}
+	return err
+}
+
+// ListRuns returns the latest runs of a monitor, newest first
+func (r *cronMonitorRepository) ListRuns(monitorID int, limit int) ([]model.CronRun, error) {
+	query := `SELECT ` + cronRunColumns + ` FROM cron_runs WHERE monitor_id = $1 ORDER BY id DESC LIMIT $2`
+	rows, err := r.db.Query(query, monitorID, limit)
+	if err != nil {
+		log.Println("Error listing cron runs:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	runs := []model.CronRun{}
+	for rows.Next() {
+		run, err := scanCronRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
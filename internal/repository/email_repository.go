@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type emailRepository struct {
+	db *sql.DB
+}
+
+// EmailRepository defines the methods implemented by the email outbox repository
+type EmailRepository interface {
+	CreateTable() error
+	Enqueue(email *model.OutboxEmail) error
+	// ListPending returns undelivered emails with fewer than maxAttempts attempts, oldest first
+	ListPending(maxAttempts int, limit int) ([]model.OutboxEmail, error)
+	// MarkSent records the delivery and clears the body, which may hold a single-use token
+	MarkSent(id int, at time.Time) error
+	MarkFailed(id int, errMessage string) error
+	// DeleteBefore deletes the emails queued before the given time, sent or not, and returns how many
+	DeleteBefore(before time.Time) (int64, error)
+}
+
+func NewEmailRepository(db *sql.DB) EmailRepository {
+	return &emailRepository{db: db}
+}
+
+func (r *emailRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS email_outbox (
+		id SERIAL PRIMARY KEY,
+		kind VARCHAR(50) NOT NULL,
+		recipient VARCHAR(255) NOT NULL,
+		subject TEXT NOT NULL,
+		body TEXT NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		sent_at TIMESTAMP
+	);
+	create index if not exists idx_email_outbox_pending on email_outbox(id) where sent_at is null;
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *emailRepository) Enqueue(email *model.OutboxEmail) error {
+	query := `
+		INSERT INTO email_outbox (kind, recipient, subject, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query, email.Kind, email.To, email.Subject, email.Body).Scan(&email.ID, &email.CreatedAt); err != nil {
+		log.Println("Error enqueueing email:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *emailRepository) ListPending(maxAttempts int, limit int) ([]model.OutboxEmail, error) {
+	query := `
+		SELECT id, kind, recipient, subject, body, attempts, last_error, created_at
+		FROM email_outbox
+		WHERE sent_at IS NULL AND attempts < $1
+		ORDER BY id
+		LIMIT $2
+	`
+	rows, err := r.db.Query(query, maxAttempts, limit)
+	if err != nil {
+		log.Println("Error listing pending emails:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	emails := []model.OutboxEmail{}
+	for rows.Next() {
+		var e model.OutboxEmail
+		if err := rows.Scan(&e.ID, &e.Kind, &e.To, &e.Subject, &e.Body, &e.Attempts, &e.LastError, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+func (r *emailRepository) MarkSent(id int, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE email_outbox SET sent_at = $2, attempts = attempts + 1, last_error = '', body = '' WHERE id = $1`, id, at)
+	if err != nil {
+		log.Println("Error marking email sent:", err)
+	}
+	return err
+}
+
+func (r *emailRepository) MarkFailed(id int, errMessage string) error {
+	_, err := r.db.Exec(`UPDATE email_outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1`, id, errMessage)
+	if err != nil {
+		log.Println("Error marking email failed:", err)
+	}
+	return err
+}
+
+func (r *emailRepository) DeleteBefore(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM email_outbox WHERE created_at < $1`, before)
+	if err != nil {
+		log.Println("Error deleting old emails:", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type heartbeatRepository struct {
+	db *sql.DB
+}
+
+// HeartbeatRepository defines the methods implemented by the heartbeat repository
+type HeartbeatRepository interface {
+	CreateTable() error
+	Touch(heartbeat model.IngestionHeartbeat) error
+	ListHeartbeats(projectID string) ([]model.IngestionHeartbeat, error)
+	PruneHeartbeats(before time.Time) error
+	UpsertConfig(config *model.HeartbeatConfig) error
+	GetConfig(projectID string) (*model.HeartbeatConfig, error)
+	ListEnabledConfigs() ([]model.HeartbeatConfig, error)
+	DeleteConfig(projectID string) error
+}
+
+func NewHeartbeatRepository(db *sql.DB) HeartbeatRepository {
+	return &heartbeatRepository{db: db}
+}
+
+func (r *heartbeatRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS ingestion_heartbeats (
+		project_id VARCHAR(255) NOT NULL,
+		connection_id VARCHAR(255) NOT NULL,
+		last_seen_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (project_id, connection_id)
+	);
+	CREATE TABLE IF NOT EXISTS heartbeat_configs (
+		project_id VARCHAR(255) PRIMARY KEY,
+		interval_seconds INT NOT NULL,
+		enabled BOOLEAN DEFAULT TRUE,
+		updated_by INT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+// Touch records that a metric was received. Older timestamps never move last_seen_at back.
+func (r *heartbeatRepository) Touch(heartbeat model.IngestionHeartbeat) error {
+	query := `
+		INSERT INTO ingestion_heartbeats (project_id, connection_id, last_seen_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (project_id, connection_id)
+		DO UPDATE SET last_seen_at = GREATEST(ingestion_heartbeats.last_seen_at, EXCLUDED.last_seen_at)
+	`
+	_, err := r.db.Exec(query, heartbeat.ProjectID, heartbeat.ConnectionID, heartbeat.LastSeenAt)
+	if err != nil {
+		log.Println("Error recording ingestion heartbeat:", err)
+	}
+	return err
+}
+
+// ListHeartbeats returns the connections of a project, most recently seen first
+func (r *heartbeatRepository) ListHeartbeats(projectID string) ([]model.IngestionHeartbeat, error) {
+	query := `SELECT project_id, connection_id, last_seen_at FROM ingestion_heartbeats WHERE project_id = $1 ORDER BY last_seen_at DESC`
+	rows, err := r.db.Query(query, projectID)
+	if err != nil {
+		log.Println("Error listing ingestion heartbeats:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	heartbeats := []model.IngestionHeartbeat{}
+	for rows.Next() {
+		var h model.IngestionHeartbeat
+		if err := rows.Scan(&h.ProjectID, &h.ConnectionID, &h.LastSeenAt); err != nil {
+			return nil, err
+		}
+		heartbeats = append(heartbeats, h)
+	}
+	return heartbeats, rows.Err()
+}
+
+// PruneHeartbeats forgets connections that have not sent anything since before
+func (r *heartbeatRepository) PruneHeartbeats(before time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM ingestion_heartbeats WHERE last_seen_at < $1`, before)
+	if err != nil {
+		log.Println("Error pruning ingestion heartbeats:", err)
+	}
+	return err
+}
+
+func (r *heartbeatRepository) UpsertConfig(config *model.HeartbeatConfig) error {
+	query := `
+		INSERT INTO heartbeat_configs (project_id, interval_seconds, enabled, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (project_id)
+		DO UPDATE SET interval_seconds = EXCLUDED.interval_seconds, enabled = EXCLUDED.enabled,
+			updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+	`
+	if _, err := r.db.Exec(query, config.ProjectID, config.IntervalSeconds, config.Enabled, config.UpdatedBy, config.UpdatedAt); err != nil {
+		log.Println("Error saving heartbeat config:", err)
+		return err
+	}
+	return nil
+}
+
+// GetConfig returns the heartbeat config of a project, or nil when none is configured
+func (r *heartbeatRepository) GetConfig(projectID string) (*model.HeartbeatConfig, error) {
+	query := `SELECT project_id, interval_seconds, enabled, updated_by, updated_at FROM heartbeat_configs WHERE project_id = $1`
+	c := &model.HeartbeatConfig{}
+	err := r.db.QueryRow(query, projectID).Scan(&c.ProjectID, &c.IntervalSeconds, &c.Enabled, &c.UpdatedBy, &c.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching heartbeat config:", err)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *heartbeatRepository) ListEnabledConfigs() ([]model.HeartbeatConfig, error) {
+	query := `SELECT project_id, interval_seconds, enabled, updated_by, updated_at FROM heartbeat_configs WHERE enabled = TRUE`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		log.Println("Error listing heartbeat configs:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	configs := []model.HeartbeatConfig{}
+	for rows.Next() {
+		var c model.HeartbeatConfig
+		if err := rows.Scan(&c.ProjectID, &c.IntervalSeconds, &c.Enabled, &c.UpdatedBy, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		configs = append(configs, c)
+	}
+	return configs, rows.Err()
+}
+
+func (r *heartbeatRepository) DeleteConfig(projectID string) error {
+	_, err := r.db.Exec(`DELETE FROM heartbeat_configs WHERE project_id = $1`, projectID)
+	if err != nil {
+		log.Println("Error deleting heartbeat config:", err)
+	}
+	return err
+}
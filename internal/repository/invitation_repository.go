@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type invitationRepository struct {
+	db *sql.DB
+}
+
+// InvitationRepository stores organization invitations
+type InvitationRepository interface {
+	CreateTable() error
+	// CreateInvitation stores an invitation, revoking earlier pending invitations
+	// of the same email to the same organization
+	CreateInvitation(invite *model.Invitation) error
+	GetInvitation(id int) (*model.Invitation, error)
+	// GetInvitationByHash returns the invitation with this token hash, or nil
+	GetInvitationByHash(tokenHash string) (*model.Invitation, error)
+	// ListPending returns invitations neither accepted, revoked nor expired
+	ListPending(orgID int, now time.Time) ([]model.Invitation, error)
+	// UpdateToken replaces the token of a pending invitation when it is sent again
+	UpdateToken(invite *model.Invitation) error
+	RevokeInvitation(id int, at time.Time) error
+	// AcceptInvitation marks a pending invitation accepted by userID. It returns false
+	// when the invitation was already accepted, revoked or has expired.
+	AcceptInvitation(id int, userID int, now time.Time) (bool, error)
+}
+
+func NewInvitationRepository(db *sql.DB) InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+func (r *invitationRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS organization_invitations (
+		id SERIAL PRIMARY KEY,
+		organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		email VARCHAR(255) NOT NULL,
+		role VARCHAR(20) NOT NULL,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		invited_by INT NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		sent_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		accepted_by INT,
+		accepted_at TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+	create index if not exists idx_organization_invitations_org on organization_invitations(organization_id);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *invitationRepository) CreateInvitation(invite *model.Invitation) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	revoke := `
+		UPDATE organization_invitations SET revoked_at = $3
+		WHERE organization_id = $1 AND LOWER(email) = LOWER($2) AND accepted_at IS NULL AND revoked_at IS NULL
+	`
+	if _, err := tx.Exec(revoke, invite.OrganizationID, invite.Email, invite.SentAt); err != nil {
+		log.Println("Error revoking earlier invitations:", err)
+		return err
+	}
+	query := `
+		INSERT INTO organization_invitations (organization_id, email, role, token_hash, invited_by, expires_at, sent_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	if err := tx.QueryRow(query,
+		invite.OrganizationID,
+		invite.Email,
+		invite.Role,
+		invite.TokenHash,
+		invite.InvitedBy,
+		invite.ExpiresAt,
+		invite.SentAt,
+	).Scan(&invite.ID, &invite.CreatedAt); err != nil {
+		log.Println("Error creating invitation:", err)
+		return err
+	}
+	return tx.Commit()
+}
+
+const invitationColumns = `id, organization_id, email, role, token_hash, invited_by, expires_at, sent_at, created_at, accepted_by, accepted_at, revoked_at`
+
+func scanInvitation(row interface{ Scan(...interface{}) error }) (*model.Invitation, error) {
+	inv := &model.Invitation{}
+	var acceptedBy sql.NullInt64
+	var acceptedAt, revokedAt sql.NullTime
+	if err := row.Scan(
+		&inv.ID,
+		&inv.OrganizationID,
+		&inv.Email,
+		&inv.Role,
+		&inv.TokenHash,
+		&inv.InvitedBy,
+		&inv.ExpiresAt,
+		&inv.SentAt,
+		&inv.CreatedAt,
+		&acceptedBy,
+		&acceptedAt,
+		&revokedAt,
+	); err != nil {
+		return nil, err
+	}
+	if acceptedBy.Valid {
+		id := int(acceptedBy.Int64)
+		inv.AcceptedBy = &id
+	}
+	inv.AcceptedAt = nullTimePtr(acceptedAt)
+	inv.RevokedAt = nullTimePtr(revokedAt)
+	return inv, nil
+}
+
+func (r *invitationRepository) GetInvitation(id int) (*model.Invitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM organization_invitations WHERE id = $1`
+	inv, err := scanInvitation(r.db.QueryRow(query, id))
+	if err != nil {
+		log.Println("Error fetching invitation:", err)
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (r *invitationRepository) GetInvitationByHash(tokenHash string) (*model.Invitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM organization_invitations WHERE token_hash = $1`
+	inv, err := scanInvitation(r.db.QueryRow(query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching invitation by token:", err)
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (r *invitationRepository) ListPending(orgID int, now time.Time) ([]model.Invitation, error) {
+	query := `
+		SELECT ` + invitationColumns + ` FROM organization_invitations
+		WHERE organization_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY id
+	`
+	rows, err := r.db.Query(query, orgID, now)
+	if err != nil {
+		log.Println("Error listing invitations:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	invites := []model.Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *inv)
+	}
+	return invites, rows.Err()
+}
+
+func (r *invitationRepository) UpdateToken(invite *model.Invitation) error {
+	query := `
+		UPDATE organization_invitations SET token_hash = $2, expires_at = $3, sent_at = $4
+		WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+	`
+	_, err := r.db.Exec(query, invite.ID, invite.TokenHash, invite.ExpiresAt, invite.SentAt)
+	if err != nil {
+		log.Println("Error updating invitation token:", err)
+	}
+	return err
+}
+
+func (r *invitationRepository) RevokeInvitation(id int, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE organization_invitations SET revoked_at = $2 WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL`, id, at)
+	if err != nil {
+		log.Println("Error revoking invitation:", err)
+	}
+	return err
+}
+
+func (r *invitationRepository) AcceptInvitation(id int, userID int, now time.Time) (bool, error) {
+	query := `
+		UPDATE organization_invitations SET accepted_by = $2, accepted_at = $3
+		WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $3
+	`
+	result, err := r.db.Exec(query, id, userID, now)
+	if err != nil {
+		log.Println("Error accepting invitation:", err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type loginAttemptRepository struct {
+	db *sql.DB
+}
+
+// LoginAttemptRepository counts failed logins per account and per IP address
+type LoginAttemptRepository interface {
+	CreateTable() error
+	// GetFailure returns the failure count of a key, or nil
+	GetFailure(scope string, key string) (*model.LoginFailure, error)
+	// RecordFailure adds a failure, restarting the count when the previous failure
+	// is older than windowStart, and returns the new count
+	RecordFailure(scope string, key string, now time.Time, windowStart time.Time) (*model.LoginFailure, error)
+	Lock(scope string, key string, until time.Time) error
+	Reset(scope string, key string) error
+}
+
+func NewLoginAttemptRepository(db *sql.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS login_failures (
+		scope VARCHAR(10) NOT NULL,
+		key VARCHAR(255) NOT NULL,
+		failures INT NOT NULL,
+		last_failure_at TIMESTAMP NOT NULL,
+		locked_until TIMESTAMP,
+		PRIMARY KEY (scope, key)
+	);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func scanLoginFailure(row interface{ Scan(...interface{}) error }) (*model.LoginFailure, error) {
+	f := &model.LoginFailure{}
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&f.Scope, &f.Key, &f.Failures, &f.LastFailureAt, &lockedUntil); err != nil {
+		return nil, err
+	}
+	f.LockedUntil = nullTimePtr(lockedUntil)
+	return f, nil
+}
+
+func (r *loginAttemptRepository) GetFailure(scope string, key string) (*model.LoginFailure, error) {
+	query := `SELECT scope, key, failures, last_failure_at, locked_until FROM login_failures WHERE scope = $1 AND key = $2`
+	f, err := scanLoginFailure(r.db.QueryRow(query, scope, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching login failures:", err)
+		return nil, err
+	}
+	return f, nil
+}
+
+func (r *loginAttemptRepository) RecordFailure(scope string, key string, now time.Time, windowStart time.Time) (*model.LoginFailure, error) {
+	query := `
+		INSERT INTO login_failures (scope, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN login_failures.last_failure_at < $4 THEN 1 ELSE login_failures.failures + 1 END,
+			last_failure_at = $3
+		RETURNING scope, key, failures, last_failure_at, locked_until
+	`
+	f, err := scanLoginFailure(r.db.QueryRow(query, scope, key, now, windowStart))
+	if err != nil {
+		log.Println("Error recording login failure:", err)
+		return nil, err
+	}
+	return f, nil
+}
+
+func (r *loginAttemptRepository) Lock(scope string, key string, until time.Time) error {
+	_, err := r.db.Exec(`UPDATE login_failures SET locked_until = $3 WHERE scope = $1 AND key = $2`, scope, key, until)
+	if err != nil {
+		log.Println("Error locking login:", err)
+	}
+	return err
+}
+
+func (r *loginAttemptRepository) Reset(scope string, key string) error {
+	_, err := r.db.Exec(`DELETE FROM login_failures WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		log.Println("Error resetting login failures:", err)
+	}
+	return err
+}
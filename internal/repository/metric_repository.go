@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type metricRepository struct {
+	db *sql.DB
+}
+
+// MetricRepository defines the queries over the metrics table
+type MetricRepository interface {
+	SaveMetric(metric *model.Metric) error
+	HourlyRollups(projectID string, route string, from time.Time, to time.Time) ([]model.MetricRollup, error)
+	CountGoodRequests(projectID string, route string, latencyThresholdMs int64, from time.Time, to time.Time) (int64, int64, error)
+	DailyAvailability(projectID string, route string, from time.Time, to time.Time) ([]model.DailyAvailability, error)
+}
+
+func NewMetricRepository(db *sql.DB) MetricRepository {
+	return &metricRepository{db: db}
+}
+
+func (r *metricRepository) SaveMetric(metric *model.Metric) error {
+	query := `
+		INSERT INTO metrics (project_id, route, method, status_code, response_time, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		metric.ProjectID,
+		metric.Route,
+		metric.Method,
+		metric.StatusCode,
+		metric.ResponseTime,
+		metric.Timestamp,
+	).Scan(&metric.ID, &metric.CreatedAt); err != nil {
+		log.Println("Error saving metric:", err)
+		return err
+	}
+	return nil
+}
+
+// HourlyRollups aggregates request count, 5xx count and p95 latency per hour in [from, to).
+// An empty route aggregates all routes of the project. Hours without traffic are not returned.
+func (r *metricRepository) HourlyRollups(projectID string, route string, from time.Time, to time.Time) ([]model.MetricRollup, error) {
+	query := `
+		SELECT (timestamp / 3600000) * 3600000 AS bucket,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status_code >= 500),
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY response_time), 0)
+		FROM metrics
+		WHERE project_id = $1 AND ($2 = '' OR route = $2) AND timestamp >= $3 AND timestamp < $4
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+	rows, err := r.db.Query(query, projectID, route, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		log.Println("Error querying metric rollups:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	rollups := []model.MetricRollup{}
+	for rows.Next() {
+		var bucket int64
+		var rollup model.MetricRollup
+		if err := rows.Scan(&bucket, &rollup.Requests, &rollup.Errors, &rollup.P95Latency); err != nil {
+			log.Println("Error scanning metric rollup:", err)
+			return nil, err
+		}
+		rollup.BucketStart = time.UnixMilli(bucket).UTC()
+		rollups = append(rollups, rollup)
+	}
+	return rollups, rows.Err()
+}
+
+// CountGoodRequests returns the total and the good request counts in [from, to).
+// A request is good when its status is below 500 and, when latencyThresholdMs is
+// positive, its response time is within the threshold.
+func (r *metricRepository) CountGoodRequests(projectID string, route string, latencyThresholdMs int64, from time.Time, to time.Time) (int64, int64, error) {
+	query := `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE status_code < 500 AND ($3 <= 0 OR response_time <= $3))
+		FROM metrics
+		WHERE project_id = $1 AND ($2 = '' OR route = $2) AND timestamp >= $4 AND timestamp < $5
+	`
+	var total, good int64
+	if err := r.db.QueryRow(query, projectID, route, latencyThresholdMs, from.UnixMilli(), to.UnixMilli()).Scan(&total, &good); err != nil {
+		log.Println("Error counting good requests:", err)
+		return 0, 0, err
+	}
+	return total, good, nil
+}
+
+// DailyAvailability counts requests and non-5xx requests per UTC day in [from, to).
+// Days without traffic are not returned.
+func (r *metricRepository) DailyAvailability(projectID string, route string, from time.Time, to time.Time) ([]model.DailyAvailability, error) {
+	query := `
+		SELECT (timestamp / 86400000) * 86400000 AS bucket,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status_code < 500)
+		FROM metrics
+		WHERE project_id = $1 AND ($2 = '' OR route = $2) AND timestamp >= $3 AND timestamp < $4
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+	rows, err := r.db.Query(query, projectID, route, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		log.Println("Error querying daily availability:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDailyAvailability(rows)
+}
+
+func scanDailyAvailability(rows *sql.Rows) ([]model.DailyAvailability, error) {
+	days := []model.DailyAvailability{}
+	for rows.Next() {
+		var bucket int64
+		var day model.DailyAvailability
+		if err := rows.Scan(&bucket, &day.Total, &day.Good); err != nil {
+			log.Println("Error scanning daily availability:", err)
+			return nil, err
+		}
+		day.Day = time.UnixMilli(bucket).UTC()
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type mfaRepository struct {
+	db *sql.DB
+}
+
+// MFARepository stores TOTP enrolments and hashed recovery codes
+type MFARepository interface {
+	CreateTable() error
+	// GetMFA returns the enrolment of a user, or nil if there is none
+	GetMFA(userID int) (*model.UserMFA, error)
+	// SavePending starts (or restarts) an unconfirmed enrolment
+	SavePending(userID int, secret string) error
+	Enable(userID int, step int64, at time.Time) error
+	// UseStep records an accepted TOTP step and reports false if it is not newer than the last one
+	UseStep(userID int, step int64) (bool, error)
+	// Delete removes the enrolment and the recovery codes of a user
+	Delete(userID int) error
+	ReplaceRecoveryCodes(userID int, codeHashes []string) error
+	// UseRecoveryCode consumes an unused recovery code and reports whether it existed
+	UseRecoveryCode(userID int, codeHash string, at time.Time) (bool, error)
+}
+
+func NewMFARepository(db *sql.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS user_mfa (
+		user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		secret VARCHAR(64) NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		last_step BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		confirmed_at TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash VARCHAR(64) NOT NULL,
+		used_at TIMESTAMP
+	);
+	create index if not exists idx_mfa_recovery_codes_user on mfa_recovery_codes(user_id);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *mfaRepository) GetMFA(userID int) (*model.UserMFA, error) {
+	query := `SELECT user_id, secret, enabled, last_step, created_at, confirmed_at FROM user_mfa WHERE user_id = $1`
+	m := &model.UserMFA{}
+	var confirmedAt sql.NullTime
+	err := r.db.QueryRow(query, userID).Scan(&m.UserID, &m.Secret, &m.Enabled, &m.LastStep, &m.CreatedAt, &confirmedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching MFA enrolment:", err)
+		return nil, err
+	}
+	if confirmedAt.Valid {
+		m.ConfirmedAt = &confirmedAt.Time
+	}
+	return m, nil
+}
+
+func (r *mfaRepository) SavePending(userID int, secret string) error {
+	query := `
+		INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, enabled = FALSE, last_step = 0,
+			created_at = CURRENT_TIMESTAMP, confirmed_at = NULL
+	`
+	_, err := r.db.Exec(query, userID, secret)
+	if err != nil {
+		log.Println("Error saving pending MFA enrolment:", err)
+	}
+	return err
+}
+
+func (r *mfaRepository) Enable(userID int, step int64, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE user_mfa SET enabled = TRUE, last_step = $2, confirmed_at = $3 WHERE user_id = $1`, userID, step, at)
+	if err != nil {
+		log.Println("Error enabling MFA:", err)
+	}
+	return err
+}
+
+func (r *mfaRepository) UseStep(userID int, step int64) (bool, error) {
+	result, err := r.db.Exec(`UPDATE user_mfa SET last_step = $2 WHERE user_id = $1 AND last_step < $2`, userID, step)
+	if err != nil {
+		log.Println("Error recording TOTP step:", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *mfaRepository) Delete(userID int) error {
+	if _, err := r.db.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		log.Println("Error deleting recovery codes:", err)
+		return err
+	}
+	_, err := r.db.Exec(`DELETE FROM user_mfa WHERE user_id = $1`, userID)
+	if err != nil {
+		log.Println("Error deleting MFA enrolment:", err)
+	}
+	return err
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		log.Println("Error deleting recovery codes:", err)
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			log.Println("Error saving recovery code:", err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *mfaRepository) UseRecoveryCode(userID int, codeHash string, at time.Time) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = $3
+		WHERE id = (SELECT id FROM mfa_recovery_codes WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL LIMIT 1)
+	`
+	result, err := r.db.Exec(query, userID, codeHash, at)
+	if err != nil {
+		log.Println("Error using recovery code:", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type oidcRepository struct {
+	db *sql.DB
+}
+
+// OIDCRepository stores pending SSO logins and the provider identities linked to users
+type OIDCRepository interface {
+	CreateTable() error
+	CreateState(state *model.OIDCState) error
+	// ConsumeState deletes and returns an unexpired state, or nil
+	ConsumeState(stateHash string, now time.Time) (*model.OIDCState, error)
+	// GetIdentity returns the identity of a provider account, or nil
+	GetIdentity(issuer string, subject string) (*model.UserIdentity, error)
+	CreateIdentity(identity *model.UserIdentity) error
+	TouchIdentity(id int, email string, at time.Time) error
+	ListIdentities(userID int) ([]model.UserIdentity, error)
+	DeleteIdentity(userID int, id int) error
+}
+
+func NewOIDCRepository(db *sql.DB) OIDCRepository {
+	return &oidcRepository{db: db}
+}
+
+func (r *oidcRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS oidc_states (
+		state_hash VARCHAR(64) PRIMARY KEY,
+		nonce VARCHAR(255) NOT NULL,
+		code_verifier VARCHAR(255) NOT NULL,
+		link_user_id INT REFERENCES users(id) ON DELETE CASCADE,
+		expires_at TIMESTAMP NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS user_identities (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		issuer VARCHAR(255) NOT NULL,
+		subject VARCHAR(255) NOT NULL,
+		email VARCHAR(255) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_login_at TIMESTAMP,
+		UNIQUE (issuer, subject)
+	);
+	create index if not exists idx_user_identities_user on user_identities(user_id);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *oidcRepository) CreateState(state *model.OIDCState) error {
+	// abandoned logins are cleaned up as new ones start
+	if _, err := r.db.Exec(`DELETE FROM oidc_states WHERE expires_at <= $1`, time.Now().UTC()); err != nil {
+		log.Println("Error purging expired OIDC states:", err)
+	}
+	query := `INSERT INTO oidc_states (state_hash, nonce, code_verifier, link_user_id, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.Exec(query, state.StateHash, state.Nonce, state.CodeVerifier, state.LinkUserID, state.ExpiresAt)
+	if err != nil {
+		log.Println("Error creating OIDC state:", err)
+	}
+	return err
+}
+
+func (r *oidcRepository) ConsumeState(stateHash string, now time.Time) (*model.OIDCState, error) {
+	query := `
+		DELETE FROM oidc_states WHERE state_hash = $1 AND expires_at > $2
+		RETURNING state_hash, nonce, code_verifier, link_user_id, expires_at
+	`
+	state := &model.OIDCState{}
+	var linkUserID sql.NullInt64
+	err := r.db.QueryRow(query, stateHash, now).Scan(&state.StateHash, &state.Nonce, &state.CodeVerifier, &linkUserID, &state.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error consuming OIDC state:", err)
+		return nil, err
+	}
+	if linkUserID.Valid {
+		id := int(linkUserID.Int64)
+		state.LinkUserID = &id
+	}
+	return state, nil
+}
+
+const userIdentityColumns = `id, user_id, issuer, subject, email, created_at, last_login_at`
+
+func scanUserIdentity(row interface{ Scan(...interface{}) error }) (*model.UserIdentity, error) {
+	identity := &model.UserIdentity{}
+	var lastLoginAt sql.NullTime
+	if err := row.Scan(&identity.ID, &identity.UserID, &identity.Issuer, &identity.Subject, &identity.Email, &identity.CreatedAt, &lastLoginAt); err != nil {
+		return nil, err
+	}
+	identity.LastLoginAt = nullTimePtr(lastLoginAt)
+	return identity, nil
+}
+
+func (r *oidcRepository) GetIdentity(issuer string, subject string) (*model.UserIdentity, error) {
+	query := `SELECT ` + userIdentityColumns + ` FROM user_identities WHERE issuer = $1 AND subject = $2`
+	identity, err := scanUserIdentity(r.db.QueryRow(query, issuer, subject))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching user identity:", err)
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (r *oidcRepository) CreateIdentity(identity *model.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query, identity.UserID, identity.Issuer, identity.Subject, identity.Email, identity.LastLoginAt).Scan(&identity.ID, &identity.CreatedAt); err != nil {
+		log.Println("Error creating user identity:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *oidcRepository) TouchIdentity(id int, email string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE user_identities SET email = $2, last_login_at = $3 WHERE id = $1`, id, email, at)
+	if err != nil {
+		log.Println("Error updating user identity:", err)
+	}
+	return err
+}
+
+func (r *oidcRepository) ListIdentities(userID int) ([]model.UserIdentity, error) {
+	query := `SELECT ` + userIdentityColumns + ` FROM user_identities WHERE user_id = $1 ORDER BY id`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		log.Println("Error listing user identities:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	identities := []model.UserIdentity{}
+	for rows.Next() {
+		identity, err := scanUserIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, *identity)
+	}
+	return identities, rows.Err()
+}
+
+func (r *oidcRepository) DeleteIdentity(userID int, id int) error {
+	_, err := r.db.Exec(`DELETE FROM user_identities WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		log.Println("Error deleting user identity:", err)
+	}
+	return err
+}
@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+)
+
+type organizationRepository struct {
+	db *sql.DB
+}
+
+// OrganizationRepository stores organizations, their members and their projects
+type OrganizationRepository interface {
+	CreateTable() error
+	// CreateOrganization creates the organization with ownerID as its first owner
+	CreateOrganization(org *model.Organization, ownerID int) error
+	GetOrganization(id int) (*model.Organization, error)
+	ListUserOrganizations(userID int) ([]model.Organization, error)
+	DeleteOrganization(id int) error
+	// GetMemberRole returns the role of a user in an organization, or "" if not a member
+	GetMemberRole(orgID int, userID int) (string, error)
+	ListMembers(orgID int) ([]model.Member, error)
+	AddMember(orgID int, userID int, role string) error
+	UpdateMemberRole(orgID int, userID int, role string) error
+	RemoveMember(orgID int, userID int) error
+	CountOwners(orgID int) (int, error)
+	CreateProject(project *model.Project) error
+	GetProject(id string) (*model.Project, error)
+	ListProjects(orgID int) ([]model.Project, error)
+	DeleteProject(id string) error
+	// GetProjectRole returns the role of a user in the organization owning a project,
+	// or "" if the project is unknown or the user is not a member
+	GetProjectRole(projectID string, userID int) (string, error)
+	// ListUserProjectIDs returns the projects of every organization the user belongs to
+	ListUserProjectIDs(userID int) ([]string, error)
+}
+
+func NewOrganizationRepository(db *sql.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+func (r *organizationRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS organizations (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		created_by INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS organization_members (
+		organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role VARCHAR(20) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (organization_id, user_id)
+	);
+	CREATE TABLE IF NOT EXISTS projects (
+		id VARCHAR(255) PRIMARY KEY,
+		organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		created_by INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	create index if not exists idx_organization_members_user on organization_members(user_id);
+	create index if not exists idx_projects_organization on projects(organization_id);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *organizationRepository) CreateOrganization(org *model.Organization, ownerID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `INSERT INTO organizations (name, created_by) VALUES ($1, $2) RETURNING id, created_at`
+	if err := tx.QueryRow(query, org.Name, org.CreatedBy).Scan(&org.ID, &org.CreatedAt); err != nil {
+		log.Println("Error creating organization:", err)
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)`, org.ID, ownerID, model.RoleOwner); err != nil {
+		log.Println("Error adding organization owner:", err)
+		return err
+	}
+	org.Role = model.RoleOwner
+	return tx.Commit()
+}
+
+func (r *organizationRepository) GetOrganization(id int) (*model.Organization, error) {
+	query := `SELECT id, name, created_by, created_at FROM organizations WHERE id = $1`
+	org := &model.Organization{}
+	if err := r.db.QueryRow(query, id).Scan(&org.ID, &org.Name, &org.CreatedBy, &org.CreatedAt); err != nil {
+		log.Println("Error fetching organization:", err)
+		return nil, err
+	}
+	return org, nil
+}
+
+func (r *organizationRepository) ListUserOrganizations(userID int) ([]model.Organization, error) {
+	query := `
+		SELECT o.id, o.name, o.created_by, o.created_at, m.role
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.id
+	`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		log.Println("Error listing organizations:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	orgs := []model.Organization{}
+	for rows.Next() {
+		var org model.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.CreatedBy, &org.CreatedAt, &org.Role); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+func (r *organizationRepository) DeleteOrganization(id int) error {
+	_, err := r.db.Exec(`DELETE FROM organizations WHERE id = $1`, id)
+	if err != nil {
+		log.Println("Error deleting organization:", err)
+	}
+	return err
+}
+
+func (r *organizationRepository) GetMemberRole(orgID int, userID int) (string, error) {
+	var role string
+	err := r.db.QueryRow(`SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2`, orgID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		log.Println("Error fetching member role:", err)
+		return "", err
+	}
+	return role, nil
+}
+
+func (r *organizationRepository) ListMembers(orgID int) ([]model.Member, error) {
+	query := `
+		SELECT m.organization_id, m.user_id, u.username, u.email, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY m.created_at
+	`
+	rows, err := r.db.Query(query, orgID)
+	if err != nil {
+		log.Println("Error listing members:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	members := []model.Member{}
+	for rows.Next() {
+		var m model.Member
+		if err := rows.Scan(&m.OrganizationID, &m.UserID, &m.Username, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (r *organizationRepository) AddMember(orgID int, userID int, role string) error {
+	_, err := r.db.Exec(`INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)`, orgID, userID, role)
+	if err != nil {
+		log.Println("Error adding member:", err)
+	}
+	return err
+}
+
+func (r *organizationRepository) UpdateMemberRole(orgID int, userID int, role string) error {
+	_, err := r.db.Exec(`UPDATE organization_members SET role = $3 WHERE organization_id = $1 AND user_id = $2`, orgID, userID, role)
+	if err != nil {
+		log.Println("Error updating member role:", err)
+	}
+	return err
+}
+
+func (r *organizationRepository) RemoveMember(orgID int, userID int) error {
+	_, err := r.db.Exec(`DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2`, orgID, userID)
+	if err != nil {
+		log.Println("Error removing member:", err)
+	}
+	return err
+}
+
+func (r *organizationRepository) CountOwners(orgID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = $2`, orgID, model.RoleOwner).Scan(&count)
+	if err != nil {
+		log.Println("Error counting owners:", err)
+	}
+	return count, err
+}
+
+func (r *organizationRepository) CreateProject(project *model.Project) error {
+	query := `
+		INSERT INTO projects (id, organization_id, name, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`
+	if err := r.db.QueryRow(query, project.ID, project.OrganizationID, project.Name, project.CreatedBy).Scan(&project.CreatedAt); err != nil {
+		log.Println("Error creating project:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *organizationRepository) GetProject(id string) (*model.Project, error) {
+	query := `SELECT id, organization_id, name, created_by, created_at FROM projects WHERE id = $1`
+	p := &model.Project{}
+	err := r.db.QueryRow(query, id).Scan(&p.ID, &p.OrganizationID, &p.Name, &p.CreatedBy, &p.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching project:", err)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *organizationRepository) ListProjects(orgID int) ([]model.Project, error) {
+	query := `SELECT id, organization_id, name, created_by, created_at FROM projects WHERE organization_id = $1 ORDER BY created_at`
+	rows, err := r.db.Query(query, orgID)
+	if err != nil {
+		log.Println("Error listing projects:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	projects := []model.Project{}
+	for rows.Next() {
+		var p model.Project
+		if err := rows.Scan(&p.ID, &p.OrganizationID, &p.Name, &p.CreatedBy, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+func (r *organizationRepository) DeleteProject(id string) error {
+	_, err := r.db.Exec(`DELETE FROM projects WHERE id = $1`, id)
+	if err != nil {
+		log.Println("Error deleting project:", err)
+	}
+	return err
+}
+
+func (r *organizationRepository) GetProjectRole(projectID string, userID int) (string, error) {
+	query := `
+		SELECT m.role
+		FROM projects p
+		JOIN organization_members m ON m.organization_id = p.organization_id
+		WHERE p.id = $1 AND m.user_id = $2
+	`
+	var role string
+	err := r.db.QueryRow(query, projectID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		log.Println("Error fetching project role:", err)
+		return "", err
+	}
+	return role, nil
+}
+
+func (r *organizationRepository) ListUserProjectIDs(userID int) ([]string, error) {
+	query := `
+		SELECT p.id
+		FROM projects p
+		JOIN organization_members m ON m.organization_id = p.organization_id
+		WHERE m.user_id = $1
+	`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		log.Println("Error listing user projects:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type projectKeyRepository struct {
+	db *sql.DB
+}
+
+// ProjectKeyRepository stores the hashed API keys of projects
+type ProjectKeyRepository interface {
+	CreateTable() error
+	CreateKey(key *model.ProjectKey) error
+	GetKey(id int) (*model.ProjectKey, error)
+	// GetKeyByHash returns the key with this secret hash, revoked or not, or nil if there is none
+	GetKeyByHash(keyHash string) (*model.ProjectKey, error)
+	ListKeys(projectID string) ([]model.ProjectKey, error)
+	HasActiveKeys(projectID string) (bool, error)
+	// RotateKey replaces the secret of an active key
+	RotateKey(key *model.ProjectKey, at time.Time) error
+	RevokeKey(id int, at time.Time) error
+	// RevokeUserKeys revokes every active key created by the user and returns them
+	RevokeUserKeys(userID int, at time.Time) ([]model.ProjectKey, error)
+}
+
+func NewProjectKeyRepository(db *sql.DB) ProjectKeyRepository {
+	return &projectKeyRepository{db: db}
+}
+
+func (r *projectKeyRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS project_keys (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		prefix VARCHAR(20) NOT NULL,
+		key_hash VARCHAR(64) UNIQUE NOT NULL,
+		created_by INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		rotated_at TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+	create index if not exists idx_project_keys_project on project_keys(project_id);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *projectKeyRepository) CreateKey(key *model.ProjectKey) error {
+	query := `
+		INSERT INTO project_keys (project_id, name, prefix, key_hash, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query, key.ProjectID, key.Name, key.Prefix, key.KeyHash, key.CreatedBy).Scan(&key.ID, &key.CreatedAt); err != nil {
+		log.Println("Error creating project key:", err)
+		return err
+	}
+	return nil
+}
+
+const projectKeyColumns = `id, project_id, name, prefix, key_hash, created_by, created_at, rotated_at, revoked_at`
+
+func scanProjectKey(row interface{ Scan(...interface{}) error }) (*model.ProjectKey, error) {
+	k := &model.ProjectKey{}
+	var rotatedAt, revokedAt sql.NullTime
+	if err := row.Scan(&k.ID, &k.ProjectID, &k.Name, &k.Prefix, &k.KeyHash, &k.CreatedBy, &k.CreatedAt, &rotatedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	k.RotatedAt = nullTimePtr(rotatedAt)
+	k.RevokedAt = nullTimePtr(revokedAt)
+	return k, nil
+}
+
+func (r *projectKeyRepository) GetKey(id int) (*model.ProjectKey, error) {
+	query := `SELECT ` + projectKeyColumns + ` FROM project_keys WHERE id = $1`
+	k, err := scanProjectKey(r.db.QueryRow(query, id))
+	if err != nil {
+		log.Println("Error fetching project key:", err)
+		return nil, err
+	}
+	return k, nil
+}
+
+func (r *projectKeyRepository) GetKeyByHash(keyHash string) (*model.ProjectKey, error) {
+	query := `SELECT ` + projectKeyColumns + ` FROM project_keys WHERE key_hash = $1`
+	k, err := scanProjectKey(r.db.QueryRow(query, keyHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching project key by hash:", err)
+		return nil, err
+	}
+	return k, nil
+}
+
+// HasActiveKeys reports whether a project has a key that is not revoked
+func (r *projectKeyRepository) HasActiveKeys(projectID string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM project_keys WHERE project_id = $1 AND revoked_at IS NULL)`
+	if err := r.db.QueryRow(query, projectID).Scan(&exists); err != nil {
+		log.Println("Error checking project keys:", err)
+		return false, err
+	}
+	return exists, nil
+}
+
+func (r *projectKeyRepository) ListKeys(projectID string) ([]model.ProjectKey, error) {
+	query := `SELECT ` + projectKeyColumns + ` FROM project_keys WHERE project_id = $1 ORDER BY id`
+	rows, err := r.db.Query(query, projectID)
+	if err != nil {
+		log.Println("Error listing project keys:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []model.ProjectKey{}
+	for rows.Next() {
+		k, err := scanProjectKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *projectKeyRepository) RotateKey(key *model.ProjectKey, at time.Time) error {
+	query := `UPDATE project_keys SET prefix = $2, key_hash = $3, rotated_at = $4 WHERE id = $1 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, key.ID, key.Prefix, key.KeyHash, at)
+	if err != nil {
+		log.Println("Error rotating project key:", err)
+	}
+	return err
+}
+
+func (r *projectKeyRepository) RevokeKey(id int, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE project_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL`, id, at)
+	if err != nil {
+		log.Println("Error revoking project key:", err)
+	}
+	return err
+}
+
+func (r *projectKeyRepository) RevokeUserKeys(userID int, at time.Time) ([]model.ProjectKey, error) {
+	query := `UPDATE project_keys SET revoked_at = $2 WHERE created_by = $1 AND revoked_at IS NULL RETURNING ` + projectKeyColumns
+	rows, err := r.db.Query(query, userID, at)
+	if err != nil {
+		log.Println("Error revoking project keys of user:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []model.ProjectKey{}
+	for rows.Next() {
+		k, err := scanProjectKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
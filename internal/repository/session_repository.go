@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+// SessionRepository stores the signed-in devices of users
+type SessionRepository interface {
+	CreateTable() error
+	CreateSession(session *model.Session) error
+	// GetSession returns a session of the user, or nil if there is none
+	GetSession(id int, userID int) (*model.Session, error)
+	// GetSessionByFamily returns the session of a refresh token family, or nil if there is none
+	GetSessionByFamily(familyID string) (*model.Session, error)
+	ListActiveSessions(userID int) ([]model.Session, error)
+	// IsSessionActive reports whether the session exists, belongs to the user and is not revoked
+	IsSessionActive(id int, userID int) (bool, error)
+	// TouchSession moves last seen to at when it is older than minInterval; an
+	// empty ip keeps the current one
+	TouchSession(id int, ip string, at time.Time, minInterval time.Duration) error
+	// RevokeSession revokes a session and returns its refresh token family, or "" if it was not active
+	RevokeSession(id int, userID int, at time.Time) (string, error)
+	// RevokeUserSessions revokes every active session of the user except exceptID
+	// and returns their refresh token families
+	RevokeUserSessions(userID int, exceptID int, at time.Time) ([]string, error)
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		family_id VARCHAR(64) UNIQUE NOT NULL,
+		device VARCHAR(255) NOT NULL DEFAULT '',
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP
+	);
+	create index if not exists idx_sessions_user on sessions(user_id);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *sessionRepository) CreateSession(session *model.Session) error {
+	query := `
+		INSERT INTO sessions (user_id, family_id, device, ip, user_agent, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		session.UserID,
+		session.FamilyID,
+		session.Device,
+		session.IP,
+		session.UserAgent,
+		session.LastSeenAt,
+	).Scan(&session.ID, &session.CreatedAt); err != nil {
+		log.Println("Error creating session:", err)
+		return err
+	}
+	return nil
+}
+
+const sessionColumns = `id, user_id, family_id, device, ip, user_agent, created_at, last_seen_at, revoked_at`
+
+func scanSession(row interface{ Scan(...interface{}) error }) (*model.Session, error) {
+	s := &model.Session{}
+	var revokedAt sql.NullTime
+	if err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.FamilyID,
+		&s.Device,
+		&s.IP,
+		&s.UserAgent,
+		&s.CreatedAt,
+		&s.LastSeenAt,
+		&revokedAt,
+	); err != nil {
+		return nil, err
+	}
+	s.RevokedAt = nullTimePtr(revokedAt)
+	return s, nil
+}
+
+func (r *sessionRepository) GetSession(id int, userID int) (*model.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1 AND user_id = $2`
+	session, err := scanSession(r.db.QueryRow(query, id, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching session:", err)
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *sessionRepository) GetSessionByFamily(familyID string) (*model.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE family_id = $1`
+	session, err := scanSession(r.db.QueryRow(query, familyID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching session by family:", err)
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *sessionRepository) ListActiveSessions(userID int) ([]model.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE user_id = $1 AND revoked_at IS NULL ORDER BY last_seen_at DESC`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		log.Println("Error listing sessions:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []model.Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *sessionRepository) IsSessionActive(id int, userID int) (bool, error) {
+	var active bool
+	query := `SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL)`
+	if err := r.db.QueryRow(query, id, userID).Scan(&active); err != nil {
+		log.Println("Error checking session:", err)
+		return false, err
+	}
+	return active, nil
+}
+
+func (r *sessionRepository) TouchSession(id int, ip string, at time.Time, minInterval time.Duration) error {
+	query := `
+		UPDATE sessions SET last_seen_at = $3, ip = COALESCE(NULLIF($2, ''), ip)
+		WHERE id = $1 AND (last_seen_at < $4 OR ($2 <> '' AND ip <> $2))
+	`
+	if _, err := r.db.Exec(query, id, ip, at, at.Add(-minInterval)); err != nil {
+		log.Println("Error updating session last seen:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeSession(id int, userID int, at time.Time) (string, error) {
+	var familyID string
+	query := `UPDATE sessions SET revoked_at = $3 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL RETURNING family_id`
+	err := r.db.QueryRow(query, id, userID, at).Scan(&familyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		log.Println("Error revoking session:", err)
+		return "", err
+	}
+	return familyID, nil
+}
+
+func (r *sessionRepository) RevokeUserSessions(userID int, exceptID int, at time.Time) ([]string, error) {
+	query := `UPDATE sessions SET revoked_at = $3 WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL RETURNING family_id`
+	rows, err := r.db.Query(query, userID, exceptID, at)
+	if err != nil {
+		log.Println("Error revoking sessions of user:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	families := []string{}
+	for rows.Next() {
+		var familyID string
+		if err := rows.Scan(&familyID); err != nil {
+			return nil, err
+		}
+		families = append(families, familyID)
+	}
+	return families, rows.Err()
+}
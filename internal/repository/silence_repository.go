@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type silenceRepository struct {
+	db *sql.DB
+}
+
+// SilenceRepository defines the methods implemented by the silence repository
+type SilenceRepository interface {
+	CreateTable() error
+	CreateSilence(silence *model.Silence) error
+	GetSilenceByID(id int) (*model.Silence, error)
+	ListSilences(projectID string, activeAt *time.Time) ([]model.Silence, error)
+	ExpireSilence(id int, at time.Time) error
+	CreateMaintenanceWindow(window *model.MaintenanceWindow) error
+	GetMaintenanceWindowByID(id int) (*model.MaintenanceWindow, error)
+	ListMaintenanceWindows(projectID string) ([]model.MaintenanceWindow, error)
+	DeleteMaintenanceWindow(id int) error
+}
+
+func NewSilenceRepository(db *sql.DB) SilenceRepository {
+	return &silenceRepository{db: db}
+}
+
+func (r *silenceRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS silences (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL,
+		route VARCHAR(500) NOT NULL DEFAULT '',
+		rule VARCHAR(255) NOT NULL DEFAULT '',
+		starts_at TIMESTAMP NOT NULL,
+		ends_at TIMESTAMP NOT NULL,
+		created_by INT NOT NULL,
+		comment TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS maintenance_windows (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL,
+		route VARCHAR(500) NOT NULL DEFAULT '',
+		rule VARCHAR(255) NOT NULL DEFAULT '',
+		schedule VARCHAR(255) NOT NULL,
+		duration_minutes INT NOT NULL,
+		created_by INT NOT NULL,
+		comment TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	create index if not exists idx_silences_project on silences(project_id, ends_at);
+	create index if not exists idx_maintenance_windows_project on maintenance_windows(project_id);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *silenceRepository) CreateSilence(silence *model.Silence) error {
+	query := `
+		INSERT INTO silences (project_id, route, rule, starts_at, ends_at, created_by, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		silence.ProjectID,
+		silence.Route,
+		silence.Rule,
+		silence.StartsAt,
+		silence.EndsAt,
+		silence.CreatedBy,
+		silence.Comment,
+	).Scan(&silence.ID, &silence.CreatedAt); err != nil {
+		log.Println("Error creating silence:", err)
+		return err
+	}
+	return nil
+}
+
+const silenceColumns = `id, project_id, route, rule, starts_at, ends_at, created_by, comment, created_at`
+
+func scanSilence(row interface{ Scan(...interface{}) error }) (*model.Silence, error) {
+	s := &model.Silence{}
+	if err := row.Scan(
+		&s.ID,
+		&s.ProjectID,
+		&s.Route,
+		&s.Rule,
+		&s.StartsAt,
+		&s.EndsAt,
+		&s.CreatedBy,
+		&s.Comment,
+		&s.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *silenceRepository) GetSilenceByID(id int) (*model.Silence, error) {
+	query := `SELECT ` + silenceColumns + ` FROM silences WHERE id = $1`
+	s, err := scanSilence(r.db.QueryRow(query, id))
+	if err != nil {
+		log.Println("Error fetching silence by ID:", err)
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListSilences returns the silences of a project. When activeAt is set only the
+// silences active at that time are returned.
+func (r *silenceRepository) ListSilences(projectID string, activeAt *time.Time) ([]model.Silence, error) {
+	query := `SELECT ` + silenceColumns + ` FROM silences WHERE project_id = $1 ORDER BY starts_at DESC`
+	args := []interface{}{projectID}
+	if activeAt != nil {
+		query = `SELECT ` + silenceColumns + ` FROM silences WHERE project_id = $1 AND starts_at <= $2 AND ends_at > $2 ORDER BY starts_at DESC`
+		args = append(args, *activeAt)
+	}
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Println("Error listing silences:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	silences := []model.Silence{}
+	for rows.Next() {
+		s, err := scanSilence(rows)
+		if err != nil {
+			return nil, err
+		}
+		silences = append(silences, *s)
+	}
+	return silences, rows.Err()
+}
+
+// ExpireSilence ends a silence early. The row is kept so history can refer to it.
+func (r *silenceRepository) ExpireSilence(id int, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE silences SET ends_at = LEAST(ends_at, $2) WHERE id = $1`, id, at)
+	if err != nil {
+		log.Println("Error expiring silence:", err)
+	}
+	return err
+}
+
+func (r *silenceRepository) CreateMaintenanceWindow(window *model.MaintenanceWindow) error {
+	query := `
+		INSERT INTO maintenance_windows (project_id, route, rule, schedule, duration_minutes, created_by, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		window.ProjectID,
+		window.Route,
+		window.Rule,
+		window.Schedule,
+		window.DurationMinutes,
+		window.CreatedBy,
+		window.Comment,
+	).Scan(&window.ID, &window.CreatedAt); err != nil {
+		log.Println("Error creating maintenance window:", err)
+		return err
+	}
+	return nil
+}
+
+const maintenanceWindowColumns = `id, project_id, route, rule, schedule, duration_minutes, created_by, comment, created_at`
+
+func scanMaintenanceWindow(row interface{ Scan(...interface{}) error }) (*model.MaintenanceWindow, error) {
+	w := &model.MaintenanceWindow{}
+	if err := row.Scan(
+		&w.ID,
+		&w.ProjectID,
+		&w.Route,
+		&w.Rule,
+		&w.Schedule,
+		&w.DurationMinutes,
+		&w.CreatedBy,
+		&w.Comment,
+		&w.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *silenceRepository) GetMaintenanceWindowByID(id int) (*model.MaintenanceWindow, error) {
+	query := `SELECT ` + maintenanceWindowColumns + ` FROM maintenance_windows WHERE id = $1`
+	w, err := scanMaintenanceWindow(r.db.QueryRow(query, id))
+	if err != nil {
+		log.Println("Error fetching maintenance window by ID:", err)
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *silenceRepository) ListMaintenanceWindows(projectID string) ([]model.MaintenanceWindow, error) {
+	query := `SELECT ` + maintenanceWindowColumns + ` FROM maintenance_windows WHERE project_id = $1 ORDER BY id`
+	rows, err := r.db.Query(query, projectID)
+	if err != nil {
+		log.Println("Error listing maintenance windows:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	windows := []model.MaintenanceWindow{}
+	for rows.Next() {
+		w, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, *w)
+	}
+	return windows, rows.Err()
+}
+
+func (r *silenceRepository) DeleteMaintenanceWindow(id int) error {
+	_, err := r.db.Exec(`DELETE FROM maintenance_windows WHERE id = $1`, id)
+	if err != nil {
+		log.Println("Error deleting maintenance window:", err)
+	}
+	return err
+}
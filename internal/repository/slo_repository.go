@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"prothomuse-server/internal/model"
+)
+
+type sloRepository struct {
+	db *sql.DB
+}
+
+// SLORepository defines the methods implemented by the SLO repository
+type SLORepository interface {
+	CreateTable() error
+	CreateSLO(slo *model.SLO) error
+	GetSLOByID(id int) (*model.SLO, error)
+	ListSLOs(projectID string) ([]model.SLO, error)
+	ListAllSLOs() ([]model.SLO, error)
+	DeleteSLO(id int) error
+}
+
+func NewSLORepository(db *sql.DB) SLORepository {
+	return &sloRepository{db: db}
+}
+
+func (r *sloRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS slos (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL,
+		route VARCHAR(500) NOT NULL DEFAULT '',
+		name VARCHAR(255) NOT NULL,
+		target DOUBLE PRECISION NOT NULL,
+		latency_threshold_ms BIGINT NOT NULL DEFAULT 0,
+		window_days INT NOT NULL,
+		alerts_enabled BOOLEAN DEFAULT TRUE,
+		created_by INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	create index if not exists idx_slos_project on slos(project_id);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *sloRepository) CreateSLO(slo *model.SLO) error {
+	query := `
+		INSERT INTO slos (project_id, route, name, target, latency_threshold_ms, window_days, alerts_enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		slo.ProjectID,
+		slo.Route,
+		slo.Name,
+		slo.Target,
+		slo.LatencyThresholdMs,
+		slo.WindowDays,
+		slo.AlertsEnabled,
+		slo.CreatedBy,
+	).Scan(&slo.ID, &slo.CreatedAt); err != nil {
+		log.Println("Error creating SLO:", err)
+		return err
+	}
+	return nil
+}
+
+const sloColumns = `id, project_id, route, name, target, latency_threshold_ms, window_days, alerts_enabled, created_by, created_at`
+
+func scanSLO(row interface{ Scan(...interface{}) error }) (*model.SLO, error) {
+	s := &model.SLO{}
+	if err := row.Scan(
+		&s.ID,
+		&s.ProjectID,
+		&s.Route,
+		&s.Name,
+		&s.Target,
+		&s.LatencyThresholdMs,
+		&s.WindowDays,
+		&s.AlertsEnabled,
+		&s.CreatedBy,
+		&s.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *sloRepository) GetSLOByID(id int) (*model.SLO, error) {
+	query := `SELECT ` + sloColumns + ` FROM slos WHERE id = $1`
+	s, err := scanSLO(r.db.QueryRow(query, id))
+	if err != nil {
+		log.Println("Error fetching SLO by ID:", err)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *sloRepository) ListSLOs(projectID string) ([]model.SLO, error) {
+	return r.querySLOs(`SELECT `+sloColumns+` FROM slos WHERE project_id = $1 ORDER BY id`, projectID)
+}
+
+func (r *sloRepository) ListAllSLOs() ([]model.SLO, error) {
+	return r.querySLOs(`SELECT ` + sloColumns + ` FROM slos ORDER BY id`)
+}
+
+func (r *sloRepository) querySLOs(query string, args ...interface{}) ([]model.SLO, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Println("Error listing SLOs:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	slos := []model.SLO{}
+	for rows.Next() {
+		s, err := scanSLO(rows)
+		if err != nil {
+			return nil, err
+		}
+		slos = append(slos, *s)
+	}
+	return slos, rows.Err()
+}
+
+func (r *sloRepository) DeleteSLO(id int) error {
+	_, err := r.db.Exec(`DELETE FROM slos WHERE id = $1`, id)
+	if err != nil {
+		log.Println("Error deleting SLO:", err)
+	}
+	return err
+}
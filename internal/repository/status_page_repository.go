@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type statusPageRepository struct {
+	db *sql.DB
+}
+
+// StatusPageRepository defines the methods implemented by the status page repository
+type StatusPageRepository interface {
+	CreateTable() error
+	CreatePage(page *model.StatusPage) error
+	UpdatePage(page *model.StatusPage) error
+	GetPageByID(id int) (*model.StatusPage, error)
+	GetPageBySlug(slug string) (*model.StatusPage, error)
+	ListPages(projectID string) ([]model.StatusPage, error)
+	DeletePage(id int) error
+	CreateComponent(component *model.StatusComponent) error
+	ListComponents(pageID int) ([]model.StatusComponent, error)
+	DeleteComponent(pageID int, id int) error
+	CreateMessage(message *model.StatusMessage) error
+	// ListMessages returns the unresolved messages and those resolved after resolvedSince, newest first
+	ListMessages(pageID int, resolvedSince time.Time) ([]model.StatusMessage, error)
+	ResolveMessage(pageID int, id int, at time.Time) error
+}
+
+func NewStatusPageRepository(db *sql.DB) StatusPageRepository {
+	return &statusPageRepository{db: db}
+}
+
+func (r *statusPageRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS status_pages (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL,
+		slug VARCHAR(100) UNIQUE NOT NULL,
+		title VARCHAR(255) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		enabled BOOLEAN DEFAULT FALSE,
+		created_by INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS status_components (
+		id SERIAL PRIMARY KEY,
+		page_id INT NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		route VARCHAR(500) NOT NULL DEFAULT '',
+		check_id INT REFERENCES uptime_checks(id) ON DELETE CASCADE,
+		position INT NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS status_messages (
+		id SERIAL PRIMARY KEY,
+		page_id INT NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+		status VARCHAR(20) NOT NULL,
+		title VARCHAR(255) NOT NULL,
+		body TEXT NOT NULL DEFAULT '',
+		created_by INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		resolved_at TIMESTAMP
+	);
+	create index if not exists idx_status_pages_project on status_pages(project_id);
+	create index if not exists idx_status_components_page on status_components(page_id);
+	create index if not exists idx_status_messages_page on status_messages(page_id, created_at);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *statusPageRepository) CreatePage(page *model.StatusPage) error {
+	query := `
+		INSERT INTO status_pages (project_id, slug, title, description, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+	if err := r.db.QueryRow(query,
+		page.ProjectID,
+		page.Slug,
+		page.Title,
+		page.Description,
+		page.Enabled,
+		page.CreatedBy,
+	).Scan(&page.ID, &page.CreatedAt, &page.UpdatedAt); err != nil {
+		log.Println("Error creating status page:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *statusPageRepository) UpdatePage(page *model.StatusPage) error {
+	query := `
+		UPDATE status_pages SET slug = $2, title = $3, description = $4, enabled = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	if err := r.db.QueryRow(query, page.ID, page.Slug, page.Title, page.Description, page.Enabled).Scan(&page.UpdatedAt); err != nil {
+		log.Println("Error updating status page:", err)
+		return err
+	}
+	return nil
+}
+
+const statusPageColumns = `id, project_id, slug, title, description, enabled, created_by, created_at, updated_at`
+
+func scanStatusPage(row interface{ Scan(...interface{}) error }) (*model.StatusPage, error) {
+	p := &model.StatusPage{}
+	if err := row.Scan(
+		&p.ID,
+		&p.ProjectID,
+		&p.Slug,
+		&p.Title,
+		&p.Description,
+		&p.Enabled,
+		&p.CreatedBy,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *statusPageRepository) GetPageByID(id int) (*model.StatusPage, error) {
+	query := `SELECT ` + statusPageColumns + ` FROM status_pages WHERE id = $1`
+	p, err := scanStatusPage(r.db.QueryRow(query, id))
+	if err != nil {
+		log.Println("Error fetching status page by ID:", err)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *statusPageRepository) GetPageBySlug(slug string) (*model.StatusPage, error) {
+	query := `SELECT ` + statusPageColumns + ` FROM status_pages WHERE slug = $1`
+	p, err := scanStatusPage(r.db.QueryRow(query, slug))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Error fetching status page by slug:", err)
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *statusPageRepository) ListPages(projectID string) ([]model.StatusPage, error) {
+	query := `SELECT ` + statusPageColumns + ` FROM status_pages WHERE project_id = $1 ORDER BY id`
+	rows, err := r.db.Query(query, projectID)
+	if err != nil {
+		log.Println("Error listing status pages:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	pages := []model.StatusPage{}
+	for rows.Next() {
+		p, err := scanStatusPage(rows)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, *p)
+	}
+	return pages, rows.Err()
+}
+
+func (r *statusPageRepository) DeletePage(id int) error {
+	_, err := r.db.Exec(`DELETE FROM status_pages WHERE id = $1`, id)
+	if err != nil {
+		log.Println("Error deleting status page:", err)
+	}
+	return err
+}
+
+func (r *statusPageRepository) CreateComponent(component *model.StatusComponent) error {
+	query := `
+		INSERT INTO status_components (page_id, name, description, route, check_id, position)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	if err := r.db.QueryRow(query,
+		component.PageID,
+		component.Name,
+		component.Description,
+		component.Route,
+		component.CheckID,
+		component.Position,
+	).Scan(&component.ID); err != nil {
+		log.Println("Error creating status component:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *statusPageRepository) ListComponents(pageID int) ([]model.StatusComponent, error) {
+	query := `
+		SELECT id, page_id, name, description, route, check_id, position
+		FROM status_components
+		WHERE page_id = $1
+		ORDER BY position, id
+	`
+	rows, err := r.db.Query(query, pageID)
+	if err != nil {
+		log.Println("Error listing status components:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	components := []model.StatusComponent{}
+	for rows.Next() {
+		var c model.StatusComponent
+		var checkID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.PageID, &c.Name, &c.Description, &c.Route, &checkID, &c.Position); err != nil {
+			return nil, err
+		}
+		if checkID.Valid {
+			id := int(checkID.Int64)
+			c.CheckID = &id
+		}
+		components = append(components, c)
+	}
+	return components, rows.Err()
+}
+
+func (r *statusPageRepository) DeleteComponent(pageID int, id int) error {
+	_, err := r.db.Exec(`DELETE FROM status_components WHERE page_id = $1 AND id = $2`, pageID, id)
+	if err != nil {
+		log.Println("Error deleting status component:", err)
+	}
+	return err
+}
+
+func (r *statusPageRepository) CreateMessage(message *model.StatusMessage) error {
+	query := `
+		INSERT INTO status_messages (page_id, status, title, body, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		message.PageID,
+		message.Status,
+		message.Title,
+		message.Body,
+		message.CreatedBy,
+	).Scan(&message.ID, &message.CreatedAt); err != nil {
+		log.Println("Error creating status message:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *statusPageRepository) ListMessages(pageID int, resolvedSince time.Time) ([]model.StatusMessage, error) {
+	query := `
+		SELECT id, page_id, status, title, body, created_by, created_at, resolved_at
+		FROM status_messages
+		WHERE page_id = $1 AND (resolved_at IS NULL OR resolved_at >= $2)
+		ORDER BY created_at DESC
+		LIMIT 100
+	`
+	rows, err := r.db.Query(query, pageID, resolvedSince)
+	if err != nil {
+		log.Println("Error listing status messages:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	messages := []model.StatusMessage{}
+	for rows.Next() {
+		var m model.StatusMessage
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&m.ID, &m.PageID, &m.Status, &m.Title, &m.Body, &m.CreatedBy, &m.CreatedAt, &resolvedAt); err != nil {
+			return nil, err
+		}
+		if resolvedAt.Valid {
+			m.ResolvedAt = &resolvedAt.Time
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+func (r *statusPageRepository) ResolveMessage(pageID int, id int, at time.Time) error {
+	query := `UPDATE status_messages SET resolved_at = $3 WHERE page_id = $1 AND id = $2 AND resolved_at IS NULL`
+	_, err := r.db.Exec(query, pageID, id, at)
+	if err != nil {
+		log.Println("Error resolving status message:", err)
+	}
+	return err
+}
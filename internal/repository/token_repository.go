@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type tokenRepository struct {
+	db *sql.DB
+}
+
+// TokenRepository stores refresh tokens and the denylist of revoked access tokens
+type TokenRepository interface {
+	CreateTable() error
+	CreateRefreshToken(token *model.RefreshToken) error
+	GetRefreshTokenByHash(hash string) (*model.RefreshToken, error)
+	// MarkRefreshTokenUsed consumes a token and reports false if it was already used or revoked
+	MarkRefreshTokenUsed(id int, at time.Time) (bool, error)
+	RevokeFamily(familyID string, at time.Time) error
+	RevokeUserRefreshTokens(userID int, at time.Time) error
+	// RevokeAccessToken denylists a jti until the token would have expired anyway
+	RevokeAccessToken(jti string, userID int, expiresAt time.Time) error
+	// IsAccessTokenRevoked reports whether the jti is denylisted or the user's
+	// token version has moved past the one in the token
+	IsAccessTokenRevoked(jti string, userID int, tokenVersion int) (bool, error)
+}
+
+func NewTokenRepository(db *sql.DB) TokenRepository {
+	return &tokenRepository{db: db}
+}
+
+func (r *tokenRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		family_id VARCHAR(64) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti VARCHAR(64) PRIMARY KEY,
+		user_id INT NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	create index if not exists idx_refresh_tokens_family on refresh_tokens(family_id);
+	create index if not exists idx_refresh_tokens_user on refresh_tokens(user_id);
+	create index if not exists idx_revoked_tokens_expiry on revoked_tokens(expires_at);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *tokenRepository) CreateRefreshToken(token *model.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query, token.UserID, token.TokenHash, token.FamilyID, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt); err != nil {
+		log.Println("Error creating refresh token:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *tokenRepository) GetRefreshTokenByHash(hash string) (*model.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, family_id, created_at, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+	t := &model.RefreshToken{}
+	var usedAt, revokedAt sql.NullTime
+	err := r.db.QueryRow(query, hash).Scan(&t.ID, &t.UserID, &t.TokenHash, &t.FamilyID, &t.CreatedAt, &t.ExpiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching refresh token:", err)
+		return nil, err
+	}
+	if usedAt.Valid {
+		t.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	return t, nil
+}
+
+func (r *tokenRepository) MarkRefreshTokenUsed(id int, at time.Time) (bool, error) {
+	query := `UPDATE refresh_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`
+	result, err := r.db.Exec(query, id, at)
+	if err != nil {
+		log.Println("Error marking refresh token used:", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *tokenRepository) RevokeFamily(familyID string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL`, familyID, at)
+	if err != nil {
+		log.Println("Error revoking refresh token family:", err)
+	}
+	return err
+}
+
+func (r *tokenRepository) RevokeUserRefreshTokens(userID int, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE refresh_tokens SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`, userID, at)
+	if err != nil {
+		log.Println("Error revoking refresh tokens of user:", err)
+	}
+	return err
+}
+
+func (r *tokenRepository) RevokeAccessToken(jti string, userID int, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	if _, err := r.db.Exec(query, jti, userID, expiresAt); err != nil {
+		log.Println("Error revoking access token:", err)
+		return err
+	}
+	// expired entries can never match a valid token again
+	if _, err := r.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < $1`, time.Now().UTC()); err != nil {
+		log.Println("Error purging revoked tokens:", err)
+	}
+	return nil
+}
+
+func (r *tokenRepository) IsAccessTokenRevoked(jti string, userID int, tokenVersion int) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+			OR COALESCE((SELECT token_version FROM users WHERE id = $2), -1) <> $3
+	`
+	var revoked bool
+	if err := r.db.QueryRow(query, jti, userID, tokenVersion).Scan(&revoked); err != nil {
+		log.Println("Error checking access token revocation:", err)
+		return false, err
+	}
+	return revoked, nil
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"prothomuse-server/internal/model"
+)
+
+type transactionRepository struct {
+	db *sql.DB
+}
+
+// TransactionRepository defines the methods implemented by the transaction check repository
+type TransactionRepository interface {
+	CreateTable() error
+	CreateCheck(check *model.TransactionCheck) error
+	GetCheckByID(id int) (*model.TransactionCheck, error)
+	ListChecks(projectID string) ([]model.TransactionCheck, error)
+	ListEnabledChecks() ([]model.TransactionCheck, error)
+	DeleteCheck(id int) error
+	SaveRun(run *model.TransactionRun) error
+	ListRuns(checkID int, limit int) ([]model.TransactionRun, error)
+}
+
+func NewTransactionRepository(db *sql.DB) TransactionRepository {
+	return &transactionRepository{db: db}
+}
+
+func (r *transactionRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS transaction_checks (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		steps JSONB NOT NULL,
+		interval_seconds INT NOT NULL,
+		timeout_seconds INT NOT NULL,
+		enabled BOOLEAN DEFAULT TRUE,
+		created_by INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS transaction_runs (
+		id SERIAL PRIMARY KEY,
+		check_id INT NOT NULL REFERENCES transaction_checks(id) ON DELETE CASCADE,
+		project_id VARCHAR(255) NOT NULL,
+		success BOOLEAN NOT NULL,
+		failed_step INT,
+		error TEXT NOT NULL DEFAULT '',
+		duration_ms BIGINT NOT NULL,
+		step_results JSONB NOT NULL,
+		started_at TIMESTAMP NOT NULL
+	);
+	create index if not exists idx_transaction_checks_project on transaction_checks(project_id);
+	create index if not exists idx_transaction_runs_check on transaction_runs(check_id, started_at);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *transactionRepository) CreateCheck(check *model.TransactionCheck) error {
+	steps, err := json.Marshal(check.Steps)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO transaction_checks (project_id, name, steps, interval_seconds, timeout_seconds, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		check.ProjectID,
+		check.Name,
+		steps,
+		check.IntervalSeconds,
+		check.TimeoutSeconds,
+		check.Enabled,
+		check.CreatedBy,
+	).Scan(&check.ID, &check.CreatedAt); err != nil {
+		log.Println("Error creating transaction check:", err)
+		return err
+	}
+	return nil
+}
+
+const transactionCheckColumns = `id, project_id, name, steps, interval_seconds, timeout_seconds, enabled, created_by, created_at`
+
+func scanTransactionCheck(row interface{ Scan(...interface{}) error }) (*model.TransactionCheck, error) {
+	c := &model.TransactionCheck{}
+	var steps []byte
+	if err := row.Scan(
+		&c.ID,
+		&c.ProjectID,
+		&c.Name,
+		&steps,
+		&c.IntervalSeconds,
+		&c.TimeoutSeconds,
+		&c.Enabled,
+		&c.CreatedBy,
+		&c.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(steps, &c.Steps); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *transactionRepository) GetCheckByID(id int) (*model.TransactionCheck, error) {
+	query := `SELECT ` + transactionCheckColumns + ` FROM transaction_checks WHERE id = $1`
+	c, err := scanTransactionCheck(r.db.QueryRow(query, id))
+	if err != nil {
+		log.Println("Error fetching transaction check by ID:", err)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *transactionRepository) ListChecks(projectID string) ([]model.TransactionCheck, error) {
+	return r.queryChecks(`SELECT `+transactionCheckColumns+` FROM transaction_checks WHERE project_id = $1 ORDER BY id`, projectID)
+}
+
+func (r *transactionRepository) ListEnabledChecks() ([]model.TransactionCheck, error) {
+	return r.queryChecks(`SELECT ` + transactionCheckColumns + ` FROM transaction_checks WHERE enabled = TRUE ORDER BY id`)
+}
+
+func (r *transactionRepository) queryChecks(query string, args ...interface{}) ([]model.TransactionCheck, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Println("Error listing transaction checks:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	checks := []model.TransactionCheck{}
+	for rows.Next() {
+		c, err := scanTransactionCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, *c)
+	}
+	return checks, rows.Err()
+}
+
+func (r *transactionRepository) DeleteCheck(id int) error {
+	_, err := r.db.Exec(`DELETE FROM transaction_checks WHERE id = $1`, id)
+	if err != nil {
+		log.Println("Error deleting transaction check:", err)
+	}
+	return err
+}
+
+func (r *transactionRepository) SaveRun(run *model.TransactionRun) error {
+	steps, err := json.Marshal(run.Steps)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO transaction_runs (check_id, project_id, success, failed_step, error, duration_ms, step_results, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+	if err := r.db.QueryRow(query,
+		run.CheckID,
+		run.ProjectID,
+		run.Success,
+		run.FailedStep,
+		run.Error,
+		run.DurationMs,
+		steps,
+		run.StartedAt,
+	).Scan(&run.ID); err != nil {
+		log.Println("Error saving transaction run:", err)
+		return err
+	}
+	return nil
+}
+
+// ListRuns returns the latest runs of a transaction check, newest first
+func (r *transactionRepository) ListRuns(checkID int, limit int) ([]model.TransactionRun, error) {
+	query := `
+		SELECT id, check_id, project_id, success, failed_step, error, duration_ms, step_results, started_at
+		FROM transaction_runs
+		WHERE check_id = $1
+		ORDER BY started_at DESC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(query, checkID, limit)
+	if err != nil {
+		log.Println("Error listing transaction runs:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	runs := []model.TransactionRun{}
+	for rows.Next() {
+		var run model.TransactionRun
+		var failedStep sql.NullInt64
+		var steps []byte
+		if err := rows.Scan(
+			&run.ID,
+			&run.CheckID,
+			&run.ProjectID,
+			&run.Success,
+			&failedStep,
+			&run.Error,
+			&run.DurationMs,
+			&steps,
+			&run.StartedAt,
+		); err != nil {
+			return nil, err
+		}
+		if failedStep.Valid {
+			i := int(failedStep.Int64)
+			run.FailedStep = &i
+		}
+		if err := json.Unmarshal(steps, &run.Steps); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,361 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type uptimeRepository struct {
+	db *sql.DB
+}
+
+// UptimeRepository defines the methods implemented by the uptime check repository
+type UptimeRepository interface {
+	CreateTable() error
+	CreateCheck(check *model.UptimeCheck) error
+	GetCheckByID(id int) (*model.UptimeCheck, error)
+	ListChecks(projectID string) ([]model.UptimeCheck, error)
+	ListEnabledChecks() ([]model.UptimeCheck, error)
+	DeleteCheck(id int) error
+	SaveResult(result *model.CheckResult) error
+	ListResults(checkID int, limit int) ([]model.CheckResult, error)
+	// DailyAvailability counts probes and successful probes per UTC day in [from, to)
+	DailyAvailability(checkID int, from time.Time, to time.Time) ([]model.DailyAvailability, error)
+	// ReplaceCertificates stores the chain last served to a check, replacing the previous one.
+	ReplaceCertificates(checkID int, certs []model.Certificate) error
+	// ListCertificates returns the certificates of a project's checks that expire
+	// before expiresBefore (all when nil), soonest first.
+	ListCertificates(projectID string, expiresBefore *time.Time) ([]model.Certificate, error)
+}
+
+func NewUptimeRepository(db *sql.DB) UptimeRepository {
+	return &uptimeRepository{db: db}
+}
+
+func (r *uptimeRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS uptime_checks (
+		id SERIAL PRIMARY KEY,
+		project_id VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		url TEXT NOT NULL,
+		method VARCHAR(10) NOT NULL,
+		expected_status INT NOT NULL,
+		body_contains TEXT NOT NULL DEFAULT '',
+		interval_seconds INT NOT NULL,
+		timeout_seconds INT NOT NULL,
+		failure_threshold INT NOT NULL DEFAULT 1,
+		enabled BOOLEAN DEFAULT TRUE,
+		created_by INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS check_results (
+		id SERIAL PRIMARY KEY,
+		check_id INT NOT NULL REFERENCES uptime_checks(id) ON DELETE CASCADE,
+		project_id VARCHAR(255) NOT NULL,
+		route VARCHAR(500) NOT NULL,
+		method VARCHAR(10) NOT NULL,
+		status_code INT NOT NULL,
+		response_time BIGINT NOT NULL,
+		timestamp BIGINT NOT NULL,
+		success BOOLEAN NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS certificates (
+		id SERIAL PRIMARY KEY,
+		check_id INT NOT NULL REFERENCES uptime_checks(id) ON DELETE CASCADE,
+		project_id VARCHAR(255) NOT NULL,
+		host VARCHAR(255) NOT NULL,
+		position INT NOT NULL,
+		subject TEXT NOT NULL,
+		issuer TEXT NOT NULL,
+		sans TEXT[] NOT NULL DEFAULT '{}',
+		serial_number VARCHAR(255) NOT NULL,
+		fingerprint_sha256 VARCHAR(64) NOT NULL,
+		not_before TIMESTAMP NOT NULL,
+		not_after TIMESTAMP NOT NULL,
+		last_seen_at TIMESTAMP NOT NULL
+	);
+	ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS cert_expiry_days INT NOT NULL DEFAULT 14;
+	create index if not exists idx_uptime_checks_project on uptime_checks(project_id);
+	create index if not exists idx_certificates_project on certificates(project_id, not_after);
+	create index if not exists idx_check_results_check on check_results(check_id, timestamp);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *uptimeRepository) CreateCheck(check *model.UptimeCheck) error {
+	query := `
+		INSERT INTO uptime_checks (project_id, name, url, method, expected_status, body_contains,
+			interval_seconds, timeout_seconds, failure_threshold, cert_expiry_days, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		check.ProjectID,
+		check.Name,
+		check.URL,
+		check.Method,
+		check.ExpectedStatus,
+		check.BodyContains,
+		check.IntervalSeconds,
+		check.TimeoutSeconds,
+		check.FailureThreshold,
+		check.CertExpiryDays,
+		check.Enabled,
+		check.CreatedBy,
+	).Scan(&check.ID, &check.CreatedAt); err != nil {
+		log.Println("Error creating uptime check:", err)
+		return err
+	}
+	return nil
+}
+
+// the last result is joined in so listings show the current state of each check
+const uptimeCheckSelect = `
+	SELECT c.id, c.project_id, c.name, c.url, c.method, c.expected_status, c.body_contains,
+		c.interval_seconds, c.timeout_seconds, c.failure_threshold, c.cert_expiry_days, c.enabled, c.created_by, c.created_at,
+		last.created_at, last.success
+	FROM uptime_checks c
+	LEFT JOIN LATERAL (
+		SELECT created_at, success FROM check_results WHERE check_id = c.id ORDER BY timestamp DESC LIMIT 1
+	) last ON TRUE`
+
+func scanUptimeCheck(row interface{ Scan(...interface{}) error }) (*model.UptimeCheck, error) {
+	c := &model.UptimeCheck{}
+	var lastCheckedAt sql.NullTime
+	var lastSuccess sql.NullBool
+	if err := row.Scan(
+		&c.ID,
+		&c.ProjectID,
+		&c.Name,
+		&c.URL,
+		&c.Method,
+		&c.ExpectedStatus,
+		&c.BodyContains,
+		&c.IntervalSeconds,
+		&c.TimeoutSeconds,
+		&c.FailureThreshold,
+		&c.CertExpiryDays,
+		&c.Enabled,
+		&c.CreatedBy,
+		&c.CreatedAt,
+		&lastCheckedAt,
+		&lastSuccess,
+	); err != nil {
+		return nil, err
+	}
+	if lastCheckedAt.Valid {
+		c.LastCheckedAt = &lastCheckedAt.Time
+	}
+	if lastSuccess.Valid {
+		c.LastSuccess = &lastSuccess.Bool
+	}
+	return c, nil
+}
+
+func (r *uptimeRepository) GetCheckByID(id int) (*model.UptimeCheck, error) {
+	c, err := scanUptimeCheck(r.db.QueryRow(uptimeCheckSelect+` WHERE c.id = $1`, id))
+	if err != nil {
+		log.Println("Error fetching uptime check by ID:", err)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *uptimeRepository) ListChecks(projectID string) ([]model.UptimeCheck, error) {
+	return r.queryChecks(uptimeCheckSelect+` WHERE c.project_id = $1 ORDER BY c.id`, projectID)
+}
+
+func (r *uptimeRepository) ListEnabledChecks() ([]model.UptimeCheck, error) {
+	return r.queryChecks(uptimeCheckSelect + ` WHERE c.enabled = TRUE ORDER BY c.id`)
+}
+
+func (r *uptimeRepository) queryChecks(query string, args ...interface{}) ([]model.UptimeCheck, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Println("Error listing uptime checks:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	checks := []model.UptimeCheck{}
+	for rows.Next() {
+		c, err := scanUptimeCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, *c)
+	}
+	return checks, rows.Err()
+}
+
+func (r *uptimeRepository) DeleteCheck(id int) error {
+	_, err := r.db.Exec(`DELETE FROM uptime_checks WHERE id = $1`, id)
+	if err != nil {
+		log.Println("Error deleting uptime check:", err)
+	}
+	return err
+}
+
+func (r *uptimeRepository) SaveResult(result *model.CheckResult) error {
+	query := `
+		INSERT INTO check_results (check_id, project_id, route, method, status_code, response_time, timestamp, success, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query,
+		result.CheckID,
+		result.ProjectID,
+		result.Route,
+		result.Method,
+		result.StatusCode,
+		result.ResponseTime,
+		result.Timestamp,
+		result.Success,
+		result.Error,
+	).Scan(&result.ID, &result.CreatedAt); err != nil {
+		log.Println("Error saving check result:", err)
+		return err
+	}
+	return nil
+}
+
+// ListResults returns the latest results of a check, newest first
+func (r *uptimeRepository) ListResults(checkID int, limit int) ([]model.CheckResult, error) {
+	query := `
+		SELECT id, check_id, project_id, route, method, status_code, response_time, timestamp, success, error, created_at
+		FROM check_results
+		WHERE check_id = $1
+		ORDER BY timestamp DESC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(query, checkID, limit)
+	if err != nil {
+		log.Println("Error listing check results:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	results := []model.CheckResult{}
+	for rows.Next() {
+		var c model.CheckResult
+		if err := rows.Scan(
+			&c.ID,
+			&c.CheckID,
+			&c.ProjectID,
+			&c.Route,
+			&c.Method,
+			&c.StatusCode,
+			&c.ResponseTime,
+			&c.Timestamp,
+			&c.Success,
+			&c.Error,
+			&c.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, c)
+	}
+	return results, rows.Err()
+}
+
+func (r *uptimeRepository) DailyAvailability(checkID int, from time.Time, to time.Time) ([]model.DailyAvailability, error) {
+	query := `
+		SELECT (timestamp / 86400000) * 86400000 AS bucket,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE success)
+		FROM check_results
+		WHERE check_id = $1 AND timestamp >= $2 AND timestamp < $3
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+	rows, err := r.db.Query(query, checkID, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		log.Println("Error querying check daily availability:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDailyAvailability(rows)
+}
+
+func (r *uptimeRepository) ReplaceCertificates(checkID int, certs []model.Certificate) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM certificates WHERE check_id = $1`, checkID); err != nil {
+		log.Println("Error clearing certificates:", err)
+		return err
+	}
+	query := `
+		INSERT INTO certificates (check_id, project_id, host, position, subject, issuer, sans,
+			serial_number, fingerprint_sha256, not_before, not_after, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+	for _, c := range certs {
+		if _, err := tx.Exec(query,
+			checkID,
+			c.ProjectID,
+			c.Host,
+			c.Position,
+			c.Subject,
+			c.Issuer,
+			pq.Array(c.SANs),
+			c.SerialNumber,
+			c.FingerprintSHA256,
+			c.NotBefore,
+			c.NotAfter,
+			c.LastSeenAt,
+		); err != nil {
+			log.Println("Error saving certificate:", err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *uptimeRepository) ListCertificates(projectID string, expiresBefore *time.Time) ([]model.Certificate, error) {
+	query := `
+		SELECT id, check_id, project_id, host, position, subject, issuer, sans,
+			serial_number, fingerprint_sha256, not_before, not_after, last_seen_at
+		FROM certificates
+		WHERE project_id = $1 AND ($2::TIMESTAMP IS NULL OR not_after < $2)
+		ORDER BY not_after, check_id, position
+	`
+	rows, err := r.db.Query(query, projectID, expiresBefore)
+	if err != nil {
+		log.Println("Error listing certificates:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	certs := []model.Certificate{}
+	for rows.Next() {
+		var c model.Certificate
+		if err := rows.Scan(
+			&c.ID,
+			&c.CheckID,
+			&c.ProjectID,
+			&c.Host,
+			&c.Position,
+			&c.Subject,
+			&c.Issuer,
+			pq.Array(&c.SANs),
+			&c.SerialNumber,
+			&c.FingerprintSHA256,
+			&c.NotBefore,
+			&c.NotAfter,
+			&c.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, rows.Err()
+}
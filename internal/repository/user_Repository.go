@@ -6,6 +6,7 @@ import (
 	"log"
 	"prothomuse-server/internal/model"
 	"strings"
+	"time"
 )
 
 type userRepository struct {
@@ -20,6 +21,19 @@ type UserRepository interface {
 	GetUserByAPIKey(apiKey string) (*model.User, error)
 	GetUserByID(id int) (*model.User, error)
 	UpdateUser(user *model.User) error
+	IncrementTokenVersion(id int) (int, error)
+	// SearchUsers returns a page of users matching the filter, oldest first, and the total matching
+	SearchUsers(filter model.UserFilter) ([]model.User, int, error)
+	// GrantAdmin makes the user with this verified email a system administrator and reports whether one exists
+	GrantAdmin(email string) (bool, error)
+	// GetUsage summarises the organizations, projects, keys, sessions and metrics of a user
+	GetUsage(id int, metricsSince time.Time) (*model.UserUsage, error)
+	// ScheduleDeletion sets when the account is deleted; nil cancels the deletion
+	ScheduleDeletion(id int, at *time.Time) error
+	// ListDueDeletions returns the users whose scheduled deletion is at or before now
+	ListDueDeletions(now time.Time) ([]int, error)
+	// ReplacePasswordHash swaps the password hash only if it is still oldHash and reports whether it did
+	ReplacePasswordHash(id int, oldHash, newHash string) (bool, error)
 }
 
 func NewUserRepository(db *sql.DB) UserRepository {
@@ -37,6 +51,12 @@ func (r *userRepository) CreateTable() error {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
+	-- accounts created before email verification existed count as verified
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT TRUE;
+	ALTER TABLE users ALTER COLUMN email_verified SET DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
 	create index if not exists idx_email on users(email);
 	create index if not exists idx_api_key on users(api_key);
 	`
@@ -45,8 +65,8 @@ func (r *userRepository) CreateTable() error {
 }
 func (r *userRepository) CreateUser(user *model.User) error {
 	query := `
-		INSERT INTO users (username, email, password, api_key, is_active)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (username, email, password, api_key, is_active, email_verified)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, updated_at
 	`
 	if err := r.db.QueryRow(query,
@@ -55,30 +75,41 @@ func (r *userRepository) CreateUser(user *model.User) error {
 		user.Password,
 		user.APIKey,
 		user.IsActive,
+		user.EmailVerified,
 	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt); err != nil {
 		return err
 	}
 	log.Println("User created with ID:", user.ID)
 	return nil
 }
-func (r *userRepository) GetUserByEmail(email string) (*model.User, error) {
-	query := `
-		SELECT id, username, email, password, api_key, is_active, created_at, updated_at
-		FROM users
-		WHERE email = $1
-			`
+const userColumns = `id, username, email, password, api_key, is_active, email_verified, is_admin, deletion_scheduled_at, token_version, created_at, updated_at`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*model.User, error) {
 	user := &model.User{}
-	err := r.db.QueryRow(query, email).Scan(
+	var deletionScheduledAt sql.NullTime
+	if err := row.Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password,
 		&user.APIKey,
 		&user.IsActive,
+		&user.EmailVerified,
+		&user.IsAdmin,
+		&deletionScheduledAt,
+		&user.TokenVersion,
 		&user.CreatedAt,
 		&user.UpdatedAt,
-	) 
-	log.Println(user);
+	); err != nil {
+		return nil, err
+	}
+	user.DeletionScheduledAt = nullTimePtr(deletionScheduledAt)
+	return user, nil
+}
+
+func (r *userRepository) GetUserByEmail(email string) (*model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+	user, err := scanUser(r.db.QueryRow(query, email))
 	if err != nil {
 		log.Println("Error fetching user by email:", err)
 		return nil, err
@@ -86,22 +117,8 @@ func (r *userRepository) GetUserByEmail(email string) (*model.User, error) {
 	return user, nil
 }
 func (r *userRepository) GetUserByAPIKey(apiKey string) (*model.User, error) {
-	query := `
-	SELECT id, username, email, password, api_key, is_active, created_at, updated_at
-	FROM users	
-	WHERE api_key = $1
-	`
-	user := &model.User{}
-	err := r.db.QueryRow(query, apiKey).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.APIKey,
-		&user.IsActive,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	query := `SELECT ` + userColumns + ` FROM users WHERE api_key = $1`
+	user, err := scanUser(r.db.QueryRow(query, apiKey))
 	if err != nil {
 		log.Println("Error fetching user by API key:", err)
 		return nil, err
@@ -111,22 +128,8 @@ func (r *userRepository) GetUserByAPIKey(apiKey string) (*model.User, error) {
 
 // GetUserByID returns a user by their numeric ID
 func (r *userRepository) GetUserByID(id int) (*model.User, error) {
-	query := `
-	SELECT id, username, email, password, api_key, is_active, created_at, updated_at
-	FROM users
-	WHERE id = $1
-	`
-	user := &model.User{}
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.APIKey,
-		&user.IsActive,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	user, err := scanUser(r.db.QueryRow(query, id))
 	if err != nil {
 		log.Println("Error fetching user by ID:", err)
 		return nil, err
@@ -134,6 +137,18 @@ func (r *userRepository) GetUserByID(id int) (*model.User, error) {
 	return user, nil
 }
 
+// IncrementTokenVersion invalidates every access token issued to the user so far
+// and returns the new version.
+func (r *userRepository) IncrementTokenVersion(id int) (int, error) {
+	var version int
+	query := `UPDATE users SET token_version = token_version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING token_version`
+	if err := r.db.QueryRow(query, id).Scan(&version); err != nil {
+		log.Println("Error incrementing token version:", err)
+		return 0, err
+	}
+	return version, nil
+}
+
 // UpdateUser updates fields on an existing user. Fields with zero values are updated as provided
 func (r *userRepository) UpdateUser(user *model.User) error {
 	// Build dynamic SET clause to avoid overwriting password when empty
@@ -146,6 +161,8 @@ func (r *userRepository) UpdateUser(user *model.User) error {
 		idx++
 	}
 	if user.Email != "" {
+		// administrator rights were granted to the old email; SET sees the old row
+		setParts = append(setParts, fmt.Sprintf("is_admin = is_admin AND email = $%d", idx))
 		setParts = append(setParts, fmt.Sprintf("email = $%d", idx))
 		args = append(args, user.Email)
 		idx++
@@ -164,6 +181,9 @@ func (r *userRepository) UpdateUser(user *model.User) error {
 	setParts = append(setParts, fmt.Sprintf("is_active = $%d", idx))
 	args = append(args, user.IsActive)
 	idx++
+	setParts = append(setParts, fmt.Sprintf("email_verified = $%d", idx))
+	args = append(args, user.EmailVerified)
+	idx++
 
 	// updated_at
 	setParts = append(setParts, "updated_at = CURRENT_TIMESTAMP")
@@ -187,3 +207,120 @@ func (r *userRepository) UpdateUser(user *model.User) error {
 	}
 	return nil
 }
+
+// likeEscaper makes %, _ and \ in a search query match themselves under ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (r *userRepository) SearchUsers(filter model.UserFilter) ([]model.User, int, error) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	if filter.Query != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf(`(username ILIKE $%d ESCAPE '\' OR email ILIKE $%d ESCAPE '\')`, len(args), len(args)))
+	}
+	if filter.Active != nil {
+		args = append(args, *filter.Active)
+		conditions = append(conditions, fmt.Sprintf("is_active = $%d", len(args)))
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM users WHERE `+where, args...).Scan(&total); err != nil {
+		log.Println("Error counting users:", err)
+		return nil, 0, err
+	}
+	query := fmt.Sprintf(`SELECT %s FROM users WHERE %s ORDER BY id LIMIT $%d OFFSET $%d`,
+		userColumns, where, len(args)+1, len(args)+2)
+	rows, err := r.db.Query(query, append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)...)
+	if err != nil {
+		log.Println("Error searching users:", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+	users := []model.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *user)
+	}
+	return users, total, rows.Err()
+}
+
+func (r *userRepository) GrantAdmin(email string) (bool, error) {
+	result, err := r.db.Exec(`UPDATE users SET is_admin = TRUE WHERE email = $1 AND email_verified`, email)
+	if err != nil {
+		log.Println("Error granting admin:", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *userRepository) GetUsage(id int, metricsSince time.Time) (*model.UserUsage, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM organization_members WHERE user_id = $1),
+			(SELECT COUNT(*) FROM projects WHERE created_by = $1),
+			(SELECT COUNT(*) FROM project_keys WHERE created_by = $1 AND revoked_at IS NULL),
+			(SELECT COUNT(*) FROM sessions WHERE user_id = $1 AND revoked_at IS NULL),
+			(SELECT COUNT(*) FROM metrics m JOIN projects p ON p.id = m.project_id
+				WHERE p.created_by = $1 AND m.timestamp >= $2),
+			(SELECT MAX(last_seen_at) FROM sessions WHERE user_id = $1)
+	`
+	usage := &model.UserUsage{}
+	var lastSeen sql.NullTime
+	if err := r.db.QueryRow(query, id, metricsSince.UnixMilli()).Scan(
+		&usage.Organizations,
+		&usage.ProjectsCreated,
+		&usage.ActiveProjectKeys,
+		&usage.ActiveSessions,
+		&usage.Metrics30Days,
+		&lastSeen,
+	); err != nil {
+		log.Println("Error fetching user usage:", err)
+		return nil, err
+	}
+	usage.LastSeenAt = nullTimePtr(lastSeen)
+	return usage, nil
+}
+
+func (r *userRepository) ScheduleDeletion(id int, at *time.Time) error {
+	_, err := r.db.Exec(`UPDATE users SET deletion_scheduled_at = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id, at)
+	if err != nil {
+		log.Println("Error scheduling account deletion:", err)
+	}
+	return err
+}
+
+func (r *userRepository) ListDueDeletions(now time.Time) ([]int, error) {
+	rows, err := r.db.Query(`SELECT id FROM users WHERE deletion_scheduled_at <= $1 ORDER BY deletion_scheduled_at`, now)
+	if err != nil {
+		log.Println("Error listing due account deletions:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *userRepository) ReplacePasswordHash(id int, oldHash, newHash string) (bool, error) {
+	result, err := r.db.Exec(`UPDATE users SET password = $3 WHERE id = $1 AND password = $2`, id, oldHash, newHash)
+	if err != nil {
+		log.Println("Error replacing password hash:", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"prothomuse-server/internal/model"
+	"time"
+)
+
+type userTokenRepository struct {
+	db *sql.DB
+}
+
+// UserTokenRepository stores the single-use tokens mailed to users
+type UserTokenRepository interface {
+	CreateTable() error
+	// CreateToken stores a token and invalidates the user's unused tokens of the same purpose
+	CreateToken(token *model.UserToken) error
+	// ConsumeToken marks an unused, unexpired token as used and returns it, or nil if there is none
+	ConsumeToken(purpose string, tokenHash string, now time.Time) (*model.UserToken, error)
+	// FindToken returns an unused, unexpired token without using it, or nil if there is none
+	FindToken(purpose string, tokenHash string, now time.Time) (*model.UserToken, error)
+	DeleteUserTokens(userID int, purpose string) error
+}
+
+func NewUserTokenRepository(db *sql.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) CreateTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS user_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		purpose VARCHAR(50) NOT NULL,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	create index if not exists idx_user_tokens_user on user_tokens(user_id, purpose);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *userTokenRepository) CreateToken(token *model.UserToken) error {
+	if err := r.DeleteUserTokens(token.UserID, token.Purpose); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(query, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt); err != nil {
+		log.Println("Error creating user token:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *userTokenRepository) ConsumeToken(purpose string, tokenHash string, now time.Time) (*model.UserToken, error) {
+	query := `
+		UPDATE user_tokens SET used_at = $3
+		WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING id, user_id, purpose, token_hash, expires_at, created_at
+	`
+	t := &model.UserToken{UsedAt: &now}
+	err := r.db.QueryRow(query, purpose, tokenHash, now).Scan(&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error consuming user token:", err)
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *userTokenRepository) FindToken(purpose string, tokenHash string, now time.Time) (*model.UserToken, error) {
+	query := `
+		SELECT id, user_id, purpose, token_hash, expires_at, created_at FROM user_tokens
+		WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > $3
+	`
+	t := &model.UserToken{}
+	err := r.db.QueryRow(query, purpose, tokenHash, now).Scan(&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("Error fetching user token:", err)
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *userTokenRepository) DeleteUserTokens(userID int, purpose string) error {
+	_, err := r.db.Exec(`DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`, userID, purpose)
+	if err != nil {
+		log.Println("Error deleting user tokens:", err)
+	}
+	return err
+}
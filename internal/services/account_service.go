@@ -0,0 +1,351 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+const deleteAccountTokenTTL = time.Hour
+
+// ErrSoleOwner blocks deleting an account that is the only owner of an
+// organization other people still use
+var ErrSoleOwner = errors.New("you are the only owner of an organization with other members; make someone else owner or delete it first")
+
+// AccountService serves data-subject requests: exporting a user's personal data
+// and deleting their account. A deletion is confirmed from an email and carried
+// out by Start once the grace period has passed; until then it can be cancelled.
+type AccountService struct {
+	userRepo     repository.UserRepository
+	orgRepo      repository.OrganizationRepository
+	accountRepo  repository.AccountRepository
+	sessionRepo  repository.SessionRepository
+	oidcRepo     repository.OIDCRepository
+	mfaService   *MFAService
+	authService  *AuthService
+	auditService *AuditService
+	gracePeriod  time.Duration
+}
+
+func NewAccountService(userRepo repository.UserRepository, orgRepo repository.OrganizationRepository, accountRepo repository.AccountRepository, sessionRepo repository.SessionRepository, oidcRepo repository.OIDCRepository, mfaService *MFAService, authService *AuthService, auditService *AuditService, gracePeriod time.Duration) *AccountService {
+	return &AccountService{
+		userRepo:     userRepo,
+		orgRepo:      orgRepo,
+		accountRepo:  accountRepo,
+		sessionRepo:  sessionRepo,
+		oidcRepo:     oidcRepo,
+		mfaService:   mfaService,
+		authService:  authService,
+		auditService: auditService,
+		gracePeriod:  gracePeriod,
+	}
+}
+
+// DeletionStatus returns what deleting the account would do and when it is scheduled
+func (s *AccountService) DeletionStatus(userID int) (*model.AccountDeletionPlan, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.plan(userID)
+	if err != nil {
+		return nil, err
+	}
+	plan.ScheduledFor = user.DeletionScheduledAt
+	return plan, nil
+}
+
+// RequestDeletion checks the password and mails a link confirming the deletion
+func (s *AccountService) RequestDeletion(actor model.Actor, req model.DeleteAccountRequest) (*model.AccountDeletionPlan, error) {
+	user, err := s.userRepo.GetUserByID(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Password != "" && !utils.CheckPasswordHash(req.Password, user.Password) {
+		return nil, errors.New("password is incorrect")
+	}
+	plan, err := s.deletablePlan(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.authService.issueUserToken(user.ID, model.TokenPurposeDeleteAccount, deleteAccountTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	link := s.authService.config.AppURL + "/confirm-account-deletion?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nSomeone asked to delete your Prothomuse account. To confirm, open this link:\n\n%s\n\nor send this token to /api/auth/account/deletion/confirm:\n\n%s\n\nThe link expires in 1 hour. Your account is deleted %s after you confirm and can be restored until then. If you did not ask for this, ignore this email and consider changing your password.\n", user.Username, link, token, graceDescription(s.gracePeriod))
+	if err := s.authService.mailService.Enqueue(model.EmailKindDeleteAccount, user.Email, "Confirm the deletion of your account", body); err != nil {
+		return nil, err
+	}
+	s.auditService.Record(newAuditEvent(actor, model.AuditDeletionRequested, "user", strconv.Itoa(user.ID)))
+	return plan, nil
+}
+
+// ConfirmDeletion consumes the token from the confirmation email and schedules
+// the deletion at the end of the grace period
+func (s *AccountService) ConfirmDeletion(token string, client model.ClientInfo) (*model.AccountDeletionPlan, error) {
+	if token == "" {
+		return nil, errors.New("token is required")
+	}
+	consumed, err := s.authService.userTokenRepo.ConsumeToken(model.TokenPurposeDeleteAccount, utils.HashToken(token), time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if consumed == nil {
+		return nil, errors.New("invalid or expired confirmation token")
+	}
+	user, err := s.userRepo.GetUserByID(consumed.UserID)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.deletablePlan(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	at := time.Now().UTC().Add(s.gracePeriod)
+	if err := s.userRepo.ScheduleDeletion(user.ID, &at); err != nil {
+		return nil, err
+	}
+	plan.ScheduledFor = &at
+
+	event := newAuditEvent(model.Actor{UserID: user.ID, ClientInfo: client}, model.AuditDeletionScheduled, "user", strconv.Itoa(user.ID))
+	event.After = auditJSON(map[string]time.Time{"deletionScheduledAt": at})
+	s.auditService.Record(event)
+	body := fmt.Sprintf("Hi %s,\n\nYour Prothomuse account will be deleted on %s.\n\nChanged your mind? Sign in and cancel the deletion from your account settings, or send DELETE /api/auth/account/deletion, before that date.\n", user.Username, at.Format("January 2, 2006 at 15:04 MST"))
+	if err := s.authService.mailService.Enqueue(model.EmailKindDeleteAccount, user.Email, "Your account is scheduled for deletion", body); err != nil {
+		log.Printf("error sending deletion notice to user %d: %v", user.ID, err)
+	}
+	return plan, nil
+}
+
+// CancelDeletion keeps an account whose deletion is scheduled
+func (s *AccountService) CancelDeletion(actor model.Actor) error {
+	user, err := s.userRepo.GetUserByID(actor.UserID)
+	if err != nil {
+		return err
+	}
+	if user.DeletionScheduledAt == nil {
+		return errors.New("no account deletion is scheduled")
+	}
+	if err := s.userRepo.ScheduleDeletion(user.ID, nil); err != nil {
+		return err
+	}
+	event := newAuditEvent(actor, model.AuditDeletionCancelled, "user", strconv.Itoa(user.ID))
+	event.Before = auditJSON(map[string]*time.Time{"deletionScheduledAt": user.DeletionScheduledAt})
+	s.auditService.Record(event)
+	return nil
+}
+
+// Start deletes the accounts whose grace period has passed every interval until ctx is cancelled
+func (s *AccountService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.PurgeDue()
+	}
+}
+
+// PurgeDue deletes the accounts whose scheduled deletion has come
+func (s *AccountService) PurgeDue() {
+	ids, err := s.userRepo.ListDueDeletions(time.Now().UTC())
+	if err != nil {
+		log.Println("error listing due account deletions:", err)
+		return
+	}
+	for _, id := range ids {
+		if err := s.purge(id); err != nil {
+			log.Printf("error deleting account of user %d: %v", id, err)
+		}
+	}
+}
+
+func (s *AccountService) purge(userID int) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	// someone may have joined an organization the user is the only owner of
+	// during the grace period: keep the account rather than orphan them
+	if _, err := s.deletablePlan(userID); err != nil {
+		if !errors.Is(err, ErrSoleOwner) {
+			return err
+		}
+		if err := s.userRepo.ScheduleDeletion(userID, nil); err != nil {
+			return err
+		}
+		event := newAuditEvent(model.Actor{}, model.AuditDeletionCancelled, "user", strconv.Itoa(userID))
+		event.Metadata = auditJSON(map[string]string{"reason": "sole_owner"})
+		s.auditService.Record(event)
+		body := fmt.Sprintf("Hi %s,\n\nYour Prothomuse account was not deleted: you are the only owner of an organization that has other members. Make someone else owner or delete the organization, then ask for the deletion again.\n", user.Username)
+		return s.authService.mailService.Enqueue(model.EmailKindDeleteAccount, user.Email, "Your account was not deleted", body)
+	}
+	deleted, err := s.accountRepo.DeleteAccount(userID)
+	if err != nil {
+		return err
+	}
+	// the log keeps the user ID only; the profile is gone
+	event := newAuditEvent(model.Actor{}, model.AuditAccountDeleted, "user", strconv.Itoa(userID))
+	event.Metadata = auditJSON(deleted)
+	s.auditService.Record(event)
+	return nil
+}
+
+// plan sorts the organizations of a user by what deleting the account does to them
+func (s *AccountService) plan(userID int) (*model.AccountDeletionPlan, error) {
+	orgs, err := s.orgRepo.ListUserOrganizations(userID)
+	if err != nil {
+		return nil, err
+	}
+	plan := &model.AccountDeletionPlan{
+		DeletedOrganizations:  []model.Organization{},
+		LeftOrganizations:     []model.Organization{},
+		BlockingOrganizations: []model.Organization{},
+	}
+	for _, org := range orgs {
+		members, err := s.orgRepo.ListMembers(org.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(members) <= 1 {
+			plan.DeletedOrganizations = append(plan.DeletedOrganizations, org)
+			continue
+		}
+		if org.Role == model.RoleOwner {
+			owners, err := s.orgRepo.CountOwners(org.ID)
+			if err != nil {
+				return nil, err
+			}
+			if owners <= 1 {
+				plan.BlockingOrganizations = append(plan.BlockingOrganizations, org)
+				continue
+			}
+		}
+		plan.LeftOrganizations = append(plan.LeftOrganizations, org)
+	}
+	return plan, nil
+}
+
+// deletablePlan is plan, failing with ErrSoleOwner when an organization blocks the deletion
+func (s *AccountService) deletablePlan(userID int) (*model.AccountDeletionPlan, error) {
+	plan, err := s.plan(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.BlockingOrganizations) > 0 {
+		names := make([]string, len(plan.BlockingOrganizations))
+		for i, org := range plan.BlockingOrganizations {
+			names[i] = org.Name
+		}
+		return plan, fmt.Errorf("%w (%s)", ErrSoleOwner, strings.Join(names, ", "))
+	}
+	return plan, nil
+}
+
+// Export builds a zip archive of the personal data of the actor: profile,
+// organizations, projects and settings, one JSON file each
+func (s *AccountService) Export(actor model.Actor) ([]byte, error) {
+	user, err := s.userRepo.GetUserByID(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	orgs, err := s.orgRepo.ListUserOrganizations(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	projects := []model.Project{}
+	for _, org := range orgs {
+		orgProjects, err := s.orgRepo.ListProjects(org.ID)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, orgProjects...)
+	}
+	mfaEnabled, err := s.mfaService.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.oidcRepo.ListIdentities(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.sessionRepo.ListActiveSessions(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the API key is a credential, not personal data: it stays out of the archive
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", map[string]interface{}{
+			"id":            user.ID,
+			"username":      user.Username,
+			"email":         user.Email,
+			"isActive":      user.IsActive,
+			"emailVerified": user.EmailVerified,
+			"createdAt":     user.CreatedAt,
+			"updatedAt":     user.UpdatedAt,
+		}},
+		{"organizations.json", orgs},
+		{"projects.json", projects},
+		{"settings.json", model.AccountSettings{
+			EmailVerified:       user.EmailVerified,
+			MFAEnabled:          mfaEnabled,
+			Identities:          identities,
+			Sessions:            sessions,
+			DeletionScheduledAt: user.DeletionScheduledAt,
+		}},
+	}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	s.auditService.Record(newAuditEvent(actor, model.AuditAccountExported, "user", strconv.Itoa(user.ID)))
+	return buf.Bytes(), nil
+}
+
+// graceDescription renders a grace period for emails, e.g. "14 days"
+func graceDescription(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	switch {
+	case d <= 0:
+		return "right away"
+	case days == 1:
+		return "1 day"
+	case days > 1:
+		return strconv.Itoa(days) + " days"
+	}
+	return d.Round(time.Minute).String()
+}
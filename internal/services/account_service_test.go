@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+func (r *fakeOrganizationRepository) ListUserOrganizations(userID int) ([]model.Organization, error) {
+	orgs := []model.Organization{}
+	for id, members := range r.members {
+		if role, ok := members[userID]; ok {
+			orgs = append(orgs, model.Organization{ID: id, Name: r.names[id], Role: role})
+		}
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].ID < orgs[j].ID })
+	return orgs, nil
+}
+
+func (r *fakeOrganizationRepository) ListMembers(orgID int) ([]model.Member, error) {
+	members := []model.Member{}
+	for userID, role := range r.members[orgID] {
+		members = append(members, model.Member{OrganizationID: orgID, UserID: userID, Role: role})
+	}
+	return members, nil
+}
+
+func (r *fakeOrganizationRepository) CountOwners(orgID int) (int, error) {
+	owners := 0
+	for _, role := range r.members[orgID] {
+		if role == model.RoleOwner {
+			owners++
+		}
+	}
+	return owners, nil
+}
+
+func (r *fakeUserRepository) ScheduleDeletion(id int, at *time.Time) error {
+	user, err := r.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	user.DeletionScheduledAt = at
+	return nil
+}
+
+func (r *fakeUserRepository) ListDueDeletions(now time.Time) ([]int, error) {
+	var ids []int
+	for _, user := range r.users {
+		if user.DeletionScheduledAt != nil && !user.DeletionScheduledAt.After(now) {
+			ids = append(ids, user.ID)
+		}
+	}
+	return ids, nil
+}
+
+// fakeAccountRepository records the accounts it deletes
+type fakeAccountRepository struct {
+	repository.AccountRepository
+	deleted []int
+}
+
+func (r *fakeAccountRepository) DeleteAccount(userID int) (*model.DeletedAccount, error) {
+	r.deleted = append(r.deleted, userID)
+	return &model.DeletedAccount{OrganizationIDs: []int{}, ProjectIDs: []string{}}, nil
+}
+
+// newTestAccountService serves user 1, the owner of organization 1, and user 7.
+// Users have no password, as when they sign in with SSO.
+func newTestAccountService() (*AccountService, *fakeUserRepository, *fakeOrganizationRepository, *fakeAccountRepository) {
+	authService, userRepo, _ := newTestAuthService(
+		model.User{ID: 1, Username: "owner", Email: "owner@example.com", IsActive: true},
+		model.User{ID: 7, Username: "jane", Email: "jane@example.com", IsActive: true},
+	)
+	orgRepo := authService.auditService.orgRepo.(*fakeOrganizationRepository)
+	accountRepo := &fakeAccountRepository{}
+	service := NewAccountService(userRepo, orgRepo, accountRepo, nil, nil, nil, authService, authService.auditService, 14*24*time.Hour)
+	return service, userRepo, orgRepo, accountRepo
+}
+
+func TestAccountDeletionIsScheduledAndCancelled(t *testing.T) {
+	service, userRepo, _, accountRepo := newTestAccountService()
+	jane := model.Actor{UserID: 7}
+
+	if _, err := service.RequestDeletion(jane, model.DeleteAccountRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	token := mailedToken(t, service.authService.mailService)
+	if userRepo.users[1].DeletionScheduledAt != nil {
+		t.Error("the deletion was scheduled before it was confirmed")
+	}
+	plan, err := service.ConfirmDeletion(token, model.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduled := userRepo.users[1].DeletionScheduledAt
+	if scheduled == nil || plan.ScheduledFor == nil || !scheduled.Equal(*plan.ScheduledFor) {
+		t.Fatalf("scheduled for %v, plan says %v", scheduled, plan.ScheduledFor)
+	}
+	if grace := time.Until(*scheduled); grace < 13*24*time.Hour || grace > 14*24*time.Hour {
+		t.Errorf("deletion in %s, want after the 14 day grace period", grace)
+	}
+	if _, err := service.ConfirmDeletion(token, model.ClientInfo{}); err == nil {
+		t.Error("the confirmation token worked twice")
+	}
+
+	// nothing is deleted during the grace period, and cancelling keeps the account
+	service.PurgeDue()
+	if len(accountRepo.deleted) != 0 {
+		t.Errorf("deleted %v during the grace period", accountRepo.deleted)
+	}
+	if err := service.CancelDeletion(jane); err != nil {
+		t.Fatal(err)
+	}
+	if userRepo.users[1].DeletionScheduledAt != nil {
+		t.Error("the deletion is still scheduled after cancelling")
+	}
+	if err := service.CancelDeletion(jane); err == nil {
+		t.Error("cancelled a deletion that was not scheduled")
+	}
+}
+
+func TestAccountDeletionPurgesDueAccounts(t *testing.T) {
+	service, userRepo, _, accountRepo := newTestAccountService()
+	past := time.Now().UTC().Add(-time.Minute)
+	future := time.Now().UTC().Add(time.Hour)
+	userRepo.users[0].DeletionScheduledAt = &future
+	userRepo.users[1].DeletionScheduledAt = &past
+
+	service.PurgeDue()
+	if len(accountRepo.deleted) != 1 || accountRepo.deleted[0] != 7 {
+		t.Errorf("deleted %v, want only the account that is due", accountRepo.deleted)
+	}
+	auditRepo := service.auditService.auditRepo.(*fakeAuditRepository)
+	if got := auditRepo.actions(); len(got) != 1 || got[0] != model.AuditAccountDeleted || auditRepo.events[0].ActorID != nil {
+		t.Errorf("audited %v, want the deletion by the system", got)
+	}
+}
+
+func TestAccountDeletionOfTheSoleOwner(t *testing.T) {
+	service, userRepo, orgRepo, accountRepo := newTestAccountService()
+	owner := model.Actor{UserID: 1}
+
+	// the only member of an organization takes it with them
+	plan, err := service.RequestDeletion(owner, model.DeleteAccountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.DeletedOrganizations) != 1 || len(plan.BlockingOrganizations) != 0 {
+		t.Errorf("plan %+v, want organization 1 deleted", plan)
+	}
+	token := mailedToken(t, service.authService.mailService)
+
+	// once someone joins, the owner has to hand the organization over first
+	orgRepo.members[1][7] = model.RoleMember
+	if _, err := service.RequestDeletion(owner, model.DeleteAccountRequest{}); !errors.Is(err, ErrSoleOwner) {
+		t.Errorf("sole owner with members: %v, want %v", err, ErrSoleOwner)
+	}
+	if _, err := service.ConfirmDeletion(token, model.ClientInfo{}); !errors.Is(err, ErrSoleOwner) {
+		t.Errorf("confirming as a sole owner: %v, want %v", err, ErrSoleOwner)
+	}
+
+	// a member joining during the grace period cancels the deletion
+	past := time.Now().UTC().Add(-time.Minute)
+	userRepo.users[0].DeletionScheduledAt = &past
+	service.PurgeDue()
+	if len(accountRepo.deleted) != 0 || userRepo.users[0].DeletionScheduledAt != nil {
+		t.Errorf("deleted %v, scheduled %v, want the deletion cancelled", accountRepo.deleted, userRepo.users[0].DeletionScheduledAt)
+	}
+
+	orgRepo.members[1][7] = model.RoleOwner
+	if plan, err := service.RequestDeletion(owner, model.DeleteAccountRequest{}); err != nil || len(plan.LeftOrganizations) != 1 {
+		t.Errorf("another owner: plan %+v (%v), want organization 1 left", plan, err)
+	}
+}
+
+func TestGraceDescription(t *testing.T) {
+	tests := []struct {
+		grace time.Duration
+		want  string
+	}{
+		{0, "right away"},
+		{24 * time.Hour, "1 day"},
+		{30 * 24 * time.Hour, "30 days"},
+		{90 * time.Minute, "1h30m0s"},
+	}
+	for _, tt := range tests {
+		if got := graceDescription(tt.grace); got != tt.want {
+			t.Errorf("graceDescription(%s) = %q, want %q", tt.grace, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+	usageWindow         = 30 * 24 * time.Hour
+)
+
+var (
+	ErrNotAdmin     = errors.New("this action requires a system administrator")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// AdminService lets system administrators manage user accounts. Every call is
+// written to the audit log with the administrator as the actor.
+type AdminService struct {
+	userRepo     repository.UserRepository
+	keyRepo      repository.ProjectKeyRepository
+	authService  *AuthService
+	auditService *AuditService
+}
+
+func NewAdminService(userRepo repository.UserRepository, keyRepo repository.ProjectKeyRepository, authService *AuthService, auditService *AuditService) *AdminService {
+	return &AdminService{userRepo: userRepo, keyRepo: keyRepo, authService: authService, auditService: auditService}
+}
+
+// IsAdmin reports whether the user is a system administrator
+func (s *AdminService) IsAdmin(userID int) (bool, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsAdmin && user.IsActive, nil
+}
+
+// GrantAdmin makes an existing user a system administrator. It is used to
+// bootstrap administrators from the server configuration.
+func (s *AdminService) GrantAdmin(email string) (bool, error) {
+	return s.userRepo.GrantAdmin(strings.TrimSpace(email))
+}
+
+func (s *AdminService) ListUsers(actor model.Actor, filter model.UserFilter) (*model.UserPage, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = defaultUserPageSize
+	}
+	if filter.PageSize > maxUserPageSize {
+		filter.PageSize = maxUserPageSize
+	}
+	filter.Query = strings.TrimSpace(filter.Query)
+	users, total, err := s.userRepo.SearchUsers(filter)
+	if err != nil {
+		return nil, err
+	}
+	event := newAuditEvent(actor, model.AuditAdminUserSearched, "user", "")
+	event.Metadata = auditJSON(filter)
+	s.auditService.Record(event)
+	return &model.UserPage{Users: users, Total: total, Page: filter.Page, PageSize: filter.PageSize}, nil
+}
+
+// AuditEvents returns a page of the whole audit log. Reading it is audited too.
+func (s *AdminService) AuditEvents(actor model.Actor, filter model.AuditFilter) (*model.AuditPage, error) {
+	page, err := s.auditService.ListAll(filter)
+	if err != nil {
+		return nil, err
+	}
+	event := newAuditEvent(actor, model.AuditAdminAuditViewed, "audit", "")
+	event.Metadata = auditJSON(filter)
+	s.auditService.Record(event)
+	return page, nil
+}
+
+// GetUser returns a user with their usage over the last 30 days
+func (s *AdminService) GetUser(actor model.Actor, userID int) (*model.AdminUser, error) {
+	user, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.userRepo.GetUsage(userID, time.Now().UTC().Add(-usageWindow))
+	if err != nil {
+		return nil, err
+	}
+	s.auditService.Record(newAuditEvent(actor, model.AuditAdminUserViewed, "user", strconv.Itoa(userID)))
+	return &model.AdminUser{User: user, Usage: usage}, nil
+}
+
+// SetActive deactivates or reactivates a user. Deactivation signs the user out
+// everywhere; administrators cannot deactivate themselves.
+func (s *AdminService) SetActive(actor model.Actor, userID int, active bool) (*model.User, error) {
+	if !active && userID == actor.UserID {
+		return nil, errors.New("you cannot deactivate your own account")
+	}
+	user, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsActive == active {
+		return user, nil
+	}
+	user.IsActive = active
+	if err := s.userRepo.UpdateUser(user); err != nil {
+		return nil, err
+	}
+	action := model.AuditAdminUserReactivate
+	if !active {
+		action = model.AuditAdminUserDeactivate
+		if err := s.authService.RevokeAllTokens(userID); err != nil {
+			return nil, err
+		}
+	}
+	event := newAuditEvent(actor, action, "user", strconv.Itoa(userID))
+	event.Before = auditJSON(map[string]bool{"isActive": !active})
+	event.After = auditJSON(map[string]bool{"isActive": active})
+	s.auditService.Record(event)
+	return user, nil
+}
+
+// ForcePasswordReset replaces the password of a user with an unknown one, signs
+// them out everywhere and mails them a reset link
+func (s *AdminService) ForcePasswordReset(actor model.Actor, userID int) error {
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return err
+	}
+	hashed, err := utils.HashPassword(secret)
+	if err != nil {
+		return err
+	}
+	user.Password = hashed
+	if err := s.userRepo.UpdateUser(user); err != nil {
+		return err
+	}
+	if err := s.authService.RevokeAllTokens(userID); err != nil {
+		return err
+	}
+	s.auditService.Record(newAuditEvent(actor, model.AuditAdminPasswordReset, "user", strconv.Itoa(userID)))
+	return s.authService.ForgotPassword(user.Email)
+}
+
+// RevokeKeys replaces the personal API key of a user and revokes every project
+// key they created. It returns how many project keys were revoked.
+func (s *AdminService) RevokeKeys(actor model.Actor, userID int) (int, error) {
+	user, err := s.user(userID)
+	if err != nil {
+		return 0, err
+	}
+	apiKey, err := utils.GenerateAPIKey()
+	if err != nil {
+		return 0, err
+	}
+	user.APIKey = apiKey
+	if err := s.userRepo.UpdateUser(user); err != nil {
+		return 0, err
+	}
+	keys, err := s.keyRepo.RevokeUserKeys(userID, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		event := newAuditEvent(actor, model.AuditKeyRevoked, "api_key", strconv.Itoa(key.ID))
+		event.Before = auditJSON(map[string]string{"projectId": key.ProjectID, "name": key.Name, "prefix": key.Prefix})
+		s.auditService.RecordProject(key.ProjectID, event)
+	}
+	event := newAuditEvent(actor, model.AuditAdminKeysRevoked, "user", strconv.Itoa(userID))
+	event.Metadata = auditJSON(map[string]int{"projectKeysRevoked": len(keys)})
+	s.auditService.Record(event)
+	return len(keys), nil
+}
+
+// Unlock clears the login lockout of a user's account
+func (s *AdminService) Unlock(actor model.Actor, userID int) error {
+	if _, err := s.user(userID); err != nil {
+		return err
+	}
+	return s.authService.UnlockUser(userID, actor.UserID, actor.ClientInfo)
+}
+
+func (s *AdminService) user(userID int) (*model.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
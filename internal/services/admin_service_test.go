@@ -0,0 +1,174 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+// fakeProjectKeyRepository keeps project keys in memory
+type fakeProjectKeyRepository struct {
+	repository.ProjectKeyRepository
+	keys  []model.ProjectKey
+	reads int // GetKey and HasActiveKeys calls
+}
+
+func (r *fakeProjectKeyRepository) RevokeUserKeys(userID int, at time.Time) ([]model.ProjectKey, error) {
+	var revoked []model.ProjectKey
+	for i := range r.keys {
+		key := &r.keys[i]
+		if key.CreatedBy == userID && key.RevokedAt == nil {
+			key.RevokedAt = &at
+			revoked = append(revoked, *key)
+		}
+	}
+	return revoked, nil
+}
+
+func newTestAdminService(users ...model.User) (*AdminService, *fakeUserRepository, *fakeTokenRepository, *fakeAuditRepository) {
+	authService, userRepo, tokenRepo := newTestAuthService(users...)
+	keyRepo := &fakeProjectKeyRepository{keys: []model.ProjectKey{
+		{ID: 1, ProjectID: "shop", Name: "ingest", CreatedBy: 7},
+		{ID: 2, ProjectID: "shop", Name: "deploy", CreatedBy: 1},
+	}}
+	service := NewAdminService(userRepo, keyRepo, authService, authService.auditService)
+	return service, userRepo, tokenRepo, authService.auditService.auditRepo.(*fakeAuditRepository)
+}
+
+func TestAdminIsAdmin(t *testing.T) {
+	service, _, _, _ := newTestAdminService(
+		model.User{ID: 1, IsAdmin: true, IsActive: true},
+		model.User{ID: 2, IsAdmin: true},
+		model.User{ID: 7, IsActive: true},
+	)
+	tests := []struct {
+		scenario string
+		userID   int
+		want     bool
+	}{
+		{"active administrator", 1, true},
+		{"deactivated administrator", 2, false},
+		{"user", 7, false},
+	}
+	for _, tt := range tests {
+		if got, err := service.IsAdmin(tt.userID); err != nil || got != tt.want {
+			t.Errorf("%s: IsAdmin = %v (%v), want %v", tt.scenario, got, err, tt.want)
+		}
+	}
+}
+
+func TestAdminSetActive(t *testing.T) {
+	service, userRepo, tokenRepo, auditRepo := newTestAdminService(
+		model.User{ID: 1, Email: "admin@example.com", IsAdmin: true, IsActive: true},
+		model.User{ID: 7, Email: "jane@example.com", IsActive: true},
+	)
+	signIn(t, service.authService, &userRepo.users[1], "laptop")
+	admin := model.Actor{UserID: 1}
+
+	if _, err := service.SetActive(admin, 1, false); err == nil {
+		t.Error("an administrator deactivated their own account")
+	}
+	if _, err := service.SetActive(admin, 9, false); err != ErrUserNotFound {
+		t.Errorf("deactivating an unknown user: %v", err)
+	}
+	if _, err := service.SetActive(admin, 7, false); err != nil {
+		t.Fatal(err)
+	}
+	if jane := userRepo.users[1]; jane.IsActive || jane.TokenVersion != 1 {
+		t.Errorf("deactivated user %+v, want inactive with bumped token version", jane)
+	}
+	if live := tokenRepo.liveFamilies(); len(live) != 0 {
+		t.Errorf("live families %v after deactivation, want none", live)
+	}
+	if _, err := service.SetActive(admin, 7, true); err != nil || !userRepo.users[1].IsActive {
+		t.Errorf("reactivating: %v", err)
+	}
+	want := []string{model.AuditAdminUserDeactivate, model.AuditAdminUserReactivate}
+	if got := auditRepo.actions(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("audited %v, want %v", got, want)
+	}
+	if event := auditRepo.events[0]; event.ActorID == nil || *event.ActorID != 1 || event.TargetID != "7" {
+		t.Errorf("audit event %+v, want the administrator acting on user 7", event)
+	}
+}
+
+func TestAdminForcePasswordReset(t *testing.T) {
+	service, userRepo, tokenRepo, auditRepo := newTestAdminService(model.User{ID: 7, Username: "jane", Email: "jane@example.com", Password: "old hash", IsActive: true})
+	signIn(t, service.authService, &userRepo.users[0], "laptop")
+
+	if err := service.ForcePasswordReset(model.Actor{UserID: 1}, 7); err != nil {
+		t.Fatal(err)
+	}
+	if jane := userRepo.users[0]; jane.Password == "old hash" || jane.TokenVersion != 1 {
+		t.Errorf("user %+v, want a new password and a bumped token version", jane)
+	}
+	if live := tokenRepo.liveFamilies(); len(live) != 0 {
+		t.Errorf("live families %v after a forced reset, want none", live)
+	}
+	token := mailedToken(t, service.authService.mailService)
+	if err := service.authService.ResetPassword(model.ResetPasswordRequest{Token: token, Password: "a new secret password"}); err != nil {
+		t.Errorf("the mailed reset link: %v", err)
+	}
+	if got := auditRepo.actions(); len(got) == 0 || got[0] != model.AuditAdminPasswordReset {
+		t.Errorf("audited %v, want the forced reset first", got)
+	}
+}
+
+func TestAdminRevokeKeys(t *testing.T) {
+	service, userRepo, _, auditRepo := newTestAdminService(model.User{ID: 7, Email: "jane@example.com", APIKey: "old key", IsActive: true})
+
+	revoked, err := service.RevokeKeys(model.Actor{UserID: 1}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != 1 || userRepo.users[0].APIKey == "old key" || userRepo.users[0].APIKey == "" {
+		t.Errorf("revoked %d project keys and set API key %q, want 1 and a new key", revoked, userRepo.users[0].APIKey)
+	}
+	keys := service.keyRepo.(*fakeProjectKeyRepository).keys
+	if keys[0].RevokedAt == nil || keys[1].RevokedAt != nil {
+		t.Errorf("keys %+v, want only jane's revoked", keys)
+	}
+	want := []string{model.AuditKeyRevoked, model.AuditAdminKeysRevoked}
+	if got := auditRepo.actions(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("audited %v, want %v", got, want)
+	}
+	if event := auditRepo.events[0]; event.OrganizationID == nil || *event.OrganizationID != 1 {
+		t.Errorf("key revocation %+v, want it filed under the project's organization", event)
+	}
+}
+
+func (r *fakeAuditRepository) ListAllEvents(filter model.AuditFilter) ([]model.AuditEvent, int, error) {
+	events := []model.AuditEvent{}
+	for _, event := range r.events {
+		if filter.WithoutOrganization && event.OrganizationID != nil {
+			continue
+		}
+		if filter.OrganizationID != 0 && (event.OrganizationID == nil || *event.OrganizationID != filter.OrganizationID) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, len(events), nil
+}
+
+func TestAdminAuditEvents(t *testing.T) {
+	service, _, _, auditRepo := newTestAdminService(model.User{ID: 7, Email: "jane@example.com", IsActive: true})
+	service.auditService.Record(newAuditEvent(model.Actor{UserID: 7}, model.AuditLogin, "user", "7"))
+	service.auditService.Record(orgAuditEvent(1, model.Actor{UserID: 7}, model.AuditMemberAdded, "user", "8"))
+
+	page, err := service.AuditEvents(model.Actor{UserID: 1}, model.AuditFilter{WithoutOrganization: true, PageSize: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Events[0].Action != model.AuditLogin || page.PageSize != maxAuditPageSize {
+		t.Errorf("page %+v, want the sign-in only and the maximum page size", page)
+	}
+	if got := auditRepo.actions(); got[len(got)-1] != model.AuditAdminAuditViewed {
+		t.Errorf("audited %v, want the read of the log last", got)
+	}
+	if page, _ := service.AuditEvents(model.Actor{UserID: 1}, model.AuditFilter{OrganizationID: 1}); page.Total != 1 || page.Events[0].Action != model.AuditMemberAdded {
+		t.Errorf("events of organization 1: %+v", page)
+	}
+}
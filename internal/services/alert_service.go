@@ -0,0 +1,243 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+// Notifier delivers alert notifications to people or other systems.
+type Notifier interface {
+	Name() string
+	Notify(alert *model.Alert) error
+}
+
+// LogNotifier writes notifications to the server log. It is the default notifier.
+type LogNotifier struct{}
+
+func (LogNotifier) Name() string {
+	return "log"
+}
+
+func (LogNotifier) Notify(alert *model.Alert) error {
+	log.Printf("🚨 [%s] alert %s (%s) %s route=%q: %s",
+		alert.ProjectID,
+		alert.Rule,
+		alert.Severity,
+		alert.State,
+		alert.Route,
+		alert.Summary,
+	)
+	return nil
+}
+
+// AlertService is the single path through which alert conditions fire and resolve.
+// Firing is idempotent: while an alert with the same labels is open, further
+// Fire calls do not create a new alert or notify again. Every transition is
+// written to the alert history, including notifications a silence suppressed.
+type AlertService struct {
+	alertRepo    repository.AlertRepository
+	silencer     Silencer
+	auditService *AuditService
+	notifiers    []Notifier
+}
+
+// NewAlertService creates the alert service. silencer may be nil; without notifiers
+// alerts are sent to the LogNotifier.
+func NewAlertService(alertRepo repository.AlertRepository, silencer Silencer, auditService *AuditService, notifiers ...Notifier) *AlertService {
+	if len(notifiers) == 0 {
+		notifiers = []Notifier{LogNotifier{}}
+	}
+	return &AlertService{alertRepo: alertRepo, silencer: silencer, auditService: auditService, notifiers: notifiers}
+}
+
+// Fire opens an alert for the given labels unless one is already firing.
+func (s *AlertService) Fire(labels model.AlertLabels, severity string, summary string) (*model.Alert, error) {
+	if labels.ProjectID == "" || labels.Rule == "" {
+		return nil, errors.New("project id and rule are required")
+	}
+	open, err := s.alertRepo.GetOpenAlert(labels)
+	if err != nil {
+		return nil, err
+	}
+	if open != nil {
+		return open, nil
+	}
+	if severity == "" {
+		severity = model.AlertSeverityWarning
+	}
+	alert := &model.Alert{
+		ProjectID: labels.ProjectID,
+		Route:     labels.Route,
+		Rule:      labels.Rule,
+		Severity:  severity,
+		State:     model.AlertStateFiring,
+		Summary:   summary,
+	}
+	if err := s.alertRepo.CreateAlert(alert); err != nil {
+		return nil, err
+	}
+	s.record(alert.ID, model.AlertEventFired, summary, nil)
+	s.notify(alert)
+	return alert, nil
+}
+
+// Resolve closes the open alert for the given labels, if any.
+func (s *AlertService) Resolve(labels model.AlertLabels) (*model.Alert, error) {
+	open, err := s.alertRepo.GetOpenAlert(labels)
+	if err != nil {
+		return nil, err
+	}
+	if open == nil {
+		return nil, nil
+	}
+	if err := s.alertRepo.ResolveAlert(open); err != nil {
+		return nil, err
+	}
+	s.record(open.ID, model.AlertEventResolved, "", nil)
+	s.notify(open)
+	return open, nil
+}
+
+func (s *AlertService) GetAlert(id int) (*model.Alert, error) {
+	return s.alertRepo.GetAlertByID(id)
+}
+
+func (s *AlertService) ListAlerts(projectID string, state string) ([]model.Alert, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if state != "" && state != model.AlertStateFiring && state != model.AlertStateResolved {
+		return nil, errors.New("state must be firing or resolved")
+	}
+	return s.alertRepo.ListAlerts(projectID, state)
+}
+
+// Acknowledge marks a firing alert as being handled by actor.
+func (s *AlertService) Acknowledge(alertID int, actor model.Actor) (*model.Alert, error) {
+	alert, err := s.alertRepo.GetAlertByID(alertID)
+	if err != nil {
+		return nil, err
+	}
+	if alert.State != model.AlertStateFiring {
+		return nil, errors.New("only firing alerts can be acknowledged")
+	}
+	if alert.AcknowledgedBy != nil {
+		return nil, errors.New("alert is already acknowledged")
+	}
+	userID := actor.UserID
+	if err := s.alertRepo.SetAcknowledgement(alert, &userID); err != nil {
+		return nil, err
+	}
+	s.record(alert.ID, model.AlertEventAcknowledged, "", &userID)
+	s.audit(alert, actor, model.AuditAlertAcknowledged, nil)
+	return alert, nil
+}
+
+// Unacknowledge clears the acknowledgement of an alert.
+func (s *AlertService) Unacknowledge(alertID int, actor model.Actor) (*model.Alert, error) {
+	alert, err := s.alertRepo.GetAlertByID(alertID)
+	if err != nil {
+		return nil, err
+	}
+	if alert.AcknowledgedBy == nil {
+		return nil, errors.New("alert is not acknowledged")
+	}
+	previous := *alert.AcknowledgedBy
+	if err := s.alertRepo.SetAcknowledgement(alert, nil); err != nil {
+		return nil, err
+	}
+	userID := actor.UserID
+	s.record(alert.ID, model.AlertEventUnacknowledged, "", &userID)
+	s.audit(alert, actor, model.AuditAlertUnacknowledged, &previous)
+	return alert, nil
+}
+
+func (s *AlertService) GetHistory(alertID int) ([]model.AlertHistoryEntry, error) {
+	return s.alertRepo.ListHistory(alertID)
+}
+
+// AddNote adds a note to the incident timeline of a project.
+func (s *AlertService) AddNote(projectID string, userID int, req model.CreateIncidentNoteRequest) (*model.IncidentNote, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return nil, errors.New("note body is required")
+	}
+	if req.AlertID != nil {
+		alert, err := s.alertRepo.GetAlertByID(*req.AlertID)
+		if err != nil {
+			return nil, errors.New("alert not found")
+		}
+		if alert.ProjectID != projectID {
+			return nil, errors.New("alert does not belong to this project")
+		}
+	}
+	note := &model.IncidentNote{
+		ProjectID: projectID,
+		AlertID:   req.AlertID,
+		UserID:    userID,
+		Body:      req.Body,
+	}
+	if err := s.alertRepo.CreateNote(note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// Timeline returns the incident timeline of a project between from and to, newest first.
+// A zero from defaults to seven days before to; a zero to defaults to now.
+func (s *AlertService) Timeline(projectID string, from time.Time, to time.Time) ([]model.TimelineEntry, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-7 * 24 * time.Hour)
+	}
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+	return s.alertRepo.ListTimeline(projectID, from, to, 1000)
+}
+
+func (s *AlertService) notify(alert *model.Alert) {
+	if s.silencer != nil {
+		if reason, silenced := s.silencer.Silenced(alert.Labels(), time.Now()); silenced {
+			s.record(alert.ID, model.AlertEventSuppressed, reason, nil)
+			return
+		}
+	}
+	for _, n := range s.notifiers {
+		if err := n.Notify(alert); err != nil {
+			log.Printf("error sending notification for alert %d: %v", alert.ID, err)
+			s.record(alert.ID, model.AlertEventNotificationFailed, n.Name()+": "+err.Error(), nil)
+			continue
+		}
+		s.record(alert.ID, model.AlertEventNotified, n.Name(), nil)
+	}
+}
+
+// audit writes an acknowledgement change to the security audit log
+func (s *AlertService) audit(alert *model.Alert, actor model.Actor, action string, previous *int) {
+	event := newAuditEvent(actor, action, "alert", strconv.Itoa(alert.ID))
+	event.Before = auditJSON(map[string]*int{"acknowledgedBy": previous})
+	event.After = auditJSON(map[string]*int{"acknowledgedBy": alert.AcknowledgedBy})
+	s.auditService.RecordProject(alert.ProjectID, event)
+}
+
+// record appends to the alert history. Failures are logged; they must not stop the alert itself.
+func (s *AlertService) record(alertID int, event string, detail string, userID *int) {
+	entry := &model.AlertHistoryEntry{AlertID: alertID, Event: event, Detail: detail, UserID: userID}
+	if err := s.alertRepo.AddHistory(entry); err != nil {
+		log.Printf("error recording %s for alert %d: %v", event, alertID, err)
+	}
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+// fakeAlertRepository keeps alerts, their history and notes in memory
+type fakeAlertRepository struct {
+	repository.AlertRepository
+	alerts  []*model.Alert
+	history []model.AlertHistoryEntry
+	notes   []model.IncidentNote
+}
+
+func (r *fakeAlertRepository) GetAlertByID(id int) (*model.Alert, error) {
+	if id < 1 || id > len(r.alerts) {
+		return nil, errors.New("alert not found")
+	}
+	return r.alerts[id-1], nil
+}
+
+func (r *fakeAlertRepository) GetOpenAlert(labels model.AlertLabels) (*model.Alert, error) {
+	for _, a := range r.alerts {
+		if a.Labels() == labels && a.State == model.AlertStateFiring {
+			return a, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeAlertRepository) CreateAlert(alert *model.Alert) error {
+	alert.ID = len(r.alerts) + 1
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func (r *fakeAlertRepository) ResolveAlert(alert *model.Alert) error {
+	alert.State = model.AlertStateResolved
+	return nil
+}
+
+func (r *fakeAlertRepository) SetAcknowledgement(alert *model.Alert, userID *int) error {
+	alert.AcknowledgedBy = userID
+	return nil
+}
+
+func (r *fakeAlertRepository) ListAlerts(projectID string, state string) ([]model.Alert, error) {
+	alerts := []model.Alert{}
+	for _, a := range r.alerts {
+		if a.ProjectID == projectID && (state == "" || a.State == state) {
+			alerts = append(alerts, *a)
+		}
+	}
+	return alerts, nil
+}
+
+func (r *fakeAlertRepository) AddHistory(entry *model.AlertHistoryEntry) error {
+	r.history = append(r.history, *entry)
+	return nil
+}
+
+func (r *fakeAlertRepository) CreateNote(note *model.IncidentNote) error {
+	note.ID = len(r.notes) + 1
+	r.notes = append(r.notes, *note)
+	return nil
+}
+
+// events returns the history events of an alert in order
+func (r *fakeAlertRepository) events(alertID int) []string {
+	var events []string
+	for _, entry := range r.history {
+		if entry.AlertID == alertID {
+			events = append(events, entry.Event)
+		}
+	}
+	return events
+}
+
+type fakeNotifier struct {
+	err  error
+	sent int
+}
+
+func (n *fakeNotifier) Name() string { return "fake" }
+
+func (n *fakeNotifier) Notify(alert *model.Alert) error {
+	n.sent++
+	return n.err
+}
+
+type fakeSilencer string
+
+func (s fakeSilencer) Silenced(labels model.AlertLabels, at time.Time) (string, bool) {
+	return string(s), s != ""
+}
+
+func equalEvents(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAlertFireIsIdempotent(t *testing.T) {
+	repo := &fakeAlertRepository{}
+	notifier := &fakeNotifier{}
+	service := NewAlertService(repo, nil, nil, notifier)
+	labels := model.AlertLabels{ProjectID: "shop", Route: "/checkout", Rule: "latency"}
+
+	first, err := service.Fire(labels, "", "p95 is 900ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := service.Fire(labels, model.AlertSeverityCritical, "p95 is 950ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || len(repo.alerts) != 1 || notifier.sent != 1 {
+		t.Errorf("firing twice opened %d alerts and sent %d notifications, want 1 and 1", len(repo.alerts), notifier.sent)
+	}
+	if first.Severity != model.AlertSeverityWarning {
+		t.Errorf("severity %q, want the warning default", first.Severity)
+	}
+
+	if _, err := service.Resolve(labels); err != nil {
+		t.Fatal(err)
+	}
+	if resolved, _ := service.Resolve(labels); resolved != nil {
+		t.Errorf("resolving a resolved alert returned %+v", resolved)
+	}
+	if reopened, _ := service.Fire(labels, "", "p95 is 900ms"); reopened.ID == first.ID {
+		t.Error("firing after a resolve reused the resolved alert")
+	}
+	if events := repo.events(first.ID); !equalEvents(events, model.AlertEventFired, model.AlertEventNotified, model.AlertEventResolved, model.AlertEventNotified) {
+		t.Errorf("history %v", events)
+	}
+	if _, err := service.Fire(model.AlertLabels{ProjectID: "shop"}, "", ""); err == nil {
+		t.Error("alert without a rule fired")
+	}
+}
+
+func TestAlertNotificationsRecordFailuresAndSilences(t *testing.T) {
+	repo := &fakeAlertRepository{}
+	failing := &fakeNotifier{err: errors.New("smtp down")}
+	service := NewAlertService(repo, nil, nil, failing)
+	alert, _ := service.Fire(model.AlertLabels{ProjectID: "shop", Rule: "errors"}, "", "")
+	if events := repo.events(alert.ID); !equalEvents(events, model.AlertEventFired, model.AlertEventNotificationFailed) {
+		t.Errorf("failed notification history %v", events)
+	}
+	if detail := repo.history[1].Detail; detail != "fake: smtp down" {
+		t.Errorf("failure detail %q", detail)
+	}
+
+	silenced := &fakeNotifier{}
+	service = NewAlertService(repo, fakeSilencer("deploy window"), nil, silenced)
+	alert, _ = service.Fire(model.AlertLabels{ProjectID: "shop", Rule: "latency"}, "", "")
+	if silenced.sent != 0 {
+		t.Errorf("silenced alert sent %d notifications", silenced.sent)
+	}
+	if events := repo.events(alert.ID); !equalEvents(events, model.AlertEventFired, model.AlertEventSuppressed) {
+		t.Errorf("silenced history %v", events)
+	}
+}
+
+func TestAlertAcknowledgement(t *testing.T) {
+	repo := &fakeAlertRepository{}
+	auditService, _ := newTestAuditService()
+	service := NewAlertService(repo, nil, auditService, &fakeNotifier{})
+	alert, _ := service.Fire(model.AlertLabels{ProjectID: "shop", Rule: "latency"}, "", "")
+
+	acked, err := service.Acknowledge(alert.ID, model.Actor{UserID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acked.AcknowledgedBy == nil || *acked.AcknowledgedBy != 7 {
+		t.Errorf("acknowledged by %v, want user 7", acked.AcknowledgedBy)
+	}
+	if _, err := service.Acknowledge(alert.ID, model.Actor{UserID: 8}); err == nil {
+		t.Error("an acknowledged alert was acknowledged again")
+	}
+	if _, err := service.Unacknowledge(alert.ID, model.Actor{UserID: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Unacknowledge(alert.ID, model.Actor{UserID: 8}); err == nil {
+		t.Error("an unacknowledged alert was unacknowledged")
+	}
+
+	// transitions made by people carry the user in the history
+	var users []int
+	for _, entry := range repo.history {
+		if entry.Event == model.AlertEventAcknowledged || entry.Event == model.AlertEventUnacknowledged {
+			users = append(users, *entry.UserID)
+		}
+	}
+	if len(users) != 2 || users[0] != 7 || users[1] != 8 {
+		t.Errorf("acknowledgement history users %v, want [7 8]", users)
+	}
+
+	service.Resolve(alert.Labels())
+	if _, err := service.Acknowledge(alert.ID, model.Actor{UserID: 7}); err == nil {
+		t.Error("a resolved alert was acknowledged")
+	}
+}
+
+func TestAlertAddNote(t *testing.T) {
+	repo := &fakeAlertRepository{}
+	service := NewAlertService(repo, nil, nil, &fakeNotifier{})
+	alert, _ := service.Fire(model.AlertLabels{ProjectID: "shop", Rule: "latency"}, "", "")
+
+	tests := []struct {
+		scenario  string
+		projectID string
+		req       model.CreateIncidentNoteRequest
+		wantErr   bool
+	}{
+		{"project note", "shop", model.CreateIncidentNoteRequest{Body: "deploying a fix"}, false},
+		{"alert note", "shop", model.CreateIncidentNoteRequest{AlertID: &alert.ID, Body: "rolled back"}, false},
+		{"blank body", "shop", model.CreateIncidentNoteRequest{Body: "  "}, true},
+		{"alert of another project", "blog", model.CreateIncidentNoteRequest{AlertID: &alert.ID, Body: "hi"}, true},
+	}
+	for _, tt := range tests {
+		note, err := service.AddNote(tt.projectID, 7, tt.req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.scenario, err, tt.wantErr)
+		}
+		if err == nil && (note.UserID != 7 || note.ProjectID != tt.projectID) {
+			t.Errorf("%s: note %+v", tt.scenario, note)
+		}
+	}
+	if len(repo.notes) != 2 {
+		t.Errorf("%d notes stored, want 2", len(repo.notes))
+	}
+}
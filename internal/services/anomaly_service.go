@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+const (
+	defaultAnomalySensitivity = 3.0
+	defaultAnomalyMinRequests = 20
+	// ewmaAlpha weights the most recent hour; 0.1 gives an effective memory of roughly a day.
+	ewmaAlpha          = 0.1
+	ewmaHistory        = 7 * 24 * time.Hour
+	ewmaMinSamples     = 24
+	seasonalWeeks      = 4
+	seasonalMinSamples = 3
+	hoursPerWeek       = 7 * 24
+)
+
+var anomalySignals = []string{model.AnomalySignalLatency, model.AnomalySignalThroughputDrop, model.AnomalySignalErrorSpike}
+
+// AnomalyService learns per detector baselines from hourly metric rollups and
+// flags the last complete hour when it deviates from them.
+type AnomalyService struct {
+	anomalyRepo  repository.AnomalyRepository
+	metricRepo   repository.MetricRepository
+	alertService *AlertService
+
+	mu            sync.Mutex
+	lastEvaluated map[int]time.Time
+}
+
+func NewAnomalyService(anomalyRepo repository.AnomalyRepository, metricRepo repository.MetricRepository, alertService *AlertService) *AnomalyService {
+	return &AnomalyService{
+		anomalyRepo:   anomalyRepo,
+		metricRepo:    metricRepo,
+		alertService:  alertService,
+		lastEvaluated: map[int]time.Time{},
+	}
+}
+
+func (s *AnomalyService) CreateDetector(userID int, req model.CreateAnomalyDetectorRequest) (*model.AnomalyDetector, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if req.Algorithm == "" {
+		req.Algorithm = model.AnomalyAlgorithmSeasonal
+	}
+	if req.Algorithm != model.AnomalyAlgorithmEWMA && req.Algorithm != model.AnomalyAlgorithmSeasonal {
+		return nil, errors.New("algorithm must be ewma or seasonal")
+	}
+	if req.Sensitivity == 0 {
+		req.Sensitivity = defaultAnomalySensitivity
+	}
+	if req.Sensitivity < 1 {
+		return nil, errors.New("sensitivity must be at least 1")
+	}
+	if req.MinRequests == 0 {
+		req.MinRequests = defaultAnomalyMinRequests
+	}
+	if req.MinRequests < 0 {
+		return nil, errors.New("minRequests must not be negative")
+	}
+	detector := &model.AnomalyDetector{
+		ProjectID:   req.ProjectID,
+		Route:       req.Route,
+		Algorithm:   req.Algorithm,
+		Sensitivity: req.Sensitivity,
+		MinRequests: req.MinRequests,
+		Enabled:     true,
+		CreatedBy:   userID,
+	}
+	if err := s.anomalyRepo.CreateDetector(detector); err != nil {
+		return nil, err
+	}
+	return detector, nil
+}
+
+func (s *AnomalyService) GetDetector(id int) (*model.AnomalyDetector, error) {
+	return s.anomalyRepo.GetDetectorByID(id)
+}
+
+func (s *AnomalyService) ListDetectors(projectID string) ([]model.AnomalyDetector, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	return s.anomalyRepo.ListDetectors(projectID)
+}
+
+func (s *AnomalyService) DeleteDetector(id int) error {
+	d, err := s.anomalyRepo.GetDetectorByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.anomalyRepo.DeleteDetector(id); err != nil {
+		return err
+	}
+	for _, signal := range anomalySignals {
+		s.alertService.Resolve(anomalyAlertLabels(d, signal))
+	}
+	return nil
+}
+
+func (s *AnomalyService) ListEvents(projectID string, limit int) ([]model.AnomalyEvent, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.anomalyRepo.ListEvents(projectID, limit)
+}
+
+// Start evaluates all enabled detectors every interval until ctx is cancelled.
+func (s *AnomalyService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.EvaluateAll(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EvaluateAll runs every enabled detector once for the last complete hour before now.
+// A detector is only evaluated once per hour.
+func (s *AnomalyService) EvaluateAll(now time.Time) {
+	detectors, err := s.anomalyRepo.ListEnabledDetectors()
+	if err != nil {
+		log.Println("error listing anomaly detectors:", err)
+		return
+	}
+	current := now.UTC().Truncate(time.Hour).Add(-time.Hour)
+	for i := range detectors {
+		d := &detectors[i]
+		s.mu.Lock()
+		done := !s.lastEvaluated[d.ID].Before(current)
+		s.mu.Unlock()
+		if done {
+			continue
+		}
+		if _, err := s.Evaluate(d, now); err != nil {
+			log.Printf("error evaluating anomaly detector %d: %v", d.ID, err)
+			continue
+		}
+		s.mu.Lock()
+		s.lastEvaluated[d.ID] = current
+		s.mu.Unlock()
+	}
+}
+
+// Evaluate checks the last complete hour before now against the detector's
+// baseline, records an anomaly event per flagged signal and fires or resolves
+// the matching "anomaly:<detector id>:<signal>" alerts. It returns the events it flagged.
+func (s *AnomalyService) Evaluate(d *model.AnomalyDetector, now time.Time) ([]model.AnomalyEvent, error) {
+	current := now.UTC().Truncate(time.Hour).Add(-time.Hour)
+	history := ewmaHistory
+	if d.Algorithm == model.AnomalyAlgorithmSeasonal {
+		history = seasonalWeeks * hoursPerWeek * time.Hour
+	}
+	from := current.Add(-history)
+	rollups, err := s.metricRepo.HourlyRollups(d.ProjectID, d.Route, from, current.Add(time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	series := denseRollups(rollups, from, current)
+	cur := series[len(series)-1]
+	past := series[:len(series)-1]
+
+	flagged := []model.AnomalyEvent{}
+	for _, signal := range anomalySignals {
+		event, ok := s.judge(d, signal, cur, past)
+		labels := anomalyAlertLabels(d, signal)
+		if !ok {
+			if _, err := s.alertService.Resolve(labels); err != nil {
+				log.Printf("error resolving anomaly alert for detector %d: %v", d.ID, err)
+			}
+			continue
+		}
+		if _, err := s.anomalyRepo.CreateEvent(event); err != nil {
+			return flagged, err
+		}
+		flagged = append(flagged, *event)
+		summary := fmt.Sprintf("%s anomaly: observed %.2f, expected %.2f (%.1fσ)", signal, event.Observed, event.Expected, event.Deviation)
+		if _, err := s.alertService.Fire(labels, model.AlertSeverityWarning, summary); err != nil {
+			log.Printf("error firing anomaly alert for detector %d: %v", d.ID, err)
+		}
+	}
+	return flagged, nil
+}
+
+// anomalyAlertLabels identifies the alert of one signal of a detector. The rule
+// includes the detector ID: detectors watching the same route must not resolve
+// each other's alerts.
+func anomalyAlertLabels(d *model.AnomalyDetector, signal string) model.AlertLabels {
+	return model.AlertLabels{ProjectID: d.ProjectID, Route: d.Route, Rule: fmt.Sprintf("anomaly:%d:%s", d.ID, signal)}
+}
+
+// judge decides whether one signal of the current hour is anomalous.
+func (s *AnomalyService) judge(d *model.AnomalyDetector, signal string, cur model.MetricRollup, past []model.MetricRollup) (*model.AnomalyEvent, bool) {
+	value := func(r model.MetricRollup) (float64, bool) {
+		switch signal {
+		case model.AnomalySignalThroughputDrop:
+			return float64(r.Requests), true
+		case model.AnomalySignalLatency:
+			return r.P95Latency, r.Requests >= int64(d.MinRequests) && r.Requests > 0
+		default:
+			if r.Requests < int64(d.MinRequests) || r.Requests == 0 {
+				return 0, false
+			}
+			return float64(r.Errors) / float64(r.Requests), true
+		}
+	}
+	observed, ok := value(cur)
+	if !ok {
+		return nil, false
+	}
+
+	var samples []float64
+	if d.Algorithm == model.AnomalyAlgorithmSeasonal {
+		// only the same hour of week in previous weeks
+		for i := len(past) - hoursPerWeek; i >= 0; i -= hoursPerWeek {
+			if v, ok := value(past[i]); ok {
+				samples = append(samples, v)
+			}
+		}
+	} else {
+		for _, r := range past {
+			if v, ok := value(r); ok {
+				samples = append(samples, v)
+			}
+		}
+	}
+
+	var b baseline
+	if d.Algorithm == model.AnomalyAlgorithmSeasonal {
+		if len(samples) < seasonalMinSamples {
+			return nil, false
+		}
+		b = meanBaseline(samples)
+	} else {
+		if len(samples) < ewmaMinSamples {
+			return nil, false
+		}
+		b = ewmaBaseline(samples, ewmaAlpha)
+	}
+
+	// Floors keep a perfectly flat history from turning every tiny wobble into an anomaly.
+	var floor float64
+	switch signal {
+	case model.AnomalySignalLatency:
+		floor = math.Max(0.1*b.mean, 1)
+	case model.AnomalySignalThroughputDrop:
+		if b.mean < float64(d.MinRequests) {
+			return nil, false
+		}
+		floor = math.Max(0.1*b.mean, 1)
+	default:
+		floor = 0.01
+	}
+	z := (observed - b.mean) / math.Max(b.std, floor)
+
+	anomalous := z > d.Sensitivity
+	if signal == model.AnomalySignalThroughputDrop {
+		anomalous = z < -d.Sensitivity
+	}
+	if !anomalous {
+		return nil, false
+	}
+	return &model.AnomalyEvent{
+		DetectorID:  d.ID,
+		ProjectID:   d.ProjectID,
+		Route:       d.Route,
+		Signal:      signal,
+		Observed:    observed,
+		Expected:    b.mean,
+		Deviation:   z,
+		WindowStart: cur.BucketStart,
+	}, true
+}
+
+type baseline struct {
+	mean float64
+	std  float64
+}
+
+func meanBaseline(values []float64) baseline {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return baseline{mean: mean, std: math.Sqrt(sq / float64(len(values)))}
+}
+
+// ewmaBaseline computes the exponentially weighted mean and standard deviation of
+// values, oldest first.
+func ewmaBaseline(values []float64, alpha float64) baseline {
+	mean := values[0]
+	var variance float64
+	for _, v := range values[1:] {
+		diff := v - mean
+		incr := alpha * diff
+		mean += incr
+		variance = (1 - alpha) * (variance + diff*incr)
+	}
+	return baseline{mean: mean, std: math.Sqrt(variance)}
+}
+
+// denseRollups returns one rollup per hour in [from, last], filling hours without traffic with zeros.
+func denseRollups(rollups []model.MetricRollup, from time.Time, last time.Time) []model.MetricRollup {
+	byHour := make(map[int64]model.MetricRollup, len(rollups))
+	for _, r := range rollups {
+		byHour[r.BucketStart.Unix()] = r
+	}
+	series := []model.MetricRollup{}
+	for t := from; !t.After(last); t = t.Add(time.Hour) {
+		r, ok := byHour[t.Unix()]
+		if !ok {
+			r = model.MetricRollup{BucketStart: t}
+		}
+		series = append(series, r)
+	}
+	return series
+}
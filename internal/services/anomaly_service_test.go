@@ -0,0 +1,176 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+func TestMeanBaseline(t *testing.T) {
+	b := meanBaseline([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if b.mean != 5 || b.std != 2 {
+		t.Errorf("meanBaseline = %+v, want mean 5 and std 2", b)
+	}
+}
+
+func TestEWMABaseline(t *testing.T) {
+	tests := []struct {
+		values   []float64
+		mean     float64
+		std      float64
+		scenario string
+	}{
+		{[]float64{7, 7, 7, 7}, 7, 0, "flat"},
+		{[]float64{0, 10}, 1, 3, "one step"},
+		{[]float64{5}, 5, 0, "single value"},
+	}
+	for _, tt := range tests {
+		b := ewmaBaseline(tt.values, 0.1)
+		if math.Abs(b.mean-tt.mean) > 1e-9 || math.Abs(b.std-tt.std) > 1e-9 {
+			t.Errorf("%s: ewmaBaseline = %+v, want mean %v and std %v", tt.scenario, b, tt.mean, tt.std)
+		}
+	}
+}
+
+func TestDenseRollupsFillsEmptyHours(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := denseRollups([]model.MetricRollup{{BucketStart: from.Add(time.Hour), Requests: 42}}, from, from.Add(3*time.Hour))
+	if len(series) != 4 {
+		t.Fatalf("got %d hours, want 4", len(series))
+	}
+	for i, r := range series {
+		if !r.BucketStart.Equal(from.Add(time.Duration(i) * time.Hour)) {
+			t.Errorf("hour %d starts at %s", i, r.BucketStart)
+		}
+		want := int64(0)
+		if i == 1 {
+			want = 42
+		}
+		if r.Requests != want {
+			t.Errorf("hour %d has %d requests, want %d", i, r.Requests, want)
+		}
+	}
+}
+
+// steadyHistory returns hours of 100 requests with 1 error and a p95 of 100ms
+func steadyHistory(hours int) []model.MetricRollup {
+	past := make([]model.MetricRollup, hours)
+	for i := range past {
+		past[i] = model.MetricRollup{Requests: 100, Errors: 1, P95Latency: 100}
+	}
+	return past
+}
+
+func TestJudgeEWMA(t *testing.T) {
+	detector := &model.AnomalyDetector{Algorithm: model.AnomalyAlgorithmEWMA, Sensitivity: 3, MinRequests: 20}
+	tests := []struct {
+		scenario string
+		signal   string
+		current  model.MetricRollup
+		history  int
+		want     bool
+	}{
+		{"steady latency", model.AnomalySignalLatency, model.MetricRollup{Requests: 100, Errors: 1, P95Latency: 105}, 48, false},
+		{"slow hour", model.AnomalySignalLatency, model.MetricRollup{Requests: 100, Errors: 1, P95Latency: 500}, 48, true},
+		{"slow hour with too little traffic", model.AnomalySignalLatency, model.MetricRollup{Requests: 5, P95Latency: 500}, 48, false},
+		{"slow hour without enough history", model.AnomalySignalLatency, model.MetricRollup{Requests: 100, P95Latency: 500}, ewmaMinSamples - 1, false},
+		{"traffic drop", model.AnomalySignalThroughputDrop, model.MetricRollup{Requests: 10}, 48, true},
+		{"traffic spike is not a drop", model.AnomalySignalThroughputDrop, model.MetricRollup{Requests: 1000}, 48, false},
+		{"error spike", model.AnomalySignalErrorSpike, model.MetricRollup{Requests: 100, Errors: 50, P95Latency: 100}, 48, true},
+		{"usual errors", model.AnomalySignalErrorSpike, model.MetricRollup{Requests: 100, Errors: 2, P95Latency: 100}, 48, false},
+	}
+	for _, tt := range tests {
+		event, got := (&AnomalyService{}).judge(detector, tt.signal, tt.current, steadyHistory(tt.history))
+		if got != tt.want {
+			t.Errorf("%s: anomalous = %v, want %v", tt.scenario, got, tt.want)
+			continue
+		}
+		if got && (event.Signal != tt.signal || event.Expected == event.Observed) {
+			t.Errorf("%s: unexpected event %+v", tt.scenario, event)
+		}
+	}
+}
+
+func TestJudgeSeasonalComparesTheSameHourOfWeek(t *testing.T) {
+	// a weekly peak of 1000 requests in the hour being judged
+	past := steadyHistory(seasonalWeeks * hoursPerWeek)
+	for i := len(past) - hoursPerWeek; i >= 0; i -= hoursPerWeek {
+		past[i].Requests = 1000
+	}
+	usual := model.MetricRollup{Requests: 100, Errors: 1, P95Latency: 100}
+
+	seasonal := &model.AnomalyDetector{Algorithm: model.AnomalyAlgorithmSeasonal, Sensitivity: 3, MinRequests: 20}
+	if _, got := (&AnomalyService{}).judge(seasonal, model.AnomalySignalThroughputDrop, usual, past); !got {
+		t.Error("seasonal: missing the weekly peak is not flagged")
+	}
+	ewma := &model.AnomalyDetector{Algorithm: model.AnomalyAlgorithmEWMA, Sensitivity: 3, MinRequests: 20}
+	if _, got := (&AnomalyService{}).judge(ewma, model.AnomalySignalThroughputDrop, usual, past); got {
+		t.Error("ewma: an ordinary hour is flagged")
+	}
+}
+
+func (r *fakeMetricRepository) HourlyRollups(projectID string, route string, from time.Time, to time.Time) ([]model.MetricRollup, error) {
+	var rollups []model.MetricRollup
+	for start := from; start.Before(to); start = start.Add(time.Hour) {
+		rollup := r.latest
+		if start.Add(time.Hour).Before(to) {
+			rollup = model.MetricRollup{Requests: 100, Errors: 1, P95Latency: 100}
+		}
+		rollup.BucketStart = start
+		rollups = append(rollups, rollup)
+	}
+	return rollups, nil
+}
+
+// fakeAnomalyRepository keeps detectors and records events in memory
+type fakeAnomalyRepository struct {
+	repository.AnomalyRepository
+	detectors map[int]*model.AnomalyDetector
+	events    []model.AnomalyEvent
+}
+
+func (r *fakeAnomalyRepository) GetDetectorByID(id int) (*model.AnomalyDetector, error) {
+	return r.detectors[id], nil
+}
+
+func (r *fakeAnomalyRepository) DeleteDetector(id int) error {
+	delete(r.detectors, id)
+	return nil
+}
+
+func (r *fakeAnomalyRepository) CreateEvent(event *model.AnomalyEvent) (bool, error) {
+	r.events = append(r.events, *event)
+	return true, nil
+}
+
+func TestAnomalyAlertsAreKeptPerDetector(t *testing.T) {
+	// two detectors watch the same route; only the sensitive one flags a slow hour
+	sensitive := &model.AnomalyDetector{ID: 1, ProjectID: "shop", Route: "/checkout", Algorithm: model.AnomalyAlgorithmEWMA, Sensitivity: 3, MinRequests: 20}
+	lenient := &model.AnomalyDetector{ID: 2, ProjectID: "shop", Route: "/checkout", Algorithm: model.AnomalyAlgorithmEWMA, Sensitivity: 1000, MinRequests: 20}
+	anomalyRepo := &fakeAnomalyRepository{detectors: map[int]*model.AnomalyDetector{1: sensitive, 2: lenient}}
+	alertRepo := &fakeAlertRepository{}
+	metricRepo := &fakeMetricRepository{latest: model.MetricRollup{Requests: 100, Errors: 1, P95Latency: 500}}
+	service := NewAnomalyService(anomalyRepo, metricRepo, NewAlertService(alertRepo, nil, nil, &fakeNotifier{}))
+	now := time.Date(2025, 1, 8, 12, 30, 0, 0, time.UTC)
+
+	for _, d := range []*model.AnomalyDetector{sensitive, lenient} {
+		if _, err := service.Evaluate(d, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	open, _ := alertRepo.GetOpenAlert(model.AlertLabels{ProjectID: "shop", Route: "/checkout", Rule: "anomaly:1:p95_latency"})
+	if open == nil || len(alertRepo.alerts) != 1 {
+		t.Fatalf("%d alerts, want detector 1's latency alert still firing", len(alertRepo.alerts))
+	}
+
+	// deleting the detector resolves its alerts
+	if err := service.DeleteDetector(1); err != nil {
+		t.Fatal(err)
+	}
+	if open.State != model.AlertStateResolved {
+		t.Errorf("alert %+v of a deleted detector, want it resolved", open)
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// AuditService writes and reads the security audit log
+type AuditService struct {
+	auditRepo repository.AuditRepository
+	orgRepo   repository.OrganizationRepository
+}
+
+func NewAuditService(auditRepo repository.AuditRepository, orgRepo repository.OrganizationRepository) *AuditService {
+	return &AuditService{auditRepo: auditRepo, orgRepo: orgRepo}
+}
+
+// Record appends an event. A failure is logged rather than returned: the
+// audited action has already happened by the time it is recorded.
+func (s *AuditService) Record(event model.AuditEvent) {
+	if err := s.auditRepo.CreateEvent(&event); err != nil {
+		log.Printf("error recording audit event %s: %v", event.Action, err)
+	}
+}
+
+// RecordProject appends an event about something in a project, filed under the
+// project's organization
+func (s *AuditService) RecordProject(projectID string, event model.AuditEvent) {
+	project, err := s.orgRepo.GetProject(projectID)
+	if err != nil {
+		log.Printf("error resolving project %s for audit event %s: %v", projectID, event.Action, err)
+	} else if project != nil {
+		event.OrganizationID = &project.OrganizationID
+	}
+	s.Record(event)
+}
+
+// List returns a page of the audit log of an organization, newest first
+func (s *AuditService) List(orgID int, filter model.AuditFilter) (*model.AuditPage, error) {
+	filter = pageAuditFilter(filter)
+	events, total, err := s.auditRepo.ListEvents(orgID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AuditPage{Events: events, Total: total, Page: filter.Page, PageSize: filter.PageSize}, nil
+}
+
+// ListAll returns a page of the whole audit log, newest first, including the
+// events outside any organization. Only system administrators may read it.
+func (s *AuditService) ListAll(filter model.AuditFilter) (*model.AuditPage, error) {
+	filter = pageAuditFilter(filter)
+	events, total, err := s.auditRepo.ListAllEvents(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AuditPage{Events: events, Total: total, Page: filter.Page, PageSize: filter.PageSize}, nil
+}
+
+// pageAuditFilter applies the default and maximum page size
+func pageAuditFilter(filter model.AuditFilter) model.AuditFilter {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = defaultAuditPageSize
+	}
+	if filter.PageSize > maxAuditPageSize {
+		filter.PageSize = maxAuditPageSize
+	}
+	return filter
+}
+
+// newAuditEvent starts an event performed by actor
+func newAuditEvent(actor model.Actor, action, targetType, targetID string) model.AuditEvent {
+	event := model.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         actor.IP,
+		UserAgent:  actor.UserAgent,
+	}
+	if actor.UserID != 0 {
+		id := actor.UserID
+		event.ActorID = &id
+	}
+	return event
+}
+
+// orgAuditEvent starts an event performed by actor in an organization
+func orgAuditEvent(orgID int, actor model.Actor, action, targetType, targetID string) model.AuditEvent {
+	event := newAuditEvent(actor, action, targetType, targetID)
+	event.OrganizationID = &orgID
+	return event
+}
+
+// auditJSON encodes a value for the before, after or metadata of an event
+func auditJSON(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("error encoding audit data: %v", err)
+		return nil
+	}
+	return data
+}
+
+// auditDiff encodes the fields that differ between two snapshots of a value,
+// keyed by their JSON names. A nil side records the other in full.
+func auditDiff(before, after interface{}) (json.RawMessage, json.RawMessage) {
+	if before == nil || after == nil {
+		var b, a json.RawMessage
+		if before != nil {
+			b = auditJSON(before)
+		}
+		if after != nil {
+			a = auditJSON(after)
+		}
+		return b, a
+	}
+	var old, cur map[string]interface{}
+	if json.Unmarshal(auditJSON(before), &old) != nil || json.Unmarshal(auditJSON(after), &cur) != nil {
+		return auditJSON(before), auditJSON(after)
+	}
+	changedOld, changedCur := map[string]interface{}{}, map[string]interface{}{}
+	for key, value := range cur {
+		if !reflect.DeepEqual(old[key], value) {
+			changedOld[key] = old[key]
+			changedCur[key] = value
+		}
+	}
+	for key, value := range old {
+		if _, ok := cur[key]; !ok {
+			changedOld[key] = value
+		}
+	}
+	return auditJSON(changedOld), auditJSON(changedCur)
+}
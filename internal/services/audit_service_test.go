@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+// fakeAuditRepository keeps audit events in memory
+type fakeAuditRepository struct {
+	repository.AuditRepository
+	events []model.AuditEvent
+}
+
+func (r *fakeAuditRepository) CreateEvent(event *model.AuditEvent) error {
+	event.ID = int64(len(r.events) + 1)
+	r.events = append(r.events, *event)
+	return nil
+}
+
+// actions returns the actions of the recorded events in order
+func (r *fakeAuditRepository) actions() []string {
+	var actions []string
+	for _, event := range r.events {
+		actions = append(actions, event.Action)
+	}
+	return actions
+}
+
+func newTestAuditService() (*AuditService, *fakeAuditRepository) {
+	auditRepo := &fakeAuditRepository{}
+	return NewAuditService(auditRepo, newFakeOrganizationRepository()), auditRepo
+}
+
+func TestAuditDiff(t *testing.T) {
+	type member struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	tests := []struct {
+		scenario   string
+		before     interface{}
+		after      interface{}
+		wantBefore string
+		wantAfter  string
+	}{
+		{"changed field", member{"jane", "member"}, member{"jane", "admin"}, `{"role":"member"}`, `{"role":"admin"}`},
+		{"nothing changed", member{"jane", "member"}, member{"jane", "member"}, `{}`, `{}`},
+		{"created", nil, member{"jane", "member"}, ``, `{"name":"jane","role":"member"}`},
+		{"deleted", member{"jane", "member"}, nil, `{"name":"jane","role":"member"}`, ``},
+	}
+	for _, tt := range tests {
+		before, after := auditDiff(tt.before, tt.after)
+		if string(before) != tt.wantBefore || string(after) != tt.wantAfter {
+			t.Errorf("%s: diff %s -> %s, want %s -> %s", tt.scenario, before, after, tt.wantBefore, tt.wantAfter)
+		}
+	}
+}
+
+func TestAlertAcknowledgementIsAudited(t *testing.T) {
+	auditService, auditRepo := newTestAuditService()
+	service := NewAlertService(&fakeAlertRepository{}, nil, auditService, &fakeNotifier{})
+	alert, _ := service.Fire(model.AlertLabels{ProjectID: "shop", Rule: "latency"}, "", "")
+	actor := model.Actor{UserID: 7, ClientInfo: model.ClientInfo{IP: "203.0.113.7", UserAgent: "curl/8.0"}}
+
+	if _, err := service.Acknowledge(alert.ID, actor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Unacknowledge(alert.ID, model.Actor{UserID: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if len(auditRepo.events) != 2 {
+		t.Fatalf("audit events %v, want the acknowledgement and its removal", auditRepo.actions())
+	}
+	acked, unacked := auditRepo.events[0], auditRepo.events[1]
+	if acked.Action != model.AuditAlertAcknowledged || acked.ActorID == nil || *acked.ActorID != 7 || acked.IP != "203.0.113.7" {
+		t.Errorf("acknowledgement recorded as %+v", acked)
+	}
+	if acked.OrganizationID == nil || *acked.OrganizationID != 1 || acked.TargetID != "1" {
+		t.Errorf("acknowledgement filed under organization %v and target %q, want 1 and alert 1", acked.OrganizationID, acked.TargetID)
+	}
+	if string(unacked.Before) != `{"acknowledgedBy":7}` || string(unacked.After) != `{"acknowledgedBy":null}` {
+		t.Errorf("removal recorded %s -> %s", unacked.Before, unacked.After)
+	}
+}
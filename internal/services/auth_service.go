@@ -2,26 +2,96 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"net/mail"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"prothomuse-server/internal/model"
 	"prothomuse-server/internal/repository"
 	"prothomuse-server/internal/utils"
 )
 
+const (
+	// refreshTokenTTL is how long a login lasts without activity; each refresh rotates the token
+	refreshTokenTTL     = 30 * 24 * time.Hour
+	verifyEmailTokenTTL = 24 * time.Hour
+	resetTokenTTL       = time.Hour
+	// mfaChallengeTTL is how long a user has to enter their code after the password
+	mfaChallengeTTL = 5 * time.Minute
+	unlockTokenTTL  = 24 * time.Hour
+)
+
+// AuthConfig holds the deployment settings of the auth flows
+type AuthConfig struct {
+	// AppURL is the public base URL used in links sent by email
+	AppURL string
+	// RequireVerifiedEmail makes Login refuse users who have not confirmed their email
+	RequireVerifiedEmail bool
+	// PasswordLoginDisabled leaves single sign-on as the only way to register and log in
+	PasswordLoginDisabled bool
+}
+
+var (
+	ErrPasswordLoginDisabled = errors.New("password login is disabled, sign in with SSO")
+	// ErrInvalidCredentials is the only answer to a wrong email or password, so
+	// login cannot be used to find out which emails have accounts
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+// dummyPasswordHash is checked against when the email is unknown, so that a
+// login takes as long whether or not the account exists. It is made on first
+// use, after the hashing parameters are configured.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashPassword("not a real password")
+	return hash
+})
+
 type AuthService struct {
-	userRepo repository.UserRepository
+	userRepo       repository.UserRepository
+	tokenRepo      repository.TokenRepository
+	userTokenRepo  repository.UserTokenRepository
+	mailService    *MailService
+	mfaService     *MFAService
+	inviteService  *InvitationService
+	loginGuard     *LoginGuard
+	passwordPolicy *PasswordPolicy
+	sessions       *SessionService
+	auditService   *AuditService
+	config         AuthConfig
 }
 
-func NewAuthService(userRepo repository.UserRepository) *AuthService {
-	return &AuthService{userRepo: userRepo}
+func NewAuthService(userRepo repository.UserRepository, tokenRepo repository.TokenRepository, userTokenRepo repository.UserTokenRepository, mailService *MailService, mfaService *MFAService, inviteService *InvitationService, loginGuard *LoginGuard, passwordPolicy *PasswordPolicy, sessions *SessionService, auditService *AuditService, config AuthConfig) *AuthService {
+	config.AppURL = strings.TrimRight(config.AppURL, "/")
+	return &AuthService{
+		userRepo:       userRepo,
+		tokenRepo:      tokenRepo,
+		userTokenRepo:  userTokenRepo,
+		mailService:    mailService,
+		mfaService:     mfaService,
+		inviteService:  inviteService,
+		loginGuard:     loginGuard,
+		passwordPolicy: passwordPolicy,
+		sessions:       sessions,
+		auditService:   auditService,
+		config:         config,
+	}
 }
 
-func (s *AuthService) RegisterUser(req model.RegisterRequest) (*model.User, error) {
+func (s *AuthService) RegisterUser(req model.RegisterRequest, client model.ClientInfo) (*model.User, error) {
+	if s.config.PasswordLoginDisabled {
+		return nil, ErrPasswordLoginDisabled
+	}
 	if err := validateRegisterRequest(req); err != nil {
 		return nil, err
 	}
+	if err := s.passwordPolicy.Validate(req.Password, req.Username, req.Email); err != nil {
+		return nil, err
+	}
 	//existingUser, err := s.userRepo.GetUserByEmail(req.Email)
 	//if err != nil {
 	//	return nil, err
@@ -29,6 +99,12 @@ func (s *AuthService) RegisterUser(req model.RegisterRequest) (*model.User, erro
 	//if existingUser != nil {
 	//	return nil, errors.New("user with this email already exists")
 //	}
+	// An invitation is checked before the account exists, so a bad token fails the registration
+	if req.InviteToken != "" {
+		if _, err := s.inviteService.Check(req.InviteToken, req.Email); err != nil {
+			return nil, err
+		}
+	}
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
 		log.Printf("error in hashing the password: %v", err)
@@ -50,38 +126,526 @@ func (s *AuthService) RegisterUser(req model.RegisterRequest) (*model.User, erro
 		log.Println("error in creating the user on the database in sql code side")
 		return nil, err
 	}
+	event := newAuditEvent(model.Actor{UserID: user.ID, ClientInfo: client}, model.AuditRegister, "user", strconv.Itoa(user.ID))
+	event.After = auditJSON(userAuditFields(user))
+	s.auditService.Record(event)
+	if req.InviteToken != "" {
+		_, err := s.inviteService.Accept(user, req.InviteToken, client)
+		if err == nil {
+			// the invitation link was mailed to this address, which proves it
+			user.EmailVerified = true
+			if err := s.userRepo.UpdateUser(user); err != nil {
+				log.Printf("error marking email of user %d verified: %v", user.ID, err)
+			}
+			return user, nil
+		}
+		// the account exists; the invitation can be accepted once signed in
+		log.Printf("error accepting invitation for user %d: %v", user.ID, err)
+	}
+	if err := s.sendVerificationEmail(user); err != nil {
+		// the account exists; the user can ask for a new email
+		log.Printf("error sending verification email to user %d: %v", user.ID, err)
+	}
+	return user, nil
+}
+
+// sendVerificationEmail mails a new single-use link confirming the user's email,
+// invalidating any earlier one.
+func (s *AuthService) sendVerificationEmail(user *model.User) error {
+	token, err := s.issueUserToken(user.ID, model.TokenPurposeVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+	link := s.config.AppURL + "/api/auth/verify-email?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\nThe link expires in 24 hours. If you did not create a Prothomuse account, ignore this email.\n", user.Username, link)
+	return s.mailService.Enqueue(model.EmailKindVerifyEmail, user.Email, "Confirm your email address", body)
+}
+
+// VerifyEmail consumes a verification token and marks the email of its user verified
+func (s *AuthService) VerifyEmail(token string) (*model.User, error) {
+	if token == "" {
+		return nil, errors.New("token is required")
+	}
+	consumed, err := s.userTokenRepo.ConsumeToken(model.TokenPurposeVerifyEmail, utils.HashToken(token), time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if consumed == nil {
+		return nil, errors.New("invalid or expired verification token")
+	}
+	user, err := s.userRepo.GetUserByID(consumed.UserID)
+	if err != nil {
+		return nil, err
+	}
+	user.EmailVerified = true
+	if err := s.userRepo.UpdateUser(user); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
+// ResendVerification mails a new verification link. It does nothing, without
+// telling, for unknown or already verified addresses so it cannot be used to
+// probe which emails have accounts.
+func (s *AuthService) ResendVerification(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil || user == nil || user.EmailVerified {
+		return nil
+	}
+	return s.sendVerificationEmail(user)
+}
+
+// issueUserToken stores a new single-use token for the user and returns its clear value
+func (s *AuthService) issueUserToken(userID int, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := s.userTokenRepo.CreateToken(&model.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ForgotPassword mails a password reset link to an active account. Unknown and
+// inactive addresses are ignored silently so the response reveals nothing.
+func (s *AuthService) ForgotPassword(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil || user == nil || !user.IsActive {
+		return nil
+	}
+	token, err := s.issueUserToken(user.ID, model.TokenPurposeResetPassword, resetTokenTTL)
+	if err != nil {
+		return err
+	}
+	link := s.config.AppURL + "/reset-password?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your Prothomuse account. To choose a new password, open this link:\n\n%s\n\nor send this token to /api/auth/reset-password:\n\n%s\n\nThe link expires in 1 hour and works once. If you did not ask for a reset, ignore this email; your password is unchanged.\n", user.Username, link, token)
+	return s.mailService.Enqueue(model.EmailKindResetPassword, user.Email, "Reset your password", body)
+}
+
+// ResetPassword sets a new password with a reset token and signs the user out
+// everywhere by revoking all of their tokens.
+func (s *AuthService) ResetPassword(req model.ResetPasswordRequest) error {
+	if req.Token == "" {
+		return errors.New("token is required")
+	}
+	// the token is only used up once the password is accepted, so a refused
+	// password can be corrected with the same link
+	pending, err := s.userTokenRepo.FindToken(model.TokenPurposeResetPassword, utils.HashToken(req.Token), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if pending == nil {
+		return errors.New("invalid or expired reset token")
+	}
+	user, err := s.userRepo.GetUserByID(pending.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.passwordPolicy.Validate(req.Password, user.Username, user.Email); err != nil {
+		return err
+	}
+	consumed, err := s.userTokenRepo.ConsumeToken(model.TokenPurposeResetPassword, utils.HashToken(req.Token), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if consumed == nil {
+		return errors.New("invalid or expired reset token")
+	}
+	hashed, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashed
+	// the reset link proved the user reads this mailbox
+	user.EmailVerified = true
+	if err := s.userRepo.UpdateUser(user); err != nil {
+		return err
+	}
+	if err := s.loginGuard.Unlock(user.Email); err != nil {
+		log.Printf("error clearing login failures of user %d: %v", user.ID, err)
+	}
+	return s.RevokeAllTokens(user.ID)
+}
+
 // login user
-func (s *AuthService) Login(req model.LoginRequest) (*model.LoginResponse, error) {
+func (s *AuthService) Login(req model.LoginRequest, client model.ClientInfo) (*model.LoginResponse, error) {
+	if s.config.PasswordLoginDisabled {
+		return nil, ErrPasswordLoginDisabled
+	}
+	now := time.Now().UTC()
+	if err := s.loginGuard.Check(req.Email, client.IP, now); err != nil {
+		return nil, err
+	}
 	user, err := s.userRepo.GetUserByEmail(req.Email)
+	if err != nil || user == nil {
+		utils.CheckPasswordHash(req.Password, dummyPasswordHash())
+		return nil, s.loginFailed(nil, req.Email, client, now)
+	}
+	if !utils.CheckPasswordHash(req.Password, user.Password) {
+		return nil, s.loginFailed(user, req.Email, client, now)
+	}
+	s.upgradePasswordHash(user, req.Password)
+	if !user.IsActive {
+		s.auditLoginFailed(user, req.Email, client, "inactive")
+		return nil, errors.New("user is not active")
+	}
+	if s.config.RequireVerifiedEmail && !user.EmailVerified {
+		s.auditLoginFailed(user, req.Email, client, "email_unverified")
+		return nil, errors.New("email address is not verified")
+	}
+	mfaEnabled, err := s.mfaService.IsEnabled(user.ID)
 	if err != nil {
-		log.Println("error in getting the user by email in login ")
 		return nil, err
 	}
-	if user == nil {
-		return nil, errors.New("user not found")
+	if mfaEnabled {
+		// failures are only cleared once the second factor is right too
+		challenge, err := s.issueUserToken(user.ID, model.TokenPurposeMFAChallenge, mfaChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &model.LoginResponse{ID: user.ID, Username: user.Username, MFARequired: true, MFAToken: challenge}, nil
+	}
+	s.clearLoginFailures(user)
+	return s.completeLogin(user, client, "password")
+}
+
+// clearLoginFailures forgets the failed logins of an account once it has signed in
+func (s *AuthService) clearLoginFailures(user *model.User) {
+	if err := s.loginGuard.Unlock(user.Email); err != nil {
+		log.Printf("error clearing login failures of user %d: %v", user.ID, err)
+	}
+}
+
+// upgradePasswordHash rehashes a bcrypt or outdated argon2id hash with the
+// current parameters, now that the plain password is known to be right
+func (s *AuthService) upgradePasswordHash(user *model.User, password string) {
+	if !utils.PasswordNeedsRehash(user.Password) {
+		return
+	}
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("error rehashing password of user %d: %v", user.ID, err)
+		return
+	}
+	// a password changed since the user was read is left alone
+	upgraded, err := s.userRepo.ReplacePasswordHash(user.ID, user.Password, hashed)
+	if err != nil {
+		log.Printf("error storing rehashed password of user %d: %v", user.ID, err)
+		return
+	}
+	if upgraded {
+		user.Password = hashed
+	}
+}
+
+// loginFailed counts a failed login and handles the lockouts it causes. user is
+// nil when the email is unknown. It always returns ErrInvalidCredentials.
+func (s *AuthService) loginFailed(user *model.User, email string, client model.ClientInfo, now time.Time) error {
+	s.countLoginFailure(user, email, client, "invalid_credentials", now)
+	return ErrInvalidCredentials
+}
+
+// countLoginFailure audits a failed login for reason, counts it against the
+// account and the IP address and handles the lockouts it causes
+func (s *AuthService) countLoginFailure(user *model.User, email string, client model.ClientInfo, reason string, now time.Time) {
+	s.auditLoginFailed(user, email, client, reason)
+	accountLocked, ipLocked, err := s.loginGuard.Fail(email, client.IP, now)
+	if err != nil {
+		log.Printf("error recording failed login: %v", err)
+	}
+	if accountLocked {
+		event := model.AuditEvent{
+			Action:     model.AuditAccountLocked,
+			TargetType: "user",
+			IP:         client.IP,
+			UserAgent:  client.UserAgent,
+			Metadata:   auditJSON(map[string]string{"scope": model.LoginScopeAccount, "email": email}),
+		}
+		if user != nil {
+			event.TargetID = strconv.Itoa(user.ID)
+			if err := s.sendUnlockEmail(user); err != nil {
+				log.Printf("error sending unlock email to user %d: %v", user.ID, err)
+			}
+		}
+		s.auditService.Record(event)
+	}
+	if ipLocked {
+		s.auditService.Record(model.AuditEvent{
+			Action:     model.AuditAccountLocked,
+			TargetType: "ip",
+			TargetID:   client.IP,
+			IP:         client.IP,
+			UserAgent:  client.UserAgent,
+			Metadata:   auditJSON(map[string]string{"scope": model.LoginScopeIP}),
+		})
+	}
+}
+
+// auditLoginFailed records a rejected login. user is nil when the email is unknown.
+func (s *AuthService) auditLoginFailed(user *model.User, email string, client model.ClientInfo, reason string) {
+	event := newAuditEvent(model.Actor{ClientInfo: client}, model.AuditLoginFailed, "user", "")
+	if user != nil {
+		event.TargetID = strconv.Itoa(user.ID)
+	}
+	event.Metadata = auditJSON(map[string]string{"email": email, "reason": reason})
+	s.auditService.Record(event)
+}
+
+// sendUnlockEmail tells the owner of a locked account and mails a link that unlocks it
+func (s *AuthService) sendUnlockEmail(user *model.User) error {
+	token, err := s.issueUserToken(user.ID, model.TokenPurposeUnlockAccount, unlockTokenTTL)
+	if err != nil {
+		return err
+	}
+	link := s.config.AppURL + "/api/auth/unlock?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nYour Prothomuse account was locked after too many failed login attempts. It unlocks by itself shortly; to unlock it now, open this link:\n\n%s\n\nIf these attempts were not yours, someone may be guessing your password: consider changing it.\n", user.Username, link)
+	return s.mailService.Enqueue(model.EmailKindUnlockAccount, user.Email, "Your account was locked", body)
+}
+
+// UnlockAccount consumes an unlock token from the lockout email
+func (s *AuthService) UnlockAccount(token string, client model.ClientInfo) error {
+	if token == "" {
+		return errors.New("token is required")
+	}
+	consumed, err := s.userTokenRepo.ConsumeToken(model.TokenPurposeUnlockAccount, utils.HashToken(token), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if consumed == nil {
+		return errors.New("invalid or expired unlock token")
+	}
+	return s.UnlockUser(consumed.UserID, consumed.UserID, client)
+}
+
+// UnlockUser clears the login failures of a user's account on behalf of actorID
+func (s *AuthService) UnlockUser(userID int, actorID int, client model.ClientInfo) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
 	}
+	if err := s.loginGuard.Unlock(user.Email); err != nil {
+		return err
+	}
+	s.auditService.Record(model.AuditEvent{
+		ActorID:    &actorID,
+		Action:     model.AuditAccountUnlocked,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(user.ID),
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+	})
+	return nil
+}
+
+// completeLogin signs the user in on a new session; method is how they proved who they are
+func (s *AuthService) completeLogin(user *model.User, client model.ClientInfo, method string) (*model.LoginResponse, error) {
+	familyID, err := utils.GenerateToken(16)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.sessions.Start(user.ID, familyID, client)
+	if err != nil {
+		return nil, err
+	}
+	login, err := s.issueTokens(user, session)
+	if err != nil {
+		return nil, err
+	}
+	event := newAuditEvent(model.Actor{UserID: user.ID, ClientInfo: client}, model.AuditLogin, "user", strconv.Itoa(user.ID))
+	event.Metadata = auditJSON(map[string]string{"method": method, "sessionId": strconv.Itoa(session.ID)})
+	s.auditService.Record(event)
+	return login, nil
+}
+
+// VerifyMFA completes an mfa_required login. The challenge is single-use: a wrong
+// code ends it and the user has to enter the password again. Wrong codes count
+// as failed logins, and the failures of the password step are only cleared by a
+// right code, so code guessing is slowed down and locked out like password guessing.
+func (s *AuthService) VerifyMFA(req model.MFAVerifyRequest, client model.ClientInfo) (*model.LoginResponse, error) {
+	if req.MFAToken == "" || req.Code == "" {
+		return nil, errors.New("mfaToken and code are required")
+	}
+	challenge, err := s.userTokenRepo.ConsumeToken(model.TokenPurposeMFAChallenge, utils.HashToken(req.MFAToken), time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil {
+		return nil, errors.New("invalid or expired MFA challenge, please log in again")
+	}
+	user, err := s.userRepo.GetUserByID(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if err := s.loginGuard.Check(user.Email, client.IP, now); err != nil {
+		return nil, err
+	}
+	if err := s.mfaService.Verify(user.ID, req.Code); err != nil {
+		s.countLoginFailure(user, user.Email, client, "invalid_mfa_code", now)
+		return nil, err
+	}
+	s.clearLoginFailures(user)
 	if !user.IsActive {
+		s.auditLoginFailed(user, user.Email, client, "inactive")
 		return nil, errors.New("user is not active")
 	}
-	if !utils.CheckPasswordHash(req.Password, user.Password) {
-		return nil, errors.New("invalid password")
-	}
-	token, err := utils.GenerateJWT(user.ID, user.Email)
+	return s.completeLogin(user, client, "mfa")
+}
+
+// issueTokens returns a new access token and a refresh token for the session
+func (s *AuthService) issueTokens(user *model.User, session *model.Session) (*model.LoginResponse, error) {
+	token, err := utils.GenerateJWT(user.ID, user.Email, user.TokenVersion, session.ID)
 	if err != nil {
 		log.Println("error in generating the jwt token ")
 		return nil, err
 	}
+	refreshToken, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tokenRepo.CreateRefreshToken(&model.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		FamilyID:  session.FamilyID,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenTTL),
+	}); err != nil {
+		return nil, err
+	}
 	return &model.LoginResponse{
-		ID:       user.ID,
-		Token:    token,
-		APIKey:   user.APIKey,
-		Username: user.Username,
+		ID:           user.ID,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+		APIKey:       user.APIKey,
+		Username:     user.Username,
 	}, nil
 }
 
+// Refresh exchanges a refresh token for a new access token and a new refresh token.
+// A refresh token works once: presenting it again means it was stolen (or the
+// client is confused), so the whole family is revoked and the user must log in.
+func (s *AuthService) Refresh(refreshToken string, client model.ClientInfo) (*model.LoginResponse, error) {
+	if refreshToken == "" {
+		return nil, errors.New("refresh token is required")
+	}
+	stored, err := s.tokenRepo.GetRefreshTokenByHash(utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, errors.New("invalid refresh token")
+	}
+	now := time.Now().UTC()
+	if stored.UsedAt != nil || stored.RevokedAt != nil {
+		return nil, s.reuseDetected(stored, now)
+	}
+	if now.After(stored.ExpiresAt) {
+		return nil, errors.New("refresh token expired")
+	}
+	ok, err := s.tokenRepo.MarkRefreshTokenUsed(stored.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// lost a race against another use of the same token
+		return nil, s.reuseDetected(stored, now)
+	}
+
+	user, err := s.userRepo.GetUserByID(stored.UserID)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+	if !user.IsActive {
+		return nil, errors.New("user is not active")
+	}
+	session, err := s.sessions.Resume(user.ID, stored.FamilyID, client)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user, session)
+}
+
+func (s *AuthService) reuseDetected(stored *model.RefreshToken, now time.Time) error {
+	log.Printf("refresh token reuse detected for user %d, revoking token family", stored.UserID)
+	if err := s.tokenRepo.RevokeFamily(stored.FamilyID, now); err != nil {
+		return err
+	}
+	if err := s.sessions.EndFamily(stored.FamilyID); err != nil {
+		return err
+	}
+	return errors.New("refresh token has already been used")
+}
+
+// Logout revokes the access token of the request and ends its session, with the
+// refresh token chain it was issued with. allDevices revokes every token of the user.
+func (s *AuthService) Logout(claims *utils.Claims, req model.LogoutRequest) error {
+	now := time.Now().UTC()
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := s.tokenRepo.RevokeAccessToken(claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+	if req.RefreshToken != "" {
+		stored, err := s.tokenRepo.GetRefreshTokenByHash(utils.HashToken(req.RefreshToken))
+		if err != nil {
+			return err
+		}
+		if stored != nil && stored.UserID == claims.UserID {
+			if err := s.tokenRepo.RevokeFamily(stored.FamilyID, now); err != nil {
+				return err
+			}
+			if err := s.sessions.EndFamily(stored.FamilyID); err != nil {
+				return err
+			}
+		}
+	}
+	if claims.SessionID != 0 {
+		if err := s.sessions.end(claims.UserID, claims.SessionID); err != nil {
+			return err
+		}
+	}
+	if req.AllDevices {
+		return s.RevokeAllTokens(claims.UserID)
+	}
+	return nil
+}
+
+// RevokeAllTokens invalidates every access and refresh token of a user
+func (s *AuthService) RevokeAllTokens(userID int) error {
+	if _, err := s.userRepo.IncrementTokenVersion(userID); err != nil {
+		return err
+	}
+	if err := s.sessions.RevokeAll(userID); err != nil {
+		return err
+	}
+	return s.tokenRepo.RevokeUserRefreshTokens(userID, time.Now().UTC())
+}
+
+// CheckRevoked is installed as the utils.RevocationCheck. It fails closed when
+// the database cannot be reached.
+func (s *AuthService) CheckRevoked(claims *utils.Claims) error {
+	revoked, err := s.tokenRepo.IsAccessTokenRevoked(claims.ID, claims.UserID, claims.TokenVersion)
+	if err != nil {
+		return errors.New("could not verify token revocation")
+	}
+	if revoked {
+		return errors.New("token has been revoked")
+	}
+	if err := s.sessions.Check(claims); err != nil {
+		if errors.Is(err, ErrSessionRevoked) {
+			return err
+		}
+		return errors.New("could not verify token revocation")
+	}
+	return nil
+}
+
 func (s *AuthService) GetUserByAPIKey(apiKey string) (*model.User, error) {
 	if apiKey == "" {
 		return nil, errors.New("API key is required")
@@ -94,12 +658,16 @@ func (s *AuthService) GetUserByAPIKey(apiKey string) (*model.User, error) {
 	if user == nil {
 		return nil, errors.New("user not found")
 	}
+	if !user.IsActive {
+		return nil, errors.New("user is not active")
+	}
 	return user, nil
 }
 
-// UpdateUser updates an existing user's mutable fields.
+// UpdateUser updates an existing user's mutable fields on behalf of actor.
 // The provided model.User must include the ID of the user to update.
-func (s *AuthService) UpdateUser(update model.UpdateUserRequest) (*model.User, error) {
+// A new password signs out every session but currentSessionID.
+func (s *AuthService) UpdateUser(update model.UpdateUserRequest, actor model.Actor, currentSessionID int) (*model.User, error) {
 	if update.ID == 0 {
 		return nil, errors.New("user id is required")
 	}
@@ -113,9 +681,14 @@ func (s *AuthService) UpdateUser(update model.UpdateUserRequest) (*model.User, e
 	if existingUser == nil {
 		return nil, errors.New("user not found")
 	}
+	before := userAuditFields(existingUser)
 
 	// if email is changing, ensure uniqueness
+	emailChanged := false
 	if update.Email != nil && *update.Email != existingUser.Email {
+		if err := validateEmail(*update.Email); err != nil {
+			return nil, err
+		}
 		other, err := s.userRepo.GetUserByEmail(*update.Email)
 		if err != nil && err.Error() != "sql: no rows in result set" {
 			return nil, err
@@ -124,6 +697,10 @@ func (s *AuthService) UpdateUser(update model.UpdateUserRequest) (*model.User, e
 			return nil, errors.New("another user with this email already exists")
 		}
 		existingUser.Email = *update.Email
+		existingUser.EmailVerified = false
+		// UpdateUser drops administrator rights, which were granted to the old email
+		existingUser.IsAdmin = false
+		emailChanged = true
 	}
 
 	if update.Username != nil {
@@ -131,6 +708,9 @@ func (s *AuthService) UpdateUser(update model.UpdateUserRequest) (*model.User, e
 	}
 
 	if update.Password != nil {
+		if err := s.passwordPolicy.Validate(*update.Password, existingUser.Username, existingUser.Email); err != nil {
+			return nil, err
+		}
 		hashed, err := utils.HashPassword(*update.Password)
 		if err != nil {
 			log.Println("error hashing updated password:", err)
@@ -139,37 +719,56 @@ func (s *AuthService) UpdateUser(update model.UpdateUserRequest) (*model.User, e
 		existingUser.Password = hashed
 	}
 
-	if update.APIKey != nil {
-		existingUser.APIKey = *update.APIKey
-	}
-
-	if update.IsActive != nil {
-		existingUser.IsActive = *update.IsActive
-	}
-
 	if err := s.userRepo.UpdateUser(existingUser); err != nil {
 		log.Println("error updating user in repo:", err)
 		return nil, err
 	}
+	event := newAuditEvent(actor, model.AuditUserUpdated, "user", strconv.Itoa(existingUser.ID))
+	event.Before, event.After = auditDiff(before, userAuditFields(existingUser))
+	// secrets are never written to the log, only the fact that they changed
+	event.Metadata = auditJSON(map[string]bool{"passwordChanged": update.Password != nil})
+	s.auditService.Record(event)
+	if update.Password != nil {
+		// whoever knew the old password must not stay signed in elsewhere
+		if _, err := s.sessions.RevokeOthers(actor, currentSessionID); err != nil {
+			log.Printf("error revoking other sessions of user %d: %v", existingUser.ID, err)
+			return nil, err
+		}
+	}
+	if emailChanged {
+		if err := s.sendVerificationEmail(existingUser); err != nil {
+			log.Printf("error sending verification email to user %d: %v", existingUser.ID, err)
+		}
+	}
 	return existingUser, nil
 }
 
-func validateRegisterRequest(req model.RegisterRequest) error {
-	if req.Email == "" || !strings.Contains(req.Email, "@") {
-		
-		return errors.New("invalid email address")
-		
+// userAuditFields is the part of a user recorded in the audit log
+func userAuditFields(user *model.User) map[string]interface{} {
+	return map[string]interface{}{
+		"username":      user.Username,
+		"email":         user.Email,
+		"isActive":      user.IsActive,
+		"emailVerified": user.EmailVerified,
 	}
-	if req.Password == "" {
-		log.Println(req.Email);
-		log.Println(req.Password);
-		return errors.New("password is required")
-	}
-	if len(req.Password) < 6 {
-		return errors.New("password must be at least 6 characters long")
+}
+
+func validateRegisterRequest(req model.RegisterRequest) error {
+	if err := validateEmail(req.Email); err != nil {
+		return err
 	}
 	if req.Username == "" {
 		return errors.New("username is required")
 	}
 	return nil
 }
+
+// validateEmail accepts a bare address such as "john@example.com"
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return errors.New("invalid email address")
+	}
+	return nil
+}
+
@@ -0,0 +1,538 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+// fakeUserRepository finds users by id and email
+type fakeUserRepository struct {
+	repository.UserRepository
+	users []model.User
+}
+
+func (r *fakeUserRepository) GetUserByID(id int) (*model.User, error) {
+	for i := range r.users {
+		if r.users[i].ID == id {
+			return &r.users[i], nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeUserRepository) GetUserByEmail(email string) (*model.User, error) {
+	for i := range r.users {
+		if r.users[i].Email == email {
+			return &r.users[i], nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeUserRepository) IncrementTokenVersion(id int) (int, error) {
+	user, err := r.GetUserByID(id)
+	if err != nil {
+		return 0, err
+	}
+	user.TokenVersion++
+	return user.TokenVersion, nil
+}
+
+func (r *fakeUserRepository) CreateUser(user *model.User) error {
+	if _, err := r.GetUserByEmail(user.Email); err == nil {
+		return errors.New("email already registered")
+	}
+	user.ID = len(r.users) + 1
+	r.users = append(r.users, *user)
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateUser(user *model.User) error {
+	stored, err := r.GetUserByID(user.ID)
+	if err != nil {
+		return err
+	}
+	*stored = *user
+	return nil
+}
+
+// fakeTokenRepository keeps refresh tokens and revoked access tokens in memory
+type fakeTokenRepository struct {
+	repository.TokenRepository
+	refreshTokens []model.RefreshToken
+	revokedJTIs   map[string]bool
+}
+
+func (r *fakeTokenRepository) CreateRefreshToken(token *model.RefreshToken) error {
+	token.ID = len(r.refreshTokens) + 1
+	r.refreshTokens = append(r.refreshTokens, *token)
+	return nil
+}
+
+func (r *fakeTokenRepository) GetRefreshTokenByHash(hash string) (*model.RefreshToken, error) {
+	for _, token := range r.refreshTokens {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeTokenRepository) MarkRefreshTokenUsed(id int, at time.Time) (bool, error) {
+	token := &r.refreshTokens[id-1]
+	if token.UsedAt != nil || token.RevokedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = &at
+	return true, nil
+}
+
+func (r *fakeTokenRepository) RevokeFamily(familyID string, at time.Time) error {
+	for i := range r.refreshTokens {
+		if r.refreshTokens[i].FamilyID == familyID && r.refreshTokens[i].RevokedAt == nil {
+			r.refreshTokens[i].RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *fakeTokenRepository) RevokeUserRefreshTokens(userID int, at time.Time) error {
+	for i := range r.refreshTokens {
+		if r.refreshTokens[i].UserID == userID && r.refreshTokens[i].RevokedAt == nil {
+			r.refreshTokens[i].RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *fakeTokenRepository) RevokeAccessToken(jti string, userID int, expiresAt time.Time) error {
+	r.revokedJTIs[jti] = true
+	return nil
+}
+
+func (r *fakeTokenRepository) IsAccessTokenRevoked(jti string, userID int, tokenVersion int) (bool, error) {
+	return r.revokedJTIs[jti], nil
+}
+
+// fakeUserTokenRepository keeps single-use user tokens in memory
+type fakeUserTokenRepository struct {
+	repository.UserTokenRepository
+	tokens []model.UserToken
+}
+
+func (r *fakeUserTokenRepository) CreateToken(token *model.UserToken) error {
+	r.DeleteUserTokens(token.UserID, token.Purpose)
+	token.ID = len(r.tokens) + 1
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+func (r *fakeUserTokenRepository) FindToken(purpose string, tokenHash string, now time.Time) (*model.UserToken, error) {
+	for i := range r.tokens {
+		token := &r.tokens[i]
+		if token.Purpose == purpose && token.TokenHash == tokenHash && token.UsedAt == nil && token.ExpiresAt.After(now) {
+			return token, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserTokenRepository) ConsumeToken(purpose string, tokenHash string, now time.Time) (*model.UserToken, error) {
+	token, err := r.FindToken(purpose, tokenHash, now)
+	if token != nil {
+		token.UsedAt = &now
+	}
+	return token, err
+}
+
+func (r *fakeUserTokenRepository) DeleteUserTokens(userID int, purpose string) error {
+	kept := r.tokens[:0]
+	for _, token := range r.tokens {
+		if token.UserID != userID || token.Purpose != purpose {
+			kept = append(kept, token)
+		}
+	}
+	r.tokens = kept
+	return nil
+}
+
+func newTestAuthService(users ...model.User) (*AuthService, *fakeUserRepository, *fakeTokenRepository) {
+	userRepo := &fakeUserRepository{users: users}
+	tokenRepo := &fakeTokenRepository{revokedJTIs: map[string]bool{}}
+	mail := NewMailService(&fakeEmailRepository{}, nil)
+	auditService, _ := newTestAuditService()
+	sessions := NewSessionService(&fakeSessionRepository{}, tokenRepo, auditService)
+	inviteService := NewInvitationService(&fakeInvitationRepository{}, auditService.orgRepo, userRepo, mail, auditService, "https://app.example.com")
+	guard := NewLoginGuard(&fakeLoginAttemptRepository{failures: map[[2]string]*model.LoginFailure{}}, LoginGuardConfig{})
+	policy, _ := NewPasswordPolicy(PasswordPolicyConfig{})
+	service := NewAuthService(userRepo, tokenRepo, &fakeUserTokenRepository{}, mail, nil, inviteService, guard, policy, sessions, auditService, AuthConfig{AppURL: "https://app.example.com/"})
+	return service, userRepo, tokenRepo
+}
+
+// signIn starts a session for user whose refresh tokens are in familyID
+func signIn(t *testing.T, service *AuthService, user *model.User, familyID string) *model.LoginResponse {
+	session, err := service.sessions.Start(user.ID, familyID, model.ClientInfo{IP: "203.0.113.7", UserAgent: "curl/8.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, err := service.issueTokens(user, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return login
+}
+
+// mailedToken returns the token of the link in the last email queued by mail
+func mailedToken(t *testing.T, mail *MailService) string {
+	emails := mail.emailRepo.(*fakeEmailRepository).emails
+	if len(emails) == 0 {
+		t.Fatal("no email was sent")
+	}
+	body := emails[len(emails)-1].Body
+	start := strings.Index(body, "?token=")
+	if start < 0 {
+		t.Fatalf("no link in email %q", body)
+	}
+	token, _, _ := strings.Cut(body[start+len("?token="):], "\n")
+	token, err := url.QueryUnescape(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// liveFamilies returns the families that still have a usable refresh token
+func (r *fakeTokenRepository) liveFamilies() map[string]bool {
+	families := map[string]bool{}
+	for _, token := range r.refreshTokens {
+		if token.UsedAt == nil && token.RevokedAt == nil {
+			families[token.FamilyID] = true
+		}
+	}
+	return families
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	service, userRepo, tokenRepo := newTestAuthService(model.User{ID: 7, Email: "jane@example.com", IsActive: true})
+	login := signIn(t, service, &userRepo.users[0], "laptop")
+	refreshed, err := service.Refresh(login.RefreshToken, model.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.RefreshToken == login.RefreshToken || refreshed.Token == "" {
+		t.Errorf("refresh returned %+v, want a new pair of tokens", refreshed)
+	}
+	claims, err := utils.ValidateJWT(refreshed.Token)
+	if err != nil || claims.UserID != 7 {
+		t.Errorf("refreshed access token: %+v, %v", claims, err)
+	}
+	for _, token := range tokenRepo.refreshTokens {
+		if token.TokenHash == login.RefreshToken || token.TokenHash == refreshed.RefreshToken {
+			t.Error("a refresh token is stored in plain text")
+		}
+		if token.FamilyID != "laptop" {
+			t.Errorf("rotated token in family %q, want laptop", token.FamilyID)
+		}
+	}
+}
+
+func TestRefreshTokenReuseRevokesTheFamily(t *testing.T) {
+	service, userRepo, tokenRepo := newTestAuthService(model.User{ID: 7, Email: "jane@example.com", IsActive: true})
+	laptop := signIn(t, service, &userRepo.users[0], "laptop")
+	phone := signIn(t, service, &userRepo.users[0], "phone")
+
+	// the laptop's token is stolen and rotated twice by the legitimate client
+	stolen := laptop.RefreshToken
+	second, err := service.Refresh(stolen, model.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := service.Refresh(second.RefreshToken, model.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the thief replays it
+	if _, err := service.Refresh(stolen, model.ClientInfo{}); err == nil || !strings.Contains(err.Error(), "already been used") {
+		t.Fatalf("replayed refresh token: %v", err)
+	}
+	if _, err := service.Refresh(third.RefreshToken, model.ClientInfo{}); err == nil {
+		t.Error("the latest token of a revoked family still refreshes")
+	}
+	if live := tokenRepo.liveFamilies(); len(live) != 1 || !live["phone"] {
+		t.Errorf("live families %v, want only the phone's", live)
+	}
+	if _, err := service.Refresh(phone.RefreshToken, model.ClientInfo{}); err != nil {
+		t.Errorf("another device was logged out: %v", err)
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	service, userRepo, tokenRepo := newTestAuthService(model.User{ID: 7, Email: "jane@example.com", IsActive: true})
+	login := signIn(t, service, &userRepo.users[0], "laptop")
+
+	if _, err := service.Refresh("", model.ClientInfo{}); err == nil {
+		t.Error("empty refresh token accepted")
+	}
+	if _, err := service.Refresh("not-a-token", model.ClientInfo{}); err == nil {
+		t.Error("unknown refresh token accepted")
+	}
+	userRepo.users[0].IsActive = false
+	if _, err := service.Refresh(login.RefreshToken, model.ClientInfo{}); err == nil || !strings.Contains(err.Error(), "not active") {
+		t.Errorf("deactivated user refreshed: %v", err)
+	}
+
+	userRepo.users[0].IsActive = true
+	login = signIn(t, service, &userRepo.users[0], "phone")
+	tokenRepo.refreshTokens[len(tokenRepo.refreshTokens)-1].ExpiresAt = time.Now().Add(-time.Minute)
+	if _, err := service.Refresh(login.RefreshToken, model.ClientInfo{}); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expired refresh token: %v", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	service, userRepo, tokenRepo := newTestAuthService(model.User{ID: 7, Email: "jane@example.com", IsActive: true})
+	laptop := signIn(t, service, &userRepo.users[0], "laptop")
+	signIn(t, service, &userRepo.users[0], "phone")
+	claims, err := utils.ValidateJWT(laptop.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Logout(claims, model.LogoutRequest{RefreshToken: laptop.RefreshToken}); err != nil {
+		t.Fatal(err)
+	}
+	if !tokenRepo.revokedJTIs[claims.ID] {
+		t.Error("the access token of the request was not revoked")
+	}
+	if live := tokenRepo.liveFamilies(); len(live) != 1 || !live["phone"] {
+		t.Errorf("live families %v after logout, want only the phone's", live)
+	}
+
+	if err := service.Logout(claims, model.LogoutRequest{AllDevices: true}); err != nil {
+		t.Fatal(err)
+	}
+	if live := tokenRepo.liveFamilies(); len(live) != 0 || userRepo.users[0].TokenVersion != 1 {
+		t.Errorf("after logging out everywhere: live families %v, token version %d", live, userRepo.users[0].TokenVersion)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	service, userRepo, _ := newTestAuthService(
+		model.User{ID: 7, Username: "jane", Email: "jane@example.com", IsActive: true},
+		model.User{ID: 8, Username: "john", Email: "john@example.com", IsActive: true, EmailVerified: true},
+	)
+	if err := service.sendVerificationEmail(&userRepo.users[0]); err != nil {
+		t.Fatal(err)
+	}
+	first := mailedToken(t, service.mailService)
+	if err := service.ResendVerification("jane@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := mailedToken(t, service.mailService)
+	outbox := service.mailService.emailRepo.(*fakeEmailRepository)
+	if !strings.Contains(outbox.emails[1].Body, "https://app.example.com/api/auth/verify-email?token=") {
+		t.Error("verification link does not use the app URL")
+	}
+
+	if _, err := service.VerifyEmail(first); err == nil {
+		t.Error("a token replaced by a resend verified the email")
+	}
+	user, err := service.VerifyEmail(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 7 || !userRepo.users[0].EmailVerified {
+		t.Errorf("verified user %d, stored %+v", user.ID, userRepo.users[0])
+	}
+	if _, err := service.VerifyEmail(token); err == nil {
+		t.Error("a verification token was used twice")
+	}
+
+	// unknown and verified addresses get no email, and no error either
+	sent := len(outbox.emails)
+	for _, email := range []string{"nobody@example.com", "john@example.com"} {
+		if err := service.ResendVerification(email); err != nil {
+			t.Errorf("resend to %s: %v", email, err)
+		}
+	}
+	if len(outbox.emails) != sent {
+		t.Errorf("resend sent %d more emails, want none", len(outbox.emails)-sent)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	service, userRepo, tokenRepo := newTestAuthService(
+		model.User{ID: 7, Username: "jane", Email: "jane@example.com", IsActive: true},
+		model.User{ID: 8, Username: "john", Email: "john@example.com"},
+	)
+	laptop := signIn(t, service, &userRepo.users[0], "laptop")
+
+	// unknown and inactive accounts get no email, and no error either
+	for _, email := range []string{"nobody@example.com", "john@example.com"} {
+		if err := service.ForgotPassword(email); err != nil {
+			t.Errorf("forgot password for %s: %v", email, err)
+		}
+	}
+	if outbox := service.mailService.emailRepo.(*fakeEmailRepository); len(outbox.emails) != 0 {
+		t.Fatalf("%d emails sent, want none", len(outbox.emails))
+	}
+	if err := service.ForgotPassword("jane@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := mailedToken(t, service.mailService)
+
+	if err := service.ResetPassword(model.ResetPasswordRequest{Token: token, Password: "short"}); err == nil {
+		t.Error("a too short password was accepted")
+	}
+	if err := service.ResetPassword(model.ResetPasswordRequest{Token: token, Password: "a new password"}); err != nil {
+		t.Fatal(err)
+	}
+	user := userRepo.users[0]
+	if !utils.CheckPasswordHash("a new password", user.Password) || !user.EmailVerified {
+		t.Errorf("after the reset user %+v, want the new password and a verified email", user)
+	}
+	if live := tokenRepo.liveFamilies(); len(live) != 0 || user.TokenVersion != 1 {
+		t.Errorf("after the reset: live families %v, token version %d, want every session ended", live, user.TokenVersion)
+	}
+	if _, err := service.Refresh(laptop.RefreshToken, model.ClientInfo{}); err == nil {
+		t.Error("a session from before the reset still refreshes")
+	}
+	if err := service.ResetPassword(model.ResetPasswordRequest{Token: token, Password: "another password"}); err == nil {
+		t.Error("a reset token was used twice")
+	}
+}
+
+func TestLoginLocksTheAccount(t *testing.T) {
+	hash, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, _, _ := newTestAuthService(model.User{ID: 7, Username: "jane", Email: "jane@example.com", Password: hash, IsActive: true})
+	client := model.ClientInfo{IP: "203.0.113.7", UserAgent: "curl/8.0"}
+	for i := 0; i < 3; i++ {
+		if _, err := service.Login(model.LoginRequest{Email: "jane@example.com", Password: "wrong"}, client); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failure %d: %v, want %v", i+1, err, ErrInvalidCredentials)
+		}
+	}
+	// an unknown email fails the same way, and still counts against the IP
+	if _, err := service.Login(model.LoginRequest{Email: "nobody@example.com", Password: "wrong"}, client); err == nil {
+		t.Fatal("unknown email logged in")
+	}
+
+	// the delay also applies to the right password until it has passed
+	var lockedOut *LockedOutError
+	if _, err := service.Login(model.LoginRequest{Email: "jane@example.com", Password: "correct horse"}, client); !errors.As(err, &lockedOut) {
+		t.Fatalf("login during the delay: %v, want a LockedOutError", err)
+	}
+
+	// once the delays have passed, the 5th failure locks the account
+	attempts := service.loginGuard.attemptRepo.(*fakeLoginAttemptRepository)
+	auditRepo := service.auditService.auditRepo.(*fakeAuditRepository)
+	locks := func() (events []model.AuditEvent) {
+		for _, event := range auditRepo.events {
+			if event.Action == model.AuditAccountLocked {
+				events = append(events, event)
+			}
+		}
+		return events
+	}
+	for i := 0; i < 2; i++ {
+		for _, failure := range attempts.failures {
+			failure.LastFailureAt = failure.LastFailureAt.Add(-time.Minute)
+		}
+		if len(locks()) != 0 {
+			t.Fatalf("account locked after %d failures", i+3)
+		}
+		if _, err := service.Login(model.LoginRequest{Email: "jane@example.com", Password: "wrong"}, model.ClientInfo{IP: "198.51.100.2"}); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failure %d: %v, want %v", i+4, err, ErrInvalidCredentials)
+		}
+	}
+	if locks := locks(); len(locks) != 1 || locks[0].TargetID != "7" {
+		t.Errorf("account locks %+v, want one of user 7", locks)
+	}
+	if outbox := service.mailService.emailRepo.(*fakeEmailRepository); len(outbox.emails) != 1 || outbox.emails[0].To != "jane@example.com" {
+		t.Errorf("emails %+v, want an unlock email to jane", outbox.emails)
+	}
+}
+
+func TestUpdateUserEmailDropsAdmin(t *testing.T) {
+	service, userRepo, _ := newTestAuthService(
+		model.User{ID: 1, Username: "admin", Email: "admin@example.com", IsAdmin: true, IsActive: true, EmailVerified: true},
+		model.User{ID: 7, Username: "jane", Email: "jane@example.com", IsActive: true},
+	)
+	taken := "jane@example.com"
+	if _, err := service.UpdateUser(model.UpdateUserRequest{ID: 1, Email: &taken}, model.Actor{UserID: 1}, 0); err == nil {
+		t.Error("changed the email to one another user has")
+	}
+
+	email := "someone@example.com"
+	if _, err := service.UpdateUser(model.UpdateUserRequest{ID: 1, Email: &email}, model.Actor{UserID: 1}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if admin := userRepo.users[0]; admin.IsAdmin || admin.EmailVerified || admin.Email != email {
+		t.Errorf("user %+v, want the new email unverified and administrator rights dropped", admin)
+	}
+	if outbox := service.mailService.emailRepo.(*fakeEmailRepository); len(outbox.emails) != 1 || outbox.emails[0].To != email {
+		t.Errorf("emails %+v, want a verification email to the new address", outbox.emails)
+	}
+}
+
+func TestUpdateUserPasswordSignsOutOtherSessions(t *testing.T) {
+	service, userRepo, tokenRepo := newTestAuthService(model.User{ID: 7, Username: "jane", Email: "jane@example.com", Password: "old hash", IsActive: true})
+	laptop := signIn(t, service, &userRepo.users[0], "laptop")
+	phone := signIn(t, service, &userRepo.users[0], "phone")
+	claims, err := utils.ValidateJWT(laptop.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	username := "jane_doe"
+	if _, err := service.UpdateUser(model.UpdateUserRequest{ID: 7, Username: &username}, model.Actor{UserID: 7}, claims.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if live := tokenRepo.liveFamilies(); len(live) != 2 {
+		t.Errorf("live families %v after renaming, want both", live)
+	}
+
+	password := "a new secret password"
+	if _, err := service.UpdateUser(model.UpdateUserRequest{ID: 7, Password: &password}, model.Actor{UserID: 7}, claims.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if userRepo.users[0].Password == "old hash" {
+		t.Error("the password was not changed")
+	}
+	if live := tokenRepo.liveFamilies(); len(live) != 1 || !live["laptop"] {
+		t.Errorf("live families %v after a password change, want only the laptop's", live)
+	}
+	if err := service.CheckRevoked(claims); err != nil {
+		t.Errorf("the session that changed the password was signed out: %v", err)
+	}
+	if phoneClaims, _ := utils.ValidateJWT(phone.Token); service.CheckRevoked(phoneClaims) == nil {
+		t.Error("another session's access token still works after a password change")
+	}
+}
+
+func TestUpdateUserIgnoresAccountFields(t *testing.T) {
+	service, userRepo, _ := newTestAuthService(model.User{ID: 7, Username: "jane", Email: "jane@example.com", APIKey: "sk_live_jane", IsActive: true})
+	var update model.UpdateUserRequest
+	if err := json.Unmarshal([]byte(`{"username":"jane_doe","isActive":false,"apiKey":"sk_live_chosen","isAdmin":true}`), &update); err != nil {
+		t.Fatal(err)
+	}
+	update.ID = 7
+	if _, err := service.UpdateUser(update, model.Actor{UserID: 7}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if jane := userRepo.users[0]; jane.Username != "jane_doe" || !jane.IsActive || jane.APIKey != "sk_live_jane" || jane.IsAdmin {
+		t.Errorf("user %+v, want only the username changed", jane)
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Probe is one synthetic check the scheduler runs periodically.
+type Probe interface {
+	// Key identifies the probe across refreshes, e.g. "uptime:12".
+	Key() string
+	Interval() time.Duration
+	Run(ctx context.Context)
+}
+
+// ProbeSource supplies the probes that should currently be scheduled.
+type ProbeSource interface {
+	Probes() ([]Probe, error)
+}
+
+// CheckScheduler runs the probes of all its sources on a shared worker pool.
+// A probe is never run twice concurrently; when the pool is saturated due probes
+// wait for the next tick instead of piling up.
+type CheckScheduler struct {
+	sources []ProbeSource
+	workers int
+	refresh time.Duration
+
+	mu      sync.Mutex
+	probes  map[string]Probe
+	source  map[string]int // index in sources of the source of each probe
+	next    map[string]time.Time
+	running map[string]bool
+}
+
+func NewCheckScheduler(workers int, sources ...ProbeSource) *CheckScheduler {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &CheckScheduler{
+		sources: sources,
+		workers: workers,
+		refresh: 30 * time.Second,
+		probes:  map[string]Probe{},
+		source:  map[string]int{},
+		next:    map[string]time.Time{},
+		running: map[string]bool{},
+	}
+}
+
+// Start runs the scheduler until ctx is cancelled.
+func (s *CheckScheduler) Start(ctx context.Context) {
+	jobs := make(chan Probe, s.workers)
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for probe := range jobs {
+				s.run(ctx, probe)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var lastRefresh time.Time
+	for {
+		now := time.Now()
+		if now.Sub(lastRefresh) >= s.refresh {
+			s.Refresh()
+			lastRefresh = now
+		}
+		s.dispatch(now, jobs)
+
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh reloads the probes from every source. Probes that disappeared are
+// dropped; new ones are due immediately.
+func (s *CheckScheduler) Refresh() {
+	probes := map[string]Probe{}
+	origin := map[string]int{}
+	for i, source := range s.sources {
+		list, err := source.Probes()
+		if err != nil {
+			log.Println("error loading probes:", err)
+			// keep what we had for this source rather than unscheduling everything
+			s.mu.Lock()
+			for key, p := range s.probes {
+				if s.source[key] == i {
+					probes[key] = p
+					origin[key] = i
+				}
+			}
+			s.mu.Unlock()
+			continue
+		}
+		for _, p := range list {
+			probes[p.Key()] = p
+			origin[p.Key()] = i
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.probes = probes
+	s.source = origin
+	for key := range s.next {
+		if _, ok := probes[key]; !ok {
+			delete(s.next, key)
+		}
+	}
+}
+
+func (s *CheckScheduler) dispatch(now time.Time, jobs chan<- Probe) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, probe := range s.probes {
+		if s.running[key] || now.Before(s.next[key]) {
+			continue
+		}
+		select {
+		case jobs <- probe:
+			s.running[key] = true
+		default:
+			return // pool is busy
+		}
+	}
+}
+
+func (s *CheckScheduler) run(ctx context.Context, probe Probe) {
+	started := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("probe %s panicked: %v", probe.Key(), r)
+		}
+		s.mu.Lock()
+		delete(s.running, probe.Key())
+		if _, ok := s.probes[probe.Key()]; ok {
+			s.next[probe.Key()] = started.Add(probe.Interval())
+		}
+		s.mu.Unlock()
+	}()
+	probe.Run(ctx)
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+type fakeProbe string
+
+func (p fakeProbe) Key() string             { return string(p) }
+func (p fakeProbe) Interval() time.Duration { return time.Minute }
+func (p fakeProbe) Run(ctx context.Context) {}
+
+// fakeProbeSource returns its probes, or err when it is set
+type fakeProbeSource struct {
+	probes []Probe
+	err    error
+}
+
+func (s *fakeProbeSource) Probes() ([]Probe, error) {
+	return s.probes, s.err
+}
+
+func scheduledKeys(s *CheckScheduler) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []string{}
+	for key := range s.probes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestCheckSchedulerRefresh(t *testing.T) {
+	uptime := &fakeProbeSource{probes: []Probe{fakeProbe("uptime:1"), fakeProbe("uptime:2")}}
+	transactions := &fakeProbeSource{probes: []Probe{fakeProbe("transaction:1")}}
+	scheduler := NewCheckScheduler(1, uptime, transactions)
+
+	scheduler.Refresh()
+	if got, want := scheduledKeys(scheduler), []string{"transaction:1", "uptime:1", "uptime:2"}; !slices.Equal(got, want) {
+		t.Fatalf("scheduled %v, want %v", got, want)
+	}
+
+	// a failing source keeps its own probes and only those
+	uptime.err = errors.New("database unavailable")
+	transactions.probes = nil
+	scheduler.Refresh()
+	if got, want := scheduledKeys(scheduler), []string{"uptime:1", "uptime:2"}; !slices.Equal(got, want) {
+		t.Errorf("after a failed refresh scheduled %v, want %v", got, want)
+	}
+
+	uptime.err = nil
+	uptime.probes = []Probe{fakeProbe("uptime:2")}
+	scheduler.Refresh()
+	if got, want := scheduledKeys(scheduler), []string{"uptime:2"}; !slices.Equal(got, want) {
+		t.Errorf("after recovery scheduled %v, want %v", got, want)
+	}
+}
+
+func TestCheckSchedulerDispatch(t *testing.T) {
+	scheduler := NewCheckScheduler(1, &fakeProbeSource{probes: []Probe{fakeProbe("uptime:1")}})
+	scheduler.Refresh()
+	jobs := make(chan Probe, 2)
+	now := time.Now()
+
+	scheduler.dispatch(now, jobs)
+	scheduler.dispatch(now, jobs)
+	if len(jobs) != 1 {
+		t.Fatalf("dispatched %d runs of one probe, want 1 while it runs", len(jobs))
+	}
+	scheduler.run(context.Background(), <-jobs)
+
+	scheduler.dispatch(now.Add(30*time.Second), jobs)
+	if len(jobs) != 0 {
+		t.Error("probe dispatched again before its interval")
+	}
+	scheduler.dispatch(now.Add(2*time.Minute), jobs)
+	if len(jobs) != 1 {
+		t.Error("probe not dispatched once its interval passed")
+	}
+}
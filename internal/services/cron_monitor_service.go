@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+const (
+	defaultCronGraceSeconds = 300
+	maxCronGraceSeconds     = 24 * 60 * 60
+	// earlyPingTolerance lets a job that starts slightly before its slot (clock skew) count for it.
+	earlyPingTolerance = time.Minute
+)
+
+// Check-in ping kinds
+const (
+	CronPingStart   = "start"
+	CronPingSuccess = "success"
+	CronPingFail    = "fail"
+)
+
+// CronMonitorService tracks batch jobs through check-in pings and detects missed,
+// late and failed runs. Alerts go through the AlertService like every other condition.
+type CronMonitorService struct {
+	cronRepo     repository.CronMonitorRepository
+	alertService *AlertService
+}
+
+func NewCronMonitorService(cronRepo repository.CronMonitorRepository, alertService *AlertService) *CronMonitorService {
+	return &CronMonitorService{cronRepo: cronRepo, alertService: alertService}
+}
+
+func (s *CronMonitorService) CreateMonitor(userID int, req model.CreateCronMonitorRequest) (*model.CronMonitor, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New("name is required")
+	}
+	schedule, err := utils.ParseCron(req.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule: %w", err)
+	}
+	now := time.Now().UTC()
+	next := schedule.Next(now)
+	if next.IsZero() {
+		return nil, errors.New("schedule never fires")
+	}
+	if req.GraceSeconds == 0 {
+		req.GraceSeconds = defaultCronGraceSeconds
+	}
+	if req.GraceSeconds < 0 || req.GraceSeconds > maxCronGraceSeconds {
+		return nil, fmt.Errorf("graceSeconds must be between 1 and %d", maxCronGraceSeconds)
+	}
+	token, err := utils.GenerateToken(24)
+	if err != nil {
+		return nil, err
+	}
+	monitor := &model.CronMonitor{
+		ProjectID:      req.ProjectID,
+		Name:           req.Name,
+		Schedule:       req.Schedule,
+		GraceSeconds:   req.GraceSeconds,
+		Token:          token,
+		Enabled:        true,
+		CreatedBy:      userID,
+		NextExpectedAt: next,
+	}
+	if err := s.cronRepo.CreateMonitor(monitor); err != nil {
+		return nil, err
+	}
+	return monitor, nil
+}
+
+func (s *CronMonitorService) GetMonitor(id int) (*model.CronMonitor, error) {
+	return s.cronRepo.GetMonitorByID(id)
+}
+
+func (s *CronMonitorService) ListMonitors(projectID string) ([]model.CronMonitor, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	return s.cronRepo.ListMonitors(projectID)
+}
+
+func (s *CronMonitorService) DeleteMonitor(id int) error {
+	monitor, err := s.cronRepo.GetMonitorByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.cronRepo.DeleteMonitor(id); err != nil {
+		return err
+	}
+	s.alertService.Resolve(cronAlertLabels(monitor, "missed"))
+	s.alertService.Resolve(cronAlertLabels(monitor, "failed"))
+	return nil
+}
+
+func (s *CronMonitorService) ListRuns(monitorID int, limit int) ([]model.CronRun, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	return s.cronRepo.ListRuns(monitorID, limit)
+}
+
+// Ping handles a check-in. A start ping, or a finish ping without a running run,
+// counts for the expected schedule slot when it arrives no earlier than
+// earlyPingTolerance before it; it is late when it arrives after the grace period.
+func (s *CronMonitorService) Ping(token string, kind string, now time.Time) (*model.CronRun, error) {
+	if kind != CronPingStart && kind != CronPingSuccess && kind != CronPingFail {
+		return nil, errors.New("ping must be start, success or fail")
+	}
+	monitor, err := s.cronRepo.GetMonitorByToken(token)
+	if err != nil {
+		return nil, errors.New("unknown check-in token")
+	}
+	if !monitor.Enabled {
+		return nil, errors.New("monitor is paused")
+	}
+	now = now.UTC()
+
+	var run *model.CronRun
+	if kind != CronPingStart {
+		if run, err = s.cronRepo.GetOpenRun(monitor.ID); err != nil {
+			return nil, err
+		}
+	}
+	isNew := run == nil
+	if isNew {
+		run = &model.CronRun{MonitorID: monitor.ID}
+		if err := s.matchSlot(monitor, run, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if kind == CronPingStart {
+		run.Status = model.CronRunRunning
+		run.StartedAt = &now
+	} else {
+		run.Status = model.CronRunSuccess
+		if kind == CronPingFail {
+			run.Status = model.CronRunFailed
+		}
+		run.FinishedAt = &now
+		if run.StartedAt != nil {
+			duration := now.Sub(*run.StartedAt).Milliseconds()
+			run.DurationMs = &duration
+		}
+	}
+	if run.ID == 0 {
+		err = s.cronRepo.CreateRun(run)
+	} else {
+		err = s.cronRepo.UpdateRun(run)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	monitor.LastPingAt = &now
+	monitor.LastStatus = run.Status
+	if err := s.cronRepo.UpdateState(monitor); err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case CronPingFail:
+		summary := fmt.Sprintf("cron job %q reported a failed run", monitor.Name)
+		if _, err := s.alertService.Fire(cronAlertLabels(monitor, "failed"), model.AlertSeverityCritical, summary); err != nil {
+			log.Printf("error firing failed alert of cron monitor %d: %v", monitor.ID, err)
+		}
+	case CronPingSuccess:
+		if _, err := s.alertService.Resolve(cronAlertLabels(monitor, "failed")); err != nil {
+			log.Printf("error resolving failed alert of cron monitor %d: %v", monitor.ID, err)
+		}
+	}
+	return run, nil
+}
+
+// matchSlot assigns the monitor's expected slot to a new run if the ping is for it,
+// reusing the "missed" run already recorded for that slot, and advances the schedule.
+func (s *CronMonitorService) matchSlot(monitor *model.CronMonitor, run *model.CronRun, now time.Time) error {
+	slot := monitor.NextExpectedAt
+	if now.Before(slot.Add(-earlyPingTolerance)) {
+		return nil // an extra run outside the schedule
+	}
+	latest, err := s.cronRepo.GetLatestRun(monitor.ID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && latest.Status == model.CronRunMissed && latest.ScheduledAt != nil && latest.ScheduledAt.Equal(slot) {
+		*run = *latest
+	}
+	run.ScheduledAt = &slot
+	run.Late = now.After(slot.Add(time.Duration(monitor.GraceSeconds) * time.Second))
+
+	schedule, err := utils.ParseCron(monitor.Schedule)
+	if err != nil {
+		return err
+	}
+	from := now
+	if from.Before(slot) {
+		from = slot
+	}
+	monitor.NextExpectedAt = schedule.Next(from)
+	if _, err := s.alertService.Resolve(cronAlertLabels(monitor, "missed")); err != nil {
+		log.Printf("error resolving missed alert of cron monitor %d: %v", monitor.ID, err)
+	}
+	return nil
+}
+
+// Start checks for missed runs every interval until ctx is cancelled.
+func (s *CronMonitorService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.CheckMissed(time.Now())
+	}
+}
+
+// CheckMissed records a "missed" run and fires the "cron_missed" alert for every
+// monitor whose expected slot passed its grace period without a ping. The slot is
+// kept so that a late ping can still claim it, until the following slot is also overdue.
+func (s *CronMonitorService) CheckMissed(now time.Time) {
+	now = now.UTC()
+	monitors, err := s.cronRepo.ListEnabledMonitors()
+	if err != nil {
+		log.Println("error listing cron monitors:", err)
+		return
+	}
+	for i := range monitors {
+		monitor := &monitors[i]
+		grace := time.Duration(monitor.GraceSeconds) * time.Second
+		if !now.After(monitor.NextExpectedAt.Add(grace)) {
+			continue
+		}
+		schedule, err := utils.ParseCron(monitor.Schedule)
+		if err != nil {
+			log.Printf("invalid schedule on cron monitor %d: %v", monitor.ID, err)
+			continue
+		}
+		// skip ahead to the latest overdue slot
+		for following := schedule.Next(monitor.NextExpectedAt); !following.IsZero() && now.After(following.Add(grace)); following = schedule.Next(following) {
+			monitor.NextExpectedAt = following
+		}
+		if err := s.recordMissed(monitor); err != nil {
+			log.Printf("error recording missed run of cron monitor %d: %v", monitor.ID, err)
+		}
+	}
+}
+
+func (s *CronMonitorService) recordMissed(monitor *model.CronMonitor) error {
+	slot := monitor.NextExpectedAt
+	latest, err := s.cronRepo.GetLatestRun(monitor.ID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && latest.ScheduledAt != nil && latest.ScheduledAt.Equal(slot) {
+		return nil // already recorded
+	}
+	run := &model.CronRun{MonitorID: monitor.ID, Status: model.CronRunMissed, ScheduledAt: &slot}
+	if err := s.cronRepo.CreateRun(run); err != nil {
+		return err
+	}
+	monitor.LastStatus = model.CronRunMissed
+	if err := s.cronRepo.UpdateState(monitor); err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("cron job %q did not check in for its run scheduled at %s", monitor.Name, slot.Format(time.RFC3339))
+	_, err = s.alertService.Fire(cronAlertLabels(monitor, "missed"), model.AlertSeverityCritical, summary)
+	return err
+}
+
+func cronAlertLabels(monitor *model.CronMonitor, condition string) model.AlertLabels {
+	return model.AlertLabels{ProjectID: monitor.ProjectID, Route: monitor.Name, Rule: "cron_" + condition + ":" + strconv.Itoa(monitor.ID)}
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+// fakeCronMonitorRepository keeps monitors and their runs in memory
+type fakeCronMonitorRepository struct {
+	repository.CronMonitorRepository
+	monitors map[int]model.CronMonitor
+	runs     []model.CronRun
+}
+
+func (r *fakeCronMonitorRepository) CreateMonitor(monitor *model.CronMonitor) error {
+	monitor.ID = len(r.monitors) + 1
+	r.monitors[monitor.ID] = *monitor
+	return nil
+}
+
+func (r *fakeCronMonitorRepository) GetMonitorByToken(token string) (*model.CronMonitor, error) {
+	for _, monitor := range r.monitors {
+		if monitor.Token == token {
+			return &monitor, nil
+		}
+	}
+	return nil, errors.New("cron monitor not found")
+}
+
+func (r *fakeCronMonitorRepository) ListEnabledMonitors() ([]model.CronMonitor, error) {
+	monitors := []model.CronMonitor{}
+	for _, monitor := range r.monitors {
+		if monitor.Enabled {
+			monitors = append(monitors, monitor)
+		}
+	}
+	return monitors, nil
+}
+
+func (r *fakeCronMonitorRepository) UpdateState(monitor *model.CronMonitor) error {
+	r.monitors[monitor.ID] = *monitor
+	return nil
+}
+
+func (r *fakeCronMonitorRepository) CreateRun(run *model.CronRun) error {
+	run.ID = len(r.runs) + 1
+	r.runs = append(r.runs, *run)
+	return nil
+}
+
+func (r *fakeCronMonitorRepository) GetOpenRun(monitorID int) (*model.CronRun, error) {
+	for i := len(r.runs) - 1; i >= 0; i-- {
+		if r.runs[i].MonitorID == monitorID && r.runs[i].Status == model.CronRunRunning {
+			run := r.runs[i]
+			return &run, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeCronMonitorRepository) GetLatestRun(monitorID int) (*model.CronRun, error) {
+	for i := len(r.runs) - 1; i >= 0; i-- {
+		if r.runs[i].MonitorID == monitorID {
+			run := r.runs[i]
+			return &run, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeCronMonitorRepository) UpdateRun(run *model.CronRun) error {
+	r.runs[run.ID-1] = *run
+	return nil
+}
+
+// newTestCronMonitor returns a service with an hourly monitor next expected at 13:00
+func newTestCronMonitor(t *testing.T) (*CronMonitorService, *fakeCronMonitorRepository, *fakeAlertRepository) {
+	cronRepo := &fakeCronMonitorRepository{monitors: map[int]model.CronMonitor{}}
+	alertRepo := &fakeAlertRepository{}
+	service := NewCronMonitorService(cronRepo, NewAlertService(alertRepo, nil, nil, &fakeNotifier{}))
+	monitor, err := service.CreateMonitor(7, model.CreateCronMonitorRequest{ProjectID: "shop", Name: "backup", Schedule: "0 * * * *"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitor.NextExpectedAt = time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC)
+	cronRepo.monitors[monitor.ID] = *monitor
+	return service, cronRepo, alertRepo
+}
+
+func cronAlertState(alertRepo *fakeAlertRepository, rule string) string {
+	state := ""
+	for _, a := range alertRepo.alerts {
+		if a.Rule == rule {
+			state = a.State
+		}
+	}
+	return state
+}
+
+func TestCronMonitorPingOnSchedule(t *testing.T) {
+	service, cronRepo, _ := newTestCronMonitor(t)
+	token := cronRepo.monitors[1].Token
+	slot := time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC)
+
+	// a job starting just before its slot counts for it
+	started, err := service.Ping(token, CronPingStart, slot.Add(-30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished, err := service.Ping(token, CronPingSuccess, slot.Add(90*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if finished.ID != started.ID || finished.Status != model.CronRunSuccess || finished.Late || *finished.DurationMs != 120000 {
+		t.Errorf("run %+v, want the started run finished on time after 2 minutes", finished)
+	}
+	if finished.ScheduledAt == nil || !finished.ScheduledAt.Equal(slot) {
+		t.Errorf("run scheduled at %v, want %s", finished.ScheduledAt, slot)
+	}
+	if next := cronRepo.monitors[1].NextExpectedAt; !next.Equal(slot.Add(time.Hour)) {
+		t.Errorf("next run expected at %s, want 14:00", next)
+	}
+
+	// a run well before the next slot is an extra run and does not use up the slot
+	extra, err := service.Ping(token, CronPingSuccess, slot.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extra.ScheduledAt != nil || !cronRepo.monitors[1].NextExpectedAt.Equal(slot.Add(time.Hour)) {
+		t.Errorf("extra run %+v moved the schedule to %s", extra, cronRepo.monitors[1].NextExpectedAt)
+	}
+
+	if _, err := service.Ping("unknown", CronPingSuccess, slot); err == nil {
+		t.Error("ping with an unknown token accepted")
+	}
+	if _, err := service.Ping(token, "done", slot); err == nil {
+		t.Error("unknown ping kind accepted")
+	}
+}
+
+func TestCronMonitorMissedAndLateRuns(t *testing.T) {
+	service, cronRepo, alertRepo := newTestCronMonitor(t)
+	token := cronRepo.monitors[1].Token
+	slot := time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC)
+	missedRule := "cron_missed:1"
+
+	service.CheckMissed(slot.Add(5 * time.Minute))
+	if len(cronRepo.runs) != 0 {
+		t.Fatalf("run missed within its grace period: %+v", cronRepo.runs)
+	}
+	service.CheckMissed(slot.Add(6 * time.Minute))
+	service.CheckMissed(slot.Add(7 * time.Minute))
+	if len(cronRepo.runs) != 1 || cronRepo.runs[0].Status != model.CronRunMissed {
+		t.Fatalf("runs %+v, want one missed run", cronRepo.runs)
+	}
+	if state := cronAlertState(alertRepo, missedRule); state != model.AlertStateFiring {
+		t.Errorf("missed alert %q, want firing", state)
+	}
+
+	// a late ping still claims the missed slot
+	run, err := service.Ping(token, CronPingSuccess, slot.Add(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ID != 1 || run.Status != model.CronRunSuccess || !run.Late {
+		t.Errorf("late run %+v, want the missed run completed late", run)
+	}
+	if state := cronAlertState(alertRepo, missedRule); state != model.AlertStateResolved {
+		t.Errorf("missed alert %q after the late run, want resolved", state)
+	}
+
+	// after an outage only the latest overdue slot is recorded
+	service.CheckMissed(slot.Add(4*time.Hour + 30*time.Minute))
+	if len(cronRepo.runs) != 2 || !cronRepo.runs[1].ScheduledAt.Equal(slot.Add(4*time.Hour)) {
+		t.Errorf("runs %+v, want one missed run for 17:00", cronRepo.runs[1:])
+	}
+}
+
+func TestCronMonitorFailedRuns(t *testing.T) {
+	service, cronRepo, alertRepo := newTestCronMonitor(t)
+	token := cronRepo.monitors[1].Token
+	slot := time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC)
+	failedRule := "cron_failed:1"
+
+	if _, err := service.Ping(token, CronPingFail, slot); err != nil {
+		t.Fatal(err)
+	}
+	if state := cronAlertState(alertRepo, failedRule); state != model.AlertStateFiring {
+		t.Errorf("failed alert %q, want firing", state)
+	}
+	if status := cronRepo.monitors[1].LastStatus; status != model.CronRunFailed {
+		t.Errorf("last status %q, want fail", status)
+	}
+	if _, err := service.Ping(token, CronPingSuccess, slot.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if state := cronAlertState(alertRepo, failedRule); state != model.AlertStateResolved {
+		t.Errorf("failed alert %q after a successful run, want resolved", state)
+	}
+}
+
+func TestCronMonitorCreateValidation(t *testing.T) {
+	service, _, _ := newTestCronMonitor(t)
+	tests := []struct {
+		scenario string
+		req      model.CreateCronMonitorRequest
+	}{
+		{"invalid schedule", model.CreateCronMonitorRequest{ProjectID: "shop", Name: "backup", Schedule: "every hour"}},
+		{"schedule that never fires", model.CreateCronMonitorRequest{ProjectID: "shop", Name: "backup", Schedule: "0 0 30 2 *"}},
+		{"negative grace", model.CreateCronMonitorRequest{ProjectID: "shop", Name: "backup", Schedule: "@daily", GraceSeconds: -1}},
+		{"grace over a day", model.CreateCronMonitorRequest{ProjectID: "shop", Name: "backup", Schedule: "@daily", GraceSeconds: maxCronGraceSeconds + 1}},
+		{"no name", model.CreateCronMonitorRequest{ProjectID: "shop", Schedule: "@daily"}},
+	}
+	for _, tt := range tests {
+		if _, err := service.CreateMonitor(7, tt.req); err == nil {
+			t.Errorf("%s: monitor created", tt.scenario)
+		}
+	}
+	monitor, err := service.CreateMonitor(7, model.CreateCronMonitorRequest{ProjectID: "shop", Name: "backup", Schedule: "@daily"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if monitor.GraceSeconds != defaultCronGraceSeconds || len(monitor.Token) != 32 || !monitor.Enabled {
+		t.Errorf("monitor %+v, want the default grace, a 24 byte token and enabled", monitor)
+	}
+}
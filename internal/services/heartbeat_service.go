@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+const (
+	minHeartbeatInterval = 60
+	// heartbeatRetention is how long a connection that went quiet is still listed.
+	heartbeatRetention = 7 * 24 * time.Hour
+	deadmanRule        = "deadman"
+)
+
+// HeartbeatService tracks when each project and middleware connection last sent a
+// metric and runs the per project dead-man's switch.
+//
+// Touch is called on the ingestion path for every metric, so it only updates an
+// in-memory map; Start flushes the map to the database before each check.
+type HeartbeatService struct {
+	heartbeatRepo repository.HeartbeatRepository
+	alertService  *AlertService
+
+	mu     sync.Mutex
+	latest map[[2]string]time.Time
+}
+
+func NewHeartbeatService(heartbeatRepo repository.HeartbeatRepository, alertService *AlertService) *HeartbeatService {
+	return &HeartbeatService{
+		heartbeatRepo: heartbeatRepo,
+		alertService:  alertService,
+		latest:        map[[2]string]time.Time{},
+	}
+}
+
+// Touch records that a metric for projectID arrived on connectionID at at.
+func (s *HeartbeatService) Touch(projectID string, connectionID string, at time.Time) {
+	if projectID == "" {
+		return
+	}
+	key := [2]string{projectID, connectionID}
+	s.mu.Lock()
+	if at.After(s.latest[key]) {
+		s.latest[key] = at.UTC()
+	}
+	s.mu.Unlock()
+}
+
+// Flush writes the heartbeats collected since the last flush to the database.
+func (s *HeartbeatService) Flush() {
+	s.mu.Lock()
+	latest := s.latest
+	s.latest = map[[2]string]time.Time{}
+	s.mu.Unlock()
+
+	for key, at := range latest {
+		heartbeat := model.IngestionHeartbeat{ProjectID: key[0], ConnectionID: key[1], LastSeenAt: at}
+		if err := s.heartbeatRepo.Touch(heartbeat); err != nil {
+			// keep it for the next flush unless a newer one arrived meanwhile
+			s.Touch(key[0], key[1], at)
+		}
+	}
+}
+
+// Start flushes heartbeats and checks every enabled dead-man's switch each interval until ctx is cancelled.
+func (s *HeartbeatService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastPrune := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			s.Flush()
+			return
+		case <-ticker.C:
+		}
+		s.Flush()
+		now := time.Now().UTC()
+		s.Check(now)
+		if now.Sub(lastPrune) > time.Hour {
+			s.heartbeatRepo.PruneHeartbeats(now.Add(-heartbeatRetention))
+			lastPrune = now
+		}
+	}
+}
+
+// Check fires the "deadman" alert of every project that has been silent for longer
+// than its interval, and resolves it for projects that are sending again.
+func (s *HeartbeatService) Check(now time.Time) {
+	configs, err := s.heartbeatRepo.ListEnabledConfigs()
+	if err != nil {
+		log.Println("error listing heartbeat configs:", err)
+		return
+	}
+	for i := range configs {
+		config := &configs[i]
+		status, err := s.status(config, now)
+		if err != nil {
+			log.Printf("error checking heartbeat of project %s: %v", config.ProjectID, err)
+			continue
+		}
+		labels := model.AlertLabels{ProjectID: config.ProjectID, Rule: deadmanRule}
+		if status.Silent {
+			summary := fmt.Sprintf("no metrics received for more than %s", time.Duration(config.IntervalSeconds)*time.Second)
+			if status.LastSeenAt != nil {
+				summary += fmt.Sprintf(" (last metric at %s)", status.LastSeenAt.Format(time.RFC3339))
+			}
+			if _, err := s.alertService.Fire(labels, model.AlertSeverityCritical, summary); err != nil {
+				log.Printf("error firing deadman alert for project %s: %v", config.ProjectID, err)
+			}
+		} else if _, err := s.alertService.Resolve(labels); err != nil {
+			log.Printf("error resolving deadman alert for project %s: %v", config.ProjectID, err)
+		}
+	}
+}
+
+// status works out whether a project is silent. A project that has never sent
+// anything is measured from the time its switch was configured.
+func (s *HeartbeatService) status(config *model.HeartbeatConfig, now time.Time) (*model.HeartbeatStatus, error) {
+	connections, err := s.heartbeatRepo.ListHeartbeats(config.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	status := &model.HeartbeatStatus{Config: config, Connections: connections}
+	reference := config.UpdatedAt
+	if len(connections) > 0 {
+		last := connections[0].LastSeenAt
+		status.LastSeenAt = &last
+		if last.After(reference) {
+			reference = last
+		}
+	}
+	status.Silent = config.Enabled && now.Sub(reference) > time.Duration(config.IntervalSeconds)*time.Second
+	return status, nil
+}
+
+// GetStatus returns the configuration and the known connections of a project.
+func (s *HeartbeatService) GetStatus(projectID string) (*model.HeartbeatStatus, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	config, err := s.heartbeatRepo.GetConfig(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		connections, err := s.heartbeatRepo.ListHeartbeats(projectID)
+		if err != nil {
+			return nil, err
+		}
+		status := &model.HeartbeatStatus{Connections: connections}
+		if len(connections) > 0 {
+			status.LastSeenAt = &connections[0].LastSeenAt
+		}
+		return status, nil
+	}
+	return s.status(config, time.Now().UTC())
+}
+
+func (s *HeartbeatService) UpdateConfig(projectID string, userID int, req model.UpdateHeartbeatConfigRequest) (*model.HeartbeatConfig, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if req.IntervalSeconds < minHeartbeatInterval {
+		return nil, fmt.Errorf("intervalSeconds must be at least %d", minHeartbeatInterval)
+	}
+	config := &model.HeartbeatConfig{
+		ProjectID:       projectID,
+		IntervalSeconds: req.IntervalSeconds,
+		Enabled:         req.Enabled == nil || *req.Enabled,
+		UpdatedBy:       userID,
+		UpdatedAt:       time.Now().UTC(),
+	}
+	if err := s.heartbeatRepo.UpsertConfig(config); err != nil {
+		return nil, err
+	}
+	if !config.Enabled {
+		s.resolve(projectID)
+	}
+	return config, nil
+}
+
+func (s *HeartbeatService) DeleteConfig(projectID string) error {
+	if err := s.heartbeatRepo.DeleteConfig(projectID); err != nil {
+		return err
+	}
+	s.resolve(projectID)
+	return nil
+}
+
+func (s *HeartbeatService) resolve(projectID string) {
+	if _, err := s.alertService.Resolve(model.AlertLabels{ProjectID: projectID, Rule: deadmanRule}); err != nil {
+		log.Printf("error resolving deadman alert for project %s: %v", projectID, err)
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+// fakeHeartbeatRepository keeps heartbeats and switches in memory
+type fakeHeartbeatRepository struct {
+	repository.HeartbeatRepository
+	heartbeats map[[2]string]time.Time
+	configs    map[string]model.HeartbeatConfig
+	touchErr   error
+}
+
+func newFakeHeartbeatRepository() *fakeHeartbeatRepository {
+	return &fakeHeartbeatRepository{heartbeats: map[[2]string]time.Time{}, configs: map[string]model.HeartbeatConfig{}}
+}
+
+func (r *fakeHeartbeatRepository) Touch(heartbeat model.IngestionHeartbeat) error {
+	if r.touchErr != nil {
+		return r.touchErr
+	}
+	r.heartbeats[[2]string{heartbeat.ProjectID, heartbeat.ConnectionID}] = heartbeat.LastSeenAt
+	return nil
+}
+
+func (r *fakeHeartbeatRepository) ListHeartbeats(projectID string) ([]model.IngestionHeartbeat, error) {
+	var heartbeats []model.IngestionHeartbeat
+	for key, at := range r.heartbeats {
+		if key[0] == projectID {
+			heartbeats = append(heartbeats, model.IngestionHeartbeat{ProjectID: key[0], ConnectionID: key[1], LastSeenAt: at})
+		}
+	}
+	sort.Slice(heartbeats, func(i, j int) bool { return heartbeats[i].LastSeenAt.After(heartbeats[j].LastSeenAt) })
+	return heartbeats, nil
+}
+
+func (r *fakeHeartbeatRepository) UpsertConfig(config *model.HeartbeatConfig) error {
+	r.configs[config.ProjectID] = *config
+	return nil
+}
+
+func (r *fakeHeartbeatRepository) ListEnabledConfigs() ([]model.HeartbeatConfig, error) {
+	var configs []model.HeartbeatConfig
+	for _, config := range r.configs {
+		if config.Enabled {
+			configs = append(configs, config)
+		}
+	}
+	return configs, nil
+}
+
+func (r *fakeHeartbeatRepository) DeleteConfig(projectID string) error {
+	delete(r.configs, projectID)
+	return nil
+}
+
+func deadmanState(repo *fakeAlertRepository, projectID string) string {
+	state := ""
+	for _, a := range repo.alerts {
+		if a.ProjectID == projectID && a.Rule == deadmanRule {
+			state = a.State
+		}
+	}
+	return state
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	heartbeatRepo := newFakeHeartbeatRepository()
+	alertRepo := &fakeAlertRepository{}
+	service := NewHeartbeatService(heartbeatRepo, NewAlertService(alertRepo, nil, nil, &fakeNotifier{}))
+	configured := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	heartbeatRepo.configs["shop"] = model.HeartbeatConfig{ProjectID: "shop", IntervalSeconds: 300, Enabled: true, UpdatedAt: configured}
+
+	tests := []struct {
+		scenario string
+		metricAt time.Time
+		now      time.Time
+		want     string
+	}{
+		// a project that never sent anything is measured from its configuration
+		{"never sent, within the interval", time.Time{}, configured.Add(5 * time.Minute), ""},
+		{"never sent, past the interval", time.Time{}, configured.Add(5*time.Minute + time.Second), model.AlertStateFiring},
+		{"sending again", configured.Add(6 * time.Minute), configured.Add(7 * time.Minute), model.AlertStateResolved},
+		{"silent again", time.Time{}, configured.Add(11*time.Minute + time.Second), model.AlertStateFiring},
+	}
+	for _, tt := range tests {
+		if !tt.metricAt.IsZero() {
+			service.Touch("shop", "conn-1", tt.metricAt)
+			service.Flush()
+		}
+		service.Check(tt.now)
+		if got := deadmanState(alertRepo, "shop"); got != tt.want {
+			t.Errorf("%s: deadman alert %q, want %q", tt.scenario, got, tt.want)
+		}
+	}
+	if len(alertRepo.alerts) != 2 {
+		t.Errorf("%d deadman alerts opened, want 2", len(alertRepo.alerts))
+	}
+
+	// switching the check off resolves a firing alert
+	enabled := false
+	if _, err := service.UpdateConfig("shop", 7, model.UpdateHeartbeatConfigRequest{IntervalSeconds: 300, Enabled: &enabled}); err != nil {
+		t.Fatal(err)
+	}
+	if got := deadmanState(alertRepo, "shop"); got != model.AlertStateResolved {
+		t.Errorf("disabled switch left the alert %q", got)
+	}
+}
+
+func TestHeartbeatTouchKeepsTheLatest(t *testing.T) {
+	heartbeatRepo := newFakeHeartbeatRepository()
+	service := NewHeartbeatService(heartbeatRepo, NewAlertService(&fakeAlertRepository{}, nil, nil, &fakeNotifier{}))
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	service.Touch("shop", "conn-1", at)
+	service.Touch("shop", "conn-1", at.Add(-time.Minute)) // out of order
+	service.Touch("", "conn-1", at)
+	heartbeatRepo.touchErr = errors.New("database down")
+	service.Flush()
+	if len(heartbeatRepo.heartbeats) != 0 {
+		t.Fatalf("stored %v while the database is down", heartbeatRepo.heartbeats)
+	}
+
+	// a failed flush is retried with the next one
+	heartbeatRepo.touchErr = nil
+	service.Flush()
+	if got := heartbeatRepo.heartbeats[[2]string{"shop", "conn-1"}]; !got.Equal(at) || len(heartbeatRepo.heartbeats) != 1 {
+		t.Errorf("heartbeats %v, want only shop/conn-1 at %s", heartbeatRepo.heartbeats, at)
+	}
+}
+
+func TestHeartbeatUpdateConfigRejectsShortIntervals(t *testing.T) {
+	service := NewHeartbeatService(newFakeHeartbeatRepository(), NewAlertService(&fakeAlertRepository{}, nil, nil, &fakeNotifier{}))
+	if _, err := service.UpdateConfig("shop", 7, model.UpdateHeartbeatConfigRequest{IntervalSeconds: 59}); err == nil {
+		t.Error("an interval below a minute is accepted")
+	}
+	config, err := service.UpdateConfig("shop", 7, model.UpdateHeartbeatConfigRequest{IntervalSeconds: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Enabled || config.UpdatedBy != 7 {
+		t.Errorf("config %+v, want enabled by default and updated by user 7", config)
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidInvitation = errors.New("invitation is invalid or has expired")
+	ErrInvitationEmail   = errors.New("invitation was sent to a different email address")
+)
+
+// InvitationService invites people to organizations by email. An invitation is
+// accepted with its token by the user owning the invited address, either signed
+// in or while registering.
+type InvitationService struct {
+	inviteRepo   repository.InvitationRepository
+	orgRepo      repository.OrganizationRepository
+	userRepo     repository.UserRepository
+	mailService  *MailService
+	auditService *AuditService
+	appURL       string
+}
+
+func NewInvitationService(inviteRepo repository.InvitationRepository, orgRepo repository.OrganizationRepository, userRepo repository.UserRepository, mailService *MailService, auditService *AuditService, appURL string) *InvitationService {
+	return &InvitationService{
+		inviteRepo:   inviteRepo,
+		orgRepo:      orgRepo,
+		userRepo:     userRepo,
+		mailService:  mailService,
+		auditService: auditService,
+		appURL:       strings.TrimRight(appURL, "/"),
+	}
+}
+
+// Invite creates an invitation and mails it. Only owners can invite owners.
+func (s *InvitationService) Invite(orgID int, actor model.Actor, actorRole string, req model.CreateInvitationRequest) (*model.Invitation, error) {
+	req.Email = strings.TrimSpace(req.Email)
+	if err := validateEmail(req.Email); err != nil {
+		return nil, err
+	}
+	if req.Role == "" {
+		req.Role = model.RoleMember
+	}
+	if !model.ValidRole(req.Role) {
+		return nil, errors.New("role must be owner, admin, member or viewer")
+	}
+	if req.Role == model.RoleOwner && actorRole != model.RoleOwner {
+		return nil, ErrForbidden
+	}
+	if user, err := s.userRepo.GetUserByEmail(req.Email); err == nil {
+		role, err := s.orgRepo.GetMemberRole(orgID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if role != "" {
+			return nil, errors.New("user is already a member")
+		}
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	invite := &model.Invitation{
+		OrganizationID: orgID,
+		Email:          req.Email,
+		Role:           req.Role,
+		TokenHash:      utils.HashToken(token),
+		InvitedBy:      actor.UserID,
+		ExpiresAt:      now.Add(invitationTTL),
+		SentAt:         now,
+	}
+	if err := s.inviteRepo.CreateInvitation(invite); err != nil {
+		return nil, err
+	}
+	event := orgAuditEvent(orgID, actor, model.AuditInvitationCreated, "invitation", strconv.Itoa(invite.ID))
+	event.After = auditJSON(map[string]string{"email": invite.Email, "role": invite.Role})
+	s.auditService.Record(event)
+	if err := s.send(invite, token); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+func (s *InvitationService) ListPending(orgID int) ([]model.Invitation, error) {
+	return s.inviteRepo.ListPending(orgID, time.Now().UTC())
+}
+
+// Resend mails a pending invitation again with a new token and a new expiry;
+// the previous link stops working
+func (s *InvitationService) Resend(orgID int, actor model.Actor, actorRole string, id int) (*model.Invitation, error) {
+	invite, err := s.pendingInvitation(orgID, actorRole, id)
+	if err != nil {
+		return nil, err
+	}
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	invite.TokenHash = utils.HashToken(token)
+	invite.ExpiresAt = now.Add(invitationTTL)
+	invite.SentAt = now
+	if err := s.inviteRepo.UpdateToken(invite); err != nil {
+		return nil, err
+	}
+	s.auditService.Record(orgAuditEvent(orgID, actor, model.AuditInvitationResent, "invitation", strconv.Itoa(id)))
+	if err := s.send(invite, token); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+func (s *InvitationService) Revoke(orgID int, actor model.Actor, actorRole string, id int) error {
+	invite, err := s.pendingInvitation(orgID, actorRole, id)
+	if err != nil {
+		return err
+	}
+	if err := s.inviteRepo.RevokeInvitation(id, time.Now().UTC()); err != nil {
+		return err
+	}
+	event := orgAuditEvent(orgID, actor, model.AuditInvitationRevoked, "invitation", strconv.Itoa(id))
+	event.Before = auditJSON(map[string]string{"email": invite.Email, "role": invite.Role})
+	s.auditService.Record(event)
+	return nil
+}
+
+// pendingInvitation loads an invitation of the organization that has not been
+// accepted or revoked. Invitations for owners are managed only by owners.
+func (s *InvitationService) pendingInvitation(orgID int, actorRole string, id int) (*model.Invitation, error) {
+	invite, err := s.inviteRepo.GetInvitation(id)
+	if err != nil || invite.OrganizationID != orgID {
+		return nil, errors.New("invitation not found")
+	}
+	if invite.AcceptedAt != nil || invite.RevokedAt != nil {
+		return nil, errors.New("invitation is no longer pending")
+	}
+	if invite.Role == model.RoleOwner && actorRole != model.RoleOwner {
+		return nil, ErrForbidden
+	}
+	return invite, nil
+}
+
+// Check returns the pending invitation of a token if it was sent to email
+func (s *InvitationService) Check(token string, email string) (*model.Invitation, error) {
+	if token == "" {
+		return nil, errors.New("token is required")
+	}
+	invite, err := s.inviteRepo.GetInvitationByHash(utils.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || invite.AcceptedAt != nil || invite.RevokedAt != nil || !time.Now().UTC().Before(invite.ExpiresAt) {
+		return nil, ErrInvalidInvitation
+	}
+	if !strings.EqualFold(invite.Email, strings.TrimSpace(email)) {
+		return nil, ErrInvitationEmail
+	}
+	return invite, nil
+}
+
+// AcceptForUser accepts an invitation for a signed-in user
+func (s *InvitationService) AcceptForUser(actor model.Actor, token string) (*model.Invitation, error) {
+	user, err := s.userRepo.GetUserByID(actor.UserID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	return s.Accept(user, token, actor.ClientInfo)
+}
+
+// Accept uses an invitation for user and makes them a member. A user who is
+// already a member keeps their current role.
+func (s *InvitationService) Accept(user *model.User, token string, client model.ClientInfo) (*model.Invitation, error) {
+	invite, err := s.Check(token, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	accepted, err := s.inviteRepo.AcceptInvitation(invite.ID, user.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrInvalidInvitation
+	}
+	role, err := s.orgRepo.GetMemberRole(invite.OrganizationID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		if err := s.orgRepo.AddMember(invite.OrganizationID, user.ID, invite.Role); err != nil {
+			return nil, err
+		}
+	}
+	actor := model.Actor{UserID: user.ID, ClientInfo: client}
+	event := orgAuditEvent(invite.OrganizationID, actor, model.AuditInvitationAccepted, "invitation", strconv.Itoa(invite.ID))
+	event.After = auditJSON(map[string]string{"email": invite.Email, "role": invite.Role})
+	s.auditService.Record(event)
+	return invite, nil
+}
+
+func (s *InvitationService) send(invite *model.Invitation, token string) error {
+	org, err := s.orgRepo.GetOrganization(invite.OrganizationID)
+	if err != nil {
+		return err
+	}
+	inviter := "A teammate"
+	if user, err := s.userRepo.GetUserByID(invite.InvitedBy); err == nil {
+		inviter = user.Username
+	}
+	link := s.appURL + "/accept-invitation?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi,\n\n%s invited you to join %s on Prothomuse as %s.\n\nAccept the invitation by opening this link:\n\n%s\n\nIf you do not have an account yet, sign up with this email address from the same link. The invitation expires in 7 days.\n", inviter, org.Name, invite.Role, link)
+	return s.mailService.Enqueue(model.EmailKindInvitation, invite.Email, "You are invited to join "+org.Name, body)
+}
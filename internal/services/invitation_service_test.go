@@ -0,0 +1,222 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+// fakeOrganizationRepository keeps organizations and their members in memory
+type fakeOrganizationRepository struct {
+	repository.OrganizationRepository
+	names    map[int]string
+	members  map[int]map[int]string
+	projects map[string]int
+}
+
+func newFakeOrganizationRepository() *fakeOrganizationRepository {
+	return &fakeOrganizationRepository{
+		names:    map[int]string{1: "Acme"},
+		members:  map[int]map[int]string{1: {1: model.RoleOwner}},
+		projects: map[string]int{"shop": 1},
+	}
+}
+
+func (r *fakeOrganizationRepository) GetProject(id string) (*model.Project, error) {
+	orgID, ok := r.projects[id]
+	if !ok {
+		return nil, nil
+	}
+	return &model.Project{ID: id, OrganizationID: orgID, Name: id}, nil
+}
+
+func (r *fakeOrganizationRepository) GetOrganization(id int) (*model.Organization, error) {
+	name, ok := r.names[id]
+	if !ok {
+		return nil, errors.New("organization not found")
+	}
+	return &model.Organization{ID: id, Name: name}, nil
+}
+
+func (r *fakeOrganizationRepository) GetMemberRole(orgID int, userID int) (string, error) {
+	return r.members[orgID][userID], nil
+}
+
+func (r *fakeOrganizationRepository) AddMember(orgID int, userID int, role string) error {
+	if r.members[orgID] == nil {
+		r.members[orgID] = map[int]string{}
+	}
+	r.members[orgID][userID] = role
+	return nil
+}
+
+// fakeInvitationRepository keeps invitations in memory
+type fakeInvitationRepository struct {
+	repository.InvitationRepository
+	invitations []model.Invitation
+}
+
+func (r *fakeInvitationRepository) CreateInvitation(invite *model.Invitation) error {
+	now := time.Now().UTC()
+	for i := range r.invitations {
+		earlier := &r.invitations[i]
+		if earlier.OrganizationID == invite.OrganizationID && earlier.Email == invite.Email && earlier.AcceptedAt == nil && earlier.RevokedAt == nil {
+			earlier.RevokedAt = &now
+		}
+	}
+	invite.ID = len(r.invitations) + 1
+	invite.CreatedAt = now
+	r.invitations = append(r.invitations, *invite)
+	return nil
+}
+
+func (r *fakeInvitationRepository) GetInvitation(id int) (*model.Invitation, error) {
+	if id < 1 || id > len(r.invitations) {
+		return nil, errors.New("invitation not found")
+	}
+	invite := r.invitations[id-1]
+	return &invite, nil
+}
+
+func (r *fakeInvitationRepository) GetInvitationByHash(tokenHash string) (*model.Invitation, error) {
+	for _, invite := range r.invitations {
+		if invite.TokenHash == tokenHash {
+			return &invite, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeInvitationRepository) UpdateToken(invite *model.Invitation) error {
+	stored := &r.invitations[invite.ID-1]
+	stored.TokenHash, stored.ExpiresAt, stored.SentAt = invite.TokenHash, invite.ExpiresAt, invite.SentAt
+	return nil
+}
+
+func (r *fakeInvitationRepository) RevokeInvitation(id int, at time.Time) error {
+	r.invitations[id-1].RevokedAt = &at
+	return nil
+}
+
+func (r *fakeInvitationRepository) AcceptInvitation(id int, userID int, now time.Time) (bool, error) {
+	invite := &r.invitations[id-1]
+	if invite.AcceptedAt != nil || invite.RevokedAt != nil || !now.Before(invite.ExpiresAt) {
+		return false, nil
+	}
+	invite.AcceptedBy, invite.AcceptedAt = &userID, &now
+	return true, nil
+}
+
+func newTestInvitationService(users ...model.User) (*InvitationService, *fakeInvitationRepository, *fakeOrganizationRepository) {
+	inviteRepo := &fakeInvitationRepository{}
+	auditService, _ := newTestAuditService()
+	orgRepo := auditService.orgRepo.(*fakeOrganizationRepository)
+	service := NewInvitationService(inviteRepo, orgRepo, &fakeUserRepository{users: users}, NewMailService(&fakeEmailRepository{}, nil), auditService, "https://app.example.com/")
+	return service, inviteRepo, orgRepo
+}
+
+func TestInvitationAccept(t *testing.T) {
+	service, _, orgRepo := newTestInvitationService(
+		model.User{ID: 1, Username: "owner", Email: "owner@example.com"},
+		model.User{ID: 7, Username: "jane", Email: "jane@example.com"},
+		model.User{ID: 8, Username: "john", Email: "john@example.com"},
+	)
+	if _, err := service.Invite(1, model.Actor{UserID: 1}, model.RoleOwner, model.CreateInvitationRequest{Email: " Jane@example.com ", Role: model.RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	token := mailedToken(t, service.mailService)
+
+	if _, err := service.AcceptForUser(model.Actor{UserID: 8}, token); !errors.Is(err, ErrInvitationEmail) {
+		t.Errorf("accepted by another user: %v, want %v", err, ErrInvitationEmail)
+	}
+	invite, err := service.AcceptForUser(model.Actor{UserID: 7}, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role := orgRepo.members[1][7]; invite.OrganizationID != 1 || role != model.RoleViewer {
+		t.Errorf("after accepting jane has role %q in organization %d, want viewer in 1", role, invite.OrganizationID)
+	}
+	if _, err := service.AcceptForUser(model.Actor{UserID: 7}, token); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("invitation accepted twice: %v", err)
+	}
+	if _, err := service.Invite(1, model.Actor{UserID: 1}, model.RoleOwner, model.CreateInvitationRequest{Email: "jane@example.com"}); err == nil {
+		t.Error("a member was invited again")
+	}
+}
+
+func TestInvitationExpiresAndIsReplaced(t *testing.T) {
+	service, inviteRepo, orgRepo := newTestInvitationService(model.User{ID: 7, Username: "jane", Email: "jane@example.com"})
+	invite, _ := service.Invite(1, model.Actor{UserID: 1}, model.RoleOwner, model.CreateInvitationRequest{Email: "jane@example.com"})
+	first := mailedToken(t, service.mailService)
+	if invite.Role != model.RoleMember || invite.ExpiresAt.Sub(invite.SentAt) != invitationTTL {
+		t.Errorf("invitation %+v, want the member role and a 7 day expiry", invite)
+	}
+
+	inviteRepo.invitations[0].ExpiresAt = time.Now().UTC().Add(-time.Minute)
+	if _, err := service.AcceptForUser(model.Actor{UserID: 7}, first); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("expired invitation: %v, want %v", err, ErrInvalidInvitation)
+	}
+
+	// sending it again makes a new link and retires the old one
+	if _, err := service.Resend(1, model.Actor{UserID: 1}, model.RoleAdmin, invite.ID); err != nil {
+		t.Fatal(err)
+	}
+	resent := mailedToken(t, service.mailService)
+	if _, err := service.AcceptForUser(model.Actor{UserID: 7}, first); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("link replaced by a resend: %v, want %v", err, ErrInvalidInvitation)
+	}
+	if _, err := service.AcceptForUser(model.Actor{UserID: 7}, resent); err != nil || orgRepo.members[1][7] != model.RoleMember {
+		t.Errorf("resent invitation: %v, role %q", err, orgRepo.members[1][7])
+	}
+}
+
+func TestInvitationRevokeAndOwnerInvitations(t *testing.T) {
+	service, _, orgRepo := newTestInvitationService(model.User{ID: 7, Username: "jane", Email: "jane@example.com"})
+	if _, err := service.Invite(1, model.Actor{UserID: 2}, model.RoleAdmin, model.CreateInvitationRequest{Email: "jane@example.com", Role: model.RoleOwner}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("admin invited an owner: %v", err)
+	}
+	owner, _ := service.Invite(1, model.Actor{UserID: 1}, model.RoleOwner, model.CreateInvitationRequest{Email: "jane@example.com", Role: model.RoleOwner})
+	token := mailedToken(t, service.mailService)
+	if err := service.Revoke(1, model.Actor{UserID: 1}, model.RoleAdmin, owner.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("admin revoked an owner invitation: %v", err)
+	}
+	if err := service.Revoke(2, model.Actor{UserID: 1}, model.RoleOwner, owner.ID); err == nil {
+		t.Error("an invitation was revoked through another organization")
+	}
+	if err := service.Revoke(1, model.Actor{UserID: 1}, model.RoleOwner, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.AcceptForUser(model.Actor{UserID: 7}, token); !errors.Is(err, ErrInvalidInvitation) || orgRepo.members[1][7] != "" {
+		t.Errorf("revoked invitation: %v, role %q", err, orgRepo.members[1][7])
+	}
+}
+
+func TestRegisterWithInvitation(t *testing.T) {
+	service, userRepo, _ := newTestAuthService(model.User{ID: 1, Username: "owner", Email: "owner@example.com"})
+	if _, err := service.inviteService.Invite(1, model.Actor{UserID: 1}, model.RoleOwner, model.CreateInvitationRequest{Email: "jane@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	token := mailedToken(t, service.mailService)
+	outbox := service.mailService.emailRepo.(*fakeEmailRepository)
+	sent := len(outbox.emails)
+
+	register := model.RegisterRequest{Username: "john", Email: "john@example.com", Password: "secret password", InviteToken: token}
+	if _, err := service.RegisterUser(register, model.ClientInfo{}); !errors.Is(err, ErrInvitationEmail) || len(userRepo.users) != 1 {
+		t.Errorf("registering another address with the invitation: %v, %d users", err, len(userRepo.users))
+	}
+	register.Username, register.Email = "jane", "jane@example.com"
+	user, err := service.RegisterUser(register, model.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgRepo := service.inviteService.orgRepo.(*fakeOrganizationRepository)
+	if !userRepo.users[1].EmailVerified || orgRepo.members[1][user.ID] != model.RoleMember {
+		t.Errorf("registered %+v with role %q, want a verified member", userRepo.users[1], orgRepo.members[1][user.ID])
+	}
+	if len(outbox.emails) != sent {
+		t.Error("a verification email was sent for an address the invitation proved")
+	}
+}
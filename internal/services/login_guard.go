@@ -0,0 +1,138 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+const (
+	// freeLoginFailures is how many failures in a row are allowed without a delay
+	freeLoginFailures = 3
+	loginBaseDelay    = time.Second
+	loginMaxDelay     = 30 * time.Second
+)
+
+// LoginGuardConfig sets when failed logins lock an account or an IP address
+type LoginGuardConfig struct {
+	MaxAccountFailures int
+	MaxIPFailures      int
+	LockoutDuration    time.Duration
+	// FailureWindow is how long a failure counts; a quiet period this long resets the count
+	FailureWindow time.Duration
+}
+
+// LockedOutError refuses a login attempt made too soon after failures
+type LockedOutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %d seconds", int(e.RetryAfter.Seconds()+0.999))
+}
+
+// LoginGuard slows down password guessing. Failures are counted per account and
+// per IP address: after freeLoginFailures each attempt must wait a delay that
+// doubles with every failure, and reaching the maximum locks for LockoutDuration.
+// Accounts are keyed by the email tried, whether or not it exists.
+type LoginGuard struct {
+	attemptRepo repository.LoginAttemptRepository
+	config      LoginGuardConfig
+}
+
+func NewLoginGuard(attemptRepo repository.LoginAttemptRepository, config LoginGuardConfig) *LoginGuard {
+	if config.MaxAccountFailures <= 0 {
+		config.MaxAccountFailures = 5
+	}
+	if config.MaxIPFailures <= 0 {
+		config.MaxIPFailures = 20
+	}
+	if config.LockoutDuration <= 0 {
+		config.LockoutDuration = 15 * time.Minute
+	}
+	if config.FailureWindow <= 0 {
+		config.FailureWindow = 15 * time.Minute
+	}
+	return &LoginGuard{attemptRepo: attemptRepo, config: config}
+}
+
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginDelay is the wait required after failures failed attempts in a row
+func loginDelay(failures int) time.Duration {
+	if failures < freeLoginFailures {
+		return 0
+	}
+	delay := loginBaseDelay << uint(failures-freeLoginFailures)
+	if delay > loginMaxDelay || delay <= 0 {
+		return loginMaxDelay
+	}
+	return delay
+}
+
+// Check returns a LockedOutError when the account or IP address may not try yet
+func (g *LoginGuard) Check(email string, ip string, now time.Time) error {
+	var wait time.Duration
+	for _, scope := range [][2]string{{model.LoginScopeAccount, accountKey(email)}, {model.LoginScopeIP, ip}} {
+		if scope[1] == "" {
+			continue
+		}
+		failure, err := g.attemptRepo.GetFailure(scope[0], scope[1])
+		if err != nil {
+			return err
+		}
+		if failure == nil || now.Sub(failure.LastFailureAt) >= g.config.FailureWindow && (failure.LockedUntil == nil || !failure.LockedUntil.After(now)) {
+			continue
+		}
+		until := failure.LastFailureAt.Add(loginDelay(failure.Failures))
+		if failure.LockedUntil != nil && failure.LockedUntil.After(until) {
+			until = *failure.LockedUntil
+		}
+		if until.Sub(now) > wait {
+			wait = until.Sub(now)
+		}
+	}
+	if wait > 0 {
+		return &LockedOutError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail records a failed attempt and reports which scopes it newly locked
+func (g *LoginGuard) Fail(email string, ip string, now time.Time) (accountLocked bool, ipLocked bool, err error) {
+	windowStart := now.Add(-g.config.FailureWindow)
+	accountLocked, err = g.fail(model.LoginScopeAccount, accountKey(email), g.config.MaxAccountFailures, now, windowStart)
+	if err != nil {
+		return false, false, err
+	}
+	if ip != "" {
+		ipLocked, err = g.fail(model.LoginScopeIP, ip, g.config.MaxIPFailures, now, windowStart)
+	}
+	return accountLocked, ipLocked, err
+}
+
+func (g *LoginGuard) fail(scope string, key string, max int, now time.Time, windowStart time.Time) (bool, error) {
+	failure, err := g.attemptRepo.RecordFailure(scope, key, now, windowStart)
+	if err != nil {
+		return false, err
+	}
+	if failure.Failures < max || (failure.LockedUntil != nil && failure.LockedUntil.After(now)) {
+		return false, nil
+	}
+	return true, g.attemptRepo.Lock(scope, key, now.Add(g.config.LockoutDuration))
+}
+
+// Unlock clears the failures of an account, after a successful login or an unlock
+func (g *LoginGuard) Unlock(email string) error {
+	return g.attemptRepo.Reset(model.LoginScopeAccount, accountKey(email))
+}
+
+// UnlockIP clears the failures of an IP address
+func (g *LoginGuard) UnlockIP(ip string) error {
+	return g.attemptRepo.Reset(model.LoginScopeIP, ip)
+}
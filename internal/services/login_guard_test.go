@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+)
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{7, 16 * time.Second},
+		{8, 30 * time.Second},
+		{100, 30 * time.Second}, // the shift overflows
+	}
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures); got != tt.want {
+			t.Errorf("loginDelay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// fakeLoginAttemptRepository keeps failure counts in memory
+type fakeLoginAttemptRepository struct {
+	failures map[[2]string]*model.LoginFailure
+}
+
+func (r *fakeLoginAttemptRepository) CreateTable() error { return nil }
+
+func (r *fakeLoginAttemptRepository) GetFailure(scope string, key string) (*model.LoginFailure, error) {
+	return r.failures[[2]string{scope, key}], nil
+}
+
+func (r *fakeLoginAttemptRepository) RecordFailure(scope string, key string, now time.Time, windowStart time.Time) (*model.LoginFailure, error) {
+	failure := r.failures[[2]string{scope, key}]
+	if failure == nil || failure.LastFailureAt.Before(windowStart) {
+		failure = &model.LoginFailure{Scope: scope, Key: key}
+		r.failures[[2]string{scope, key}] = failure
+	}
+	failure.Failures++
+	failure.LastFailureAt = now
+	return failure, nil
+}
+
+func (r *fakeLoginAttemptRepository) Lock(scope string, key string, until time.Time) error {
+	r.failures[[2]string{scope, key}].LockedUntil = &until
+	return nil
+}
+
+func (r *fakeLoginAttemptRepository) Reset(scope string, key string) error {
+	delete(r.failures, [2]string{scope, key})
+	return nil
+}
+
+func TestLoginGuard(t *testing.T) {
+	guard := NewLoginGuard(&fakeLoginAttemptRepository{failures: map[[2]string]*model.LoginFailure{}}, LoginGuardConfig{MaxAccountFailures: 5})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	retryAfter := func(email string, ip string, at time.Time) time.Duration {
+		err := guard.Check(email, ip, at)
+		var lockedOut *LockedOutError
+		if errors.As(err, &lockedOut) {
+			return lockedOut.RetryAfter
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return 0
+	}
+
+	for i := 0; i < 3; i++ {
+		if wait := retryAfter("Jane@example.com", "203.0.113.7", now); wait != 0 {
+			t.Fatalf("attempt %d refused for %s within the free failures", i+1, wait)
+		}
+		guard.Fail("jane@example.com", "203.0.113.7", now)
+	}
+	if wait := retryAfter(" JANE@example.com", "198.51.100.1", now); wait != time.Second {
+		t.Errorf("after 3 failures the account waits %s, want 1s from any IP", wait)
+	}
+	if wait := retryAfter("joe@example.com", "203.0.113.7", now); wait != time.Second {
+		t.Errorf("after 3 failures the IP waits %s, want 1s for any account", wait)
+	}
+	if wait := retryAfter("jane@example.com", "203.0.113.7", now.Add(time.Second)); wait != 0 {
+		t.Errorf("refused for %s once the delay passed", wait)
+	}
+
+	guard.Fail("jane@example.com", "203.0.113.7", now)
+	accountLocked, ipLocked, err := guard.Fail("jane@example.com", "203.0.113.7", now)
+	if err != nil || !accountLocked || ipLocked {
+		t.Fatalf("5th failure: account locked %v, IP locked %v, %v; want only the account locked", accountLocked, ipLocked, err)
+	}
+	if wait := retryAfter("jane@example.com", "198.51.100.1", now.Add(time.Minute)); wait != 14*time.Minute {
+		t.Errorf("locked account waits %s, want the rest of 15 minutes", wait)
+	}
+	if wait := retryAfter("jane@example.com", "198.51.100.1", now.Add(15*time.Minute)); wait != 0 {
+		t.Errorf("refused for %s after the lockout", wait)
+	}
+
+	guard.Unlock("JANE@example.com")
+	if failure, _ := guard.attemptRepo.GetFailure(model.LoginScopeAccount, "jane@example.com"); failure != nil {
+		t.Errorf("unlock kept %+v", failure)
+	}
+}
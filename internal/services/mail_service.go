@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+const (
+	maxEmailAttempts = 5
+	// emailRetention is how long emails stay in the outbox. Their links expire
+	// before that: invitations, the longest lived, last 7 days.
+	emailRetention = invitationTTL
+)
+
+// MailSender delivers one email
+type MailSender interface {
+	Send(to string, subject string, body string) error
+}
+
+// SMTPSender delivers plain text email through an SMTP server
+type SMTPSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPSender creates an SMTPSender. Authentication is skipped when username is empty.
+func NewSMTPSender(host string, port string, username string, password string, from string) *SMTPSender {
+	sender := &SMTPSender{addr: host + ":" + port, from: from}
+	if username != "" {
+		sender.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return sender
+}
+
+func (s *SMTPSender) Send(to string, subject string, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("invalid header value")
+	}
+	message := "From: " + s.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{to}, []byte(message))
+}
+
+// MailService writes every email to the outbox table and, when a sender is
+// configured, delivers pending emails in the background with retries. Without a
+// sender emails stay in the outbox, which is how they are read in local setups.
+// The body of a sent email is cleared and every email is deleted after
+// emailRetention, so that the tokens they carry do not outlive their use.
+type MailService struct {
+	emailRepo repository.EmailRepository
+	sender    MailSender
+}
+
+func NewMailService(emailRepo repository.EmailRepository, sender MailSender) *MailService {
+	return &MailService{emailRepo: emailRepo, sender: sender}
+}
+
+func (s *MailService) Enqueue(kind string, to string, subject string, body string) error {
+	email := &model.OutboxEmail{Kind: kind, To: to, Subject: subject, Body: body}
+	if err := s.emailRepo.Enqueue(email); err != nil {
+		return err
+	}
+	if s.sender == nil {
+		log.Printf("email %d (%s) to %s kept in outbox, no SMTP server configured", email.ID, kind, to)
+	}
+	return nil
+}
+
+// Start delivers pending emails every interval, and deletes old ones every
+// hour, until ctx is cancelled
+func (s *MailService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if s.sender != nil {
+			s.Deliver()
+		}
+		if now := time.Now(); now.Sub(lastPurge) >= time.Hour {
+			s.Purge(now.UTC())
+			lastPurge = now
+		}
+	}
+}
+
+// Purge deletes the emails queued more than emailRetention before now
+func (s *MailService) Purge(now time.Time) {
+	deleted, err := s.emailRepo.DeleteBefore(now.Add(-emailRetention))
+	if err != nil {
+		log.Println("error deleting old emails:", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("deleted %d emails older than %s from the outbox", deleted, emailRetention)
+	}
+}
+
+// Deliver sends the pending emails of the outbox
+func (s *MailService) Deliver() {
+	emails, err := s.emailRepo.ListPending(maxEmailAttempts, 50)
+	if err != nil {
+		log.Println("error listing pending emails:", err)
+		return
+	}
+	for _, email := range emails {
+		if err := s.sender.Send(email.To, email.Subject, email.Body); err != nil {
+			log.Printf("error sending email %d: %v", email.ID, err)
+			s.emailRepo.MarkFailed(email.ID, err.Error())
+			continue
+		}
+		s.emailRepo.MarkSent(email.ID, time.Now().UTC())
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+)
+
+// fakeEmailRepository is an in-memory outbox
+type fakeEmailRepository struct {
+	emails        []*model.OutboxEmail
+	deletedBefore time.Time
+}
+
+func (r *fakeEmailRepository) CreateTable() error { return nil }
+
+func (r *fakeEmailRepository) Enqueue(email *model.OutboxEmail) error {
+	email.ID = len(r.emails) + 1
+	email.CreatedAt = time.Now().UTC()
+	r.emails = append(r.emails, email)
+	return nil
+}
+
+func (r *fakeEmailRepository) ListPending(maxAttempts int, limit int) ([]model.OutboxEmail, error) {
+	pending := []model.OutboxEmail{}
+	for _, e := range r.emails {
+		if e.SentAt == nil && e.Attempts < maxAttempts && len(pending) < limit {
+			pending = append(pending, *e)
+		}
+	}
+	return pending, nil
+}
+
+func (r *fakeEmailRepository) MarkSent(id int, at time.Time) error {
+	r.emails[id-1].SentAt = &at
+	r.emails[id-1].Body = ""
+	return nil
+}
+
+func (r *fakeEmailRepository) MarkFailed(id int, errMessage string) error {
+	r.emails[id-1].Attempts++
+	r.emails[id-1].LastError = errMessage
+	return nil
+}
+
+func (r *fakeEmailRepository) DeleteBefore(before time.Time) (int64, error) {
+	r.deletedBefore = before
+	return 0, nil
+}
+
+// fakeMailSender refuses to deliver to the addresses in down
+type fakeMailSender struct {
+	sent []string
+	down map[string]bool
+}
+
+func (s *fakeMailSender) Send(to string, subject string, body string) error {
+	if s.down[to] {
+		return errors.New("mailbox unavailable")
+	}
+	s.sent = append(s.sent, to)
+	return nil
+}
+
+func TestMailServiceDeliver(t *testing.T) {
+	repo := &fakeEmailRepository{}
+	sender := &fakeMailSender{down: map[string]bool{"bounce@example.com": true}}
+	service := NewMailService(repo, sender)
+	for _, to := range []string{"jane@example.com", "bounce@example.com"} {
+		if err := service.Enqueue(model.EmailKindVerifyEmail, to, "Confirm your email address", "token"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < maxEmailAttempts+2; i++ {
+		service.Deliver()
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != "jane@example.com" {
+		t.Errorf("sent to %v, want jane@example.com once", sender.sent)
+	}
+	delivered, bounced := repo.emails[0], repo.emails[1]
+	if delivered.SentAt == nil || delivered.Body != "" {
+		t.Errorf("delivered email %+v, want it marked sent with its token cleared", delivered)
+	}
+	if bounced.SentAt != nil || bounced.Attempts != maxEmailAttempts || bounced.LastError != "mailbox unavailable" {
+		t.Errorf("bounced email %+v, want %d failed attempts and no more", bounced, maxEmailAttempts)
+	}
+}
+
+func TestMailServicePurge(t *testing.T) {
+	repo := &fakeEmailRepository{}
+	now := time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)
+	NewMailService(repo, nil).Purge(now)
+	if want := now.Add(-emailRetention); !repo.deletedBefore.Equal(want) {
+		t.Errorf("purged emails before %s, want %s", repo.deletedBefore, want)
+	}
+}
+
+func TestSMTPSenderRefusesHeaderInjection(t *testing.T) {
+	sender := NewSMTPSender("localhost", "0", "", "", "noreply@example.com")
+	for _, to := range []string{"jane@example.com\r\nBcc: all@example.com", "jane@example.com\nBcc: all@example.com"} {
+		if err := sender.Send(to, "Hello", "body"); err == nil || err.Error() != "invalid header value" {
+			t.Errorf("Send(%q) = %v, want the header refused", to, err)
+		}
+	}
+	if err := sender.Send("jane@example.com", "Hello\r\nBcc: all@example.com", "body"); err == nil || err.Error() != "invalid header value" {
+		t.Errorf("a subject with a line break was not refused: %v", err)
+	}
+}
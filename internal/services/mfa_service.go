@@ -0,0 +1,178 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+const (
+	totpIssuer        = "Prothomuse"
+	recoveryCodeCount = 10
+)
+
+// MFAService manages TOTP enrolment and checks second factors. Logins with MFA
+// go through AuthService, which asks this service to verify the code.
+type MFAService struct {
+	userRepo   repository.UserRepository
+	mfaRepo    repository.MFARepository
+	loginGuard *LoginGuard
+}
+
+func NewMFAService(userRepo repository.UserRepository, mfaRepo repository.MFARepository, loginGuard *LoginGuard) *MFAService {
+	return &MFAService{userRepo: userRepo, mfaRepo: mfaRepo, loginGuard: loginGuard}
+}
+
+// Enroll starts TOTP enrolment with a new secret. MFA is only enabled once the
+// user confirms a code from their authenticator app.
+func (s *MFAService) Enroll(userID int) (*model.MFAEnrollment, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	existing, err := s.mfaRepo.GetMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Enabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.SavePending(userID, secret); err != nil {
+		return nil, err
+	}
+	return &model.MFAEnrollment{Secret: secret, URI: utils.TOTPURI(totpIssuer, user.Email, secret)}, nil
+}
+
+// Confirm enables MFA with a first valid code and returns the recovery codes.
+// They are shown once; only their hashes are kept.
+func (s *MFAService) Confirm(userID int, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, errors.New("start enrolment first")
+	}
+	if mfa.Enabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	now := time.Now().UTC()
+	step, ok := utils.ValidateTOTP(mfa.Secret, code, now)
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+	if err := s.mfaRepo.Enable(userID, step, now); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(userID)
+}
+
+// Disable turns MFA off. It needs the password and a current code, so a stolen
+// access token alone cannot remove the second factor.
+func (s *MFAService) Disable(userID int, password string, code string, ip string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	now := time.Now().UTC()
+	if err := s.loginGuard.Check(user.Email, ip, now); err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(password, user.Password) {
+		s.fail(user, ip, now)
+		return errors.New("invalid password")
+	}
+	if err := s.Verify(userID, code); err != nil {
+		s.fail(user, ip, now)
+		return err
+	}
+	return s.mfaRepo.Delete(userID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a current code
+func (s *MFAService) RegenerateRecoveryCodes(userID int, code string, ip string) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	now := time.Now().UTC()
+	if err := s.loginGuard.Check(user.Email, ip, now); err != nil {
+		return nil, err
+	}
+	if err := s.Verify(userID, code); err != nil {
+		s.fail(user, ip, now)
+		return nil, err
+	}
+	return s.newRecoveryCodes(userID)
+}
+
+// fail counts a wrong password or code given with an access token as a failed
+// login, so a stolen token cannot be used to guess them faster than logins can
+func (s *MFAService) fail(user *model.User, ip string, now time.Time) {
+	if _, _, err := s.loginGuard.Fail(user.Email, ip, now); err != nil {
+		log.Printf("error recording failed MFA check of user %d: %v", user.ID, err)
+	}
+}
+
+func (s *MFAService) newRecoveryCodes(userID int) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(code))
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// IsEnabled reports whether the user must pass a second factor at login
+func (s *MFAService) IsEnabled(userID int) (bool, error) {
+	mfa, err := s.mfaRepo.GetMFA(userID)
+	if err != nil {
+		return false, err
+	}
+	return mfa != nil && mfa.Enabled, nil
+}
+
+// Verify checks a TOTP code, which cannot be replayed, or consumes a recovery code
+func (s *MFAService) Verify(userID int, code string) error {
+	mfa, err := s.mfaRepo.GetMFA(userID)
+	if err != nil {
+		return err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if step, ok := utils.ValidateTOTP(mfa.Secret, code, time.Now().UTC()); ok {
+		fresh, err := s.mfaRepo.UseStep(userID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return errors.New("code has already been used")
+		}
+		return nil
+	}
+	used, err := s.mfaRepo.UseRecoveryCode(userID, utils.HashToken(utils.NormalizeRecoveryCode(code)), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errors.New("invalid code")
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+// fakeMFARepository keeps enrolments and recovery codes in memory
+type fakeMFARepository struct {
+	repository.MFARepository
+	enrolments    map[int]*model.UserMFA
+	recoveryCodes map[string]bool // hash to whether it is unused
+}
+
+func (r *fakeMFARepository) GetMFA(userID int) (*model.UserMFA, error) {
+	return r.enrolments[userID], nil
+}
+
+func (r *fakeMFARepository) UseStep(userID int, step int64) (bool, error) {
+	mfa := r.enrolments[userID]
+	if step <= mfa.LastStep {
+		return false, nil
+	}
+	mfa.LastStep = step
+	return true, nil
+}
+
+func (r *fakeMFARepository) UseRecoveryCode(userID int, codeHash string, at time.Time) (bool, error) {
+	unused := r.recoveryCodes[codeHash]
+	r.recoveryCodes[codeHash] = false
+	return unused, nil
+}
+
+func (r *fakeMFARepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	r.recoveryCodes = map[string]bool{}
+	for _, hash := range codeHashes {
+		r.recoveryCodes[hash] = true
+	}
+	return nil
+}
+
+// newTestMFAService returns an auth service for jane, whose password is
+// "correct horse" and who has MFA enabled with the recovery code "ABCDE-12345"
+func newTestMFAService(t *testing.T) (*AuthService, *fakeLoginAttemptRepository) {
+	hash, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, userRepo, _ := newTestAuthService(model.User{ID: 7, Username: "jane", Email: "jane@example.com", Password: hash, IsActive: true})
+	mfaRepo := &fakeMFARepository{
+		enrolments:    map[int]*model.UserMFA{7: {UserID: 7, Secret: "JBSWY3DPEHPK3PXP", Enabled: true}},
+		recoveryCodes: map[string]bool{utils.HashToken(utils.NormalizeRecoveryCode("ABCDE-12345")): true},
+	}
+	service.mfaService = NewMFAService(userRepo, mfaRepo, service.loginGuard)
+	return service, service.loginGuard.attemptRepo.(*fakeLoginAttemptRepository)
+}
+
+func TestVerifyMFACountsWrongCodesAsFailedLogins(t *testing.T) {
+	service, attempts := newTestMFAService(t)
+	client := model.ClientInfo{IP: "203.0.113.7"}
+	accountFailures := func() int {
+		if failure := attempts.failures[[2]string{model.LoginScopeAccount, "jane@example.com"}]; failure != nil {
+			return failure.Failures
+		}
+		return 0
+	}
+	login := func() string {
+		for _, failure := range attempts.failures {
+			failure.LastFailureAt = failure.LastFailureAt.Add(-time.Minute)
+		}
+		resp, err := service.Login(model.LoginRequest{Email: "jane@example.com", Password: "correct horse"}, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.MFARequired || resp.Token != "" {
+			t.Fatalf("login %+v, want an MFA challenge without tokens", resp)
+		}
+		return resp.MFAToken
+	}
+
+	service.Login(model.LoginRequest{Email: "jane@example.com", Password: "wrong"}, client)
+	service.Login(model.LoginRequest{Email: "jane@example.com", Password: "wrong"}, client)
+
+	// the right password alone does not forget the failures
+	challenge := login()
+	if got := accountFailures(); got != 2 {
+		t.Errorf("%d failures after the password step, want 2", got)
+	}
+	if _, err := service.VerifyMFA(model.MFAVerifyRequest{MFAToken: challenge, Code: "00000-00000"}, client); err == nil {
+		t.Fatal("a wrong code was accepted")
+	}
+	if got := accountFailures(); got != 3 {
+		t.Errorf("%d failures after a wrong code, want 3", got)
+	}
+
+	// a code sent straight after a failure waits out the delay like a password
+	challenge = login()
+	service.Login(model.LoginRequest{Email: "jane@example.com", Password: "wrong"}, client)
+	var lockedOut *LockedOutError
+	if _, err := service.VerifyMFA(model.MFAVerifyRequest{MFAToken: challenge, Code: "ABCDE-12345"}, client); !errors.As(err, &lockedOut) {
+		t.Errorf("code during the delay: %v, want a LockedOutError", err)
+	}
+
+	challenge = login()
+	resp, err := service.VerifyMFA(model.MFAVerifyRequest{MFAToken: challenge, Code: "abcde12345"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token == "" || accountFailures() != 0 {
+		t.Errorf("login %+v with %d failures left, want tokens and none", resp, accountFailures())
+	}
+}
+
+func TestMFAManagementCountsFailures(t *testing.T) {
+	service, attempts := newTestMFAService(t)
+	mfa := service.mfaService
+
+	if err := mfa.Disable(7, "wrong", "ABCDE-12345", "203.0.113.7"); err == nil {
+		t.Fatal("MFA disabled with a wrong password")
+	}
+	if _, err := mfa.RegenerateRecoveryCodes(7, "00000-00000", "203.0.113.7"); err == nil {
+		t.Fatal("recovery codes regenerated with a wrong code")
+	}
+	account := attempts.failures[[2]string{model.LoginScopeAccount, "jane@example.com"}]
+	ip := attempts.failures[[2]string{model.LoginScopeIP, "203.0.113.7"}]
+	if account == nil || account.Failures != 2 || ip == nil || ip.Failures != 2 {
+		t.Errorf("failures %+v and %+v, want 2 for the account and the IP", account, ip)
+	}
+
+	// a third failure starts a delay, which the right password and code wait out too
+	service.Login(model.LoginRequest{Email: "jane@example.com", Password: "wrong"}, model.ClientInfo{})
+	var lockedOut *LockedOutError
+	if err := mfa.Disable(7, "correct horse", "ABCDE-12345", "198.51.100.2"); !errors.As(err, &lockedOut) {
+		t.Errorf("disabling during the delay: %v, want a LockedOutError", err)
+	}
+}
@@ -0,0 +1,485 @@
+package services
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// OIDCStateTTL is how long a started SSO flow can be completed
+	OIDCStateTTL = 10 * time.Minute
+	// oidcKeyRefetchInterval limits JWKS downloads when tokens carry an unknown kid
+	oidcKeyRefetchInterval = time.Minute
+)
+
+var ErrSSODisabled = errors.New("single sign-on is not configured")
+
+// OIDCConfig configures login through an OpenID Connect provider
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for public clients, which rely on PKCE alone
+	RedirectURL  string
+	Scopes       []string
+}
+
+// oidcProvider is the part of the provider's discovery document used here
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type idTokenClaims struct {
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"` // some providers send "true"
+	Name              string      `json:"name"`
+	PreferredUsername string      `json:"preferred_username"`
+	Nonce             string      `json:"nonce"`
+	AuthorizedParty   string      `json:"azp"`
+	jwt.RegisteredClaims
+}
+
+func (c *idTokenClaims) emailVerified() bool {
+	return c.EmailVerified == true || c.EmailVerified == "true"
+}
+
+// OIDCService logs users in with the authorization code flow and PKCE. Provider
+// accounts are linked to users by issuer and subject; on first login an account
+// is linked to the user with the same email when both the provider and the user
+// have verified it, or a user is created.
+type OIDCService struct {
+	config      OIDCConfig
+	oidcRepo    repository.OIDCRepository
+	userRepo    repository.UserRepository
+	authService *AuthService
+	client      *http.Client
+
+	mu            sync.Mutex
+	provider      *oidcProvider
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+func NewOIDCService(config OIDCConfig, oidcRepo repository.OIDCRepository, userRepo repository.UserRepository, authService *AuthService) *OIDCService {
+	config.Issuer = strings.TrimRight(config.Issuer, "/")
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDCService{
+		config:      config,
+		oidcRepo:    oidcRepo,
+		userRepo:    userRepo,
+		authService: authService,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Enabled reports whether an issuer and client are configured
+func (s *OIDCService) Enabled() bool {
+	return s.config.Issuer != "" && s.config.ClientID != ""
+}
+
+// AuthorizationURL starts a login, or links the provider account to linkUserID
+// when it is not zero, and returns the provider URL to send the browser to with
+// the state. The caller must bind the state to the browser (a cookie) and give
+// it back to Callback, so that a flow cannot be completed in another browser.
+func (s *OIDCService) AuthorizationURL(linkUserID int, loginHint string) (string, string, error) {
+	if !s.Enabled() {
+		return "", "", ErrSSODisabled
+	}
+	provider, err := s.discover()
+	if err != nil {
+		return "", "", err
+	}
+	state, err := utils.GenerateToken(24)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.GenerateToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	stored := &model.OIDCState{
+		StateHash:    utils.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().UTC().Add(OIDCStateTTL),
+	}
+	if linkUserID != 0 {
+		stored.LinkUserID = &linkUserID
+	}
+	if err := s.oidcRepo.CreateState(stored); err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {s.config.ClientID},
+		"redirect_uri":          {s.config.RedirectURL},
+		"scope":                 {strings.Join(s.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if loginHint != "" {
+		params.Set("login_hint", loginHint)
+	}
+	separator := "?"
+	if strings.Contains(provider.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return provider.AuthorizationEndpoint + separator + params.Encode(), state, nil
+}
+
+// Callback finishes a flow started by AuthorizationURL. browserState is the state
+// the browser was given when the flow started. A login returns tokens; linking
+// returns the identity now linked to the signed-in user.
+func (s *OIDCService) Callback(code string, state string, browserState string, client model.ClientInfo) (*model.LoginResponse, *model.UserIdentity, error) {
+	if !s.Enabled() {
+		return nil, nil, ErrSSODisabled
+	}
+	if code == "" || state == "" {
+		return nil, nil, errors.New("code and state are required")
+	}
+	// a flow started by someone else (an attacker's link URL) must not complete here
+	if subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, nil, errors.New("login was not started in this browser, please start again")
+	}
+	stored, err := s.oidcRepo.ConsumeState(utils.HashToken(state), time.Now().UTC())
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored == nil {
+		return nil, nil, errors.New("invalid or expired login state, please start again")
+	}
+	rawIDToken, err := s.exchangeCode(code, stored.CodeVerifier)
+	if err != nil {
+		return nil, nil, err
+	}
+	claims, err := s.verifyIDToken(rawIDToken, stored.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if stored.LinkUserID != nil {
+		identity, err := s.link(*stored.LinkUserID, claims, client)
+		return nil, identity, err
+	}
+	user, err := s.resolveUser(claims, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		s.authService.auditLoginFailed(user, user.Email, client, "inactive")
+		return nil, nil, errors.New("user is not active")
+	}
+	// multi-factor authentication is left to the identity provider
+	login, err := s.authService.completeLogin(user, client, "sso")
+	return login, nil, err
+}
+
+// resolveUser finds the user of a provider account, linking it by verified email
+// or provisioning a new user the first time it is seen
+func (s *OIDCService) resolveUser(claims *idTokenClaims, client model.ClientInfo) (*model.User, error) {
+	now := time.Now().UTC()
+	identity, err := s.oidcRepo.GetIdentity(claims.Issuer, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		if err := s.oidcRepo.TouchIdentity(identity.ID, claims.Email, now); err != nil {
+			return nil, err
+		}
+		return s.userRepo.GetUserByID(identity.UserID)
+	}
+
+	if claims.Email == "" {
+		return nil, errors.New("identity provider did not return an email address")
+	}
+	user, err := s.userRepo.GetUserByEmail(claims.Email)
+	if err == nil {
+		// an unverified email could belong to anyone at the provider, and an
+		// account that never verified its email may have been registered by
+		// someone else, with a password they know
+		if !claims.emailVerified() || !user.EmailVerified {
+			return nil, errors.New("an account with this email exists; sign in with your password (or reset it) and link SSO from your account")
+		}
+	} else {
+		user, err = s.provision(claims)
+		if err != nil {
+			return nil, err
+		}
+		event := newAuditEvent(model.Actor{UserID: user.ID, ClientInfo: client}, model.AuditRegister, "user", strconv.Itoa(user.ID))
+		event.After = auditJSON(userAuditFields(user))
+		event.Metadata = auditJSON(map[string]string{"method": "sso", "issuer": claims.Issuer})
+		s.authService.auditService.Record(event)
+	}
+	identity = &model.UserIdentity{
+		UserID:      user.ID,
+		Issuer:      claims.Issuer,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: &now,
+	}
+	if err := s.oidcRepo.CreateIdentity(identity); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// provision creates a user for a provider account. It has no password, so it
+// can only sign in through SSO until one is set with the password reset flow.
+func (s *OIDCService) provision(claims *idTokenClaims) (*model.User, error) {
+	if s.authService.config.RequireVerifiedEmail && !claims.emailVerified() {
+		return nil, errors.New("email address is not verified")
+	}
+	apikey, err := utils.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Name
+	}
+	if username == "" {
+		username = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	user := &model.User{
+		Username:      username,
+		Email:         claims.Email,
+		APIKey:        apikey,
+		IsActive:      true,
+		EmailVerified: claims.emailVerified(),
+	}
+	if err := s.userRepo.CreateUser(user); err != nil {
+		return nil, err
+	}
+	log.Printf("provisioned user %d from SSO subject %s", user.ID, claims.Subject)
+	return user, nil
+}
+
+func (s *OIDCService) link(userID int, claims *idTokenClaims, client model.ClientInfo) (*model.UserIdentity, error) {
+	identity, err := s.oidcRepo.GetIdentity(claims.Issuer, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		if identity.UserID != userID {
+			return nil, errors.New("this SSO account is already linked to another user")
+		}
+		return identity, nil
+	}
+	now := time.Now().UTC()
+	identity = &model.UserIdentity{
+		UserID:      userID,
+		Issuer:      claims.Issuer,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: &now,
+	}
+	if err := s.oidcRepo.CreateIdentity(identity); err != nil {
+		return nil, err
+	}
+	event := newAuditEvent(model.Actor{UserID: userID, ClientInfo: client}, model.AuditIdentityLinked, "user_identity", strconv.Itoa(identity.ID))
+	event.After = auditJSON(map[string]string{"issuer": identity.Issuer, "subject": identity.Subject, "email": identity.Email})
+	s.authService.auditService.Record(event)
+	return identity, nil
+}
+
+func (s *OIDCService) ListIdentities(userID int) ([]model.UserIdentity, error) {
+	return s.oidcRepo.ListIdentities(userID)
+}
+
+func (s *OIDCService) Unlink(actor model.Actor, id int) error {
+	if err := s.oidcRepo.DeleteIdentity(actor.UserID, id); err != nil {
+		return err
+	}
+	s.authService.auditService.Record(newAuditEvent(actor, model.AuditIdentityUnlinked, "user_identity", strconv.Itoa(id)))
+	return nil
+}
+
+// exchangeCode redeems an authorization code at the token endpoint and returns the ID token
+func (s *OIDCService) exchangeCode(code string, verifier string) (string, error) {
+	provider, err := s.discover()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {s.config.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {s.config.ClientID},
+	}
+	req, err := http.NewRequest(http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token request rejected: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return body.IDToken, nil
+}
+
+func (s *OIDCService) verifyIDToken(raw string, nonce string) (*idTokenClaims, error) {
+	provider, err := s.discover()
+	if err != nil {
+		return nil, err
+	}
+	claims := &idTokenClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, s.providerKey,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(s.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+	if claims.AuthorizedParty != "" && claims.AuthorizedParty != s.config.ClientID {
+		return nil, errors.New("invalid id_token: issued to another client")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid id_token: no subject")
+	}
+	return claims, nil
+}
+
+// providerKey finds the provider key that signed a token, downloading the JWKS
+// again when the kid is unknown so provider key rotation is picked up
+func (s *OIDCService) providerKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(s.keysFetchedAt) < oidcKeyRefetchInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := s.fetchKeys(); err != nil {
+		return nil, err
+	}
+	if key, ok := s.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey returns the key with this kid, or the only key when the token has no kid
+func (s *OIDCService) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok && kid != ""
+}
+
+func (s *OIDCService) fetchKeys() error {
+	s.keysFetchedAt = time.Now()
+	var set utils.JSONWebKeySet
+	if err := s.getJSON(s.provider.JWKSURI, &set); err != nil {
+		return fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			log.Printf("skipping provider key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	s.keys = keys
+	return nil
+}
+
+// discover loads the provider's discovery document once
+func (s *OIDCService) discover() (*oidcProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.provider != nil {
+		return s.provider, nil
+	}
+	provider := &oidcProvider{}
+	if err := s.getJSON(s.config.Issuer+"/.well-known/openid-configuration", provider); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if strings.TrimRight(provider.Issuer, "/") != s.config.Issuer {
+		return nil, fmt.Errorf("OIDC discovery returned issuer %q, expected %q", provider.Issuer, s.config.Issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is incomplete")
+	}
+	s.provider = provider
+	return provider, nil
+}
+
+func (s *OIDCService) getJSON(url string, v interface{}) error {
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
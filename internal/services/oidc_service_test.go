@@ -0,0 +1,297 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testProvider is an OpenID Connect provider that issues one code per Authorize call
+type testProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    ed25519.PrivateKey
+	codes  map[string]testAuthorization
+}
+
+type testAuthorization struct {
+	challenge     string
+	nonce         string
+	subject       string
+	email         string
+	emailVerified bool
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvider{t: t, key: key, codes: map[string]testAuthorization{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcProvider{
+			Issuer:                p.server.URL,
+			AuthorizationEndpoint: p.server.URL + "/authorize",
+			TokenEndpoint:         p.server.URL + "/token",
+			JWKSURI:               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(utils.JSONWebKeySet{Keys: []utils.JSONWebKey{{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			Use: "sig",
+			Kid: "test",
+			X:   base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		}}})
+	})
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// Authorize signs in a provider account at the authorization URL and returns the code
+func (p *testProvider) Authorize(authURL string, subject string, email string, emailVerified bool) string {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		p.t.Fatalf("authorization request without PKCE: %s", authURL)
+	}
+	code := "code-" + subject
+	p.codes[code] = testAuthorization{
+		challenge:     query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		subject:       subject,
+		email:         email,
+		emailVerified: emailVerified,
+	}
+	return code
+}
+
+func (p *testProvider) token(w http.ResponseWriter, r *http.Request) {
+	authorization, ok := p.codes[r.FormValue("code")]
+	delete(p.codes, r.FormValue("code"))
+	verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != authorization.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &idTokenClaims{
+		Email:         authorization.email,
+		EmailVerified: authorization.emailVerified,
+		Nonce:         authorization.nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.server.URL,
+			Subject:   authorization.subject,
+			Audience:  jwt.ClaimStrings{"prothomuse"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	})
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+}
+
+// fakeOIDCRepository keeps states and identities in memory
+type fakeOIDCRepository struct {
+	repository.OIDCRepository
+	states     map[string]model.OIDCState
+	identities []model.UserIdentity
+}
+
+func (r *fakeOIDCRepository) CreateState(state *model.OIDCState) error {
+	r.states[state.StateHash] = *state
+	return nil
+}
+
+func (r *fakeOIDCRepository) ConsumeState(stateHash string, now time.Time) (*model.OIDCState, error) {
+	state, ok := r.states[stateHash]
+	delete(r.states, stateHash)
+	if !ok || !now.Before(state.ExpiresAt) {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (r *fakeOIDCRepository) GetIdentity(issuer string, subject string) (*model.UserIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeOIDCRepository) CreateIdentity(identity *model.UserIdentity) error {
+	identity.ID = len(r.identities) + 1
+	r.identities = append(r.identities, *identity)
+	return nil
+}
+
+func (r *fakeOIDCRepository) TouchIdentity(id int, email string, at time.Time) error {
+	r.identities[id-1].Email = email
+	r.identities[id-1].LastLoginAt = &at
+	return nil
+}
+
+func newTestOIDCService(t *testing.T, users ...model.User) (*OIDCService, *testProvider, *fakeOIDCRepository) {
+	provider := newTestProvider(t)
+	oidcRepo := &fakeOIDCRepository{states: map[string]model.OIDCState{}}
+	authService, userRepo, _ := newTestAuthService(users...)
+	service := NewOIDCService(OIDCConfig{
+		Issuer:      provider.server.URL,
+		ClientID:    "prothomuse",
+		RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+	}, oidcRepo, userRepo, authService)
+	return service, provider, oidcRepo
+}
+
+func TestOIDCLink(t *testing.T) {
+	service, provider, oidcRepo := newTestOIDCService(t)
+	authURL, state, err := service.AuthorizationURL(7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := provider.Authorize(authURL, "sub-1", "jane@example.com", true)
+
+	login, identity, err := service.Callback(code, state, state, model.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login != nil || identity == nil || identity.UserID != 7 || identity.Subject != "sub-1" || identity.Issuer != provider.server.URL {
+		t.Errorf("link returned %+v, %+v", login, identity)
+	}
+	if len(oidcRepo.identities) != 1 {
+		t.Errorf("%d identities stored, want 1", len(oidcRepo.identities))
+	}
+
+	// the state is single use
+	if _, _, err := service.Callback(code, state, state, model.ClientInfo{}); err == nil || !strings.Contains(err.Error(), "expired login state") {
+		t.Errorf("replayed state: %v", err)
+	}
+}
+
+func TestOIDCLoginProvisionsUsers(t *testing.T) {
+	service, provider, oidcRepo := newTestOIDCService(t, model.User{ID: 7, Email: "jane@example.com", IsActive: true, EmailVerified: true})
+	tests := []struct {
+		scenario string
+		subject  string
+		email    string
+		wantUser int
+	}{
+		{"new account", "sub-joe", "joe@example.com", 2},
+		{"verified email of an existing user", "sub-jane", "jane@example.com", 7},
+		{"known subject with a changed email", "sub-joe", "joe@elsewhere.example.com", 2},
+	}
+	for _, tt := range tests {
+		authURL, state, err := service.AuthorizationURL(0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := provider.Authorize(authURL, tt.subject, tt.email, true)
+		login, _, err := service.Callback(code, state, state, model.ClientInfo{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.scenario, err)
+			continue
+		}
+		claims, err := utils.ValidateJWT(login.Token)
+		if err != nil || claims.UserID != tt.wantUser {
+			t.Errorf("%s: signed in as %+v (%v), want user %d", tt.scenario, claims, err, tt.wantUser)
+		}
+	}
+	if len(oidcRepo.identities) != 2 || oidcRepo.identities[0].Email != "joe@elsewhere.example.com" {
+		t.Errorf("identities %+v, want joe's with the new email and jane's", oidcRepo.identities)
+	}
+	joe := service.userRepo.(*fakeUserRepository).users[1]
+	if joe.Username != "joe" || joe.Password != "" || !joe.EmailVerified {
+		t.Errorf("provisioned user %+v, want joe without a password and with a verified email", joe)
+	}
+}
+
+func TestOIDCCallbackRequiresTheBrowserThatStarted(t *testing.T) {
+	service, provider, oidcRepo := newTestOIDCService(t)
+	// an attacker starts linking their provider account to their own user and
+	// sends the callback URL to a victim, whose browser has no state cookie
+	authURL, state, err := service.AuthorizationURL(7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := provider.Authorize(authURL, "attacker", "attacker@example.com", true)
+	for _, browserState := range []string{"", "another-state"} {
+		if _, _, err := service.Callback(code, state, browserState, model.ClientInfo{}); err == nil || !strings.Contains(err.Error(), "not started in this browser") {
+			t.Errorf("browser state %q: %v", browserState, err)
+		}
+	}
+	if len(oidcRepo.identities) != 0 {
+		t.Errorf("identity linked from another browser: %+v", oidcRepo.identities)
+	}
+}
+
+func TestOIDCLoginDoesNotAutoLinkUnverifiedAccounts(t *testing.T) {
+	tests := []struct {
+		scenario         string
+		localVerified    bool
+		providerVerified bool
+	}{
+		// someone registered the email before its owner and set the password
+		{"account never verified", false, true},
+		{"provider email not verified", true, false},
+	}
+	for _, tt := range tests {
+		service, provider, oidcRepo := newTestOIDCService(t, model.User{ID: 7, Email: "jane@example.com", IsActive: true, EmailVerified: tt.localVerified})
+		authURL, state, err := service.AuthorizationURL(0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := provider.Authorize(authURL, "sub-1", "jane@example.com", tt.providerVerified)
+		if _, _, err := service.Callback(code, state, state, model.ClientInfo{}); err == nil || !strings.Contains(err.Error(), "link SSO from your account") {
+			t.Errorf("%s: %v", tt.scenario, err)
+		}
+		if len(oidcRepo.identities) != 0 {
+			t.Errorf("%s: identity linked: %+v", tt.scenario, oidcRepo.identities)
+		}
+	}
+}
+
+func TestOIDCRejectsTamperedCodes(t *testing.T) {
+	service, provider, _ := newTestOIDCService(t)
+	authURL, state, err := service.AuthorizationURL(7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a code issued for another authorization request fails PKCE
+	otherURL, _, err := service.AuthorizationURL(8, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.Authorize(authURL, "sub-1", "jane@example.com", true)
+	code := provider.Authorize(otherURL, "sub-2", "joe@example.com", true)
+	if _, _, err := service.Callback(code, state, state, model.ClientInfo{}); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("code of another request: %v", err)
+	}
+}
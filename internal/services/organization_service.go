@@ -0,0 +1,483 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+var (
+	ErrNotMember       = errors.New("you are not a member of this organization")
+	ErrForbidden       = errors.New("your role does not allow this action")
+	ErrLastOwner       = errors.New("an organization must keep at least one owner")
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrInvalidProjectKey answers an unknown or revoked project API key
+	ErrInvalidProjectKey = errors.New("invalid or revoked project API key")
+	// ErrProjectKeyRequired refuses keyless metrics for a project that has API keys
+	ErrProjectKeyRequired = errors.New("this project requires a project API key")
+	// ErrSyntheticIngest refuses metrics sent for a synthetic project
+	ErrSyntheticIngest = errors.New("project IDs ending in :synthetic are reserved for synthetic checks")
+)
+
+// ingestRecheckInterval is how long an IngestGuard trusts its last look at a
+// key or at whether a project requires one
+const ingestRecheckInterval = 30 * time.Second
+
+// OrganizationService manages organizations, their members and projects, and
+// answers the role checks done by the RBAC middleware on every request.
+type OrganizationService struct {
+	orgRepo      repository.OrganizationRepository
+	keyRepo      repository.ProjectKeyRepository
+	userRepo     repository.UserRepository
+	auditService *AuditService
+}
+
+func NewOrganizationService(orgRepo repository.OrganizationRepository, keyRepo repository.ProjectKeyRepository, userRepo repository.UserRepository, auditService *AuditService) *OrganizationService {
+	return &OrganizationService{orgRepo: orgRepo, keyRepo: keyRepo, userRepo: userRepo, auditService: auditService}
+}
+
+func (s *OrganizationService) CreateOrganization(actor model.Actor, req model.CreateOrganizationRequest) (*model.Organization, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	org := &model.Organization{Name: name, CreatedBy: actor.UserID}
+	if err := s.orgRepo.CreateOrganization(org, actor.UserID); err != nil {
+		return nil, err
+	}
+	event := orgAuditEvent(org.ID, actor, model.AuditOrganizationCreated, "organization", strconv.Itoa(org.ID))
+	event.After = auditJSON(org)
+	s.auditService.Record(event)
+	return org, nil
+}
+
+func (s *OrganizationService) ListOrganizations(userID int) ([]model.Organization, error) {
+	return s.orgRepo.ListUserOrganizations(userID)
+}
+
+func (s *OrganizationService) GetOrganization(orgID int) (*model.Organization, error) {
+	return s.orgRepo.GetOrganization(orgID)
+}
+
+// DeleteOrganization deletes an organization with its members and projects
+func (s *OrganizationService) DeleteOrganization(orgID int, actor model.Actor) error {
+	org, err := s.orgRepo.GetOrganization(orgID)
+	if err != nil {
+		return err
+	}
+	if err := s.orgRepo.DeleteOrganization(orgID); err != nil {
+		return err
+	}
+	event := orgAuditEvent(orgID, actor, model.AuditOrganizationDeleted, "organization", strconv.Itoa(orgID))
+	event.Before = auditJSON(org)
+	s.auditService.Record(event)
+	return nil
+}
+
+// OrganizationRole returns the role of a user in an organization, or "" if not a member
+func (s *OrganizationService) OrganizationRole(orgID int, userID int) (string, error) {
+	return s.orgRepo.GetMemberRole(orgID, userID)
+}
+
+// ProjectRole returns the role of a user in the organization owning a project,
+// or "" if the project is not registered or the user is not a member. Synthetic
+// projects are governed by the project they belong to.
+func (s *OrganizationService) ProjectRole(projectID string, userID int) (string, error) {
+	return s.orgRepo.GetProjectRole(model.ParentProjectID(projectID), userID)
+}
+
+// ProjectIDs returns every project the user can see
+func (s *OrganizationService) ProjectIDs(userID int) ([]string, error) {
+	return s.orgRepo.ListUserProjectIDs(userID)
+}
+
+func (s *OrganizationService) ListMembers(orgID int) ([]model.Member, error) {
+	return s.orgRepo.ListMembers(orgID)
+}
+
+// AddMember adds an existing user to the organization. Only owners can add owners.
+func (s *OrganizationService) AddMember(orgID int, actor model.Actor, actorRole string, req model.AddMemberRequest) (*model.Member, error) {
+	if req.Role == "" {
+		req.Role = model.RoleMember
+	}
+	if !model.ValidRole(req.Role) {
+		return nil, errors.New("role must be owner, admin, member or viewer")
+	}
+	if req.Role == model.RoleOwner && actorRole != model.RoleOwner {
+		return nil, ErrForbidden
+	}
+	user, err := s.userRepo.GetUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil {
+		return nil, errors.New("no user with this email")
+	}
+	role, err := s.orgRepo.GetMemberRole(orgID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if role != "" {
+		return nil, errors.New("user is already a member")
+	}
+	if err := s.orgRepo.AddMember(orgID, user.ID, req.Role); err != nil {
+		return nil, err
+	}
+	member := &model.Member{
+		OrganizationID: orgID,
+		UserID:         user.ID,
+		Username:       user.Username,
+		Email:          user.Email,
+		Role:           req.Role,
+		CreatedAt:      time.Now().UTC(),
+	}
+	event := orgAuditEvent(orgID, actor, model.AuditMemberAdded, "user", strconv.Itoa(user.ID))
+	event.After = auditJSON(map[string]string{"email": user.Email, "role": req.Role})
+	s.auditService.Record(event)
+	return member, nil
+}
+
+// UpdateMemberRole changes the role of a member. Owners are managed only by owners,
+// and the last owner cannot be demoted.
+func (s *OrganizationService) UpdateMemberRole(orgID int, actor model.Actor, actorRole string, userID int, role string) error {
+	if !model.ValidRole(role) {
+		return errors.New("role must be owner, admin, member or viewer")
+	}
+	if !model.RoleAtLeast(actorRole, model.RoleAdmin) {
+		return ErrForbidden
+	}
+	current, err := s.orgRepo.GetMemberRole(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return ErrNotMember
+	}
+	if (current == model.RoleOwner || role == model.RoleOwner) && actorRole != model.RoleOwner {
+		return ErrForbidden
+	}
+	if current == model.RoleOwner && role != model.RoleOwner {
+		if err := s.keepOwner(orgID); err != nil {
+			return err
+		}
+	}
+	if err := s.orgRepo.UpdateMemberRole(orgID, userID, role); err != nil {
+		return err
+	}
+	event := orgAuditEvent(orgID, actor, model.AuditMemberRoleChanged, "user", strconv.Itoa(userID))
+	event.Before = auditJSON(map[string]string{"role": current})
+	event.After = auditJSON(map[string]string{"role": role})
+	s.auditService.Record(event)
+	return nil
+}
+
+// RemoveMember removes a member. Any member may leave; removing someone else
+// takes an admin, and removing an owner takes an owner.
+func (s *OrganizationService) RemoveMember(orgID int, actor model.Actor, actorRole string, userID int) error {
+	current, err := s.orgRepo.GetMemberRole(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return ErrNotMember
+	}
+	if actor.UserID != userID {
+		if !model.RoleAtLeast(actorRole, model.RoleAdmin) || (current == model.RoleOwner && actorRole != model.RoleOwner) {
+			return ErrForbidden
+		}
+	}
+	if current == model.RoleOwner {
+		if err := s.keepOwner(orgID); err != nil {
+			return err
+		}
+	}
+	if err := s.orgRepo.RemoveMember(orgID, userID); err != nil {
+		return err
+	}
+	event := orgAuditEvent(orgID, actor, model.AuditMemberRemoved, "user", strconv.Itoa(userID))
+	event.Before = auditJSON(map[string]string{"role": current})
+	s.auditService.Record(event)
+	return nil
+}
+
+// keepOwner fails when the organization has a single owner left
+func (s *OrganizationService) keepOwner(orgID int) error {
+	owners, err := s.orgRepo.CountOwners(orgID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// CreateProject claims a project ID for the organization
+func (s *OrganizationService) CreateProject(orgID int, actor model.Actor, req model.CreateProjectRequest) (*model.Project, error) {
+	req.ID = strings.TrimSpace(req.ID)
+	if req.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	if len(req.ID) > 255 || strings.ContainsAny(req.ID, "/?# \t\n") {
+		return nil, errors.New("id must be at most 255 characters, without spaces, '/', '?' or '#'")
+	}
+	// synthetic projects come with the project they belong to
+	if model.IsSyntheticProjectID(req.ID) {
+		return nil, errors.New("id must not end with :synthetic")
+	}
+	existing, err := s.orgRepo.GetProject(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.New("project already belongs to an organization")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		req.Name = req.ID
+	}
+	project := &model.Project{
+		ID:             req.ID,
+		OrganizationID: orgID,
+		Name:           strings.TrimSpace(req.Name),
+		CreatedBy:      actor.UserID,
+	}
+	if err := s.orgRepo.CreateProject(project); err != nil {
+		return nil, err
+	}
+	event := orgAuditEvent(orgID, actor, model.AuditProjectCreated, "project", project.ID)
+	event.After = auditJSON(project)
+	s.auditService.Record(event)
+	return project, nil
+}
+
+func (s *OrganizationService) GetProject(projectID string) (*model.Project, error) {
+	project, err := s.orgRepo.GetProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, ErrProjectNotFound
+	}
+	return project, nil
+}
+
+func (s *OrganizationService) ListProjects(orgID int) ([]model.Project, error) {
+	return s.orgRepo.ListProjects(orgID)
+}
+
+// DeleteProject releases the project ID and deletes its keys. Data stored under the
+// project ID is kept and becomes reachable again once the ID is claimed.
+func (s *OrganizationService) DeleteProject(projectID string, actor model.Actor) error {
+	project, err := s.GetProject(projectID)
+	if err != nil {
+		return err
+	}
+	if err := s.orgRepo.DeleteProject(projectID); err != nil {
+		return err
+	}
+	event := orgAuditEvent(project.OrganizationID, actor, model.AuditProjectDeleted, "project", projectID)
+	event.Before = auditJSON(project)
+	s.auditService.Record(event)
+	return nil
+}
+
+// CreateProjectKey creates a project API key. The secret is returned only here.
+func (s *OrganizationService) CreateProjectKey(projectID string, actor model.Actor, req model.CreateProjectKeyRequest) (*model.ProjectKey, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New("name is required")
+	}
+	key := &model.ProjectKey{
+		ProjectID: projectID,
+		Name:      strings.TrimSpace(req.Name),
+		CreatedBy: actor.UserID,
+	}
+	if err := newProjectKeySecret(key); err != nil {
+		return nil, err
+	}
+	if err := s.keyRepo.CreateKey(key); err != nil {
+		return nil, err
+	}
+	event := newAuditEvent(actor, model.AuditKeyCreated, "api_key", strconv.Itoa(key.ID))
+	event.After = auditJSON(map[string]string{"projectId": projectID, "name": key.Name, "prefix": key.Prefix})
+	s.auditService.RecordProject(projectID, event)
+	return key, nil
+}
+
+func (s *OrganizationService) ListProjectKeys(projectID string) ([]model.ProjectKey, error) {
+	return s.keyRepo.ListKeys(projectID)
+}
+
+// RotateProjectKey replaces the secret of an active key; the old secret stops working at once
+func (s *OrganizationService) RotateProjectKey(projectID string, id int, actor model.Actor) (*model.ProjectKey, error) {
+	key, err := s.projectKey(projectID, id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, errors.New("key is revoked")
+	}
+	oldPrefix := key.Prefix
+	if err := newProjectKeySecret(key); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if err := s.keyRepo.RotateKey(key, now); err != nil {
+		return nil, err
+	}
+	key.RotatedAt = &now
+	event := newAuditEvent(actor, model.AuditKeyRotated, "api_key", strconv.Itoa(id))
+	event.Before = auditJSON(map[string]string{"prefix": oldPrefix})
+	event.After = auditJSON(map[string]string{"prefix": key.Prefix})
+	s.auditService.RecordProject(projectID, event)
+	return key, nil
+}
+
+func (s *OrganizationService) RevokeProjectKey(projectID string, id int, actor model.Actor) error {
+	key, err := s.projectKey(projectID, id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	if err := s.keyRepo.RevokeKey(id, time.Now().UTC()); err != nil {
+		return err
+	}
+	event := newAuditEvent(actor, model.AuditKeyRevoked, "api_key", strconv.Itoa(id))
+	event.Before = auditJSON(map[string]string{"projectId": projectID, "name": key.Name, "prefix": key.Prefix})
+	s.auditService.RecordProject(projectID, event)
+	return nil
+}
+
+// AuthenticateProjectKey returns the active key with this secret, or ErrInvalidProjectKey
+func (s *OrganizationService) AuthenticateProjectKey(secret string) (*model.ProjectKey, error) {
+	if !strings.HasPrefix(secret, "pk_") {
+		return nil, ErrInvalidProjectKey
+	}
+	key, err := s.keyRepo.GetKeyByHash(utils.HashToken(secret))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || key.RevokedAt != nil {
+		return nil, ErrInvalidProjectKey
+	}
+	return key, nil
+}
+
+// AuthorizeIngest checks that metrics for projectID may be accepted from a
+// connection that presented key, nil when it presented none. Once a project has
+// an active API key it only accepts metrics sent with one of its keys, so
+// revoking or rotating a key cuts off its holder; projects without keys stay open.
+// Synthetic projects only receive the metrics of synthetic checks.
+func (s *OrganizationService) AuthorizeIngest(projectID string, key *model.ProjectKey) error {
+	if model.IsSyntheticProjectID(projectID) {
+		return ErrSyntheticIngest
+	}
+	if key != nil {
+		if err := s.checkIngestKey(key); err != nil {
+			return err
+		}
+		return checkKeyProject(projectID, key)
+	}
+	return s.checkKeyless(projectID)
+}
+
+// checkIngestKey fails with ErrInvalidProjectKey once key has been revoked,
+// rotated or deleted with its project
+func (s *OrganizationService) checkIngestKey(key *model.ProjectKey) error {
+	current, err := s.keyRepo.GetKey(key.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidProjectKey
+	}
+	if err != nil {
+		return err
+	}
+	if current.RevokedAt != nil || current.KeyHash != key.KeyHash {
+		return ErrInvalidProjectKey
+	}
+	return nil
+}
+
+func checkKeyProject(projectID string, key *model.ProjectKey) error {
+	if projectID != key.ProjectID {
+		return errors.New("the project API key belongs to another project")
+	}
+	return nil
+}
+
+// checkKeyless fails with ErrProjectKeyRequired when the project has an active key
+func (s *OrganizationService) checkKeyless(projectID string) error {
+	required, err := s.keyRepo.HasActiveKeys(projectID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrProjectKeyRequired
+	}
+	return nil
+}
+
+// IngestGuard is AuthorizeIngest for one middleware connection. It looks up its
+// key and the projects it sends for at most once per ingestRecheckInterval
+// instead of on every metric, so a revoked or rotated key is cut off within
+// that interval. It is not safe for concurrent use.
+type IngestGuard struct {
+	orgService *OrganizationService
+	key        *model.ProjectKey
+	keyChecked time.Time
+	keyless    map[string]time.Time // projects last found to accept keyless metrics
+}
+
+// NewIngestGuard starts guarding a connection that presented key, nil when it
+// presented none, and had it authenticated at now
+func (s *OrganizationService) NewIngestGuard(key *model.ProjectKey, now time.Time) *IngestGuard {
+	return &IngestGuard{orgService: s, key: key, keyChecked: now, keyless: map[string]time.Time{}}
+}
+
+// Authorize checks a metric for projectID received at now
+func (g *IngestGuard) Authorize(projectID string, now time.Time) error {
+	if model.IsSyntheticProjectID(projectID) {
+		return ErrSyntheticIngest
+	}
+	if g.key != nil {
+		if now.Sub(g.keyChecked) >= ingestRecheckInterval {
+			if err := g.orgService.checkIngestKey(g.key); err != nil {
+				return err
+			}
+			g.keyChecked = now
+		}
+		return checkKeyProject(projectID, g.key)
+	}
+	if checked, ok := g.keyless[projectID]; ok && now.Sub(checked) < ingestRecheckInterval {
+		return nil
+	}
+	if err := g.orgService.checkKeyless(projectID); err != nil {
+		delete(g.keyless, projectID)
+		return err
+	}
+	g.keyless[projectID] = now
+	return nil
+}
+
+// projectKey loads a key and checks it belongs to the project of the request
+func (s *OrganizationService) projectKey(projectID string, id int) (*model.ProjectKey, error) {
+	key, err := s.keyRepo.GetKey(id)
+	if err != nil || key.ProjectID != projectID {
+		return nil, errors.New("key not found")
+	}
+	return key, nil
+}
+
+func newProjectKeySecret(key *model.ProjectKey) error {
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return err
+	}
+	key.Key = "pk_" + secret
+	key.Prefix = key.Key[:10]
+	key.KeyHash = utils.HashToken(key.Key)
+	return nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+)
+
+func (r *fakeProjectKeyRepository) CreateKey(key *model.ProjectKey) error {
+	key.ID = len(r.keys) + 1
+	r.keys = append(r.keys, *key)
+	return nil
+}
+
+func (r *fakeProjectKeyRepository) GetKey(id int) (*model.ProjectKey, error) {
+	r.reads++
+	if id < 1 || id > len(r.keys) {
+		return nil, sql.ErrNoRows
+	}
+	key := r.keys[id-1]
+	return &key, nil
+}
+
+func (r *fakeProjectKeyRepository) GetKeyByHash(keyHash string) (*model.ProjectKey, error) {
+	for _, key := range r.keys {
+		if key.KeyHash == keyHash {
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeProjectKeyRepository) HasActiveKeys(projectID string) (bool, error) {
+	r.reads++
+	for _, key := range r.keys {
+		if key.ProjectID == projectID && key.RevokedAt == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeProjectKeyRepository) RotateKey(key *model.ProjectKey, at time.Time) error {
+	stored := &r.keys[key.ID-1]
+	stored.Prefix, stored.KeyHash, stored.RotatedAt = key.Prefix, key.KeyHash, &at
+	return nil
+}
+
+func (r *fakeProjectKeyRepository) RevokeKey(id int, at time.Time) error {
+	r.keys[id-1].RevokedAt = &at
+	return nil
+}
+
+func newTestOrganizationService() (*OrganizationService, *fakeProjectKeyRepository) {
+	keyRepo := &fakeProjectKeyRepository{}
+	auditService, _ := newTestAuditService()
+	return NewOrganizationService(auditService.orgRepo, keyRepo, &fakeUserRepository{}, auditService), keyRepo
+}
+
+func TestAuthenticateProjectKey(t *testing.T) {
+	service, _ := newTestOrganizationService()
+	owner := model.Actor{UserID: 1}
+	key, err := service.CreateProjectKey("shop", owner, model.CreateProjectKeyRequest{Name: "ingest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, err := service.AuthenticateProjectKey(key.Key); err != nil || found.ID != key.ID {
+		t.Fatalf("authenticating a new key: %+v, %v", found, err)
+	}
+
+	rotated, err := service.RotateProjectKey("shop", key.ID, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		scenario string
+		secret   string
+	}{
+		{"secret replaced by a rotation", key.Key},
+		{"unknown secret", "pk_unknown"},
+		{"user API key", "api_key"},
+	}
+	for _, tt := range tests {
+		if _, err := service.AuthenticateProjectKey(tt.secret); !errors.Is(err, ErrInvalidProjectKey) {
+			t.Errorf("%s: %v, want %v", tt.scenario, err, ErrInvalidProjectKey)
+		}
+	}
+	if err := service.RevokeProjectKey("shop", key.ID, owner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.AuthenticateProjectKey(rotated.Key); !errors.Is(err, ErrInvalidProjectKey) {
+		t.Errorf("revoked key: %v, want %v", err, ErrInvalidProjectKey)
+	}
+}
+
+func TestAuthorizeIngest(t *testing.T) {
+	service, _ := newTestOrganizationService()
+	owner := model.Actor{UserID: 1}
+
+	// a project without keys takes metrics from anyone
+	if err := service.AuthorizeIngest("shop", nil); err != nil {
+		t.Errorf("keyless metric for a project without keys: %v", err)
+	}
+	created, err := service.CreateProjectKey("shop", owner, model.CreateProjectKeyRequest{Name: "ingest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := service.AuthenticateProjectKey(created.Key)
+
+	tests := []struct {
+		scenario  string
+		projectID string
+		key       *model.ProjectKey
+		want      error
+	}{
+		{"keyless metric once the project has a key", "shop", nil, ErrProjectKeyRequired},
+		{"metric with the project's key", "shop", key, nil},
+		{"keyless metric for another project", "blog", nil, nil},
+		{"keyless metric for the project's synthetic ID", "shop:synthetic", nil, ErrSyntheticIngest},
+		{"metric with a key for the project's synthetic ID", "shop:synthetic", key, ErrSyntheticIngest},
+	}
+	for _, tt := range tests {
+		if err := service.AuthorizeIngest(tt.projectID, tt.key); !errors.Is(err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.scenario, err, tt.want)
+		}
+	}
+	if err := service.AuthorizeIngest("blog", key); err == nil {
+		t.Error("a key was accepted for another project")
+	}
+
+	// a connection that authenticated before a rotation or revocation is cut off
+	if _, err := service.RotateProjectKey("shop", key.ID, owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.AuthorizeIngest("shop", key); !errors.Is(err, ErrInvalidProjectKey) {
+		t.Errorf("key rotated after the connection opened: %v, want %v", err, ErrInvalidProjectKey)
+	}
+	if err := service.RevokeProjectKey("shop", key.ID, owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.AuthorizeIngest("shop", nil); err != nil {
+		t.Errorf("keyless metric once every key is revoked: %v", err)
+	}
+
+	// a key deleted with its project is refused the same way
+	if err := service.AuthorizeIngest("blog", &model.ProjectKey{ID: 9, ProjectID: "blog"}); !errors.Is(err, ErrInvalidProjectKey) {
+		t.Errorf("deleted key: %v, want %v", err, ErrInvalidProjectKey)
+	}
+}
+
+func TestIngestGuardRechecksOnAnInterval(t *testing.T) {
+	service, keyRepo := newTestOrganizationService()
+	owner := model.Actor{UserID: 1}
+	created, err := service.CreateProjectKey("shop", owner, model.CreateProjectKeyRequest{Name: "ingest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := service.AuthenticateProjectKey(created.Key)
+	opened := time.Now()
+	keyed, keyless := service.NewIngestGuard(key, opened), service.NewIngestGuard(nil, opened)
+
+	keyRepo.reads = 0
+	for i := 0; i < 10; i++ {
+		at := opened.Add(time.Duration(i) * time.Second)
+		if err := keyed.Authorize("shop", at); err != nil {
+			t.Fatalf("metric with the project's key: %v", err)
+		}
+		if err := keyless.Authorize("blog", at); err != nil {
+			t.Fatalf("keyless metric for a project without keys: %v", err)
+		}
+	}
+	if keyRepo.reads != 1 {
+		t.Errorf("%d key reads for 20 metrics, want 1", keyRepo.reads)
+	}
+	if err := keyless.Authorize("shop:synthetic", opened); !errors.Is(err, ErrSyntheticIngest) {
+		t.Errorf("keyless metric for a synthetic ID: %v, want %v", err, ErrSyntheticIngest)
+	}
+
+	if err := service.RevokeProjectKey("shop", key.ID, owner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreateProjectKey("blog", owner, model.CreateProjectKeyRequest{Name: "ingest"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyed.Authorize("shop", opened.Add(time.Second)); err != nil {
+		t.Errorf("revoked key refused before the recheck interval: %v", err)
+	}
+	later := opened.Add(ingestRecheckInterval)
+	if err := keyed.Authorize("shop", later); !errors.Is(err, ErrInvalidProjectKey) {
+		t.Errorf("revoked key after the recheck interval: %v, want %v", err, ErrInvalidProjectKey)
+	}
+	if err := keyless.Authorize("blog", later); !errors.Is(err, ErrProjectKeyRequired) {
+		t.Errorf("keyless metric once the project has a key: %v, want %v", err, ErrProjectKeyRequired)
+	}
+}
+
+func TestCreateProjectReservesSyntheticIDs(t *testing.T) {
+	service, _ := newTestOrganizationService()
+	_, err := service.CreateProject(1, model.Actor{UserID: 1}, model.CreateProjectRequest{ID: "blog:synthetic", Name: "Blog"})
+	if err == nil || !strings.Contains(err.Error(), ":synthetic") {
+		t.Errorf("creating a project with a synthetic ID: %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Codes of the passwords refused by the policy, returned to clients as "code"
+const (
+	PasswordRequired         = "password_required"
+	PasswordTooShort         = "password_too_short"
+	PasswordTooLong          = "password_too_long"
+	PasswordMissingUppercase = "password_missing_uppercase"
+	PasswordMissingLowercase = "password_missing_lowercase"
+	PasswordMissingDigit     = "password_missing_digit"
+	PasswordMissingSymbol    = "password_missing_symbol"
+	PasswordContainsUsername = "password_contains_username"
+	PasswordContainsEmail    = "password_contains_email"
+	PasswordBreached         = "password_breached"
+)
+
+// PasswordPolicyError refuses a password, with a code telling clients why
+type PasswordPolicyError struct {
+	Code    string
+	Message string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Message
+}
+
+// PasswordPolicyConfig configures PasswordPolicy; zero lengths use the defaults
+type PasswordPolicyConfig struct {
+	MinLength int // characters, default 8
+	MaxLength int // characters, default 128
+	// Require* ask for at least one character of the class
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	// BreachedPasswords is a file of known breached passwords, one per line,
+	// either in plain text or as SHA-1 hex hashes optionally followed by
+	// ":count". A directory is read as a k-anonymity range list: one file per
+	// 5 character hash prefix (e.g. 5BAA6 or 5BAA6.txt) holding the remaining
+	// 35 characters of each hash, as served by the Pwned Passwords range API.
+	BreachedPasswords string
+}
+
+// PasswordPolicy decides which new passwords are accepted
+type PasswordPolicy struct {
+	config   PasswordPolicyConfig
+	breached map[[sha1.Size]byte]struct{}
+	rangeDir string
+}
+
+// NewPasswordPolicy loads the breached password list of the config, if any
+func NewPasswordPolicy(config PasswordPolicyConfig) (*PasswordPolicy, error) {
+	if config.MinLength <= 0 {
+		config.MinLength = 8
+	}
+	if config.MaxLength <= 0 {
+		config.MaxLength = 128
+	}
+	if config.MaxLength < config.MinLength {
+		return nil, fmt.Errorf("maximum password length %d is below the minimum %d", config.MaxLength, config.MinLength)
+	}
+	p := &PasswordPolicy{config: config}
+	if config.BreachedPasswords == "" {
+		return p, nil
+	}
+	info, err := os.Stat(config.BreachedPasswords)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		p.rangeDir = config.BreachedPasswords
+		return p, nil
+	}
+	if p.breached, err = loadBreachedPasswords(config.BreachedPasswords); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// BreachedCount returns how many passwords the breached list file holds; 0 for a range directory
+func (p *PasswordPolicy) BreachedCount() int {
+	return len(p.breached)
+}
+
+// Validate returns a PasswordPolicyError when password may not be used by the
+// account with this username and email
+func (p *PasswordPolicy) Validate(password, username, email string) error {
+	if password == "" {
+		return &PasswordPolicyError{PasswordRequired, "password is required"}
+	}
+	length := len([]rune(password))
+	if length < p.config.MinLength {
+		return &PasswordPolicyError{PasswordTooShort, fmt.Sprintf("password must be at least %d characters long", p.config.MinLength)}
+	}
+	if length > p.config.MaxLength {
+		return &PasswordPolicyError{PasswordTooLong, fmt.Sprintf("password must be at most %d characters long", p.config.MaxLength)}
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	switch {
+	case p.config.RequireUppercase && !upper:
+		return &PasswordPolicyError{PasswordMissingUppercase, "password must contain an uppercase letter"}
+	case p.config.RequireLowercase && !lower:
+		return &PasswordPolicyError{PasswordMissingLowercase, "password must contain a lowercase letter"}
+	case p.config.RequireDigit && !digit:
+		return &PasswordPolicyError{PasswordMissingDigit, "password must contain a digit"}
+	case p.config.RequireSymbol && !symbol:
+		return &PasswordPolicyError{PasswordMissingSymbol, "password must contain a symbol"}
+	}
+
+	// names shorter than 3 characters would refuse too many passwords by chance
+	lowered := strings.ToLower(password)
+	if name := strings.ToLower(strings.TrimSpace(username)); len(name) >= 3 && strings.Contains(lowered, name) {
+		return &PasswordPolicyError{PasswordContainsUsername, "password must not contain your username"}
+	}
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		local, _, _ := strings.Cut(email, "@")
+		if strings.Contains(lowered, email) || (len(local) >= 3 && strings.Contains(lowered, local)) {
+			return &PasswordPolicyError{PasswordContainsEmail, "password must not contain your email address"}
+		}
+	}
+
+	if p.isBreached(password) {
+		return &PasswordPolicyError{PasswordBreached, "this password has appeared in a data breach, choose another one"}
+	}
+	return nil
+}
+
+func (p *PasswordPolicy) isBreached(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	if p.breached != nil {
+		_, ok := p.breached[sum]
+		return ok
+	}
+	if p.rangeDir == "" {
+		return false
+	}
+	// only the file of the hash prefix is read
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		found, err := rangeContains(filepath.Join(p.rangeDir, name), suffix)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			// an unreadable list must not stop users from choosing passwords
+			log.Printf("error reading breached password range %s: %v", name, err)
+			return false
+		}
+		return found
+	}
+	return false
+}
+
+func rangeContains(path string, suffix string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+func loadBreachedPasswords(path string) (map[[sha1.Size]byte]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	breached := map[[sha1.Size]byte]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		var sum [sha1.Size]byte
+		hash, _, _ := strings.Cut(line, ":")
+		if decoded, err := hex.DecodeString(hash); err == nil && len(decoded) == sha1.Size {
+			copy(sum[:], decoded)
+		} else {
+			sum = sha1.Sum([]byte(line))
+		}
+		breached[sum] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return breached, nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	breached := "letmein123\r\n\n" + sha1Hex("Tr0ub4dor&3") + ":42\n"
+	if err := os.WriteFile(list, []byte(breached), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPasswordPolicy(PasswordPolicyConfig{
+		MinLength:         10,
+		MaxLength:         20,
+		RequireUppercase:  true,
+		RequireLowercase:  true,
+		RequireDigit:      true,
+		RequireSymbol:     true,
+		BreachedPasswords: list,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.BreachedCount() != 2 {
+		t.Errorf("%d breached passwords loaded, want 2", policy.BreachedCount())
+	}
+	// plain-text entries such as letmein123 would fail the character rules first
+	loose, err := NewPasswordPolicy(PasswordPolicyConfig{MinLength: 1, BreachedPasswords: list})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		scenario string
+		policy   *PasswordPolicy
+		password string
+		username string
+		email    string
+		want     string
+	}{
+		{"empty", policy, "", "jane", "jane@example.com", PasswordRequired},
+		{"too short", policy, "Ab1!", "", "", PasswordTooShort},
+		{"too long", policy, "Abcdefghij1!abcdefghij", "", "", PasswordTooLong},
+		{"length counts characters, not bytes", policy, "Äbcdéfghí1!", "", "", ""},
+		{"no uppercase", policy, "abcdefghij1!", "", "", PasswordMissingUppercase},
+		{"no lowercase", policy, "ABCDEFGHIJ1!", "", "", PasswordMissingLowercase},
+		{"no digit", policy, "Abcdefghij!!", "", "", PasswordMissingDigit},
+		{"no symbol", policy, "Abcdefghij12", "", "", PasswordMissingSymbol},
+		{"contains the username", policy, "My-JaneDoe-99", "janedoe", "", PasswordContainsUsername},
+		{"short usernames are ignored", policy, "Jo-Abcdefg-99", "jo", "", ""},
+		{"contains the email", policy, "Jo@Example.com1", "", "jo@example.com", PasswordContainsEmail},
+		{"contains the email's local part", policy, "Xyz-JANE.DOE-1", "", " Jane.Doe@example.com", PasswordContainsEmail},
+		{"breached by hash", policy, "Tr0ub4dor&3", "", "", PasswordBreached},
+		{"breached in plain text", loose, "letmein123", "", "", PasswordBreached},
+		{"accepted", policy, "Correct-Horse-9", "janedoe", "jane@example.com", ""},
+	}
+	for _, tt := range tests {
+		err := tt.policy.Validate(tt.password, tt.username, tt.email)
+		var policyErr *PasswordPolicyError
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: refused with %v", tt.scenario, err)
+		case tt.want != "" && !errors.As(err, &policyErr):
+			t.Errorf("%s: error %v, want code %s", tt.scenario, err, tt.want)
+		case tt.want != "" && policyErr.Code != tt.want:
+			t.Errorf("%s: code %s, want %s", tt.scenario, policyErr.Code, tt.want)
+		}
+	}
+}
+
+func TestPasswordPolicyRangeDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, password := range []string{"hunter2hunter2", "correcthorse"} {
+		hash := sha1Hex(password)
+		name := hash[:5]
+		if password == "correcthorse" {
+			name = strings.ToLower(name) + ".txt"
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("0000000000000000000000000000000000A:1\r\n"+strings.ToLower(hash[5:])+":3\r\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	policy, err := NewPasswordPolicy(PasswordPolicyConfig{BreachedPasswords: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.BreachedCount() != 0 {
+		t.Errorf("range directory counted %d passwords, want 0", policy.BreachedCount())
+	}
+	tests := []struct {
+		password string
+		breached bool
+	}{
+		{"hunter2hunter2", true},
+		{"correcthorse", true},
+		{"hunter2hunter3", false}, // no file for its prefix
+	}
+	for _, tt := range tests {
+		err := policy.Validate(tt.password, "", "")
+		var policyErr *PasswordPolicyError
+		if breached := errors.As(err, &policyErr) && policyErr.Code == PasswordBreached; breached != tt.breached {
+			t.Errorf("%s: breached = %v, want %v (%v)", tt.password, breached, tt.breached, err)
+		}
+	}
+}
+
+func TestNewPasswordPolicyConfig(t *testing.T) {
+	if _, err := NewPasswordPolicy(PasswordPolicyConfig{MinLength: 20, MaxLength: 10}); err == nil {
+		t.Error("a maximum below the minimum is accepted")
+	}
+	if _, err := NewPasswordPolicy(PasswordPolicyConfig{BreachedPasswords: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("a missing breached password list is accepted")
+	}
+	policy, err := NewPasswordPolicy(PasswordPolicyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.config.MinLength != 8 || policy.config.MaxLength != 128 {
+		t.Errorf("default lengths %d-%d, want 8-128", policy.config.MinLength, policy.config.MaxLength)
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+// sessionTouchInterval limits how often validating a token writes last seen
+const sessionTouchInterval = time.Minute
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session has been revoked")
+)
+
+// SessionService tracks where users are signed in. A session starts at login
+// and lives as long as its refresh token family; revoking it also revokes the
+// family, and access tokens of the session fail their next validation.
+type SessionService struct {
+	sessionRepo  repository.SessionRepository
+	tokenRepo    repository.TokenRepository
+	auditService *AuditService
+}
+
+func NewSessionService(sessionRepo repository.SessionRepository, tokenRepo repository.TokenRepository, auditService *AuditService) *SessionService {
+	return &SessionService{sessionRepo: sessionRepo, tokenRepo: tokenRepo, auditService: auditService}
+}
+
+// Start records a new session for a login
+func (s *SessionService) Start(userID int, familyID string, client model.ClientInfo) (*model.Session, error) {
+	session := &model.Session{
+		UserID:     userID,
+		FamilyID:   familyID,
+		Device:     deviceName(client.UserAgent),
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		LastSeenAt: time.Now().UTC(),
+	}
+	if err := s.sessionRepo.CreateSession(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Resume returns the session of a refresh token family being refreshed. Families
+// from before sessions were tracked get one.
+func (s *SessionService) Resume(userID int, familyID string, client model.ClientInfo) (*model.Session, error) {
+	session, err := s.sessionRepo.GetSessionByFamily(familyID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return s.Start(userID, familyID, client)
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+	if err := s.sessionRepo.TouchSession(session.ID, client.IP, time.Now().UTC(), 0); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Check fails when the session of an access token was revoked and otherwise
+// updates its last seen. Tokens issued before sessions were tracked have none.
+func (s *SessionService) Check(claims *utils.Claims) error {
+	if claims.SessionID == 0 {
+		return nil
+	}
+	active, err := s.sessionRepo.IsSessionActive(claims.SessionID, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return ErrSessionRevoked
+	}
+	// last seen is informational: a failed write does not reject the request
+	_ = s.sessionRepo.TouchSession(claims.SessionID, "", time.Now().UTC(), sessionTouchInterval)
+	return nil
+}
+
+// List returns the active sessions of a user, marking currentID as the current one
+func (s *SessionService) List(userID int, currentID int) ([]model.Session, error) {
+	sessions, err := s.sessionRepo.ListActiveSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// Revoke signs one of the actor's sessions out
+func (s *SessionService) Revoke(actor model.Actor, id int) error {
+	session, err := s.sessionRepo.GetSession(id, actor.UserID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+	if err := s.end(actor.UserID, id); err != nil {
+		return err
+	}
+	event := newAuditEvent(actor, model.AuditSessionRevoked, "session", strconv.Itoa(id))
+	event.Before = auditJSON(session)
+	s.auditService.Record(event)
+	return nil
+}
+
+// RevokeOthers signs out every session of the actor except currentID and
+// returns how many were revoked
+func (s *SessionService) RevokeOthers(actor model.Actor, currentID int) (int, error) {
+	families, err := s.sessionRepo.RevokeUserSessions(actor.UserID, currentID, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	if err := s.revokeFamilies(families); err != nil {
+		return 0, err
+	}
+	event := newAuditEvent(actor, model.AuditSessionRevoked, "user", strconv.Itoa(actor.UserID))
+	event.Metadata = auditJSON(map[string]int{"revoked": len(families), "keptSessionId": currentID})
+	s.auditService.Record(event)
+	return len(families), nil
+}
+
+// RevokeAll revokes every session of a user, with their refresh tokens
+func (s *SessionService) RevokeAll(userID int) error {
+	families, err := s.sessionRepo.RevokeUserSessions(userID, 0, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return s.revokeFamilies(families)
+}
+
+// EndFamily revokes the session of a refresh token family, if any
+func (s *SessionService) EndFamily(familyID string) error {
+	session, err := s.sessionRepo.GetSessionByFamily(familyID)
+	if err != nil || session == nil {
+		return err
+	}
+	_, err = s.sessionRepo.RevokeSession(session.ID, session.UserID, time.Now().UTC())
+	return err
+}
+
+// end revokes a session of the user with its refresh tokens
+func (s *SessionService) end(userID int, id int) error {
+	familyID, err := s.sessionRepo.RevokeSession(id, userID, time.Now().UTC())
+	if err != nil || familyID == "" {
+		return err
+	}
+	return s.tokenRepo.RevokeFamily(familyID, time.Now().UTC())
+}
+
+func (s *SessionService) revokeFamilies(families []string) error {
+	now := time.Now().UTC()
+	for _, familyID := range families {
+		if err := s.tokenRepo.RevokeFamily(familyID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deviceName describes a user agent as "Browser on OS", e.g. "Firefox on Linux"
+func deviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"PostmanRuntime/", "Postman"},
+		{"Go-http-client/", "Go HTTP client"},
+	}
+	systems := []struct{ token, name string }{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+	browser := ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	system := ""
+	for _, s := range systems {
+		if strings.Contains(userAgent, s.token) {
+			system = s.name
+			break
+		}
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	// an unknown client: its product token is the best name there is
+	name := strings.SplitN(userAgent, " ", 2)[0]
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+// fakeSessionRepository keeps sessions in memory
+type fakeSessionRepository struct {
+	repository.SessionRepository
+	sessions []model.Session
+}
+
+func (r *fakeSessionRepository) CreateSession(session *model.Session) error {
+	session.ID = len(r.sessions) + 1
+	session.CreatedAt = time.Now().UTC()
+	r.sessions = append(r.sessions, *session)
+	return nil
+}
+
+func (r *fakeSessionRepository) GetSession(id int, userID int) (*model.Session, error) {
+	if id < 1 || id > len(r.sessions) || r.sessions[id-1].UserID != userID {
+		return nil, nil
+	}
+	session := r.sessions[id-1]
+	return &session, nil
+}
+
+func (r *fakeSessionRepository) GetSessionByFamily(familyID string) (*model.Session, error) {
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID {
+			return &session, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeSessionRepository) ListActiveSessions(userID int) ([]model.Session, error) {
+	sessions := []model.Session{}
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeSessionRepository) IsSessionActive(id int, userID int) (bool, error) {
+	session, _ := r.GetSession(id, userID)
+	return session != nil && session.RevokedAt == nil, nil
+}
+
+func (r *fakeSessionRepository) TouchSession(id int, ip string, at time.Time, minInterval time.Duration) error {
+	session := &r.sessions[id-1]
+	if at.Sub(session.LastSeenAt) < minInterval {
+		return nil
+	}
+	session.LastSeenAt = at
+	if ip != "" {
+		session.IP = ip
+	}
+	return nil
+}
+
+func (r *fakeSessionRepository) RevokeSession(id int, userID int, at time.Time) (string, error) {
+	if active, _ := r.IsSessionActive(id, userID); !active {
+		return "", nil
+	}
+	r.sessions[id-1].RevokedAt = &at
+	return r.sessions[id-1].FamilyID, nil
+}
+
+func (r *fakeSessionRepository) RevokeUserSessions(userID int, exceptID int, at time.Time) ([]string, error) {
+	var families []string
+	for i := range r.sessions {
+		session := &r.sessions[i]
+		if session.UserID == userID && session.ID != exceptID && session.RevokedAt == nil {
+			session.RevokedAt = &at
+			families = append(families, session.FamilyID)
+		}
+	}
+	return families, nil
+}
+
+// activeSessions returns the IDs of the sessions not revoked
+func (r *fakeSessionRepository) activeSessions() []int {
+	var ids []int
+	for _, session := range r.sessions {
+		if session.RevokedAt == nil {
+			ids = append(ids, session.ID)
+		}
+	}
+	return ids
+}
+
+func TestSessionList(t *testing.T) {
+	service, userRepo, _ := newTestAuthService(model.User{ID: 7, Email: "jane@example.com", IsActive: true})
+	signIn(t, service, &userRepo.users[0], "laptop")
+	signIn(t, service, &userRepo.users[0], "phone")
+	service.sessions.Start(8, "someone-else", model.ClientInfo{})
+
+	sessions, err := service.sessions.List(7, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("listed %d sessions, want the user's 2", len(sessions))
+	}
+	if sessions[0].Current || !sessions[1].Current {
+		t.Errorf("current sessions %v and %v, want only session 2", sessions[0].Current, sessions[1].Current)
+	}
+	if sessions[0].Device != "curl" || sessions[0].IP != "203.0.113.7" {
+		t.Errorf("session %+v, want the device and IP of the login", sessions[0])
+	}
+}
+
+func TestSessionRevoke(t *testing.T) {
+	service, userRepo, tokenRepo := newTestAuthService(model.User{ID: 7, Email: "jane@example.com", IsActive: true})
+	laptop := signIn(t, service, &userRepo.users[0], "laptop")
+	phone := signIn(t, service, &userRepo.users[0], "phone")
+	sessionRepo := service.sessions.sessionRepo.(*fakeSessionRepository)
+	laptopClaims, _ := utils.ValidateJWT(laptop.Token)
+	phoneClaims, _ := utils.ValidateJWT(phone.Token)
+
+	if err := service.sessions.Revoke(model.Actor{UserID: 8}, phoneClaims.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("another user revoked the session: %v", err)
+	}
+	if err := service.sessions.Revoke(model.Actor{UserID: 7}, phoneClaims.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.sessions.Revoke(model.Actor{UserID: 7}, phoneClaims.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking a revoked session: %v", err)
+	}
+
+	// the access token fails its next check and the refresh token is gone
+	if err := service.CheckRevoked(phoneClaims); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("access token of a revoked session: %v", err)
+	}
+	if _, err := service.Refresh(phone.RefreshToken, model.ClientInfo{}); err == nil {
+		t.Error("a revoked session refreshed")
+	}
+	if err := service.CheckRevoked(laptopClaims); err != nil {
+		t.Errorf("the other session was signed out: %v", err)
+	}
+	if live := tokenRepo.liveFamilies(); len(live) != 1 || !live["laptop"] {
+		t.Errorf("live families %v, want only the laptop's", live)
+	}
+	if active := sessionRepo.activeSessions(); len(active) != 1 || active[0] != laptopClaims.SessionID {
+		t.Errorf("active sessions %v, want only the laptop's", active)
+	}
+}
+
+func TestSessionRevokeOthers(t *testing.T) {
+	service, userRepo, tokenRepo := newTestAuthService(model.User{ID: 7, Email: "jane@example.com", IsActive: true})
+	laptop := signIn(t, service, &userRepo.users[0], "laptop")
+	signIn(t, service, &userRepo.users[0], "phone")
+	signIn(t, service, &userRepo.users[0], "tablet")
+	claims, _ := utils.ValidateJWT(laptop.Token)
+
+	revoked, err := service.sessions.RevokeOthers(model.Actor{UserID: 7}, claims.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live := tokenRepo.liveFamilies(); revoked != 2 || len(live) != 1 || !live["laptop"] {
+		t.Errorf("revoked %d sessions leaving families %v, want 2 and the laptop's", revoked, live)
+	}
+	if _, err := service.Refresh(laptop.RefreshToken, model.ClientInfo{IP: "198.51.100.1"}); err != nil {
+		t.Errorf("the current session was signed out: %v", err)
+	}
+	if ip := service.sessions.sessionRepo.(*fakeSessionRepository).sessions[0].IP; ip != "198.51.100.1" {
+		t.Errorf("session IP %s after a refresh, want the new one", ip)
+	}
+}
+
+func TestLogoutEndsTheSession(t *testing.T) {
+	service, userRepo, _ := newTestAuthService(model.User{ID: 7, Email: "jane@example.com", IsActive: true})
+	laptop := signIn(t, service, &userRepo.users[0], "laptop")
+	stolen := signIn(t, service, &userRepo.users[0], "phone")
+	sessionRepo := service.sessions.sessionRepo.(*fakeSessionRepository)
+	claims, _ := utils.ValidateJWT(laptop.Token)
+
+	if err := service.Logout(claims, model.LogoutRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	// a replayed refresh token ends the session of its family
+	rotated, err := service.Refresh(stolen.RefreshToken, model.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.Refresh(stolen.RefreshToken, model.ClientInfo{})
+	if active := sessionRepo.activeSessions(); len(active) != 0 {
+		t.Errorf("active sessions %v, want none", active)
+	}
+	if phoneClaims, _ := utils.ValidateJWT(rotated.Token); service.CheckRevoked(phoneClaims) == nil {
+		t.Error("an access token of a family revoked for reuse still works")
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"", "Unknown device"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", "Firefox on Linux"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0", "Edge on Windows"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1", "Safari on iOS"},
+		{"curl/8.0", "curl"},
+		{"prothomuse-agent/1.2 (+https://example.com)", "prothomuse-agent/1.2"},
+	}
+	for _, tt := range tests {
+		if got := deviceName(tt.userAgent); got != tt.want {
+			t.Errorf("deviceName(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+const maxMaintenanceWindowMinutes = 7 * 24 * 60
+
+// Silencer decides whether notifications for an alert should be suppressed.
+// It returns a human readable reason when they should.
+type Silencer interface {
+	Silenced(labels model.AlertLabels, at time.Time) (string, bool)
+}
+
+type SilenceService struct {
+	silenceRepo repository.SilenceRepository
+}
+
+func NewSilenceService(silenceRepo repository.SilenceRepository) *SilenceService {
+	return &SilenceService{silenceRepo: silenceRepo}
+}
+
+func (s *SilenceService) CreateSilence(userID int, req model.CreateSilenceRequest) (*model.Silence, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if req.StartsAt.IsZero() {
+		req.StartsAt = time.Now()
+	}
+	if req.EndsAt.IsZero() || !req.EndsAt.After(req.StartsAt) {
+		return nil, errors.New("endsAt must be after startsAt")
+	}
+	if strings.TrimSpace(req.Comment) == "" {
+		return nil, errors.New("comment is required")
+	}
+	silence := &model.Silence{
+		ProjectID: req.ProjectID,
+		Route:     req.Route,
+		Rule:      req.Rule,
+		StartsAt:  req.StartsAt.UTC(),
+		EndsAt:    req.EndsAt.UTC(),
+		CreatedBy: userID,
+		Comment:   req.Comment,
+	}
+	if err := s.silenceRepo.CreateSilence(silence); err != nil {
+		return nil, err
+	}
+	return silence, nil
+}
+
+func (s *SilenceService) GetSilence(id int) (*model.Silence, error) {
+	return s.silenceRepo.GetSilenceByID(id)
+}
+
+func (s *SilenceService) ListSilences(projectID string, activeOnly bool) ([]model.Silence, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if activeOnly {
+		now := time.Now().UTC()
+		return s.silenceRepo.ListSilences(projectID, &now)
+	}
+	return s.silenceRepo.ListSilences(projectID, nil)
+}
+
+func (s *SilenceService) ExpireSilence(id int) error {
+	return s.silenceRepo.ExpireSilence(id, time.Now().UTC())
+}
+
+func (s *SilenceService) CreateMaintenanceWindow(userID int, req model.CreateMaintenanceWindowRequest) (*model.MaintenanceWindow, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if _, err := utils.ParseCron(req.Schedule); err != nil {
+		return nil, fmt.Errorf("invalid schedule: %w", err)
+	}
+	if req.DurationMinutes <= 0 || req.DurationMinutes > maxMaintenanceWindowMinutes {
+		return nil, fmt.Errorf("durationMinutes must be between 1 and %d", maxMaintenanceWindowMinutes)
+	}
+	window := &model.MaintenanceWindow{
+		ProjectID:       req.ProjectID,
+		Route:           req.Route,
+		Rule:            req.Rule,
+		Schedule:        req.Schedule,
+		DurationMinutes: req.DurationMinutes,
+		CreatedBy:       userID,
+		Comment:         req.Comment,
+	}
+	if err := s.silenceRepo.CreateMaintenanceWindow(window); err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+func (s *SilenceService) GetMaintenanceWindow(id int) (*model.MaintenanceWindow, error) {
+	return s.silenceRepo.GetMaintenanceWindowByID(id)
+}
+
+func (s *SilenceService) ListMaintenanceWindows(projectID string) ([]model.MaintenanceWindow, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	return s.silenceRepo.ListMaintenanceWindows(projectID)
+}
+
+func (s *SilenceService) DeleteMaintenanceWindow(id int) error {
+	return s.silenceRepo.DeleteMaintenanceWindow(id)
+}
+
+// Silenced implements Silencer. Active silences are checked first, then maintenance windows.
+// Lookup errors are logged and treated as "not silenced" so that alerts are never lost.
+func (s *SilenceService) Silenced(labels model.AlertLabels, at time.Time) (string, bool) {
+	at = at.UTC()
+	silences, err := s.silenceRepo.ListSilences(labels.ProjectID, &at)
+	if err != nil {
+		log.Println("error loading silences:", err)
+	}
+	for _, silence := range silences {
+		if labelMatches(silence.Route, labels.Route) && labelMatches(silence.Rule, labels.Rule) {
+			return fmt.Sprintf("silence #%d: %s", silence.ID, silence.Comment), true
+		}
+	}
+
+	windows, err := s.silenceRepo.ListMaintenanceWindows(labels.ProjectID)
+	if err != nil {
+		log.Println("error loading maintenance windows:", err)
+	}
+	for _, window := range windows {
+		if !labelMatches(window.Route, labels.Route) || !labelMatches(window.Rule, labels.Rule) {
+			continue
+		}
+		if maintenanceWindowOpen(&window, at) {
+			return fmt.Sprintf("maintenance window #%d: %s", window.ID, window.Comment), true
+		}
+	}
+	return "", false
+}
+
+// maintenanceWindowOpen reports whether the window opened within its duration before at.
+func maintenanceWindowOpen(window *model.MaintenanceWindow, at time.Time) bool {
+	schedule, err := utils.ParseCron(window.Schedule)
+	if err != nil {
+		log.Printf("invalid schedule on maintenance window %d: %v", window.ID, err)
+		return false
+	}
+	duration := time.Duration(window.DurationMinutes) * time.Minute
+	start := schedule.Prev(at, duration)
+	return !start.IsZero() && at.Before(start.Add(duration))
+}
+
+// labelMatches matches a label value against a matcher. An empty matcher matches
+// anything and a trailing "*" matches by prefix.
+func labelMatches(matcher string, value string) bool {
+	if matcher == "" {
+		return true
+	}
+	if strings.HasSuffix(matcher, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(matcher, "*"))
+	}
+	return matcher == value
+}
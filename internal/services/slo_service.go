@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+const defaultSLOWindowDays = 30
+
+// burnWindows are the windows burn rates are reported for.
+var burnWindows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+	{"3d", 3 * 24 * time.Hour},
+}
+
+// burnRateCondition is a multi-window burn-rate alert. It fires only when both
+// its long and its short window burn faster than the threshold, so it pages
+// quickly on a real incident and stops soon after it is over.
+type burnRateCondition struct {
+	name  string
+	long  string
+	short string
+	// budgetSpent is the share of the error budget the long window must use up
+	budgetSpent float64
+	severity    string
+}
+
+// burnRateConditions use the thresholds of the SRE workbook for a 30 day window,
+// 14.4x over 6h and 1x over 3d, expressed as budget shares so that they scale to
+// the window of each SLO
+var burnRateConditions = []burnRateCondition{
+	// 12% of the budget within six hours
+	{"fast_burn", "6h", "1h", 0.12, model.AlertSeverityCritical},
+	// 10% of the budget within three days
+	{"slow_burn", "3d", "6h", 0.10, model.AlertSeverityWarning},
+}
+
+// threshold is the burn rate at which the long window of the condition uses up
+// budgetSpent of a windowDays budget. It is at least 1: a slower burn does not
+// exhaust the budget within the window.
+func (c burnRateCondition) threshold(windowDays int) float64 {
+	var long time.Duration
+	for _, w := range burnWindows {
+		if w.name == c.long {
+			long = w.duration
+		}
+	}
+	window := time.Duration(windowDays) * 24 * time.Hour
+	return math.Max(1, c.budgetSpent*window.Hours()/long.Hours())
+}
+
+const budgetExhaustedCondition = "budget_exhausted"
+
+type SLOService struct {
+	sloRepo      repository.SLORepository
+	metricRepo   repository.MetricRepository
+	alertService *AlertService
+}
+
+func NewSLOService(sloRepo repository.SLORepository, metricRepo repository.MetricRepository, alertService *AlertService) *SLOService {
+	return &SLOService{sloRepo: sloRepo, metricRepo: metricRepo, alertService: alertService}
+}
+
+func (s *SLOService) CreateSLO(userID int, req model.CreateSLORequest) (*model.SLO, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New("name is required")
+	}
+	if req.Target <= 0 || req.Target >= 1 {
+		return nil, errors.New("target must be between 0 and 1, e.g. 0.995")
+	}
+	if req.LatencyThresholdMs < 0 {
+		return nil, errors.New("latencyThresholdMs must not be negative")
+	}
+	if req.WindowDays == 0 {
+		req.WindowDays = defaultSLOWindowDays
+	}
+	if req.WindowDays < 1 || req.WindowDays > 90 {
+		return nil, errors.New("windowDays must be between 1 and 90")
+	}
+	slo := &model.SLO{
+		ProjectID:          req.ProjectID,
+		Route:              req.Route,
+		Name:               req.Name,
+		Target:             req.Target,
+		LatencyThresholdMs: req.LatencyThresholdMs,
+		WindowDays:         req.WindowDays,
+		AlertsEnabled:      req.AlertsEnabled == nil || *req.AlertsEnabled,
+		CreatedBy:          userID,
+	}
+	if err := s.sloRepo.CreateSLO(slo); err != nil {
+		return nil, err
+	}
+	return slo, nil
+}
+
+func (s *SLOService) GetSLO(id int) (*model.SLO, error) {
+	return s.sloRepo.GetSLOByID(id)
+}
+
+func (s *SLOService) ListSLOs(projectID string) ([]model.SLO, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	return s.sloRepo.ListSLOs(projectID)
+}
+
+func (s *SLOService) DeleteSLO(id int) error {
+	slo, err := s.sloRepo.GetSLOByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.sloRepo.DeleteSLO(id); err != nil {
+		return err
+	}
+	for _, rule := range sloRules(slo) {
+		s.alertService.Resolve(model.AlertLabels{ProjectID: slo.ProjectID, Route: slo.Route, Rule: rule})
+	}
+	return nil
+}
+
+// Status computes compliance, remaining error budget, burn rates and the exhaustion forecast of an SLO at now.
+func (s *SLOService) Status(slo *model.SLO, now time.Time) (*model.SLOStatus, error) {
+	window := time.Duration(slo.WindowDays) * 24 * time.Hour
+	total, good, err := s.metricRepo.CountGoodRequests(slo.ProjectID, slo.Route, slo.LatencyThresholdMs, now.Add(-window), now)
+	if err != nil {
+		return nil, err
+	}
+	allowed := 1 - slo.Target
+	status := &model.SLOStatus{
+		SLO:                  slo,
+		EvaluatedAt:          now,
+		TotalRequests:        total,
+		GoodRequests:         good,
+		Compliance:           1,
+		ErrorBudgetRemaining: 1,
+		BurnRates:            map[string]float64{},
+		FiringConditions:     []string{},
+	}
+	if total > 0 {
+		status.Compliance = float64(good) / float64(total)
+		status.ErrorBudgetRemaining = 1 - (1-status.Compliance)/allowed
+	}
+
+	for _, w := range burnWindows {
+		total, good, err := s.metricRepo.CountGoodRequests(slo.ProjectID, slo.Route, slo.LatencyThresholdMs, now.Add(-w.duration), now)
+		if err != nil {
+			return nil, err
+		}
+		var rate float64
+		if total > 0 {
+			rate = (float64(total-good) / float64(total)) / allowed
+		}
+		status.BurnRates[w.name] = rate
+	}
+
+	if burn := status.BurnRates["6h"]; burn > 0 && status.ErrorBudgetRemaining > 0 {
+		left := time.Duration(status.ErrorBudgetRemaining / burn * float64(window))
+		forecast := now.Add(left)
+		status.ExhaustionForecast = &forecast
+	}
+
+	for _, c := range burnRateConditions {
+		threshold := c.threshold(slo.WindowDays)
+		if status.BurnRates[c.long] >= threshold && status.BurnRates[c.short] >= threshold {
+			status.FiringConditions = append(status.FiringConditions, c.name)
+		}
+	}
+	if total > 0 && status.ErrorBudgetRemaining <= 0 {
+		status.FiringConditions = append(status.FiringConditions, budgetExhaustedCondition)
+	}
+	return status, nil
+}
+
+// Start evaluates every SLO with alerts enabled each interval until ctx is cancelled.
+func (s *SLOService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.EvaluateAll(time.Now())
+	}
+}
+
+// EvaluateAll fires an "slo:<id>:<condition>" alert for each firing condition and resolves the others.
+func (s *SLOService) EvaluateAll(now time.Time) {
+	slos, err := s.sloRepo.ListAllSLOs()
+	if err != nil {
+		log.Println("error listing SLOs:", err)
+		return
+	}
+	for i := range slos {
+		slo := &slos[i]
+		if !slo.AlertsEnabled {
+			continue
+		}
+		status, err := s.Status(slo, now)
+		if err != nil {
+			log.Printf("error evaluating SLO %d: %v", slo.ID, err)
+			continue
+		}
+		firing := map[string]bool{}
+		for _, c := range status.FiringConditions {
+			firing[c] = true
+		}
+		for _, c := range burnRateConditions {
+			summary := fmt.Sprintf("SLO %q is burning its error budget at %.1fx (%s) and %.1fx (%s)",
+				slo.Name, status.BurnRates[c.long], c.long, status.BurnRates[c.short], c.short)
+			s.setCondition(slo, c.name, firing[c.name], c.severity, summary)
+		}
+		summary := fmt.Sprintf("SLO %q has used its whole error budget (compliance %.3f%%, target %.3f%%)",
+			slo.Name, status.Compliance*100, slo.Target*100)
+		s.setCondition(slo, budgetExhaustedCondition, firing[budgetExhaustedCondition], model.AlertSeverityCritical, summary)
+	}
+}
+
+func (s *SLOService) setCondition(slo *model.SLO, condition string, firing bool, severity string, summary string) {
+	labels := model.AlertLabels{ProjectID: slo.ProjectID, Route: slo.Route, Rule: sloRule(slo, condition)}
+	var err error
+	if firing {
+		_, err = s.alertService.Fire(labels, severity, summary)
+	} else {
+		_, err = s.alertService.Resolve(labels)
+	}
+	if err != nil {
+		log.Printf("error updating %s alert of SLO %d: %v", condition, slo.ID, err)
+	}
+}
+
+func sloRule(slo *model.SLO, condition string) string {
+	return fmt.Sprintf("slo:%d:%s", slo.ID, condition)
+}
+
+func sloRules(slo *model.SLO) []string {
+	rules := []string{sloRule(slo, budgetExhaustedCondition)}
+	for _, c := range burnRateConditions {
+		rules = append(rules, sloRule(slo, c.name))
+	}
+	return rules
+}
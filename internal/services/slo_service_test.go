@@ -0,0 +1,110 @@
+package services
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+// fakeMetricRepository answers CountGoodRequests from fixed counts per window
+// length, and HourlyRollups with steady hours before latest
+type fakeMetricRepository struct {
+	repository.MetricRepository
+	counts map[time.Duration][2]int64 // total, good
+	latest model.MetricRollup
+}
+
+func (r *fakeMetricRepository) CountGoodRequests(projectID string, route string, latencyThresholdMs int64, from time.Time, to time.Time) (int64, int64, error) {
+	c := r.counts[to.Sub(from)]
+	return c[0], c[1], nil
+}
+
+func TestBurnRateThresholdScalesWithTheWindow(t *testing.T) {
+	tests := []struct {
+		windowDays int
+		fast, slow float64
+	}{
+		{30, 14.4, 1},
+		{7, 3.36, 1}, // a slow burn below 1x would never exhaust the budget
+		{90, 43.2, 3},
+	}
+	for _, tt := range tests {
+		for _, c := range burnRateConditions {
+			want := tt.fast
+			if c.name == "slow_burn" {
+				want = tt.slow
+			}
+			if got := c.threshold(tt.windowDays); math.Abs(got-want) > 1e-9 {
+				t.Errorf("%s over %d days: threshold %v, want %v", c.name, tt.windowDays, got, want)
+			}
+		}
+	}
+}
+
+func TestSLOStatus(t *testing.T) {
+	const day = 24 * time.Hour
+	now := time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)
+	slo := &model.SLO{ProjectID: "shop", Target: 0.99, WindowDays: 30}
+	tests := []struct {
+		scenario   string
+		counts     map[time.Duration][2]int64
+		compliance float64
+		remaining  float64
+		forecast   time.Duration // from now, 0 for none
+		firing     []string
+	}{
+		{
+			scenario:   "no traffic",
+			counts:     map[time.Duration][2]int64{},
+			compliance: 1,
+			remaining:  1,
+			firing:     []string{},
+		},
+		{
+			scenario: "incident burning fast",
+			counts: map[time.Duration][2]int64{
+				30 * day:      {100000, 99500},
+				time.Hour:     {1000, 800},
+				6 * time.Hour: {6000, 5100},
+				3 * day:       {20000, 19500},
+			},
+			compliance: 0.995,
+			remaining:  0.5,
+			forecast:   day, // half the budget left at 15x
+			firing:     []string{"fast_burn", "slow_burn"},
+		},
+		{
+			scenario: "budget used up by an old incident",
+			counts: map[time.Duration][2]int64{
+				30 * day: {100, 90},
+			},
+			compliance: 0.9,
+			remaining:  -9,
+			firing:     []string{budgetExhaustedCondition},
+		},
+	}
+	for _, tt := range tests {
+		service := NewSLOService(nil, &fakeMetricRepository{counts: tt.counts}, nil)
+		status, err := service.Status(slo, now)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.scenario, err)
+		}
+		if math.Abs(status.Compliance-tt.compliance) > 1e-9 || math.Abs(status.ErrorBudgetRemaining-tt.remaining) > 1e-9 {
+			t.Errorf("%s: compliance %v and budget %v, want %v and %v",
+				tt.scenario, status.Compliance, status.ErrorBudgetRemaining, tt.compliance, tt.remaining)
+		}
+		switch {
+		case tt.forecast == 0 && status.ExhaustionForecast != nil:
+			t.Errorf("%s: forecast %s, want none", tt.scenario, status.ExhaustionForecast)
+		case tt.forecast != 0 && (status.ExhaustionForecast == nil || status.ExhaustionForecast.Sub(now).Round(time.Minute) != tt.forecast):
+			t.Errorf("%s: forecast %v, want %s from now", tt.scenario, status.ExhaustionForecast, tt.forecast)
+		}
+		if !slices.Equal(status.FiringConditions, tt.firing) {
+			t.Errorf("%s: firing %v, want %v", tt.scenario, status.FiringConditions, tt.firing)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+const (
+	statusPageDays = 90
+	// resolved messages stay visible for a week
+	statusMessageRetention = 7 * 24 * time.Hour
+	// public pages are served from a short cache so anonymous traffic does not hit the metrics table
+	statusPageCacheTTL = time.Minute
+)
+
+var statusSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+// statusRank orders statuses from best to worst
+var statusRank = map[string]int{
+	model.StatusOperational:   0,
+	model.StatusMaintenance:   1,
+	model.StatusDegraded:      2,
+	model.StatusPartialOutage: 3,
+	model.StatusMajorOutage:   4,
+}
+
+type cachedStatusPage struct {
+	page    *model.PublicStatusPage
+	expires time.Time
+}
+
+// StatusPageService manages public status pages and renders their public view
+// from stored traffic, uptime check results and firing alerts.
+type StatusPageService struct {
+	statusRepo   repository.StatusPageRepository
+	metricRepo   repository.MetricRepository
+	uptimeRepo   repository.UptimeRepository
+	alertService *AlertService
+
+	mu    sync.Mutex
+	cache map[string]cachedStatusPage
+}
+
+func NewStatusPageService(statusRepo repository.StatusPageRepository, metricRepo repository.MetricRepository, uptimeRepo repository.UptimeRepository, alertService *AlertService) *StatusPageService {
+	return &StatusPageService{
+		statusRepo:   statusRepo,
+		metricRepo:   metricRepo,
+		uptimeRepo:   uptimeRepo,
+		alertService: alertService,
+		cache:        make(map[string]cachedStatusPage),
+	}
+}
+
+func (s *StatusPageService) CreatePage(userID int, req model.SaveStatusPageRequest) (*model.StatusPage, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if err := s.validatePage(0, &req); err != nil {
+		return nil, err
+	}
+	page := &model.StatusPage{
+		ProjectID:   req.ProjectID,
+		Slug:        req.Slug,
+		Title:       req.Title,
+		Description: req.Description,
+		Enabled:     req.Enabled,
+		CreatedBy:   userID,
+	}
+	if err := s.statusRepo.CreatePage(page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// UpdatePage changes the slug, texts and visibility of a page. The project cannot change.
+func (s *StatusPageService) UpdatePage(id int, req model.SaveStatusPageRequest) (*model.StatusPage, error) {
+	page, err := s.statusRepo.GetPageByID(id)
+	if err != nil {
+		return nil, errors.New("status page not found")
+	}
+	if err := s.validatePage(id, &req); err != nil {
+		return nil, err
+	}
+	oldSlug := page.Slug
+	page.Slug = req.Slug
+	page.Title = req.Title
+	page.Description = req.Description
+	page.Enabled = req.Enabled
+	if err := s.statusRepo.UpdatePage(page); err != nil {
+		return nil, err
+	}
+	s.invalidate(oldSlug)
+	return page, nil
+}
+
+func (s *StatusPageService) validatePage(id int, req *model.SaveStatusPageRequest) error {
+	req.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	if !statusSlug.MatchString(req.Slug) {
+		return errors.New("slug must be 2-63 lowercase letters, digits or dashes")
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		return errors.New("title is required")
+	}
+	if existing, err := s.statusRepo.GetPageBySlug(req.Slug); err == nil && existing.ID != id {
+		return errors.New("slug is already taken")
+	}
+	return nil
+}
+
+func (s *StatusPageService) GetPage(id int) (*model.StatusPage, error) {
+	return s.statusRepo.GetPageByID(id)
+}
+
+func (s *StatusPageService) ListPages(projectID string) ([]model.StatusPage, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	return s.statusRepo.ListPages(projectID)
+}
+
+func (s *StatusPageService) DeletePage(id int) error {
+	page, err := s.statusRepo.GetPageByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.statusRepo.DeletePage(id); err != nil {
+		return err
+	}
+	s.invalidate(page.Slug)
+	return nil
+}
+
+// AddComponent maps a component to either a route of the project or one of its uptime checks.
+func (s *StatusPageService) AddComponent(pageID int, req model.CreateStatusComponentRequest) (*model.StatusComponent, error) {
+	page, err := s.statusRepo.GetPageByID(pageID)
+	if err != nil {
+		return nil, errors.New("status page not found")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New("name is required")
+	}
+	if (req.Route == "") == (req.CheckID == nil) {
+		return nil, errors.New("exactly one of route or checkId is required")
+	}
+	if req.CheckID != nil {
+		check, err := s.uptimeRepo.GetCheckByID(*req.CheckID)
+		if err != nil || check.ProjectID != page.ProjectID {
+			return nil, errors.New("uptime check not found in this project")
+		}
+	}
+	component := &model.StatusComponent{
+		PageID:      pageID,
+		Name:        req.Name,
+		Description: req.Description,
+		Route:       req.Route,
+		CheckID:     req.CheckID,
+		Position:    req.Position,
+	}
+	if err := s.statusRepo.CreateComponent(component); err != nil {
+		return nil, err
+	}
+	s.invalidate(page.Slug)
+	return component, nil
+}
+
+func (s *StatusPageService) ListComponents(pageID int) ([]model.StatusComponent, error) {
+	return s.statusRepo.ListComponents(pageID)
+}
+
+func (s *StatusPageService) RemoveComponent(pageID int, id int) error {
+	page, err := s.statusRepo.GetPageByID(pageID)
+	if err != nil {
+		return err
+	}
+	if err := s.statusRepo.DeleteComponent(pageID, id); err != nil {
+		return err
+	}
+	s.invalidate(page.Slug)
+	return nil
+}
+
+func (s *StatusPageService) PostMessage(pageID int, userID int, req model.CreateStatusMessageRequest) (*model.StatusMessage, error) {
+	page, err := s.statusRepo.GetPageByID(pageID)
+	if err != nil {
+		return nil, errors.New("status page not found")
+	}
+	if _, ok := statusRank[req.Status]; !ok {
+		return nil, errors.New("status must be operational, maintenance, degraded, partial_outage or major_outage")
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		return nil, errors.New("title is required")
+	}
+	message := &model.StatusMessage{
+		PageID:    pageID,
+		Status:    req.Status,
+		Title:     req.Title,
+		Body:      req.Body,
+		CreatedBy: userID,
+	}
+	if err := s.statusRepo.CreateMessage(message); err != nil {
+		return nil, err
+	}
+	s.invalidate(page.Slug)
+	return message, nil
+}
+
+func (s *StatusPageService) ListMessages(pageID int) ([]model.StatusMessage, error) {
+	return s.statusRepo.ListMessages(pageID, time.Now().UTC().Add(-statusMessageRetention))
+}
+
+func (s *StatusPageService) ResolveMessage(pageID int, id int) error {
+	page, err := s.statusRepo.GetPageByID(pageID)
+	if err != nil {
+		return err
+	}
+	if err := s.statusRepo.ResolveMessage(pageID, id, time.Now().UTC()); err != nil {
+		return err
+	}
+	s.invalidate(page.Slug)
+	return nil
+}
+
+func (s *StatusPageService) invalidate(slug string) {
+	s.mu.Lock()
+	delete(s.cache, slug)
+	s.mu.Unlock()
+}
+
+// PublicPage returns the anonymous view of an enabled page. Disabled and unknown
+// pages give the same error so that the existence of a page is not revealed.
+func (s *StatusPageService) PublicPage(slug string) (*model.PublicStatusPage, error) {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	cached, ok := s.cache[slug]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.page, nil
+	}
+
+	page, err := s.statusRepo.GetPageBySlug(slug)
+	if err != nil || !page.Enabled {
+		return nil, errors.New("status page not found")
+	}
+	public, err := s.buildPublicPage(page, now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cache[slug] = cachedStatusPage{page: public, expires: now.Add(statusPageCacheTTL)}
+	s.mu.Unlock()
+	return public, nil
+}
+
+func (s *StatusPageService) buildPublicPage(page *model.StatusPage, now time.Time) (*model.PublicStatusPage, error) {
+	components, err := s.statusRepo.ListComponents(page.ID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.statusRepo.ListMessages(page.ID, now.Add(-statusMessageRetention))
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := s.alertService.ListAlerts(page.ProjectID, model.AlertStateFiring)
+	if err != nil {
+		return nil, err
+	}
+
+	today := now.Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -(statusPageDays - 1))
+	to := today.AddDate(0, 0, 1)
+
+	public := &model.PublicStatusPage{
+		Slug:        page.Slug,
+		Title:       page.Title,
+		Description: page.Description,
+		Status:      model.StatusOperational,
+		Components:  []model.PublicComponent{},
+		Incidents:   []model.PublicIncident{},
+		Messages:    messages,
+		GeneratedAt: now,
+	}
+	for _, component := range components {
+		var days []model.DailyAvailability
+		if component.CheckID != nil {
+			days, err = s.uptimeRepo.DailyAvailability(*component.CheckID, from, to)
+		} else {
+			days, err = s.metricRepo.DailyAvailability(page.ProjectID, component.Route, from, to)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not compute uptime of %q: %w", component.Name, err)
+		}
+		pc := model.PublicComponent{
+			Name:        component.Name,
+			Description: component.Description,
+			Status:      model.StatusOperational,
+		}
+		pc.Days, pc.UptimePercent = uptimeDays(days, from, statusPageDays)
+
+		for _, alert := range alerts {
+			if !componentAffected(component, alert) {
+				continue
+			}
+			status := model.StatusDegraded
+			if alert.Severity == model.AlertSeverityCritical {
+				status = model.StatusMajorOutage
+			}
+			pc.Status = worseStatus(pc.Status, status)
+			stage := "investigating"
+			if alert.AcknowledgedAt != nil {
+				stage = "identified"
+			}
+			public.Incidents = append(public.Incidents, model.PublicIncident{
+				Component: component.Name,
+				Status:    status,
+				Stage:     stage,
+				StartedAt: alert.FiredAt,
+			})
+		}
+		public.Status = worseStatus(public.Status, pc.Status)
+		public.Components = append(public.Components, pc)
+	}
+	for _, message := range messages {
+		if message.ResolvedAt == nil {
+			public.Status = worseStatus(public.Status, message.Status)
+		}
+	}
+	return public, nil
+}
+
+// componentAffected reports whether a firing alert concerns a component: alerts on
+// the component's route, or the failure alert of its uptime check.
+func componentAffected(component model.StatusComponent, alert model.Alert) bool {
+	if component.CheckID != nil {
+		return alert.Rule == "uptime:"+strconv.Itoa(*component.CheckID)
+	}
+	return alert.Route == component.Route
+}
+
+// uptimeDays lays out one entry per day starting at from, and the uptime over all days with data.
+func uptimeDays(counts []model.DailyAvailability, from time.Time, n int) ([]model.UptimeDay, *float64) {
+	byDay := make(map[string]model.DailyAvailability, len(counts))
+	for _, c := range counts {
+		byDay[c.Day.Format("2006-01-02")] = c
+	}
+	days := make([]model.UptimeDay, 0, n)
+	var total, good int64
+	for i := 0; i < n; i++ {
+		date := from.AddDate(0, 0, i).Format("2006-01-02")
+		day := model.UptimeDay{Date: date, Status: "no_data"}
+		if c, ok := byDay[date]; ok && c.Total > 0 {
+			percent := uptimePercent(c.Good, c.Total)
+			day.UptimePercent = &percent
+			day.Status = dayStatus(percent)
+			total += c.Total
+			good += c.Good
+		}
+		days = append(days, day)
+	}
+	if total == 0 {
+		return days, nil
+	}
+	overall := uptimePercent(good, total)
+	return days, &overall
+}
+
+// uptimePercent is rounded to three decimals, which is all a status page shows
+func uptimePercent(good int64, total int64) float64 {
+	return float64(good*100000/total) / 1000
+}
+
+func dayStatus(percent float64) string {
+	switch {
+	case percent >= 99.9:
+		return model.StatusOperational
+	case percent >= 99:
+		return model.StatusDegraded
+	case percent >= 95:
+		return model.StatusPartialOutage
+	default:
+		return model.StatusMajorOutage
+	}
+}
+
+func worseStatus(a string, b string) string {
+	if statusRank[b] > statusRank[a] {
+		return b
+	}
+	return a
+}
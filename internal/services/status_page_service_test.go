@@ -0,0 +1,214 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+// fakeStatusPageRepository keeps pages, components and messages in memory
+type fakeStatusPageRepository struct {
+	repository.StatusPageRepository
+	pages      map[int]model.StatusPage
+	components []model.StatusComponent
+	messages   []model.StatusMessage
+}
+
+func (r *fakeStatusPageRepository) CreatePage(page *model.StatusPage) error {
+	page.ID = len(r.pages) + 1
+	r.pages[page.ID] = *page
+	return nil
+}
+
+func (r *fakeStatusPageRepository) UpdatePage(page *model.StatusPage) error {
+	r.pages[page.ID] = *page
+	return nil
+}
+
+func (r *fakeStatusPageRepository) GetPageByID(id int) (*model.StatusPage, error) {
+	page, ok := r.pages[id]
+	if !ok {
+		return nil, errors.New("status page not found")
+	}
+	return &page, nil
+}
+
+func (r *fakeStatusPageRepository) GetPageBySlug(slug string) (*model.StatusPage, error) {
+	for _, page := range r.pages {
+		if page.Slug == slug {
+			return &page, nil
+		}
+	}
+	return nil, errors.New("status page not found")
+}
+
+func (r *fakeStatusPageRepository) CreateComponent(component *model.StatusComponent) error {
+	component.ID = len(r.components) + 1
+	r.components = append(r.components, *component)
+	return nil
+}
+
+func (r *fakeStatusPageRepository) ListComponents(pageID int) ([]model.StatusComponent, error) {
+	components := []model.StatusComponent{}
+	for _, c := range r.components {
+		if c.PageID == pageID {
+			components = append(components, c)
+		}
+	}
+	return components, nil
+}
+
+func (r *fakeStatusPageRepository) CreateMessage(message *model.StatusMessage) error {
+	message.ID = len(r.messages) + 1
+	r.messages = append(r.messages, *message)
+	return nil
+}
+
+func (r *fakeStatusPageRepository) ListMessages(pageID int, resolvedSince time.Time) ([]model.StatusMessage, error) {
+	messages := []model.StatusMessage{}
+	for _, m := range r.messages {
+		if m.PageID == pageID && (m.ResolvedAt == nil || !m.ResolvedAt.Before(resolvedSince)) {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (r *fakeStatusPageRepository) ResolveMessage(pageID int, id int, at time.Time) error {
+	r.messages[id-1].ResolvedAt = &at
+	return nil
+}
+
+// fakeAvailabilityRepository answers DailyAvailability with the same days for every route
+type fakeAvailabilityRepository struct {
+	repository.MetricRepository
+	days []model.DailyAvailability
+}
+
+func (r *fakeAvailabilityRepository) DailyAvailability(projectID string, route string, from time.Time, to time.Time) ([]model.DailyAvailability, error) {
+	return r.days, nil
+}
+
+func newTestStatusPageService() (*StatusPageService, *fakeStatusPageRepository, *fakeAlertRepository, *fakeAvailabilityRepository) {
+	statusRepo := &fakeStatusPageRepository{pages: map[int]model.StatusPage{}}
+	alertRepo := &fakeAlertRepository{}
+	metricRepo := &fakeAvailabilityRepository{}
+	service := NewStatusPageService(statusRepo, metricRepo, nil, NewAlertService(alertRepo, nil, nil, &fakeNotifier{}))
+	return service, statusRepo, alertRepo, metricRepo
+}
+
+func TestStatusPageIsOnlyPublicWhenEnabled(t *testing.T) {
+	service, _, _, _ := newTestStatusPageService()
+	page, err := service.CreatePage(7, model.SaveStatusPageRequest{ProjectID: "shop", Slug: " Shop-Status ", Title: "Shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Slug != "shop-status" || page.Enabled {
+		t.Fatalf("page %+v, want slug shop-status and disabled by default", page)
+	}
+
+	// a disabled page looks exactly like one that does not exist
+	_, disabledErr := service.PublicPage("shop-status")
+	_, unknownErr := service.PublicPage("nothing-here")
+	if disabledErr == nil || unknownErr == nil || disabledErr.Error() != unknownErr.Error() {
+		t.Errorf("disabled page: %v, unknown page: %v, want the same error", disabledErr, unknownErr)
+	}
+
+	save := model.SaveStatusPageRequest{Slug: "shop-status", Title: "Shop", Enabled: true}
+	if _, err := service.UpdatePage(page.ID, save); err != nil {
+		t.Fatal(err)
+	}
+	public, err := service.PublicPage("shop-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(public)
+	if strings.Contains(string(body), "shop\"") || strings.Contains(string(body), "projectId") {
+		t.Errorf("public page reveals the project: %s", body)
+	}
+
+	// disabling takes effect at once, not when the cached view expires
+	save.Enabled = false
+	if _, err := service.UpdatePage(page.ID, save); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.PublicPage("shop-status"); err == nil {
+		t.Error("page still public after it was disabled")
+	}
+}
+
+func TestStatusPageSlugs(t *testing.T) {
+	service, _, _, _ := newTestStatusPageService()
+	if _, err := service.CreatePage(7, model.SaveStatusPageRequest{ProjectID: "shop", Slug: "shop", Title: "Shop"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		scenario string
+		slug     string
+	}{
+		{"taken", "SHOP"},
+		{"too short", "s"},
+		{"leading dash", "-shop"},
+		{"path characters", "shop/admin"},
+	}
+	for _, tt := range tests {
+		if _, err := service.CreatePage(8, model.SaveStatusPageRequest{ProjectID: "blog", Slug: tt.slug, Title: "Blog"}); err == nil {
+			t.Errorf("%s: slug %q accepted", tt.scenario, tt.slug)
+		}
+	}
+}
+
+func TestStatusPagePublicView(t *testing.T) {
+	service, _, alertRepo, metricRepo := newTestStatusPageService()
+	page, _ := service.CreatePage(7, model.SaveStatusPageRequest{ProjectID: "shop", Slug: "shop", Title: "Shop", Enabled: true})
+	service.AddComponent(page.ID, model.CreateStatusComponentRequest{Name: "Checkout", Route: "/checkout"})
+	service.AddComponent(page.ID, model.CreateStatusComponentRequest{Name: "Search", Route: "/search"})
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	metricRepo.days = []model.DailyAvailability{
+		{Day: today, Total: 1000, Good: 995},
+		{Day: today.AddDate(0, 0, -1), Total: 1000, Good: 1000},
+	}
+	alert, _ := NewAlertService(alertRepo, nil, nil, &fakeNotifier{}).Fire(model.AlertLabels{ProjectID: "shop", Route: "/checkout", Rule: "uptime:1"}, model.AlertSeverityCritical, "checkout down")
+	// alerts of other projects do not show
+	NewAlertService(alertRepo, nil, nil, &fakeNotifier{}).Fire(model.AlertLabels{ProjectID: "blog", Route: "/search", Rule: "latency"}, model.AlertSeverityCritical, "")
+
+	public, err := service.PublicPage("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if public.Status != model.StatusMajorOutage || len(public.Components) != 2 {
+		t.Fatalf("page %s with %d components, want a major outage on 2", public.Status, len(public.Components))
+	}
+	checkout, search := public.Components[0], public.Components[1]
+	if checkout.Status != model.StatusMajorOutage || search.Status != model.StatusOperational {
+		t.Errorf("components %s and %s, want major_outage and operational", checkout.Status, search.Status)
+	}
+	if len(checkout.Days) != statusPageDays || checkout.Days[statusPageDays-1].Status != model.StatusDegraded || checkout.Days[0].Status != "no_data" {
+		t.Errorf("%d days, today %+v, first %+v", len(checkout.Days), checkout.Days[statusPageDays-1], checkout.Days[0])
+	}
+	if checkout.UptimePercent == nil || *checkout.UptimePercent != 99.75 {
+		t.Errorf("uptime %v, want 99.75", checkout.UptimePercent)
+	}
+	if len(public.Incidents) != 1 || public.Incidents[0].Stage != "investigating" || public.Incidents[0].Component != "Checkout" {
+		t.Errorf("incidents %+v, want checkout investigating", public.Incidents)
+	}
+
+	// the view is cached; changes made through the service invalidate it
+	now := time.Now()
+	alert.AcknowledgedAt = &now
+	if cached, _ := service.PublicPage("shop"); cached.Incidents[0].Stage != "investigating" {
+		t.Error("public page was rebuilt within its cache time")
+	}
+	if _, err := service.PostMessage(page.ID, 7, model.CreateStatusMessageRequest{Status: model.StatusMaintenance, Title: "Database upgrade"}); err != nil {
+		t.Fatal(err)
+	}
+	public, _ = service.PublicPage("shop")
+	if public.Incidents[0].Stage != "identified" || len(public.Messages) != 1 {
+		t.Errorf("after a message: incidents %+v and %d messages", public.Incidents, len(public.Messages))
+	}
+}
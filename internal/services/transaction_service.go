@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+	"prothomuse-server/internal/utils"
+)
+
+const maxTransactionSteps = 20
+
+var transactionVariable = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// TransactionService manages multi-step transaction checks and runs them.
+// It is a ProbeSource for the CheckScheduler, next to the UptimeService.
+type TransactionService struct {
+	transactionRepo repository.TransactionRepository
+	metricRepo      repository.MetricRepository
+	alertService    *AlertService
+	client          *http.Client
+}
+
+// NewTransactionService creates the transaction service. Each run gets a copy of
+// client with its own cookie jar; when client is nil a default one is used.
+func NewTransactionService(transactionRepo repository.TransactionRepository, metricRepo repository.MetricRepository, alertService *AlertService, client *http.Client) *TransactionService {
+	if client == nil {
+		client = newProbeClient()
+	}
+	return &TransactionService{
+		transactionRepo: transactionRepo,
+		metricRepo:      metricRepo,
+		alertService:    alertService,
+		client:          client,
+	}
+}
+
+func (s *TransactionService) CreateCheck(userID int, req model.CreateTransactionCheckRequest) (*model.TransactionCheck, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(req.Steps) == 0 || len(req.Steps) > maxTransactionSteps {
+		return nil, fmt.Errorf("a transaction needs between 1 and %d steps", maxTransactionSteps)
+	}
+	for i := range req.Steps {
+		if err := normalizeStep(&req.Steps[i], i); err != nil {
+			return nil, err
+		}
+	}
+	if req.IntervalSeconds == 0 {
+		req.IntervalSeconds = 5 * defaultCheckInterval
+	}
+	if req.IntervalSeconds < minCheckInterval {
+		return nil, fmt.Errorf("intervalSeconds must be at least %d", minCheckInterval)
+	}
+	if req.TimeoutSeconds == 0 {
+		req.TimeoutSeconds = defaultCheckTimeout
+	}
+	if req.TimeoutSeconds < 1 || req.TimeoutSeconds > maxCheckTimeout {
+		return nil, fmt.Errorf("timeoutSeconds must be between 1 and %d", maxCheckTimeout)
+	}
+	check := &model.TransactionCheck{
+		ProjectID:       req.ProjectID,
+		Name:            req.Name,
+		Steps:           req.Steps,
+		IntervalSeconds: req.IntervalSeconds,
+		TimeoutSeconds:  req.TimeoutSeconds,
+		Enabled:         true,
+		CreatedBy:       userID,
+	}
+	if err := s.transactionRepo.CreateCheck(check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+func normalizeStep(step *model.TransactionStep, i int) error {
+	if step.Name == "" {
+		step.Name = "step " + strconv.Itoa(i+1)
+	}
+	step.Method = strings.ToUpper(step.Method)
+	if step.Method == "" {
+		step.Method = http.MethodGet
+	}
+	// the URL may start with a variable, e.g. a base URL extracted by an earlier step;
+	// it is checked again once expanded
+	if !strings.HasPrefix(step.URL, "http://") && !strings.HasPrefix(step.URL, "https://") && !strings.HasPrefix(step.URL, "{{") {
+		return fmt.Errorf("%s: url must be an absolute http or https URL", step.Name)
+	}
+	if step.ExpectStatus == 0 {
+		step.ExpectStatus = http.StatusOK
+	}
+	if step.ExpectStatus < 100 || step.ExpectStatus > 599 {
+		return fmt.Errorf("%s: expectStatus must be a valid HTTP status code", step.Name)
+	}
+	if step.MaxLatencyMs < 0 {
+		return fmt.Errorf("%s: maxLatencyMs must not be negative", step.Name)
+	}
+	return nil
+}
+
+func (s *TransactionService) GetCheck(id int) (*model.TransactionCheck, error) {
+	return s.transactionRepo.GetCheckByID(id)
+}
+
+func (s *TransactionService) ListChecks(projectID string) ([]model.TransactionCheck, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	return s.transactionRepo.ListChecks(projectID)
+}
+
+func (s *TransactionService) DeleteCheck(id int) error {
+	check, err := s.transactionRepo.GetCheckByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.transactionRepo.DeleteCheck(id); err != nil {
+		return err
+	}
+	s.alertService.Resolve(transactionAlertLabels(check))
+	return nil
+}
+
+func (s *TransactionService) ListRuns(checkID int, limit int) ([]model.TransactionRun, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	return s.transactionRepo.ListRuns(checkID, limit)
+}
+
+// Probes implements ProbeSource
+func (s *TransactionService) Probes() ([]Probe, error) {
+	checks, err := s.transactionRepo.ListEnabledChecks()
+	if err != nil {
+		return nil, err
+	}
+	probes := make([]Probe, 0, len(checks))
+	for i := range checks {
+		probes = append(probes, &transactionProbe{service: s, check: checks[i]})
+	}
+	return probes, nil
+}
+
+type transactionProbe struct {
+	service *TransactionService
+	check   model.TransactionCheck
+}
+
+func (p *transactionProbe) Key() string {
+	return "transaction:" + strconv.Itoa(p.check.ID)
+}
+
+func (p *transactionProbe) Interval() time.Duration {
+	return time.Duration(p.check.IntervalSeconds) * time.Second
+}
+
+func (p *transactionProbe) Run(ctx context.Context) {
+	p.service.RunCheck(ctx, &p.check)
+}
+
+// RunCheck executes the check once, stores the run and a metric per executed step
+// under the project's synthetic project, and updates the check's alert.
+func (s *TransactionService) RunCheck(ctx context.Context, check *model.TransactionCheck) *model.TransactionRun {
+	run := s.execute(ctx, check)
+	if err := s.transactionRepo.SaveRun(run); err != nil {
+		log.Printf("error saving run of transaction check %d: %v", check.ID, err)
+	}
+
+	started := run.StartedAt
+	for _, step := range run.Steps {
+		metric := &model.Metric{
+			ProjectID:    model.SyntheticProjectID(check.ProjectID),
+			Route:        check.Name + " / " + step.Name,
+			Method:       step.Method,
+			StatusCode:   step.StatusCode,
+			ResponseTime: step.DurationMs,
+			Timestamp:    started.UnixMilli(),
+		}
+		if err := s.metricRepo.SaveMetric(metric); err != nil {
+			log.Printf("error saving metric of transaction check %d: %v", check.ID, err)
+		}
+		started = started.Add(time.Duration(step.DurationMs) * time.Millisecond)
+	}
+
+	labels := transactionAlertLabels(check)
+	if run.Success {
+		if _, err := s.alertService.Resolve(labels); err != nil {
+			log.Printf("error resolving alert of transaction check %d: %v", check.ID, err)
+		}
+	} else {
+		summary := fmt.Sprintf("transaction %q failed at %s: %s", check.Name, run.Steps[len(run.Steps)-1].Name, run.Error)
+		if _, err := s.alertService.Fire(labels, model.AlertSeverityCritical, summary); err != nil {
+			log.Printf("error firing alert of transaction check %d: %v", check.ID, err)
+		}
+	}
+	return run
+}
+
+// execute runs the steps in order and stops at the first failing one.
+func (s *TransactionService) execute(ctx context.Context, check *model.TransactionCheck) *model.TransactionRun {
+	run := &model.TransactionRun{
+		CheckID:   check.ID,
+		ProjectID: check.ProjectID,
+		Success:   true,
+		Steps:     []model.StepResult{},
+		StartedAt: time.Now().UTC(),
+	}
+	client := *s.client
+	client.Jar, _ = cookiejar.New(nil)
+	vars := map[string]string{}
+
+	for i := range check.Steps {
+		result, err := s.executeStep(ctx, &client, &check.Steps[i], vars, time.Duration(check.TimeoutSeconds)*time.Second)
+		run.Steps = append(run.Steps, *result)
+		run.DurationMs += result.DurationMs
+		if err != nil {
+			failed := i
+			run.Success = false
+			run.FailedStep = &failed
+			run.Error = err.Error()
+			break
+		}
+	}
+	return run
+}
+
+func (s *TransactionService) executeStep(ctx context.Context, client *http.Client, step *model.TransactionStep, vars map[string]string, timeout time.Duration) (*model.StepResult, error) {
+	expand := func(in string) (string, error) {
+		var missing string
+		out := transactionVariable.ReplaceAllStringFunc(in, func(m string) string {
+			name := transactionVariable.FindStringSubmatch(m)[1]
+			v, ok := vars[name]
+			if !ok {
+				missing = name
+			}
+			return v
+		})
+		if missing != "" {
+			return "", fmt.Errorf("variable %q is not defined", missing)
+		}
+		return out, nil
+	}
+	fail := func(result *model.StepResult, err error) (*model.StepResult, error) {
+		result.Error = err.Error()
+		return result, err
+	}
+
+	result := &model.StepResult{Name: step.Name, Method: step.Method, URL: step.URL}
+	rawURL, err := expand(step.URL)
+	if err != nil {
+		return fail(result, err)
+	}
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fail(result, fmt.Errorf("%q is not an absolute http or https URL", rawURL))
+	}
+	result.URL = rawURL
+	body, err := expand(step.Body)
+	if err != nil {
+		return fail(result, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, step.Method, rawURL, strings.NewReader(body))
+	if err != nil {
+		return fail(result, err)
+	}
+	req.Header.Set("User-Agent", "Prothomuse-Synthetic/1.0")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range step.Headers {
+		v, err := expand(value)
+		if err != nil {
+			return fail(result, err)
+		}
+		req.Header.Set(name, v)
+	}
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.DurationMs = time.Since(started).Milliseconds()
+		return fail(result, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxCheckBodyBytes))
+	result.DurationMs = time.Since(started).Milliseconds()
+	result.StatusCode = resp.StatusCode
+	if err != nil {
+		return fail(result, fmt.Errorf("reading body: %w", err))
+	}
+
+	if resp.StatusCode != step.ExpectStatus {
+		return fail(result, fmt.Errorf("expected status %d, got %d", step.ExpectStatus, resp.StatusCode))
+	}
+	if step.MaxLatencyMs > 0 && result.DurationMs > step.MaxLatencyMs {
+		return fail(result, fmt.Errorf("took %dms, more than %dms", result.DurationMs, step.MaxLatencyMs))
+	}
+	if step.BodyContains != "" {
+		want, err := expand(step.BodyContains)
+		if err != nil {
+			return fail(result, err)
+		}
+		if !strings.Contains(string(respBody), want) {
+			return fail(result, fmt.Errorf("body does not contain %q", want))
+		}
+	}
+	for name, path := range step.Extract {
+		v, err := utils.ExtractJSONPath(respBody, path)
+		if err != nil {
+			return fail(result, fmt.Errorf("extracting %s: %w", name, err))
+		}
+		vars[name] = v
+	}
+	result.Success = true
+	return result, nil
+}
+
+func transactionAlertLabels(check *model.TransactionCheck) model.AlertLabels {
+	return model.AlertLabels{ProjectID: check.ProjectID, Rule: "transaction:" + strconv.Itoa(check.ID)}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"prothomuse-server/internal/model"
+)
+
+// shopServer signs in with a session cookie and a bearer token, which the
+// orders endpoint both requires
+func shopServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("login sent Content-Type %q", r.Header.Get("Content-Type"))
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+		w.Write([]byte(`{"data":{"token":"t1","user":{"id":42}}}`))
+	})
+	mux.HandleFunc("GET /users/{id}/orders", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "s1" || r.Header.Get("Authorization") != "Bearer t1" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"orders":[{"id":"order-` + r.PathValue("id") + `"}]}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestTransactionExecute(t *testing.T) {
+	server := shopServer(t)
+	defer server.Close()
+
+	login := model.TransactionStep{
+		Name:    "login",
+		Method:  http.MethodPost,
+		URL:     server.URL + "/login",
+		Body:    `{"user":"synthetic"}`,
+		Extract: map[string]string{"token": "data.token", "userId": "data.user.id"},
+	}
+	orders := model.TransactionStep{
+		Name:         "orders",
+		Method:       http.MethodGet,
+		URL:          server.URL + "/users/{{userId}}/orders",
+		Headers:      map[string]string{"Authorization": "Bearer {{ token }}"},
+		BodyContains: "order-{{userId}}",
+	}
+	tests := []struct {
+		scenario   string
+		steps      []model.TransactionStep
+		failedStep int // -1 when the run succeeds
+		err        string
+	}{
+		{"login then orders", []model.TransactionStep{login, orders}, -1, ""},
+		{"orders without login", []model.TransactionStep{orders}, 0, `variable "userId" is not defined`},
+		{"request without the token", []model.TransactionStep{login, {Name: "orders", Method: http.MethodGet, URL: server.URL + "/users/42/orders"}, orders}, 1, "expected status 200, got 401"},
+		{"missing extraction", []model.TransactionStep{{Name: "login", Method: http.MethodPost, URL: server.URL + "/login", Body: "{}", Extract: map[string]string{"token": "data.jwt"}}}, 0, `extracting token`},
+	}
+	service := NewTransactionService(nil, nil, nil, nil)
+	for _, tt := range tests {
+		for i := range tt.steps {
+			if tt.steps[i].ExpectStatus == 0 {
+				tt.steps[i].ExpectStatus = http.StatusOK
+			}
+		}
+		run := service.execute(context.Background(), &model.TransactionCheck{ID: 1, ProjectID: "shop", Steps: tt.steps, TimeoutSeconds: 5})
+		if tt.failedStep < 0 {
+			if !run.Success || run.FailedStep != nil || len(run.Steps) != len(tt.steps) {
+				t.Errorf("%s: run failed: %+v", tt.scenario, run)
+			}
+			continue
+		}
+		if run.Success || run.FailedStep == nil || *run.FailedStep != tt.failedStep || !strings.Contains(run.Error, tt.err) {
+			t.Errorf("%s: run %+v, want step %d to fail with %q", tt.scenario, run, tt.failedStep, tt.err)
+			continue
+		}
+		if len(run.Steps) != tt.failedStep+1 {
+			t.Errorf("%s: %d steps ran, want none after the failing one", tt.scenario, len(run.Steps))
+		}
+	}
+}
+
+func TestNormalizeStep(t *testing.T) {
+	step := model.TransactionStep{URL: "{{baseUrl}}/health", Method: "post"}
+	if err := normalizeStep(&step, 2); err != nil {
+		t.Fatal(err)
+	}
+	if step.Name != "step 3" || step.Method != http.MethodPost || step.ExpectStatus != http.StatusOK {
+		t.Errorf("normalized step %+v", step)
+	}
+	for _, invalid := range []model.TransactionStep{
+		{URL: "ftp://example.com"},
+		{URL: "/relative"},
+		{URL: "https://example.com", ExpectStatus: 700},
+		{URL: "https://example.com", MaxLatencyMs: -1},
+	} {
+		if err := normalizeStep(&invalid, 0); err == nil {
+			t.Errorf("normalizeStep accepted %+v", invalid)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+const (
+	minCheckInterval        = 10
+	defaultCheckInterval    = 60
+	defaultCheckTimeout     = 10
+	maxCheckTimeout         = 60
+	maxCheckBodyBytes       = 1 << 20
+	defaultFailureThreshold = 1
+	defaultCertExpiryDays   = 14
+)
+
+// UptimeService manages uptime checks and probes them. It is a ProbeSource for the CheckScheduler.
+type UptimeService struct {
+	uptimeRepo   repository.UptimeRepository
+	alertService *AlertService
+	client       *http.Client
+
+	mu       sync.Mutex
+	failures map[int]int
+}
+
+// NewUptimeService creates the uptime service. client is used for every probe;
+// when nil a client that does not share cookies or follow more than 5 redirects is used.
+func NewUptimeService(uptimeRepo repository.UptimeRepository, alertService *AlertService, client *http.Client) *UptimeService {
+	if client == nil {
+		client = newProbeClient()
+	}
+	return &UptimeService{
+		uptimeRepo:   uptimeRepo,
+		alertService: alertService,
+		client:       client,
+		failures:     map[int]int{},
+	}
+}
+
+func newProbeClient() *http.Client {
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			return nil
+		},
+	}
+}
+
+func (s *UptimeService) CreateCheck(userID int, req model.CreateUptimeCheckRequest) (*model.UptimeCheck, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("url must be an absolute http or https URL")
+	}
+	if req.Name == "" {
+		req.Name = u.Host
+	}
+	req.Method = strings.ToUpper(req.Method)
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead && req.Method != http.MethodPost {
+		return nil, errors.New("method must be GET, HEAD or POST")
+	}
+	if req.ExpectedStatus == 0 {
+		req.ExpectedStatus = http.StatusOK
+	}
+	if req.ExpectedStatus < 100 || req.ExpectedStatus > 599 {
+		return nil, errors.New("expectedStatus must be a valid HTTP status code")
+	}
+	if req.IntervalSeconds == 0 {
+		req.IntervalSeconds = defaultCheckInterval
+	}
+	if req.IntervalSeconds < minCheckInterval {
+		return nil, fmt.Errorf("intervalSeconds must be at least %d", minCheckInterval)
+	}
+	if req.TimeoutSeconds == 0 {
+		req.TimeoutSeconds = defaultCheckTimeout
+	}
+	if req.TimeoutSeconds < 1 || req.TimeoutSeconds > maxCheckTimeout || req.TimeoutSeconds > req.IntervalSeconds {
+		return nil, fmt.Errorf("timeoutSeconds must be between 1 and %d and not exceed the interval", maxCheckTimeout)
+	}
+	if req.FailureThreshold == 0 {
+		req.FailureThreshold = defaultFailureThreshold
+	}
+	if req.FailureThreshold < 1 {
+		return nil, errors.New("failureThreshold must be at least 1")
+	}
+	if req.CertExpiryDays == 0 {
+		req.CertExpiryDays = defaultCertExpiryDays
+	}
+	if req.CertExpiryDays < 1 || req.CertExpiryDays > 365 {
+		return nil, errors.New("certExpiryDays must be between 1 and 365")
+	}
+	check := &model.UptimeCheck{
+		ProjectID:        req.ProjectID,
+		Name:             req.Name,
+		URL:              req.URL,
+		Method:           req.Method,
+		ExpectedStatus:   req.ExpectedStatus,
+		BodyContains:     req.BodyContains,
+		IntervalSeconds:  req.IntervalSeconds,
+		TimeoutSeconds:   req.TimeoutSeconds,
+		FailureThreshold: req.FailureThreshold,
+		CertExpiryDays:   req.CertExpiryDays,
+		Enabled:          true,
+		CreatedBy:        userID,
+	}
+	if err := s.uptimeRepo.CreateCheck(check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+func (s *UptimeService) GetCheck(id int) (*model.UptimeCheck, error) {
+	return s.uptimeRepo.GetCheckByID(id)
+}
+
+func (s *UptimeService) ListChecks(projectID string) ([]model.UptimeCheck, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	return s.uptimeRepo.ListChecks(projectID)
+}
+
+func (s *UptimeService) DeleteCheck(id int) error {
+	check, err := s.uptimeRepo.GetCheckByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.uptimeRepo.DeleteCheck(id); err != nil {
+		return err
+	}
+	s.alertService.Resolve(uptimeAlertLabels(check))
+	s.alertService.Resolve(certAlertLabels(check, false))
+	s.alertService.Resolve(certAlertLabels(check, true))
+	return nil
+}
+
+func (s *UptimeService) ListResults(checkID int, limit int) ([]model.CheckResult, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	return s.uptimeRepo.ListResults(checkID, limit)
+}
+
+// Probes implements ProbeSource
+func (s *UptimeService) Probes() ([]Probe, error) {
+	checks, err := s.uptimeRepo.ListEnabledChecks()
+	if err != nil {
+		return nil, err
+	}
+	probes := make([]Probe, 0, len(checks))
+	for i := range checks {
+		probes = append(probes, &uptimeProbe{service: s, check: checks[i]})
+	}
+	return probes, nil
+}
+
+type uptimeProbe struct {
+	service *UptimeService
+	check   model.UptimeCheck
+}
+
+func (p *uptimeProbe) Key() string {
+	return "uptime:" + strconv.Itoa(p.check.ID)
+}
+
+func (p *uptimeProbe) Interval() time.Duration {
+	return time.Duration(p.check.IntervalSeconds) * time.Second
+}
+
+func (p *uptimeProbe) Run(ctx context.Context) {
+	p.service.RunCheck(ctx, &p.check)
+}
+
+// RunCheck probes the check once, stores the result and updates its alert.
+func (s *UptimeService) RunCheck(ctx context.Context, check *model.UptimeCheck) *model.CheckResult {
+	result, chain := s.probe(ctx, check)
+	if err := s.uptimeRepo.SaveResult(result); err != nil {
+		log.Printf("error saving result of uptime check %d: %v", check.ID, err)
+	}
+	if len(chain) > 0 {
+		s.recordCertificates(check, chain, time.Now().UTC())
+	}
+
+	s.mu.Lock()
+	if result.Success {
+		delete(s.failures, check.ID)
+	} else {
+		s.failures[check.ID]++
+	}
+	failures := s.failures[check.ID]
+	s.mu.Unlock()
+
+	labels := uptimeAlertLabels(check)
+	if result.Success {
+		if _, err := s.alertService.Resolve(labels); err != nil {
+			log.Printf("error resolving alert of uptime check %d: %v", check.ID, err)
+		}
+	} else if failures >= check.FailureThreshold {
+		summary := fmt.Sprintf("%s (%s %s) is down: %s", check.Name, check.Method, check.URL, result.Error)
+		if _, err := s.alertService.Fire(labels, model.AlertSeverityCritical, summary); err != nil {
+			log.Printf("error firing alert of uptime check %d: %v", check.ID, err)
+		}
+	}
+	return result
+}
+
+// probe performs the HTTP request of a check and judges the response. For HTTPS it
+// also returns the certificate chain the server presented, even when it did not verify.
+func (s *UptimeService) probe(ctx context.Context, check *model.UptimeCheck) (*model.CheckResult, []*x509.Certificate) {
+	started := time.Now()
+	result := &model.CheckResult{
+		CheckID:   check.ID,
+		ProjectID: check.ProjectID,
+		Route:     check.URL,
+		Method:    check.Method,
+		Timestamp: started.UnixMilli(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(check.TimeoutSeconds)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, check.Method, check.URL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	req.Header.Set("User-Agent", "Prothomuse-Uptime/1.0")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		result.ResponseTime = time.Since(started).Milliseconds()
+		result.Error = err.Error()
+		var verifyErr *tls.CertificateVerificationError
+		if errors.As(err, &verifyErr) {
+			return result, verifyErr.UnverifiedCertificates
+		}
+		return result, nil
+	}
+	defer resp.Body.Close()
+	var chain []*x509.Certificate
+	if resp.TLS != nil {
+		chain = resp.TLS.PeerCertificates
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCheckBodyBytes))
+	result.ResponseTime = time.Since(started).Milliseconds()
+	result.StatusCode = resp.StatusCode
+
+	switch {
+	case err != nil:
+		result.Error = "reading body: " + err.Error()
+	case resp.StatusCode != check.ExpectedStatus:
+		result.Error = fmt.Sprintf("expected status %d, got %d", check.ExpectedStatus, resp.StatusCode)
+	case check.BodyContains != "" && !strings.Contains(string(body), check.BodyContains):
+		result.Error = fmt.Sprintf("body does not contain %q", check.BodyContains)
+	default:
+		result.Success = true
+	}
+	return result, chain
+}
+
+// recordCertificates stores the chain of an HTTPS check and fires its "cert_expiry"
+// alert when any certificate in the chain expires within the check's CertExpiryDays,
+// or its "cert_expired" alert once one has expired.
+func (s *UptimeService) recordCertificates(check *model.UptimeCheck, chain []*x509.Certificate, now time.Time) {
+	host := check.URL
+	if u, err := url.Parse(check.URL); err == nil {
+		host = u.Hostname()
+	}
+	certs := make([]model.Certificate, 0, len(chain))
+	var soonest *model.Certificate
+	for i, c := range chain {
+		fingerprint := sha256.Sum256(c.Raw)
+		sans := append([]string{}, c.DNSNames...)
+		for _, ip := range c.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		certs = append(certs, model.Certificate{
+			CheckID:           check.ID,
+			ProjectID:         check.ProjectID,
+			Host:              host,
+			Position:          i,
+			Subject:           c.Subject.String(),
+			Issuer:            c.Issuer.String(),
+			SANs:              sans,
+			SerialNumber:      c.SerialNumber.Text(16),
+			FingerprintSHA256: hex.EncodeToString(fingerprint[:]),
+			NotBefore:         c.NotBefore.UTC(),
+			NotAfter:          c.NotAfter.UTC(),
+			LastSeenAt:        now,
+		})
+		if soonest == nil || c.NotAfter.Before(soonest.NotAfter) {
+			soonest = &certs[i]
+		}
+	}
+	if err := s.uptimeRepo.ReplaceCertificates(check.ID, certs); err != nil {
+		log.Printf("error saving certificates of uptime check %d: %v", check.ID, err)
+	}
+
+	days := daysRemaining(soonest.NotAfter, now)
+	expired := !now.Before(soonest.NotAfter)
+	if days >= check.CertExpiryDays {
+		s.resolveCertAlert(check, false)
+		s.resolveCertAlert(check, true)
+		return
+	}
+	// expiring and expired are separate alerts, so that a warning raised while
+	// the certificate was still valid turns critical once it has expired
+	severity := model.AlertSeverityWarning
+	summary := fmt.Sprintf("certificate %q for %s expires in %d days (%s)", soonest.Subject, host, days, soonest.NotAfter.Format(time.RFC3339))
+	if expired {
+		severity = model.AlertSeverityCritical
+		summary = fmt.Sprintf("certificate %q for %s expired on %s", soonest.Subject, host, soonest.NotAfter.Format(time.RFC3339))
+	}
+	if _, err := s.alertService.Fire(certAlertLabels(check, expired), severity, summary); err != nil {
+		log.Printf("error firing certificate alert of uptime check %d: %v", check.ID, err)
+	}
+	s.resolveCertAlert(check, !expired)
+}
+
+func (s *UptimeService) resolveCertAlert(check *model.UptimeCheck, expired bool) {
+	if _, err := s.alertService.Resolve(certAlertLabels(check, expired)); err != nil {
+		log.Printf("error resolving certificate alert of uptime check %d: %v", check.ID, err)
+	}
+}
+
+// ListCertificates returns the certificates seen by a project's HTTPS checks,
+// soonest expiry first. withinDays > 0 limits them to those expiring within that many days.
+func (s *UptimeService) ListCertificates(projectID string, withinDays int) ([]model.Certificate, error) {
+	if projectID == "" {
+		return nil, errors.New("projectId is required")
+	}
+	now := time.Now().UTC()
+	var before *time.Time
+	if withinDays > 0 {
+		t := now.AddDate(0, 0, withinDays)
+		before = &t
+	}
+	certs, err := s.uptimeRepo.ListCertificates(projectID, before)
+	if err != nil {
+		return nil, err
+	}
+	for i := range certs {
+		certs[i].DaysRemaining = daysRemaining(certs[i].NotAfter, now)
+	}
+	return certs, nil
+}
+
+// daysRemaining counts whole days until notAfter; it is negative once expired.
+func daysRemaining(notAfter time.Time, now time.Time) int {
+	d := notAfter.Sub(now)
+	if d < 0 {
+		return -int((-d).Hours()/24) - 1
+	}
+	return int(d.Hours() / 24)
+}
+
+func uptimeAlertLabels(check *model.UptimeCheck) model.AlertLabels {
+	return model.AlertLabels{ProjectID: check.ProjectID, Route: check.URL, Rule: "uptime:" + strconv.Itoa(check.ID)}
+}
+
+// certAlertLabels are the labels of the "cert_expiry" alert of a check, or of
+// its "cert_expired" alert once the certificate has expired
+func certAlertLabels(check *model.UptimeCheck, expired bool) model.AlertLabels {
+	rule := "cert_expiry:"
+	if expired {
+		rule = "cert_expired:"
+	}
+	return model.AlertLabels{ProjectID: check.ProjectID, Route: check.URL, Rule: rule + strconv.Itoa(check.ID)}
+}
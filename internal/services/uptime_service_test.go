@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"prothomuse-server/internal/model"
+	"prothomuse-server/internal/repository"
+)
+
+func TestUptimeProbe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != "Prothomuse-Uptime/1.0" {
+			t.Errorf("probe sent User-Agent %q", r.UserAgent())
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		scenario     string
+		path         string
+		status       int
+		bodyContains string
+		success      bool
+		err          string
+	}{
+		{"healthy", "/health", 200, `"ok"`, true, ""},
+		{"unexpected status", "/broken", 200, "", false, "expected status 200, got 503"},
+		{"expected error status", "/broken", 503, "", true, ""},
+		{"body without the keyword", "/health", 200, "degraded", false, `body does not contain "degraded"`},
+		{"timeout", "/slow", 200, "", false, "deadline exceeded"},
+		{"redirect loop", "/loop", 200, "", false, "stopped after 5 redirects"},
+	}
+	service := NewUptimeService(nil, nil, nil)
+	for _, tt := range tests {
+		check := &model.UptimeCheck{
+			ID:             1,
+			ProjectID:      "shop",
+			URL:            server.URL + tt.path,
+			Method:         http.MethodGet,
+			ExpectedStatus: tt.status,
+			BodyContains:   tt.bodyContains,
+			TimeoutSeconds: 1,
+		}
+		result, chain := service.probe(context.Background(), check)
+		if result.Success != tt.success {
+			t.Errorf("%s: success = %v (%s), want %v", tt.scenario, result.Success, result.Error, tt.success)
+		}
+		if !strings.Contains(result.Error, tt.err) || (tt.err == "" && result.Error != "") {
+			t.Errorf("%s: error %q, want %q", tt.scenario, result.Error, tt.err)
+		}
+		if result.ProjectID != "shop" || result.Route != check.URL || result.Timestamp == 0 {
+			t.Errorf("%s: result not labelled with the check: %+v", tt.scenario, result)
+		}
+		if chain != nil {
+			t.Errorf("%s: plain HTTP returned a certificate chain", tt.scenario)
+		}
+	}
+}
+
+func TestUptimeProbeReturnsTheCertificateChain(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	check := &model.UptimeCheck{URL: server.URL, Method: http.MethodGet, ExpectedStatus: 200, TimeoutSeconds: 5}
+
+	// the test certificate is not trusted by the default client, but its expiry
+	// is still worth recording
+	result, chain := NewUptimeService(nil, nil, nil).probe(context.Background(), check)
+	if result.Success || !strings.Contains(result.Error, "certificate") || len(chain) == 0 {
+		t.Errorf("untrusted certificate: success %v, error %q, %d certificates", result.Success, result.Error, len(chain))
+	}
+	result, chain = NewUptimeService(nil, nil, server.Client()).probe(context.Background(), check)
+	if !result.Success || len(chain) == 0 {
+		t.Errorf("trusted certificate: success %v, error %q, %d certificates", result.Success, result.Error, len(chain))
+	}
+}
+
+func TestDaysRemaining(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		notAfter time.Duration
+		want     int
+	}{
+		{36 * time.Hour, 1},
+		{23 * time.Hour, 0},
+		{0, 0},
+		{-time.Hour, -1},
+		{-25 * time.Hour, -2},
+	}
+	for _, tt := range tests {
+		if got := daysRemaining(now.Add(tt.notAfter), now); got != tt.want {
+			t.Errorf("daysRemaining(now%+v) = %d, want %d", tt.notAfter, got, tt.want)
+		}
+	}
+}
+
+// fakeUptimeRepository keeps the results saved and the certificates last stored
+type fakeUptimeRepository struct {
+	repository.UptimeRepository
+	results []model.CheckResult
+	certs   []model.Certificate
+}
+
+func (r *fakeUptimeRepository) SaveResult(result *model.CheckResult) error {
+	r.results = append(r.results, *result)
+	return nil
+}
+
+func (r *fakeUptimeRepository) ReplaceCertificates(checkID int, certs []model.Certificate) error {
+	r.certs = certs
+	return nil
+}
+
+func TestUptimeRunCheckAlertsAfterConsecutiveFailures(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	uptimeRepo := &fakeUptimeRepository{}
+	alertRepo := &fakeAlertRepository{}
+	service := NewUptimeService(uptimeRepo, NewAlertService(alertRepo, nil, nil, &fakeNotifier{}), nil)
+	check := &model.UptimeCheck{ID: 3, ProjectID: "shop", Name: "home", URL: server.URL, Method: http.MethodGet, ExpectedStatus: 200, TimeoutSeconds: 1, FailureThreshold: 2}
+
+	tests := []struct {
+		scenario string
+		healthy  bool
+		want     string
+	}{
+		{"first failure", false, ""},
+		{"second failure", false, model.AlertStateFiring},
+		{"still failing", false, model.AlertStateFiring},
+		{"recovered", true, model.AlertStateResolved},
+		// the count starts again after a success
+		{"failure after recovery", false, model.AlertStateResolved},
+	}
+	for _, tt := range tests {
+		healthy = tt.healthy
+		service.RunCheck(context.Background(), check)
+		state := ""
+		if len(alertRepo.alerts) > 0 {
+			state = alertRepo.alerts[len(alertRepo.alerts)-1].State
+		}
+		if state != tt.want {
+			t.Errorf("%s: alert %q, want %q", tt.scenario, state, tt.want)
+		}
+	}
+	if len(alertRepo.alerts) != 1 || alertRepo.alerts[0].Rule != "uptime:3" || alertRepo.alerts[0].Severity != model.AlertSeverityCritical {
+		t.Errorf("alerts %+v, want one critical uptime:3 alert", alertRepo.alerts)
+	}
+	if len(uptimeRepo.results) != len(tests) {
+		t.Errorf("%d results saved, want %d", len(uptimeRepo.results), len(tests))
+	}
+}
+
+func testCertificate(t *testing.T, notAfter time.Time) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "shop.example.com"},
+		DNSNames:     []string{"shop.example.com"},
+		NotBefore:    notAfter.AddDate(0, -3, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestRecordCertificatesEscalatesOnExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	check := &model.UptimeCheck{ID: 3, ProjectID: "shop", URL: "https://shop.example.com/health", CertExpiryDays: 14}
+	alertRepo := &fakeAlertRepository{}
+	uptimeRepo := &fakeUptimeRepository{}
+	service := NewUptimeService(uptimeRepo, NewAlertService(alertRepo, nil, nil, &fakeNotifier{}), nil)
+	open := func(expired bool) *model.Alert {
+		alert, _ := alertRepo.GetOpenAlert(certAlertLabels(check, expired))
+		return alert
+	}
+
+	steps := []struct {
+		scenario        string
+		expiresIn       time.Duration
+		expiring        bool
+		expired         bool
+		expiredSeverity string
+	}{
+		{"valid for two months", 60 * 24 * time.Hour, false, false, ""},
+		{"expires in five days", 5 * 24 * time.Hour, true, false, ""},
+		{"expired yesterday", -24 * time.Hour, false, true, model.AlertSeverityCritical},
+		{"renewed", 90 * 24 * time.Hour, false, false, ""},
+	}
+	for _, step := range steps {
+		service.recordCertificates(check, []*x509.Certificate{testCertificate(t, now.Add(step.expiresIn))}, now)
+		if got := open(false) != nil; got != step.expiring {
+			t.Errorf("%s: expiry alert open = %v, want %v", step.scenario, got, step.expiring)
+		}
+		if got := open(true) != nil; got != step.expired {
+			t.Errorf("%s: expired alert open = %v, want %v", step.scenario, got, step.expired)
+		}
+		if step.expired && open(true).Severity != step.expiredSeverity {
+			t.Errorf("%s: expired alert severity %q, want %q", step.scenario, open(true).Severity, step.expiredSeverity)
+		}
+		if len(uptimeRepo.certs) != 1 || uptimeRepo.certs[0].Host != "shop.example.com" || uptimeRepo.certs[0].SANs[0] != "shop.example.com" {
+			t.Errorf("%s: stored certificates %+v", step.scenario, uptimeRepo.certs)
+		}
+	}
+}
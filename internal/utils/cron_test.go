@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@fortnightly",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		expr string
+		from string
+		want string // "" for none
+	}{
+		{"*/15 * * * *", "2025-01-01 10:07", "2025-01-01 10:15"},
+		{"0 * * * *", "2025-01-01 10:00", "2025-01-01 11:00"}, // strictly after
+		{"5/20 * * * *", "2025-01-01 10:06", "2025-01-01 10:25"},
+		{"0 9 * * 1-5", "2025-01-03 10:00", "2025-01-06 09:00"}, // Friday to Monday
+		{"0 0 * * 7", "2025-01-01 00:00", "2025-01-05 00:00"},   // 7 is Sunday
+		{"0 0 13 * 5", "2025-01-01 00:00", "2025-01-03 00:00"},  // either day field matches
+		{"30 2 1,15 * *", "2025-01-02 00:00", "2025-01-15 02:30"},
+		{"@monthly", "2025-01-31 12:00", "2025-02-01 00:00"},
+		{"@yearly", "2024-06-01 00:00", "2025-01-01 00:00"},
+		{"0 0 29 2 *", "2025-03-01 00:00", "2028-02-29 00:00"},
+		{"0 0 30 2 *", "2025-01-01 00:00", ""},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		got := schedule.Next(at(tt.from))
+		if tt.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%q.Next(%s) = %s, want none", tt.expr, tt.from, got)
+			}
+			continue
+		}
+		if want := at(tt.want); !got.Equal(want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.expr, tt.from, got, want)
+		}
+	}
+}
+
+func TestCronPrev(t *testing.T) {
+	schedule, err := ParseCron("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 1, 2, 2, 0, 0, 0, time.UTC)
+	if got, want := schedule.Prev(now, 48*time.Hour), time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Prev = %s, want %s", got, want)
+	}
+	if got := schedule.Prev(now, time.Hour); !got.IsZero() {
+		t.Errorf("Prev outside the lookback = %s, want none", got)
+	}
+	firing := time.Date(2025, 1, 2, 3, 0, 30, 0, time.UTC)
+	if got, want := schedule.Prev(firing, time.Hour), time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Prev at a firing time = %s, want %s", got, want)
+	}
+}
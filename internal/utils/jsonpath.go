@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExtractJSONPath returns the value at a dotted path such as "data.items.0.id" in a
+// JSON document. Strings are returned as is; other values as their JSON text.
+func ExtractJSONPath(document []byte, path string) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(document, &value); err != nil {
+		return "", fmt.Errorf("response is not JSON: %w", err)
+	}
+	if path != "" && path != "." {
+		for _, key := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+			switch node := value.(type) {
+			case map[string]interface{}:
+				v, ok := node[key]
+				if !ok {
+					return "", fmt.Errorf("%s: key %q not found", path, key)
+				}
+				value = v
+			case []interface{}:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(node) {
+					return "", fmt.Errorf("%s: index %q out of range", path, key)
+				}
+				value = node[i]
+			default:
+				return "", fmt.Errorf("%s: cannot descend into %q", path, key)
+			}
+		}
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
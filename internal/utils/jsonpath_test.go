@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractJSONPath(t *testing.T) {
+	document := []byte(`{
+		"token": "abc123",
+		"data": {"items": [{"id": 7, "name": "first"}, {"id": 8, "tags": ["a", "b"]}]},
+		"ok": true,
+		"empty": null
+	}`)
+	tests := []struct {
+		path string
+		want string
+		err  string
+	}{
+		{"token", "abc123", ""},
+		{".token", "abc123", ""},
+		{"data.items.0.id", "7", ""},
+		{"data.items.1.tags.1", "b", ""},
+		{"data.items.1.tags", `["a","b"]`, ""},
+		{"data.items.0", `{"id":7,"name":"first"}`, ""},
+		{"ok", "true", ""},
+		{"empty", "null", ""},
+		{"data.missing", "", `key "missing" not found`},
+		{"data.items.2", "", `index "2" out of range`},
+		{"data.items.first", "", `index "first" out of range`},
+		{"token.length", "", `cannot descend into "length"`},
+	}
+	for _, tt := range tests {
+		got, err := ExtractJSONPath(document, tt.path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ExtractJSONPath(%q) error = %v, want %q", tt.path, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ExtractJSONPath(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestExtractJSONPathWholeDocument(t *testing.T) {
+	for _, path := range []string{"", "."} {
+		if got, err := ExtractJSONPath([]byte(`[1, 2]`), path); err != nil || got != "[1,2]" {
+			t.Errorf("ExtractJSONPath(%q) = %q, %v, want the whole document", path, got, err)
+		}
+	}
+	if _, err := ExtractJSONPath([]byte("<html>"), "token"); err == nil {
+		t.Error("a non-JSON response was accepted")
+	}
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is an asymmetric key identified by the RFC 7638 thumbprint of its public part.
+// private is only set for the key that signs new tokens.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	public  crypto.PublicKey
+	private crypto.PrivateKey
+}
+
+// JSONWebKey is the public part of a verification key as published in the JWKS.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+var (
+	currentKey       *signingKey
+	verificationKeys = map[string]*signingKey{}
+)
+
+// LoadSigningKeys switches token signing from HS256 to the RS256 or EdDSA private
+// key in signingKeyFile (PEM, PKCS#8 or PKCS#1). Tokens carry the key's kid.
+// verificationKeyFiles are extra public keys (or certificates, or retired private
+// keys) that are still accepted, so keys can be rotated without logging users out:
+// add the new key as a verification key everywhere, switch signing to it, and drop
+// the old one after the access token lifetime.
+func LoadSigningKeys(signingKeyFile string, verificationKeyFiles []string) error {
+	data, err := os.ReadFile(signingKeyFile)
+	if err != nil {
+		return err
+	}
+	signing, err := parseKeyPEM(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", signingKeyFile, err)
+	}
+	if signing.private == nil {
+		return fmt.Errorf("%s: signing key must be a private key", signingKeyFile)
+	}
+
+	keys := map[string]*signingKey{signing.kid: signing}
+	for _, file := range verificationKeyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		key, err := parseKeyPEM(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		key.private = nil
+		if _, ok := keys[key.kid]; !ok {
+			keys[key.kid] = key
+		}
+	}
+	currentKey = signing
+	verificationKeys = keys
+	return nil
+}
+
+// parseKeyPEM reads the first PEM block holding an RSA or Ed25519 private key,
+// public key or certificate.
+func parseKeyPEM(data []byte) (*signingKey, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no RSA or Ed25519 key found in PEM data")
+		}
+		var parsed interface{}
+		var err error
+		switch block.Type {
+		case "PRIVATE KEY":
+			parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PUBLIC KEY":
+			parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				parsed = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return newSigningKey(parsed)
+	}
+}
+
+func newSigningKey(parsed interface{}) (*signingKey, error) {
+	key := &signingKey{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.private, key.public, key.method = k, &k.PublicKey, jwt.SigningMethodRS256
+	case *rsa.PublicKey:
+		key.public, key.method = k, jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		key.private, key.public, key.method = k, k.Public(), jwt.SigningMethodEdDSA
+	case ed25519.PublicKey:
+		key.public, key.method = k, jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, only RSA and Ed25519 are supported", parsed)
+	}
+	if rsaKey, ok := key.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+	key.kid = thumbprint(key.jwk())
+	return key, nil
+}
+
+// jwk returns the public JWK without kid
+func (k *signingKey) jwk() JSONWebKey {
+	jwk := JSONWebKey{Use: "sig", Alg: k.method.Alg()}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	jwk.Kid = k.kid
+	return jwk
+}
+
+// thumbprint is the RFC 7638 SHA-256 thumbprint of a public JWK, so a key gets
+// the same kid on every server and across restarts.
+func thumbprint(jwk JSONWebKey) string {
+	var members string
+	if jwk.Kty == "RSA" {
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	} else {
+		members = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Crv, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// JWKS returns the public verification keys. It is empty while tokens are signed with HS256.
+func JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	if currentKey != nil {
+		set.Keys = append(set.Keys, currentKey.jwk())
+	}
+	for kid, key := range verificationKeys {
+		if currentKey == nil || kid != currentKey.kid {
+			set.Keys = append(set.Keys, key.jwk())
+		}
+	}
+	return set
+}
+
+// PublicKey parses a JWK published by another issuer, such as an OIDC provider.
+// RSA, EC (P-256, P-384, P-521) and Ed25519 keys are supported.
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("invalid EC point")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return key, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThumbprintMatchesRFC7638(t *testing.T) {
+	tests := []struct {
+		scenario string
+		jwk      JSONWebKey
+		want     string
+	}{
+		{
+			"RFC 7638 section 3.1 RSA key",
+			JSONWebKey{
+				Kty: "RSA",
+				E:   "AQAB",
+				N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+			},
+			"NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			"RFC 8037 appendix A.3 Ed25519 key",
+			JSONWebKey{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			"kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+	for _, tt := range tests {
+		// members outside the required ones do not change the thumbprint
+		tt.jwk.Use, tt.jwk.Alg, tt.jwk.Kid = "sig", "RS256", "ignored"
+		if got := thumbprint(tt.jwk); got != tt.want {
+			t.Errorf("%s: thumbprint %s, want %s", tt.scenario, got, tt.want)
+		}
+	}
+}
+
+// writeEd25519Key writes a new PKCS#8 Ed25519 private key to dir and returns its path and key
+func writeEd25519Key(t *testing.T, dir string, name string) (string, ed25519.PrivateKey) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, private
+}
+
+// useSigningKeys loads keys for one test and goes back to HS256 afterwards
+func useSigningKeys(t *testing.T, signingKeyFile string, verificationKeyFiles ...string) {
+	if err := LoadSigningKeys(signingKeyFile, verificationKeyFiles); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		currentKey = nil
+		verificationKeys = map[string]*signingKey{}
+	})
+}
+
+func TestJWKSPublishesTheVerificationKeys(t *testing.T) {
+	dir := t.TempDir()
+	current, _ := writeEd25519Key(t, dir, "current.pem")
+	retired, _ := writeEd25519Key(t, dir, "retired.pem")
+	if keys := JWKS().Keys; len(keys) != 0 {
+		t.Fatalf("JWKS lists %d keys while signing with HS256", len(keys))
+	}
+	useSigningKeys(t, current, retired, current)
+
+	keys := JWKS().Keys
+	if len(keys) != 2 {
+		t.Fatalf("JWKS lists %d keys, want the current and the retired one", len(keys))
+	}
+	first := keys[0]
+	if first.Kty != "OKP" || first.Crv != "Ed25519" || first.Alg != "EdDSA" || first.Use != "sig" || first.Kid != currentKey.kid {
+		t.Errorf("current key published as %+v", first)
+	}
+	if first.Kid != thumbprint(first) {
+		t.Errorf("kid %s is not the thumbprint of the key", first.Kid)
+	}
+	if keys[1].Kid == first.Kid || keys[1].Kid != thumbprint(keys[1]) {
+		t.Errorf("retired key published as %+v", keys[1])
+	}
+}
+
+func TestLoadSigningKeysRejects(t *testing.T) {
+	dir := t.TempDir()
+	_, private := writeEd25519Key(t, dir, "key.pem")
+	der, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := filepath.Join(dir, "public.pem")
+	os.WriteFile(public, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+	garbage := filepath.Join(dir, "garbage.pem")
+	os.WriteFile(garbage, []byte("not a key"), 0o600)
+
+	for _, file := range []string{public, garbage, filepath.Join(dir, "missing.pem")} {
+		if err := LoadSigningKeys(file, nil); err == nil {
+			t.Errorf("%s loaded as a signing key", filepath.Base(file))
+		}
+	}
+	if currentKey != nil {
+		t.Error("a rejected key was installed")
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"crypto/rand"
 	"errors"
 	"log"
 	"time"
@@ -8,32 +9,99 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("health_check") // Replace with your secret key
+// jwtSecret signs HS256 tokens when no asymmetric key is loaded (development
+// setups). Until SetJWTSecret is called it is random, so that nobody can forge
+// tokens with a well-known secret; tokens then stop working at restart.
+var jwtSecret = randomJWTSecret()
+
+func randomJWTSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("could not generate a JWT secret: " + err.Error())
+	}
+	return secret
+}
+
+// SetJWTSecret replaces the random HS256 secret
+func SetJWTSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
+
+// AccessTokenTTL is the lifetime of access tokens; clients renew them with a refresh token.
+const AccessTokenTTL = 15 * time.Minute
+
 type Claims struct {
-	UserID int    `json:"userId"`
-	Email  string `json:"email"`
+	UserID       int    `json:"userId"`
+	Email        string `json:"email"`
+	TokenVersion int    `json:"tokenVersion"`
+	SessionID    int    `json:"sid,omitempty"` // 0 for tokens issued before sessions were tracked
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID int, email string) (string, error) {
+// RevocationCheck returns an error when a signature-valid token has been revoked.
+type RevocationCheck func(claims *Claims) error
+
+var revocationCheck RevocationCheck
+
+// SetRevocationCheck installs the check ValidateJWT runs after verifying a token,
+// so every endpoint that validates tokens honours logout and revocation.
+func SetRevocationCheck(check RevocationCheck) {
+	revocationCheck = check
+}
+
+// GenerateJWT issues a short-lived access token with a unique jti, the user's
+// token version and the session it belongs to
+func GenerateJWT(userID int, email string, tokenVersion int, sessionID int) (string, error) {
+	jti, err := GenerateToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:       userID,
+		Email:        email,
+		TokenVersion: tokenVersion,
+		SessionID:    sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
+	if currentKey != nil {
+		token := jwt.NewWithClaims(currentKey.method, claims)
+		token.Header["kid"] = currentKey.kid
+		return token.SignedString(currentKey.private)
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	log.Println("token created not signed")
 	return token.SignedString(jwtSecret)
 }
 
+// verificationKey picks the key for a token: by kid once asymmetric keys are
+// loaded, the HS256 secret otherwise. The algorithm must match the key so a
+// public key can never be used as an HMAC secret.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if currentKey == nil {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtSecret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := verificationKeys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("signing method does not match key")
+	}
+	return key.public, nil
+}
+
 func ValidateJWT(tokenString string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
 	if err != nil {
 		log.Println("errror in compareing the jwt issue in token ")
 		return nil, err
@@ -42,5 +110,10 @@ func ValidateJWT(tokenString string) (*Claims, error) {
 		log.Println("token is not valid")
 		return nil, errors.New("invalid token")
 	}
+	if revocationCheck != nil {
+		if err := revocationCheck(claims); err != nil {
+			return nil, err
+		}
+	}
 	return claims, nil
 }
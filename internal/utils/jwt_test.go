@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaims() *Claims {
+	now := time.Now()
+	return &Claims{
+		UserID: 7,
+		Email:  "jane@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+// signed returns a token with the given method, kid and key, or fails the test
+func signed(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	token := jwt.NewWithClaims(method, testClaims())
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestValidateJWTWithHS256(t *testing.T) {
+	token, err := GenerateJWT(7, "jane@example.com", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != 7 || claims.TokenVersion != 2 || claims.SessionID != 3 || claims.ID == "" {
+		t.Errorf("claims %+v, want user 7, token version 2, session 3 and a jti", claims)
+	}
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	tests := []struct {
+		scenario string
+		token    string
+	}{
+		{"unsigned", signed(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType)},
+		{"another secret", signed(t, jwt.SigningMethodHS256, "", []byte("not the secret"))},
+		{"the former built-in secret", signed(t, jwt.SigningMethodHS256, "", []byte("health_check"))},
+		{"asymmetric key while none is loaded", signed(t, jwt.SigningMethodEdDSA, "", edKey)},
+		{"HS512", signed(t, jwt.SigningMethodHS512, "", jwtSecret)},
+		{"expired", func() string {
+			claims := testClaims()
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+			return s
+		}()},
+	}
+	for _, tt := range tests {
+		if _, err := ValidateJWT(tt.token); err == nil {
+			t.Errorf("%s: token accepted", tt.scenario)
+		}
+	}
+}
+
+func TestValidateJWTWithSigningKeys(t *testing.T) {
+	dir := t.TempDir()
+	current, currentPrivate := writeEd25519Key(t, dir, "current.pem")
+	retired, retiredPrivate := writeEd25519Key(t, dir, "retired.pem")
+	_, unknownPrivate := writeEd25519Key(t, dir, "unknown.pem")
+	hs256Token, err := GenerateJWT(7, "jane@example.com", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useSigningKeys(t, current, retired)
+	currentKid := currentKey.kid
+	var retiredKid string
+	for kid := range verificationKeys {
+		if kid != currentKid {
+			retiredKid = kid
+		}
+	}
+
+	token, err := GenerateJWT(7, "jane@example.com", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil || parsed.Method != jwt.SigningMethodEdDSA || parsed.Header["kid"] != currentKid {
+		t.Errorf("new token signed with %v and kid %v, want EdDSA and %s", parsed.Method, parsed.Header["kid"], currentKid)
+	}
+	if _, err := ValidateJWT(token); err != nil {
+		t.Errorf("token of the current key: %v", err)
+	}
+	if _, err := ValidateJWT(signed(t, jwt.SigningMethodEdDSA, retiredKid, retiredPrivate)); err != nil {
+		t.Errorf("token of a retired key still in the verification keys: %v", err)
+	}
+
+	publicPEM, _ := os.ReadFile(current)
+	currentPublic := []byte(currentPrivate.Public().(ed25519.PublicKey))
+	tests := []struct {
+		scenario string
+		token    string
+	}{
+		// the public key is published in the JWKS, so it must never work as an HMAC secret
+		{"HS256 signed with the public key", signed(t, jwt.SigningMethodHS256, currentKid, currentPublic)},
+		{"HS256 signed with the key file", signed(t, jwt.SigningMethodHS256, currentKid, publicPEM)},
+		{"HS256 with the development secret", hs256Token},
+		{"none", signed(t, jwt.SigningMethodNone, currentKid, jwt.UnsafeAllowNoneSignatureType)},
+		{"unknown kid", signed(t, jwt.SigningMethodEdDSA, "someone-else", unknownPrivate)},
+		{"unknown key under a known kid", signed(t, jwt.SigningMethodEdDSA, currentKid, unknownPrivate)},
+		{"no kid", signed(t, jwt.SigningMethodEdDSA, "", currentPrivate)},
+	}
+	for _, tt := range tests {
+		if _, err := ValidateJWT(tt.token); err == nil {
+			t.Errorf("%s: token accepted", tt.scenario)
+		}
+	}
+}
@@ -1,13 +1,161 @@
 package utils
+
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
+
+// Argon2Params are the argon2id costs of new password hashes. Every hash
+// records the parameters it was made with, so they can be raised at any time:
+// older hashes still verify and are upgraded on the next login.
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for argon2id
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var argon2Params = DefaultArgon2Params
+
+// SetArgon2Params sets the parameters of new password hashes. Zero fields keep their default.
+func SetArgon2Params(params Argon2Params) {
+	if params.Memory == 0 {
+		params.Memory = DefaultArgon2Params.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = DefaultArgon2Params.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = DefaultArgon2Params.Parallelism
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = DefaultArgon2Params.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = DefaultArgon2Params.KeyLength
+	}
+	argon2Params = params
+}
+
+// argon2Slots bounds how many argon2id hashes run at once: each takes Memory KiB
+// (64 MiB by default), so a burst of logins must not exhaust the server's memory
+var argon2Slots = make(chan struct{}, runtime.NumCPU())
+
+// SetArgon2Concurrency sets how many argon2id hashes may run at once. Zero or less keeps one per CPU.
+func SetArgon2Concurrency(n int) {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	argon2Slots = make(chan struct{}, n)
+}
+
+// argon2IDKey is argon2.IDKey, waiting for a free slot first
+func argon2IDKey(password, salt []byte, params Argon2Params, keyLength uint32) []byte {
+	slots := argon2Slots
+	slots <- struct{}{}
+	defer func() { <-slots }()
+	return argon2.IDKey(password, salt, params.Iterations, params.Memory, params.Parallelism, keyLength)
+}
+
+const argon2Prefix = "$argon2id$"
+
+// HashPassword hashes a password with argon2id in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
-}	
+	params := argon2Params
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2IDKey([]byte(password), salt, params, params.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPasswordHash verifies a password against an argon2id or a legacy bcrypt
+// hash. An empty hash (accounts without a password) matches nothing.
 func CheckPasswordHash(password, hash string) bool {
+	if hash == "" {
+		return false
+	}
+	if strings.HasPrefix(hash, argon2Prefix) {
+		params, salt, key, err := decodeArgon2Hash(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2IDKey([]byte(password), salt, params, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
 
+// PasswordNeedsRehash reports whether a hash is bcrypt or argon2id weaker than
+// the current parameters. It is false for an empty or unrecognised hash.
+func PasswordNeedsRehash(hash string) bool {
+	if hash == "" {
+		return false
+	}
+	if !strings.HasPrefix(hash, argon2Prefix) {
+		_, err := bcrypt.Cost([]byte(hash))
+		return err == nil
+	}
+	params, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false
+	}
+	current := argon2Params
+	return params.Memory < current.Memory ||
+		params.Iterations < current.Iterations ||
+		params.Parallelism < current.Parallelism ||
+		uint32(len(salt)) < current.SaltLength ||
+		uint32(len(key)) < current.KeyLength
+}
+
+func decodeArgon2Hash(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errors.New("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, errors.New("malformed argon2id parameters")
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, errors.New("malformed argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("malformed argon2id hash")
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheapArgon2Params keep the tests fast; the format and checks do not depend on the costs
+var cheapArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func useArgon2Params(t *testing.T, params Argon2Params) {
+	previous := argon2Params
+	SetArgon2Params(params)
+	t.Cleanup(func() { argon2Params = previous })
+}
+
+func TestHashPassword(t *testing.T) {
+	useArgon2Params(t, cheapArgon2Params)
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^\$argon2id\$v=19\$m=1024,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`).MatchString(hash) {
+		t.Errorf("hash %q is not a PHC argon2id string", hash)
+	}
+	if other, _ := HashPassword("correct horse battery staple"); other == hash {
+		t.Error("two hashes of the same password share a salt")
+	}
+	if !CheckPasswordHash("correct horse battery staple", hash) {
+		t.Error("the password does not match its hash")
+	}
+	if CheckPasswordHash("correct horse battery stapler", hash) {
+		t.Error("another password matches the hash")
+	}
+}
+
+func TestCheckPasswordHash(t *testing.T) {
+	useArgon2Params(t, cheapArgon2Params)
+	argonHash, err := HashPassword("s3cret!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("s3cret!"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		scenario string
+		password string
+		hash     string
+		want     bool
+	}{
+		{"argon2id", "s3cret!", argonHash, true},
+		{"legacy bcrypt", "s3cret!", string(bcryptHash), true},
+		{"wrong password for bcrypt", "secret", string(bcryptHash), false},
+		{"account without password", "", "", false},
+		{"truncated hash", "s3cret!", argonHash[:len(argonHash)-10], false},
+		{"missing parameters", "s3cret!", "$argon2id$v=19$m=0,t=0,p=0$c2FsdA$a2V5", false},
+		{"other argon2 version", "s3cret!", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5", false},
+		{"malformed", "s3cret!", "$argon2id$garbage", false},
+	}
+	for _, tt := range tests {
+		if got := CheckPasswordHash(tt.password, tt.hash); got != tt.want {
+			t.Errorf("%s: CheckPasswordHash = %v, want %v", tt.scenario, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	useArgon2Params(t, cheapArgon2Params)
+	current, err := HashPassword("s3cret!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("s3cret!"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stronger := cheapArgon2Params
+	stronger.Iterations = 2
+	useArgon2Params(t, stronger)
+	upgraded, err := HashPassword("s3cret!")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		scenario string
+		hash     string
+		want     bool
+	}{
+		{"bcrypt", string(bcryptHash), true},
+		{"argon2id with fewer iterations", current, true},
+		{"argon2id with the current parameters", upgraded, false},
+		{"account without password", "", false},
+		{"unrecognised", "not a hash", false},
+	}
+	for _, tt := range tests {
+		if got := PasswordNeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: PasswordNeedsRehash = %v, want %v", tt.scenario, got, tt.want)
+		}
+	}
+	// the old hash still verifies until it is upgraded
+	if !CheckPasswordHash("s3cret!", current) {
+		t.Error("a hash made with older parameters no longer verifies")
+	}
+}
+
+func TestSetArgon2ParamsKeepsDefaultsForZeroFields(t *testing.T) {
+	useArgon2Params(t, Argon2Params{Memory: 32 * 1024})
+	want := DefaultArgon2Params
+	want.Memory = 32 * 1024
+	if argon2Params != want {
+		t.Errorf("params %+v, want %+v", argon2Params, want)
+	}
+}
+
+func TestArgon2ConcurrencyIsLimited(t *testing.T) {
+	useArgon2Params(t, cheapArgon2Params)
+	previous := argon2Slots
+	t.Cleanup(func() { argon2Slots = previous })
+	SetArgon2Concurrency(1)
+
+	// a hash in progress holds the only slot
+	argon2Slots <- struct{}{}
+	done := make(chan struct{})
+	go func() {
+		HashPassword("correct horse battery staple")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("a hash ran while every slot was taken")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-argon2Slots
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the hash did not run once a slot was free")
+	}
+
+	if SetArgon2Concurrency(0); cap(argon2Slots) < 1 {
+		t.Errorf("%d slots for a zero concurrency, want one per CPU", cap(argon2Slots))
+	}
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 appendix B, SHA-1; the codes are the last six of the eight digits listed
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, v := range rfc6238Vectors {
+		if got := totpCode(key, v.unix/totpPeriod); got != v.code {
+			t.Errorf("code at %d = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		step, ok := ValidateTOTP(rfc6238Secret, v.code, time.Unix(v.unix, 0))
+		if !ok || step != v.unix/totpPeriod {
+			t.Errorf("ValidateTOTP(%s at %d) = %d, %v, want step %d", v.code, v.unix, step, ok, v.unix/totpPeriod)
+		}
+	}
+
+	at := time.Unix(1111111111, 0) // step 37037037, code 050471
+	tests := []struct {
+		scenario string
+		secret   string
+		code     string
+		t        time.Time
+		want     bool
+	}{
+		{"with a space", rfc6238Secret, "050 471", at, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050471", at, true},
+		{"one step late", rfc6238Secret, "050471", at.Add(totpPeriod * time.Second), true},
+		{"one step early", rfc6238Secret, "050471", at.Add(-totpPeriod * time.Second), true},
+		{"two steps late", rfc6238Secret, "050471", at.Add(2 * totpPeriod * time.Second), false},
+		{"wrong code", rfc6238Secret, "050472", at, false},
+		{"eight digits", rfc6238Secret, "14050471", at, false},
+		{"invalid secret", "not base32!", "050471", at, false},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, tt.t); ok != tt.want {
+			t.Errorf("%s: valid = %v, want %v", tt.scenario, ok, tt.want)
+		}
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q is not 160 bits of base32", secret)
+	}
+	code := totpCode(key, time.Now().Unix()/totpPeriod)
+	if _, ok := ValidateTOTP(secret, code, time.Now()); !ok {
+		t.Error("the current code of a new secret is refused")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Prothomuse", "jane@example.com", rfc6238Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Prothomuse:jane@example.com" {
+		t.Errorf("URI %s does not name the issuer and account", uri)
+	}
+	query := uri.Query()
+	for name, want := range map[string]string{"secret": rfc6238Secret, "issuer": "Prothomuse", "algorithm": "SHA1", "digits": "6", "period": "30"} {
+		if got := query.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	code, err := GenerateRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`).MatchString(code) {
+		t.Errorf("recovery code %q is not four groups of four base32 characters", code)
+	}
+	for _, typed := range []string{"MFZW4ZLCNBSWY3DP", "mfzw 4zlc nbsw y3dp", "Mfzw-4zlc-Nbsw-Y3dp"} {
+		if got := NormalizeRecoveryCode(typed); got != "mfzw-4zlc-nbsw-y3dp" {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q", typed, got)
+		}
+	}
+	if got := NormalizeRecoveryCode("short"); got != "short" {
+		t.Errorf("NormalizeRecoveryCode(short) = %q, want it unchanged", got)
+	}
+}